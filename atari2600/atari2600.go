@@ -24,9 +24,12 @@ type VCS struct {
 	cpuClock int
 	cpu      *cpu.Chip
 	memory   *controller
+	bus      *memory.Observed // Wraps memory so observers can be attached. This is what the CPU uses.
 	debug    bool
 }
 
+var _ = memory.Debug(&controller{})
+
 // controller defines the various memory mapped components of the
 // system including the PIA, TIA chips and then a cart abstraction
 // which properly handles a given cart (which may internally handle
@@ -36,6 +39,7 @@ type controller struct {
 	tia        *tia.Chip
 	cart       memory.Bank
 	databusVal uint8
+	openBus    memory.OpenBus // What the bits the TIA doesn't drive read as.
 }
 
 // VCSDef defines the pieces needed to setup a basic Atari 2600. Assuming up to 2 joysticks and 4 paddles.
@@ -78,6 +82,28 @@ type VCSDef struct {
 
 	// Debug if true wll emit output from Debug() calls to the PIA, TIA and CPU chips.
 	Debug bool
+
+	// PowerOnPolicy if non-nil determines the power on state of the PIA, TIA and CPU chips. They all
+	// share its random source so a seeded policy makes the whole console reproducible.
+	// If nil everything starts up random as on real hardware.
+	PowerOnPolicy *memory.PowerOnPolicy
+
+	// Profiler if non-nil is installed on the CPU so it counts where cycles go in the ROM.
+	// See cpu.Profiler for details.
+	Profiler *cpu.Profiler
+	// CallStack if non-nil is installed on the CPU to track calls and report stack misuse in the ROM.
+	// See cpu.CallStack for details.
+	CallStack *cpu.CallStack
+	// OpcodeActions if non-nil sets what the CPU does when it runs each class of opcode.
+	// Any class not listed is allowed. See cpu.SetOpcodeAction for details.
+	OpcodeActions map[cpu.OpClass]cpu.OpAction
+	// Magic if non-nil sets the constants and quirks the CPU uses for the unstable opcodes
+	// (XAA, OAL, AHX, SHX, SHY and TAS). See cpu.Magic for details.
+	Magic *cpu.Magic
+	// OpenBus determines what the data bits the TIA doesn't drive on reads (D5-D0) return.
+	// The default (memory.OPEN_BUS_FLOAT) matches real hardware where they keep the last value on the bus
+	// (which some games depend on). The pull modes match consoles (and emulators) which read them as 0 or 1.
+	OpenBus memory.OpenBus
 }
 
 // Init returns an initialized and powered on Atari 2600 emulator.
@@ -101,6 +127,14 @@ func Init(def *VCSDef) (*VCS, error) {
 	if def.Image == nil {
 		return nil, errors.New("Image must be non-nil in def")
 	}
+	if def.OpenBus < memory.OPEN_BUS_FLOAT || def.OpenBus >= memory.OPEN_BUS_MAX {
+		return nil, fmt.Errorf("invalid open bus policy %d", def.OpenBus)
+	}
+	// Resolve this once so all the chips share one random source.
+	pol, err := memory.ResolvePowerOnPolicy(def.PowerOnPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid power on policy: %v", err)
+	}
 
 	var ch [4]io.PortIn1
 	var paddles bool
@@ -129,18 +163,19 @@ func Init(def *VCSDef) (*VCS, error) {
 
 	// Order is important since the chips depend on each other.
 	tia, err := tia.Init(&tia.ChipDef{
-		Mode:        def.Mode,
-		Port0:       ch[0],
-		Port1:       ch[1],
-		Port2:       ch[2],
-		Port3:       ch[3],
-		Port4:       b[0],
-		Port5:       b[1],
-		IoPortGnd:   def.PaddleGround,
-		Image:       def.Image,
-		ScaleFactor: def.ScaleFactor,
-		FrameDone:   def.FrameDone,
-		Debug:       def.Debug,
+		Mode:          def.Mode,
+		Port0:         ch[0],
+		Port1:         ch[1],
+		Port2:         ch[2],
+		Port3:         ch[3],
+		Port4:         b[0],
+		Port5:         b[1],
+		IoPortGnd:     def.PaddleGround,
+		Image:         def.Image,
+		ScaleFactor:   def.ScaleFactor,
+		FrameDone:     def.FrameDone,
+		Debug:         def.Debug,
+		PowerOnPolicy: pol,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("can't initialize TIA: %v", err)
@@ -157,7 +192,8 @@ func Init(def *VCSDef) (*VCS, error) {
 			reset:      def.Reset,
 		},
 		memory: &controller{
-			tia: tia,
+			tia:     tia,
+			openBus: def.OpenBus,
 		},
 		debug: def.Debug,
 	}
@@ -197,34 +233,56 @@ func Init(def *VCSDef) (*VCS, error) {
 	}
 
 	pia, err := pia6532.Init(&pia6532.ChipDef{
-		PortA: a.portA,
-		PortB: a.portB,
-		Debug: def.Debug,
+		PortA:         a.portA,
+		PortB:         a.portB,
+		Debug:         def.Debug,
+		PowerOnPolicy: pol,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("can't initialize PIA: %v", err)
 	}
 
 	a.memory.pia = pia
+	a.bus = memory.NewObserved(a.memory, nil)
 
-	// No IRQ in the VCS so those aren't setup.
+	// The 6507 has no IRQ/NMI pins so those aren't setup.
 	// Note there is some circular dependencies here as the CPU depends
 	// on VCS for it's memory and the VCS needs to know about the CPU for
 	// executing Tick() against it.
 	c, err := cpu.Init(&cpu.ChipDef{
-		Cpu:   cpu.CPU_NMOS,
-		Ram:   a.memory,
-		Rdy:   tia,
-		Debug: def.Debug,
+		Cpu:           cpu.CPU_NMOS_6507,
+		Ram:           a.bus,
+		Rdy:           tia,
+		Debug:         def.Debug,
+		PowerOnPolicy: pol,
+		Magic:         def.Magic,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("can't initialize cpu: %v", err)
 	}
+	for cl, act := range def.OpcodeActions {
+		if err := c.SetOpcodeAction(cl, act); err != nil {
+			return nil, fmt.Errorf("can't set opcode action: %v", err)
+		}
+	}
 
+	if def.Profiler != nil {
+		c.SetProfiler(def.Profiler)
+	}
+	if def.CallStack != nil {
+		c.SetCallStack(def.CallStack)
+	}
+	a.bus.SetClock(c.Clocks)
 	a.cpu = c
 	return a, nil
 }
 
+// Bus returns the memory bus the CPU accesses. Observers can be attached to it to watch
+// reads and writes (i.e. TIA register writes) with the CPU cycle they happened on.
+func (a *VCS) Bus() *memory.Observed {
+	return a.bus
+}
+
 const (
 	kADDRESS_MASK = uint16(0x1FFF)
 
@@ -240,7 +298,8 @@ const (
 // Read implements the memory.Bank interface for Read.
 // On the VCS this is the main logic for tying the various chips together.
 func (c *controller) Read(addr uint16) uint8 {
-	// We only have 13 address pins so mask for that.
+	// The 6507 only has 13 address pins and masks for that itself but other
+	// callers (debuggers, etc) may pass full addresses.
 	addr &= kADDRESS_MASK
 
 	// The board implements CS for the address/data bus for the TIA
@@ -265,7 +324,8 @@ func (c *controller) Read(addr uint16) uint8 {
 	}
 	if !read && (addr&kROM_MASK) != kROM_MASK {
 		// TIA is from 0x00-0x3F and mirrors except for the ROM bank (A12) being set.
-		ret = c.tia.Read(addr)
+		// It only drives D7/D6 so the rest come from the open bus.
+		ret = c.openBus.Resolve(c.tia.Read(addr), c.tia.Driven(addr), c.databusVal)
 		//		fmt.Printf("TIA: 0x%.4X\n", addr)
 		read = true
 	}
@@ -283,7 +343,8 @@ func (c *controller) Read(addr uint16) uint8 {
 // Write implements the memory.Bank interface for Write.
 // On the VCS this is the main logic for tying the various chips together.
 func (c *controller) Write(addr uint16, val uint8) {
-	// We only have 13 address pins so mask for that.
+	// The 6507 only has 13 address pins and masks for that itself but other
+	// callers (debuggers, etc) may pass full addresses.
 	addr &= kADDRESS_MASK
 
 	c.databusVal = val
@@ -311,6 +372,41 @@ func (c *controller) Write(addr uint16, val uint8) {
 	return
 }
 
+// decode returns the chip which answers for addr other than the cart (or nil if only the cart does).
+func (c *controller) decode(addr uint16) memory.Bank {
+	if (addr & kROM_MASK) == kROM_MASK {
+		return nil
+	}
+	if (addr & kPIA_MASK) == kPIA_MASK {
+		if (addr & kPIA_IO_MASK) == kPIA_IO_MASK {
+			return c.pia.IO()
+		}
+		return c.pia
+	}
+	return c.tia
+}
+
+// Peek implements the memory.Debug interface for Peek. This returns what Read would
+// without any side effects (bank switching, clearing PIA interrupts, etc).
+func (c *controller) Peek(addr uint16) uint8 {
+	addr &= kADDRESS_MASK
+	if b := c.decode(addr); b != nil {
+		return c.openBus.Resolve(memory.Peek(b, addr), memory.Driven(b, addr), c.databusVal)
+	}
+	return memory.Peek(c.cart, addr)
+}
+
+// Poke implements the memory.Debug interface for Poke. Unlike Write the cart
+// only sees pokes to its own address range.
+func (c *controller) Poke(addr uint16, val uint8) {
+	addr &= kADDRESS_MASK
+	if b := c.decode(addr); b != nil {
+		memory.Poke(b, addr, val)
+		return
+	}
+	memory.Poke(c.cart, addr, val)
+}
+
 // PowerOn implements the memory.Bank interface for PowerOn.
 func (c *controller) PowerOn() {}
 
@@ -347,11 +443,15 @@ func (a *VCS) Tick() error {
 		if err := a.memory.pia.Tick(); err != nil {
 			return fmt.Errorf("PIA tick error: %v", err)
 		}
-		if err := a.cpu.Tick(); err != nil {
-			return fmt.Errorf("CPU tick error: %v", err)
-		}
+		// The cycle is finished even if the CPU returns an error since some (i.e. cpu.OpcodeTrap or
+		// cpu.BreakpointHit) leave it able to carry on with the next Tick().
+		err := a.cpu.Tick()
 		a.memory.pia.TickDone()
 		a.cpu.TickDone()
+		if err != nil {
+			a.memory.tia.TickDone()
+			return fmt.Errorf("CPU tick error: %w", err)
+		}
 	}
 	a.memory.tia.TickDone()
 	return nil
@@ -1,6 +1,7 @@
 package atari2600
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"image"
@@ -12,6 +13,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/jmchacon/6502/cpu"
 	"github.com/jmchacon/6502/io"
 	"github.com/jmchacon/6502/tia"
 )
@@ -105,6 +107,55 @@ func TestCarts(t *testing.T) {
 	}
 }
 
+func TestOpcodeTrap(t *testing.T) {
+	rom := make([]uint8, 4096)
+	copy(rom, []uint8{
+		0xA9, 0x42, // LDA #$42
+		0x85, 0x80, // STA $80
+		0xA7, 0x80, // LAX $80
+		0x86, 0x81, // STX $81
+		0x4C, 0x08, 0xF0, // JMP $F008
+	})
+	rom[0xFFC] = 0x00
+	rom[0xFFD] = 0xF0
+	sw := &swtch{false}
+	a, err := Init(&VCSDef{
+		Mode:          tia.TIA_MODE_NTSC,
+		Difficulty:    [2]io.PortIn1{sw, sw},
+		ColorBW:       sw,
+		GameSelect:    sw,
+		Reset:         sw,
+		Image:         image.NewNRGBA(image.Rect(0, 0, tia.NTSCWidth, tia.NTSCHeight)),
+		FrameDone:     func(draw.Image) {},
+		Rom:           rom,
+		OpcodeActions: map[cpu.OpClass]cpu.OpAction{cpu.OPCLASS_UNDOCUMENTED: cpu.OPACTION_TRAP},
+	})
+	if err != nil {
+		t.Fatalf("Can't init VCS: %v", err)
+	}
+	var trap cpu.OpcodeTrap
+	for i := 0; i < 300; i++ {
+		if err := a.Tick(); err != nil {
+			if !errors.As(err, &trap) {
+				t.Fatalf("Tick error: %v", err)
+			}
+			break
+		}
+	}
+	if got, want := trap.PC, uint16(0xF004); got != want {
+		t.Fatalf("Bad trap PC. Got %.4X and want %.4X", got, want)
+	}
+	// Ticking again carries on with the LAX.
+	for i := 0; i < 300; i++ {
+		if err := a.Tick(); err != nil {
+			t.Fatalf("Tick error after trap: %v", err)
+		}
+	}
+	if got, want := a.Bus().Peek(0x81), uint8(0x42); got != want {
+		t.Errorf("LAX didn't run after the trap. Got %.2X and want %.2X", got, want)
+	}
+}
+
 // curry some things and return a valid image callback for the TIA on frame end.
 func generateImage(t *testing.T, name string, max int, done *bool) func(i draw.Image) {
 	cnt := 0
@@ -8,6 +8,13 @@ import (
 	"github.com/jmchacon/6502/memory"
 )
 
+var (
+	_ = memory.Debug(&basicCart{})
+	_ = memory.Debug(&f8BankSwitchCart{})
+	_ = memory.Debug(&f6BankSwitchCart{})
+	_ = memory.Debug(&f6SCBankSwitchCart{})
+)
+
 // basicCart implements support for a 2k or 4k ROM. For 2k the upper half is simply
 // a mirror of the lower half. The simplest implementation of carts.
 type basicCart struct {
@@ -60,6 +67,21 @@ func (b *basicCart) Write(addr uint16, val uint8) {
 	b.databusVal = val
 }
 
+// Peek implements the memory.Debug interface for Peek.
+func (b *basicCart) Peek(addr uint16) uint8 {
+	if (addr & kROM_MASK) == kROM_MASK {
+		return b.rom[addr&b.mask]
+	}
+	return 0
+}
+
+// Poke implements the memory.Debug interface for Poke by patching the ROM.
+func (b *basicCart) Poke(addr uint16, val uint8) {
+	if (addr & kROM_MASK) == kROM_MASK {
+		b.rom[addr&b.mask] = val
+	}
+}
+
 // PowerOn implements the memory.Bank interface for PowerOn.
 func (b *basicCart) PowerOn() {}
 
@@ -236,12 +258,8 @@ func (f *f8BankSwitchCart) Read(addr uint16) uint8 {
 		if addr&0x1FFF == 0x1FF9 {
 			f.lowBank = false
 		}
-		off := uint16(0)
-		if !f.lowBank {
-			off = 4096
-		}
 		// Move it into a range for indexing into our byte array.
-		val := f.rom[(addr&k4K_MASK)+off]
+		val := f.rom[f.offset(addr)]
 		f.databusVal = val
 		return val
 	}
@@ -265,6 +283,32 @@ func (f *f8BankSwitchCart) Write(addr uint16, val uint8) {
 	}
 }
 
+// Peek implements the memory.Debug interface for Peek. This reads from the current
+// bank and never switches banks.
+func (f *f8BankSwitchCart) Peek(addr uint16) uint8 {
+	if (addr & kROM_MASK) == kROM_MASK {
+		return f.rom[f.offset(addr)]
+	}
+	return 0
+}
+
+// Poke implements the memory.Debug interface for Poke by patching the ROM in the current
+// bank. This never switches banks.
+func (f *f8BankSwitchCart) Poke(addr uint16, val uint8) {
+	if (addr & kROM_MASK) == kROM_MASK {
+		f.rom[f.offset(addr)] = val
+	}
+}
+
+// offset returns the index into the ROM for addr in the current bank.
+func (f *f8BankSwitchCart) offset(addr uint16) uint16 {
+	off := uint16(0)
+	if !f.lowBank {
+		off = 4096
+	}
+	return (addr & k4K_MASK) + off
+}
+
 // PowerOn implements the memory.Bank interface for PowerOn.
 func (b *f8BankSwitchCart) PowerOn() {}
 
@@ -339,6 +383,23 @@ func (f *f6BankSwitchCart) Write(addr uint16, val uint8) {
 	}
 }
 
+// Peek implements the memory.Debug interface for Peek. This reads from the current
+// bank and never switches banks.
+func (f *f6BankSwitchCart) Peek(addr uint16) uint8 {
+	if (addr & kROM_MASK) == kROM_MASK {
+		return f.rom[(addr&k4K_MASK)+f.bank*4096]
+	}
+	return 0
+}
+
+// Poke implements the memory.Debug interface for Poke by patching the ROM in the current
+// bank. This never switches banks.
+func (f *f6BankSwitchCart) Poke(addr uint16, val uint8) {
+	if (addr & kROM_MASK) == kROM_MASK {
+		f.rom[(addr&k4K_MASK)+f.bank*4096] = val
+	}
+}
+
 // PowerOn implements the memory.Bank interface for PowerOn.
 func (b *f6BankSwitchCart) PowerOn() {}
 
@@ -372,7 +433,7 @@ func NewF6SCBankSwitchCart(rom []uint8, parent memory.Bank) (memory.Bank, error)
 		parent: parent,
 	}
 	var err error
-	if p.ram, err = memory.New8BitRAMBank(128, p); err != nil {
+	if p.ram, err = memory.New8BitRAMBank(128, p, nil); err != nil {
 		return nil, fmt.Errorf("can't initialize RAM: %v", err)
 	}
 	return p, nil
@@ -438,6 +499,31 @@ func (f *f6SCBankSwitchCart) Write(addr uint16, val uint8) {
 	}
 }
 
+// Peek implements the memory.Debug interface for Peek. This reads from the current
+// bank and never switches banks. Both the read and write windows of the RAM return
+// its contents (a Read of the write window instead writes the databus value to it).
+func (f *f6SCBankSwitchCart) Peek(addr uint16) uint8 {
+	if (addr & kROM_MASK) == kROM_MASK {
+		if addr&0x1FFF <= 0x10FF {
+			return memory.Peek(f.ram, addr&k4K_MASK)
+		}
+		return f.rom[(addr&k4K_MASK)+f.bank*4096]
+	}
+	return 0
+}
+
+// Poke implements the memory.Debug interface for Poke. Either RAM window sets the RAM and
+// anything else patches the ROM in the current bank. This never switches banks.
+func (f *f6SCBankSwitchCart) Poke(addr uint16, val uint8) {
+	if (addr & kROM_MASK) == kROM_MASK {
+		if addr&0x1FFF <= 0x10FF {
+			memory.Poke(f.ram, addr&k4K_MASK, val)
+			return
+		}
+		f.rom[(addr&k4K_MASK)+f.bank*4096] = val
+	}
+}
+
 // PowerOn implements the memory.Bank interface for PowerOn.
 func (b *f6SCBankSwitchCart) PowerOn() {}
 
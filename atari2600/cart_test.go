@@ -0,0 +1,36 @@
+package atari2600
+
+import (
+	"testing"
+
+	"github.com/jmchacon/6502/memory"
+)
+
+func TestCartPeek(t *testing.T) {
+	rom := make([]uint8, 8192)
+	rom[0x0000] = 0x11
+	rom[0x1000] = 0x22
+	c, err := NewF8BankSwitchCart(rom, nil)
+	if err != nil {
+		t.Fatalf("Can't create cart: %v", err)
+	}
+	if got, want := memory.Peek(c, 0x1000), uint8(0x11); got != want {
+		t.Errorf("Bad peek. Got %.2X and want %.2X", got, want)
+	}
+	// Peeking the hotspot doesn't switch banks.
+	memory.Peek(c, 0x1FF9)
+	if got, want := memory.Peek(c, 0x1000), uint8(0x11); got != want {
+		t.Errorf("Peek switched banks. Got %.2X and want %.2X", got, want)
+	}
+	memory.Poke(c, 0x1FF9, 0xFF)
+	if got, want := memory.Peek(c, 0x1000), uint8(0x11); got != want {
+		t.Errorf("Poke switched banks. Got %.2X and want %.2X", got, want)
+	}
+	c.Read(0x1FF9)
+	if got, want := memory.Peek(c, 0x1000), uint8(0x22); got != want {
+		t.Errorf("Read didn't switch banks. Got %.2X and want %.2X", got, want)
+	}
+	if got, want := c.DatabusVal(), rom[0x1FF9]; got != want {
+		t.Errorf("Bad databus value. Got %.2X and want %.2X", got, want)
+	}
+}
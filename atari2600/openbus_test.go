@@ -0,0 +1,117 @@
+package atari2600
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	"github.com/jmchacon/6502/io"
+	"github.com/jmchacon/6502/memory"
+	"github.com/jmchacon/6502/tia"
+)
+
+func TestOpenBus(t *testing.T) {
+	// No real carts are checked in so this uses a small ROM which reads INPT4 (0x0C mirrored at 0x3C)
+	// with zero page and absolute addressing and stores the results in PIA RAM. It then draws frames
+	// with the background color picked by branching on the bits the TIA doesn't drive (as a game which
+	// depends on them would) so the policy changes what's on screen.
+	rom := make([]uint8, 4096)
+	copy(rom, []uint8{
+		0xA5, 0x3C, // LDA $3C
+		0x85, 0x80, // STA $80
+		0xAD, 0x3C, 0x00, // LDA $003C
+		0x85, 0x81, // STA $81
+		// Frame loop at 0xF009.
+		0xA9, 0x02, // LDA #$02
+		0x85, 0x00, // STA VSYNC
+		0x85, 0x02, // STA WSYNC
+		0x85, 0x02, // STA WSYNC
+		0x85, 0x02, // STA WSYNC
+		0xA9, 0x00, // LDA #$00
+		0x85, 0x00, // STA VSYNC
+		0xA2, 0x00, // LDX #$00
+		0xA5, 0x3C, // LDA $3C
+		0x29, 0x3F, // AND #$3F
+		0xF0, 0x08, // BEQ $F027 (pulled low draws black)
+		0xA2, 0x0E, // LDX #$0E
+		0xC9, 0x3F, // CMP #$3F
+		0xF0, 0x02, // BEQ $F027 (pulled high draws white)
+		0xA2, 0x44, // LDX #$44 (floating reads 0x3C and draws red)
+		0x86, 0x09, // STX COLUBK
+		0xA0, 0xFF, // LDY #$FF
+		0x85, 0x02, // STA WSYNC
+		0x88,       // DEY
+		0xD0, 0xFB, // BNE $F02B
+		0x4C, 0x09, 0xF0, // JMP $F009
+	})
+	rom[0xFFC] = 0x00
+	rom[0xFFD] = 0xF0
+
+	tests := []struct {
+		policy memory.OpenBus
+		zp     uint8      // Low bits for LDA $3C
+		abs    uint8      // Low bits for LDA $003C
+		bg     color.RGBA // Background drawn
+	}{
+		// Floating bits hold the last byte fetched which is the operand (0x3C) or its high byte (0x00).
+		{memory.OPEN_BUS_FLOAT, 0x3C, 0x00, color.RGBA{0xA6, 0x00, 0x38, 0xFF}},
+		{memory.OPEN_BUS_PULL_LOW, 0x00, 0x00, color.RGBA{0x00, 0x00, 0x00, 0xFF}},
+		{memory.OPEN_BUS_PULL_HIGH, 0x3F, 0x3F, color.RGBA{0xED, 0xED, 0xED, 0xFF}},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			sw := &swtch{false}
+			img := image.NewNRGBA(image.Rect(0, 0, tia.NTSCWidth, tia.NTSCHeight))
+			frames := 0
+			a, err := Init(&VCSDef{
+				Mode:       tia.TIA_MODE_NTSC,
+				Difficulty: [2]io.PortIn1{sw, sw},
+				ColorBW:    sw,
+				GameSelect: sw,
+				Reset:      sw,
+				Image:      img,
+				FrameDone: func(draw.Image) {
+					frames++
+				},
+				Rom: rom,
+				PowerOnPolicy: &memory.PowerOnPolicy{
+					Mode: memory.POWER_ON_ZERO,
+				},
+				OpenBus: test.policy,
+			})
+			if err != nil {
+				t.Fatalf("Can't init VCS: %v", err)
+			}
+			// The first frame may have started before COLUBK was set so wait for a full one.
+			for frames < 3 {
+				if err := a.Tick(); err != nil {
+					t.Fatalf("Tick error: %v", err)
+				}
+			}
+			if got, want := a.Bus().Peek(0x80)&0x3F, test.zp; got != want {
+				t.Errorf("Bad zero page read. Got %.2X and want %.2X", got, want)
+			}
+			if got, want := a.Bus().Peek(0x81)&0x3F, test.abs; got != want {
+				t.Errorf("Bad absolute read. Got %.2X and want %.2X", got, want)
+			}
+			if got := color.RGBAModel.Convert(img.At(tia.NTSCWidth-10, tia.NTSCHeight/2)).(color.RGBA); got != test.bg {
+				t.Errorf("Bad background. Got %v and want %v", got, test.bg)
+			}
+		})
+	}
+
+	sw := &swtch{false}
+	if _, err := Init(&VCSDef{
+		Difficulty: [2]io.PortIn1{sw, sw},
+		ColorBW:    sw,
+		GameSelect: sw,
+		Reset:      sw,
+		Image:      image.NewNRGBA(image.Rect(0, 0, tia.NTSCWidth, tia.NTSCHeight)),
+		FrameDone:  func(draw.Image) {},
+		Rom:        rom,
+		OpenBus:    memory.OPEN_BUS_MAX,
+	}); err == nil {
+		t.Errorf("Didn't get error for invalid open bus policy")
+	}
+}
@@ -12,7 +12,7 @@ import (
 )
 
 func readAddr(r memory.Bank, addr uint16) uint16 {
-	return (uint16(r.Read(addr+1)) << 8) + uint16(r.Read(addr))
+	return (uint16(memory.Peek(r, addr+1)) << 8) + uint16(memory.Peek(r, addr))
 }
 
 // List will take the given PC value and disassembles the Basic line at that location
@@ -22,6 +22,7 @@ func readAddr(r memory.Bank, addr uint16) uint16 {
 // On a normal program end (next addr == 0x0000) it will return an empty string and PC of 0x0000.
 // If there is a token parsing problem an error is returned instead with as much of the
 // line as would tokenize. Normally a c64 won't continue so the newPC value here will be 0.
+// Memory is read with memory.Peek so listing never changes it.
 // NOTE: This returns the ASCII characters as parsed, displaying in PETSCII is up to the caller
 //       to determine.
 func List(pc uint16, r memory.Bank) (string, uint16, error) {
@@ -45,7 +46,7 @@ func List(pc uint16, r memory.Bank) (string, uint16, error) {
 
 	// Read until we reach a NUL indicating EOL.
 	for {
-		tok := r.Read(pc)
+		tok := memory.Peek(r, pc)
 		pc++
 		if tok == 0x00 {
 			break
@@ -0,0 +1,152 @@
+package cpu
+
+import (
+	"fmt"
+)
+
+// BreakKind is an enumeration of the types of breakpoints which can be set.
+type BreakKind int
+
+const (
+	BREAK_UNIMPLEMENTED BreakKind = iota // Start of valid break enumerations.
+	BREAK_EXEC                           // Execution reaching an address (checked before the opcode fetch).
+	BREAK_READ                           // Any bus read in an address range.
+	BREAK_WRITE                          // Any bus write in an address range.
+	BREAK_BRK                            // Start of a BRK instruction.
+	BREAK_IRQ                            // Start of an IRQ sequence.
+	BREAK_NMI                            // Start of an NMI sequence.
+	BREAK_MAX                            // End of break enumerations.
+)
+
+// String implements fmt.Stringer for BreakKind.
+func (b BreakKind) String() string {
+	switch b {
+	case BREAK_EXEC:
+		return "EXEC"
+	case BREAK_READ:
+		return "READ"
+	case BREAK_WRITE:
+		return "WRITE"
+	case BREAK_BRK:
+		return "BRK"
+	case BREAK_IRQ:
+		return "IRQ"
+	case BREAK_NMI:
+		return "NMI"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(b))
+}
+
+// Breakpoint defines a condition which will stop Tick() (and anything built on it such as Step()).
+type Breakpoint struct {
+	// Kind is the type of breakpoint.
+	Kind BreakKind
+	// Addr is the address for BREAK_EXEC and the start of the range for BREAK_READ/BREAK_WRITE.
+	// It's ignored for the other kinds.
+	Addr uint16
+	// End is the inclusive end of the range for BREAK_READ/BREAK_WRITE. If it's less than Addr only
+	// Addr is checked.
+	End uint16
+	// Cond is an optional condition which must also return true for the breakpoint to trigger.
+	// It's evaluated at the point the breakpoint matches so it can check registers, Clocks(), etc.
+	// It must not call anything which changes CPU state.
+	Cond func(*Chip) bool
+}
+
+// BreakpointHit is returned from Tick() when a breakpoint triggers. Unlike HaltOpcode the CPU
+// isn't halted so calling Tick() again continues on.
+// For BREAK_EXEC this is returned before the opcode fetch and no clock cycle is used. The next
+// Tick() will not trigger the same breakpoint again.
+// For all other kinds this is returned at the end of the cycle which triggered it. The CPU
+// may be partway through an instruction at that point.
+type BreakpointHit struct {
+	ID   int       // The ID returned from AddBreakpoint.
+	Kind BreakKind // The kind of breakpoint which triggered.
+	PC   uint16    // For BREAK_EXEC the PC about to run, otherwise the PC the current instruction (or interrupt) started at.
+	Addr uint16    // For BREAK_READ/BREAK_WRITE the address accessed.
+	Val  uint8     // For BREAK_READ/BREAK_WRITE the value read or written.
+}
+
+// Error implements the interface for error types.
+func (e BreakpointHit) Error() string {
+	switch e.Kind {
+	case BREAK_READ, BREAK_WRITE:
+		return fmt.Sprintf("breakpoint %d (%s) hit at PC 0x%.4X: 0x%.2X at 0x%.4X", e.ID, e.Kind, e.PC, e.Val, e.Addr)
+	}
+	return fmt.Sprintf("breakpoint %d (%s) hit at PC 0x%.4X", e.ID, e.Kind, e.PC)
+}
+
+// breakpoint is an installed Breakpoint along with its ID.
+type breakpoint struct {
+	Breakpoint
+	id int
+}
+
+// AddBreakpoint installs the given breakpoint and returns an ID which can be used to remove it.
+func (p *Chip) AddBreakpoint(b Breakpoint) (int, error) {
+	if b.Kind <= BREAK_UNIMPLEMENTED || b.Kind >= BREAK_MAX {
+		return 0, InvalidCPUState{fmt.Sprintf("breakpoint kind %d is invalid", b.Kind)}
+	}
+	if b.End < b.Addr {
+		b.End = b.Addr
+	}
+	p.breakID++
+	p.breakpoints = append(p.breakpoints, breakpoint{b, p.breakID})
+	return p.breakID, nil
+}
+
+// RemoveBreakpoint removes the breakpoint with the given ID.
+func (p *Chip) RemoveBreakpoint(id int) error {
+	for i, b := range p.breakpoints {
+		if b.id == id {
+			p.breakpoints = append(p.breakpoints[:i], p.breakpoints[i+1:]...)
+			return nil
+		}
+	}
+	return InvalidCPUState{fmt.Sprintf("no breakpoint with ID %d", id)}
+}
+
+// ClearBreakpoints removes all breakpoints.
+func (p *Chip) ClearBreakpoints() {
+	p.breakpoints = nil
+	p.breakHit = nil
+	p.breakResume = false
+}
+
+// checkExecBreak returns a BreakpointHit if an execution breakpoint matches the PC about to be
+// fetched. This is only called at instruction boundaries. Once one triggers the next call always
+// returns nil so execution can continue.
+func (p *Chip) checkExecBreak() error {
+	if p.breakResume {
+		p.breakResume = false
+		return nil
+	}
+	for _, b := range p.breakpoints {
+		if b.Kind == BREAK_EXEC && b.Addr == p.PC && (b.Cond == nil || b.Cond(p)) {
+			p.breakResume = true
+			return BreakpointHit{ID: b.id, Kind: b.Kind, PC: p.PC}
+		}
+	}
+	return nil
+}
+
+// checkBreak records a hit for the first breakpoint of the given kind which matches.
+// Tick() returns it at the end of the current cycle. addr/val are only checked for BREAK_READ/BREAK_WRITE.
+func (p *Chip) checkBreak(kind BreakKind, addr uint16, val uint8) {
+	if p.breakHit != nil {
+		return
+	}
+	for _, b := range p.breakpoints {
+		if b.Kind != kind {
+			continue
+		}
+		if (kind == BREAK_READ || kind == BREAK_WRITE) && (addr < b.Addr || addr > b.End) {
+			continue
+		}
+		if b.Cond != nil && !b.Cond(p) {
+			continue
+		}
+		p.breakHit = &BreakpointHit{ID: b.id, Kind: kind, PC: p.opPC, Addr: addr, Val: val}
+		return
+	}
+}
@@ -0,0 +1,121 @@
+package cpu
+
+import (
+	"testing"
+)
+
+func TestBreakpoints(t *testing.T) {
+	var irq, nmi testIRQ
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Irq: &irq, Nmi: &nmi}, 0xEA, 0x0202)
+	r.addr[kRESET+0] = 0xEA // NOP
+	r.addr[kRESET+1] = 0xA2 // LDX #01
+	r.addr[kRESET+2] = 0x01
+	r.addr[kRESET+3] = 0xBD // LDA 12FF,X
+	r.addr[kRESET+4] = 0xFF
+	r.addr[kRESET+5] = 0x12
+	r.addr[kRESET+6] = 0x8D // STA 2000
+	r.addr[kRESET+7] = 0x00
+	r.addr[kRESET+8] = 0x20
+	r.addr[kRESET+9] = 0x00 // BRK
+	r.addr[0x1300] = 0x56
+	c.P &^= P_INTERRUPT
+
+	exec, err := c.AddBreakpoint(Breakpoint{Kind: BREAK_EXEC, Addr: kRESET + 1})
+	if err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	if info, err := c.Step(); err != nil || info.PC != kRESET {
+		t.Fatalf("Bad first step: %+v - %v", info, err)
+	}
+	clocks := c.Clocks()
+	_, err = c.Step()
+	if got, want := err, (BreakpointHit{ID: exec, Kind: BREAK_EXEC, PC: kRESET + 1}); got != want {
+		t.Fatalf("Didn't get exec breakpoint. Got %v and want %v", got, want)
+	}
+	if c.Clocks() != clocks || c.PC != kRESET+1 {
+		t.Errorf("Exec breakpoint ran a cycle: clocks %d -> %d PC: 0x%.4X", clocks, c.Clocks(), c.PC)
+	}
+	// Continuing shouldn't hit the same breakpoint again.
+	if info, err := c.Step(); err != nil || info.PC != kRESET+1 || c.X != 0x01 {
+		t.Fatalf("Didn't continue after breakpoint: %+v - %v", info, err)
+	}
+
+	// The page crossing does a dummy read at 0x1200 before the real one at 0x1300.
+	// Both are in range so the first one triggers partway through the instruction.
+	read, err := c.AddBreakpoint(Breakpoint{Kind: BREAK_READ, Addr: 0x1200, End: 0x13FF})
+	if err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	info, err := c.Step()
+	if got, want := err, (BreakpointHit{ID: read, Kind: BREAK_READ, PC: kRESET + 3, Addr: 0x1200, Val: 0xEA}); got != want {
+		t.Fatalf("Didn't get read breakpoint. Got %v and want %v", got, want)
+	}
+	if got, want := info.Cycles, 4; got != want || c.InstructionDone() {
+		t.Errorf("Read breakpoint at wrong cycle. Got %d and want %d (done: %t)", got, want, c.InstructionDone())
+	}
+	_, err = c.Step()
+	if got, want := err, (BreakpointHit{ID: read, Kind: BREAK_READ, PC: kRESET + 3, Addr: 0x1300, Val: 0x56}); got != want {
+		t.Fatalf("Didn't get 2nd read breakpoint. Got %v and want %v", got, want)
+	}
+	if !c.InstructionDone() || c.A != 0x56 {
+		t.Errorf("LDA didn't complete. A: 0x%.2X", c.A)
+	}
+	if err := c.RemoveBreakpoint(read); err != nil {
+		t.Fatalf("Can't remove breakpoint: %v", err)
+	}
+	if err := c.RemoveBreakpoint(read); err == nil {
+		t.Error("Didn't get error removing breakpoint twice")
+	}
+
+	// Conditions have to match too.
+	if _, err := c.AddBreakpoint(Breakpoint{Kind: BREAK_WRITE, Addr: 0x2000, Cond: func(c *Chip) bool { return c.A == 0x00 }}); err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	write, err := c.AddBreakpoint(Breakpoint{Kind: BREAK_WRITE, Addr: 0x2000, Cond: func(c *Chip) bool { return c.A == 0x56 }})
+	if err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	_, err = c.Step()
+	if got, want := err, (BreakpointHit{ID: write, Kind: BREAK_WRITE, PC: kRESET + 6, Addr: 0x2000, Val: 0x56}); got != want {
+		t.Fatalf("Didn't get write breakpoint. Got %v and want %v", got, want)
+	}
+	if got, want := r.addr[0x2000], uint8(0x56); got != want {
+		t.Errorf("Write didn't happen. Got 0x%.2X and want 0x%.2X", got, want)
+	}
+
+	brk, err := c.AddBreakpoint(Breakpoint{Kind: BREAK_BRK})
+	if err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	info, err = c.Step()
+	if got, want := err, (BreakpointHit{ID: brk, Kind: BREAK_BRK, PC: kRESET + 9}); got != want {
+		t.Fatalf("Didn't get BRK breakpoint. Got %v and want %v", got, want)
+	}
+	if got, want := info.Cycles, 1; got != want {
+		t.Errorf("BRK breakpoint at wrong cycle. Got %d and want %d", got, want)
+	}
+	if info, err = c.Step(); err != nil || c.PC != kIRQ {
+		t.Fatalf("BRK didn't complete: %+v - %v PC: 0x%.4X", info, err, c.PC)
+	}
+
+	c.ClearBreakpoints()
+	if _, err := c.AddBreakpoint(Breakpoint{Kind: BREAK_IRQ}); err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	nmiID, err := c.AddBreakpoint(Breakpoint{Kind: BREAK_NMI})
+	if err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	nmi.s = true
+	_, err = c.Step()
+	nmi.s = false
+	if got, want := err, (BreakpointHit{ID: nmiID, Kind: BREAK_NMI, PC: kIRQ}); got != want {
+		t.Fatalf("Didn't get NMI breakpoint. Got %v and want %v", got, want)
+	}
+
+	for _, k := range []BreakKind{BREAK_UNIMPLEMENTED, BREAK_MAX} {
+		if _, err := c.AddBreakpoint(Breakpoint{Kind: k}); err == nil {
+			t.Errorf("Didn't get error for kind %d", k)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package cpu
+
+import (
+	"fmt"
+)
+
+// BusKind is an enumeration of the classifications for a bus access.
+type BusKind int
+
+const (
+	BUS_UNIMPLEMENTED BusKind = iota // Start of valid bus enumerations.
+	BUS_OPCODE                       // Opcode fetch (the SYNC cycle).
+	BUS_OPERAND                      // Fetch of an instruction operand byte from the PC.
+	BUS_DATA                         // Data read/write for an instruction (including pointer fetches for indirect modes).
+	BUS_DUMMY                        // Read (or NMOS RMW write) whose value is discarded.
+	BUS_STACK                        // Stack push (write) or pop (read).
+	BUS_VECTOR                       // Fetch of a reset, IRQ/BRK or NMI vector byte.
+	BUS_INTERRUPT                    // Reads during an interrupt or reset sequence which are discarded.
+	BUS_MAX                          // End of bus enumerations.
+)
+
+// String implements fmt.Stringer for BusKind.
+func (b BusKind) String() string {
+	switch b {
+	case BUS_OPCODE:
+		return "OPCODE"
+	case BUS_OPERAND:
+		return "OPERAND"
+	case BUS_DATA:
+		return "DATA"
+	case BUS_DUMMY:
+		return "DUMMY"
+	case BUS_STACK:
+		return "STACK"
+	case BUS_VECTOR:
+		return "VECTOR"
+	case BUS_INTERRUPT:
+		return "INTERRUPT"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(b))
+}
+
+// BusAccess is a single bus cycle made by the CPU.
+type BusAccess struct {
+	Cycle int     // Value of Clocks() during the access. Reset() cycles don't advance this.
+	PC    uint16  // The PC the current instruction (or interrupt) started at.
+	Addr  uint16  // Address accessed.
+	Val   uint8   // Value read or written.
+	Write bool    // True for a write, false for a read.
+	Kind  BusKind // Classification of the access.
+}
+
+// String implements fmt.Stringer for BusAccess.
+func (b BusAccess) String() string {
+	rw := "R"
+	if b.Write {
+		rw = "W"
+	}
+	return fmt.Sprintf("%d %.4X %s %.4X %.2X %s", b.Cycle, b.PC, rw, b.Addr, b.Val, b.Kind)
+}
+
+// SetBusLog installs a callback which is called for every bus access the CPU makes (including
+// ones from Reset()). Passing nil removes it. The callback must not call anything which changes CPU state.
+// The fetch of the byte after the opcode is classified as BUS_OPERAND or BUS_DUMMY depending on
+// whether the instruction uses it so it's reported at the end of that cycle. All other accesses are
+// reported as they happen.
+func (p *Chip) SetBusLog(fn func(BusAccess)) {
+	p.busLog = fn
+	p.busPending = nil
+}
+
+// BusRing is a fixed size ring buffer of BusAccess entries. Its Record method can be passed to SetBusLog.
+type BusRing struct {
+	entries []BusAccess
+	next    int
+	full    bool
+}
+
+// NewBusRing returns a BusRing which holds the last size accesses.
+func NewBusRing(size int) (*BusRing, error) {
+	if size <= 0 {
+		return nil, InvalidCPUState{fmt.Sprintf("bus ring size %d must be positive", size)}
+	}
+	return &BusRing{entries: make([]BusAccess, size)}, nil
+}
+
+// Record adds an access to the ring overwriting the oldest one if full.
+func (r *BusRing) Record(b BusAccess) {
+	r.entries[r.next] = b
+	r.next++
+	if r.next >= len(r.entries) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// Entries returns a copy of the accesses in the ring from oldest to newest.
+func (r *BusRing) Entries() []BusAccess {
+	if !r.full {
+		return append([]BusAccess(nil), r.entries[:r.next]...)
+	}
+	return append(append([]BusAccess(nil), r.entries[r.next:]...), r.entries[:r.next]...)
+}
+
+// Clear empties the ring.
+func (r *BusRing) Clear() {
+	r.next = 0
+	r.full = false
+}
+
+// readAs is read() but classifies the access as kind for the bus log.
+func (p *Chip) readAs(kind BusKind, addr uint16) uint8 {
+	p.busKind = kind
+	return p.read(addr)
+}
+
+// writeAs is write() but classifies the access as kind for the bus log.
+func (p *Chip) writeAs(kind BusKind, addr uint16, val uint8) {
+	p.busKind = kind
+	p.write(addr, val)
+}
+
+// logBus reports an access to the bus log (if any) using the kind set by readAs/writeAs
+// (BUS_DATA otherwise) and then clears the kind for the next access.
+func (p *Chip) logBus(addr uint16, val uint8, write bool) {
+	kind := p.busKind
+	p.busKind = BUS_UNIMPLEMENTED
+	if p.busLog == nil {
+		return
+	}
+	if kind == BUS_UNIMPLEMENTED {
+		kind = BUS_DATA
+	}
+	b := BusAccess{
+		Cycle: p.clocks,
+		PC:    p.opPC,
+		Addr:  addr,
+		Val:   val,
+		Write: write,
+		Kind:  kind,
+	}
+	// The 2nd byte of an instruction is always fetched but whether it's used isn't known
+	// until the instruction runs for this cycle. See flushBus.
+	if kind == BUS_OPERAND && p.opTick == 2 && !write {
+		p.busPending = &b
+		return
+	}
+	p.busLog(b)
+}
+
+// flushBus reports any access held back by logBus. If the instruction didn't advance the PC past
+// the byte after the opcode it didn't use it and the access was a dummy read.
+func (p *Chip) flushBus() {
+	if p.busPending == nil {
+		return
+	}
+	b := *p.busPending
+	p.busPending = nil
+	if p.PC == p.opPC+1 {
+		b.Kind = BUS_DUMMY
+	}
+	if p.busLog != nil {
+		p.busLog(b)
+	}
+}
@@ -0,0 +1,121 @@
+package cpu
+
+import (
+	"testing"
+)
+
+func TestBusLog(t *testing.T) {
+	var irq testIRQ
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Irq: &irq}, 0xEA, 0x0202)
+	prog := []uint8{
+		0x18,             // CLC
+		0xBD, 0xFF, 0x12, // LDA 12FF,X
+		0x9D, 0x00, 0x30, // STA 3000,X
+		0xE6, 0x10, // INC 10
+		0x48,             // PHA
+		0x20, 0x00, 0x40, // JSR 4000
+	}
+	for i, v := range prog {
+		r.addr[kRESET+uint16(i)] = v
+	}
+	r.addr[0x1300] = 0x56
+	c.X = 0x01
+	c.S = 0xFF
+	c.P &^= P_INTERRUPT
+
+	ring, err := NewBusRing(64)
+	if err != nil {
+		t.Fatalf("Can't create ring: %v", err)
+	}
+	c.SetBusLog(ring.Record)
+	for range []string{"CLC", "LDA", "STA", "INC", "PHA", "JSR"} {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	irq.s = true
+	if _, err := c.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	irq.s = false
+
+	const R = kRESET
+	want := []BusAccess{
+		{PC: R, Addr: R, Val: 0x18, Kind: BUS_OPCODE},
+		{PC: R, Addr: R + 1, Val: 0xBD, Kind: BUS_DUMMY},
+		{PC: R + 1, Addr: R + 1, Val: 0xBD, Kind: BUS_OPCODE},
+		{PC: R + 1, Addr: R + 2, Val: 0xFF, Kind: BUS_OPERAND},
+		{PC: R + 1, Addr: R + 3, Val: 0x12, Kind: BUS_OPERAND},
+		{PC: R + 1, Addr: 0x1200, Val: 0xEA, Kind: BUS_DUMMY},
+		{PC: R + 1, Addr: 0x1300, Val: 0x56, Kind: BUS_DATA},
+		{PC: R + 4, Addr: R + 4, Val: 0x9D, Kind: BUS_OPCODE},
+		{PC: R + 4, Addr: R + 5, Val: 0x00, Kind: BUS_OPERAND},
+		{PC: R + 4, Addr: R + 6, Val: 0x30, Kind: BUS_OPERAND},
+		{PC: R + 4, Addr: 0x3001, Val: 0xEA, Kind: BUS_DUMMY},
+		{PC: R + 4, Addr: 0x3001, Val: 0x56, Write: true, Kind: BUS_DATA},
+		{PC: R + 7, Addr: R + 7, Val: 0xE6, Kind: BUS_OPCODE},
+		{PC: R + 7, Addr: R + 8, Val: 0x10, Kind: BUS_OPERAND},
+		{PC: R + 7, Addr: 0x0010, Val: 0xEA, Kind: BUS_DATA},
+		{PC: R + 7, Addr: 0x0010, Val: 0xEA, Write: true, Kind: BUS_DUMMY},
+		{PC: R + 7, Addr: 0x0010, Val: 0xEB, Write: true, Kind: BUS_DATA},
+		{PC: R + 9, Addr: R + 9, Val: 0x48, Kind: BUS_OPCODE},
+		{PC: R + 9, Addr: R + 10, Val: 0x20, Kind: BUS_DUMMY},
+		{PC: R + 9, Addr: 0x01FF, Val: 0x56, Write: true, Kind: BUS_STACK},
+		{PC: R + 10, Addr: R + 10, Val: 0x20, Kind: BUS_OPCODE},
+		{PC: R + 10, Addr: R + 11, Val: 0x00, Kind: BUS_OPERAND},
+		{PC: R + 10, Addr: 0x01FE, Val: 0xEA, Kind: BUS_DUMMY},
+		{PC: R + 10, Addr: 0x01FE, Val: uint8((R + 12) >> 8), Write: true, Kind: BUS_STACK},
+		{PC: R + 10, Addr: 0x01FD, Val: uint8((R + 12) & 0xFF), Write: true, Kind: BUS_STACK},
+		{PC: R + 10, Addr: R + 12, Val: 0x40, Kind: BUS_OPERAND},
+		{PC: 0x4000, Addr: 0x4000, Val: 0xEA, Kind: BUS_INTERRUPT},
+		{PC: 0x4000, Addr: 0x4000, Val: 0xEA, Kind: BUS_INTERRUPT},
+		{PC: 0x4000, Addr: 0x01FC, Val: 0x40, Write: true, Kind: BUS_STACK},
+		{PC: 0x4000, Addr: 0x01FB, Val: 0x00, Write: true, Kind: BUS_STACK},
+		{PC: 0x4000, Addr: 0x01FA, Val: c.P&^(P_INTERRUPT|P_B) | P_S1, Write: true, Kind: BUS_STACK},
+		{PC: 0x4000, Addr: IRQ_VECTOR, Val: r.addr[IRQ_VECTOR], Kind: BUS_VECTOR},
+		{PC: 0x4000, Addr: IRQ_VECTOR + 1, Val: r.addr[IRQ_VECTOR+1], Kind: BUS_VECTOR},
+	}
+	got := ring.Entries()
+	if len(got) != len(want) {
+		t.Errorf("Wrong number of accesses. Got %d and want %d", len(got), len(want))
+	}
+	for i := range want {
+		if i >= len(got) {
+			break
+		}
+		// Every one of these instructions uses the bus on each cycle.
+		want[i].Cycle = got[0].Cycle + i
+		if got[i] != want[i] {
+			t.Errorf("Access %d wrong.\nGot  %s\nWant %s", i, got[i], want[i])
+		}
+	}
+
+	// Once full the ring only keeps the newest entries.
+	small, err := NewBusRing(3)
+	if err != nil {
+		t.Fatalf("Can't create ring: %v", err)
+	}
+	for _, b := range want {
+		small.Record(b)
+	}
+	if got, want := small.Entries(), want[len(want)-3:]; len(got) != 3 || got[0] != want[0] || got[2] != want[2] {
+		t.Errorf("Bad ring contents. Got %v and want %v", got, want)
+	}
+	small.Clear()
+	if got := small.Entries(); len(got) != 0 {
+		t.Errorf("Ring not empty after Clear: %v", got)
+	}
+	if _, err := NewBusRing(0); err == nil {
+		t.Error("Didn't get error for zero size ring")
+	}
+
+	// Removing the callback stops logging.
+	c.SetBusLog(nil)
+	ring.Clear()
+	if _, err := c.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if got := ring.Entries(); len(got) != 0 {
+		t.Errorf("Got accesses after removing callback: %v", got)
+	}
+}
@@ -0,0 +1,302 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CallKind is an enumeration of the ways a frame can be entered on the shadow call stack.
+type CallKind int
+
+const (
+	CALL_UNIMPLEMENTED CallKind = iota // Start of valid call kinds.
+	CALL_JSR                           // A JSR. Expected to end with RTS.
+	CALL_BRK                           // A BRK. Expected to end with RTI.
+	CALL_IRQ                           // An IRQ. Expected to end with RTI.
+	CALL_NMI                           // An NMI. Expected to end with RTI.
+	CALL_MAX                           // End of call kinds.
+)
+
+// String implements fmt.Stringer for CallKind.
+func (c CallKind) String() string {
+	switch c {
+	case CALL_JSR:
+		return "JSR"
+	case CALL_BRK:
+		return "BRK"
+	case CALL_IRQ:
+		return "IRQ"
+	case CALL_NMI:
+		return "NMI"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(c))
+}
+
+// CallFrame is a single entry on the shadow call stack.
+type CallFrame struct {
+	Kind   CallKind // How the frame was entered.
+	PC     uint16   // The PC of the JSR/BRK or of the instruction an interrupt ran in place of.
+	Target uint16   // The address the frame started running at.
+	Return uint16   // The return address pushed. For JSR this is the last byte of the JSR (RTS adds one).
+	SP     uint8    // S once the return address (and P for BRK/IRQ/NMI) was pushed.
+}
+
+// String implements fmt.Stringer for CallFrame.
+func (c CallFrame) String() string {
+	return fmt.Sprintf("%.4X %s %.4X (S: %.2X)", c.PC, c.Kind, c.Target, c.SP)
+}
+
+// ret returns the PC a matching RTS/RTI returns to.
+func (c CallFrame) ret() uint16 {
+	if c.Kind == CALL_JSR {
+		return c.Return + 1
+	}
+	return c.Return
+}
+
+// size returns the number of bytes the frame pushed.
+func (c CallFrame) size() uint8 {
+	if c.Kind == CALL_JSR {
+		return 2
+	}
+	return 3
+}
+
+// StackIssueKind is an enumeration of the stack misuse a CallStack detects.
+type StackIssueKind int
+
+const (
+	STACK_UNIMPLEMENTED  StackIssueKind = iota // Start of valid stack issues.
+	STACK_NO_CALL                              // RTS/RTI pulled a return address no tracked call pushed.
+	STACK_WRONG_RETURN                         // RTS ended a BRK/IRQ/NMI frame or RTI ended a JSR frame.
+	STACK_RETURN_ADDRESS                       // The return address pulled isn't the one the call pushed (it was overwritten).
+	STACK_SKIPPED                              // A return went past frames which never returned (their return addresses were pulled some other way).
+	STACK_OVERFLOW                             // A push with S at 0x00 wrapped to 0x01FF.
+	STACK_UNDERFLOW                            // A pull with S at 0xFF wrapped to 0x0100.
+	STACK_MAX                                  // End of stack issues.
+)
+
+// String implements fmt.Stringer for StackIssueKind.
+func (s StackIssueKind) String() string {
+	switch s {
+	case STACK_NO_CALL:
+		return "NO_CALL"
+	case STACK_WRONG_RETURN:
+		return "WRONG_RETURN"
+	case STACK_RETURN_ADDRESS:
+		return "RETURN_ADDRESS"
+	case STACK_SKIPPED:
+		return "SKIPPED"
+	case STACK_OVERFLOW:
+		return "OVERFLOW"
+	case STACK_UNDERFLOW:
+		return "UNDERFLOW"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(s))
+}
+
+// StackIssue describes a single detected misuse of the stack.
+type StackIssue struct {
+	Kind    StackIssueKind
+	PC      uint16      // The PC the instruction (or interrupt) started at.
+	Op      uint8       // The opcode at PC.
+	Frame   *CallFrame  // For STACK_WRONG_RETURN, STACK_RETURN_ADDRESS and STACK_SKIPPED the frame the return ended.
+	Got     uint16      // For RTS/RTI issues the return address pulled (after RTS adds one).
+	Skipped int         // For STACK_SKIPPED the number of frames skipped.
+	Trace   []CallFrame // The backtrace (innermost first) at the time of the issue. For returns this is before any frames are removed.
+}
+
+// String implements fmt.Stringer for StackIssue.
+func (s StackIssue) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s at %.4X (opcode %.2X)", s.Kind, s.PC, s.Op)
+	switch s.Kind {
+	case STACK_WRONG_RETURN:
+		fmt.Fprintf(&b, " ended %s frame from %.4X", s.Frame.Kind, s.Frame.PC)
+	case STACK_NO_CALL:
+		fmt.Fprintf(&b, " returned to %.4X", s.Got)
+	case STACK_RETURN_ADDRESS:
+		fmt.Fprintf(&b, " returned to %.4X but %s at %.4X expects %.4X", s.Got, s.Frame.Kind, s.Frame.PC, s.Frame.ret())
+	case STACK_SKIPPED:
+		fmt.Fprintf(&b, " returned to %s at %.4X skipping %d frame(s)", s.Frame.Kind, s.Frame.PC, s.Skipped)
+	}
+	return b.String()
+}
+
+// CallStack tracks calls (JSR, BRK, IRQ and NMI) and their returns (RTS and RTI) to build a backtrace
+// of the running code and to detect misuse of the stack. Install one with SetCallStack.
+// Frames are tracked by where their return address sits on the stack so code which drops a frame on
+// purpose (i.e. pulls the return address and jumps elsewhere) or resets S with TXS doesn't confuse it.
+type CallStack struct {
+	frames []CallFrame
+	report func(StackIssue)
+	issues int
+}
+
+// NewCallStack returns an empty CallStack. If report is non-nil it's called for each issue found.
+// It's called during Tick() (or Step()) so it must not call anything which changes CPU state.
+func NewCallStack(report func(StackIssue)) *CallStack {
+	return &CallStack{report: report}
+}
+
+// SetCallStack installs a CallStack which is updated as instructions complete. Passing nil removes it.
+// Any frames already in s are discarded since they don't belong to the current stack.
+func (p *Chip) SetCallStack(s *CallStack) {
+	p.calls = s
+	if s != nil {
+		s.frames = s.frames[:0]
+	}
+}
+
+// Backtrace returns the current frames with the innermost first.
+func (s *CallStack) Backtrace() []CallFrame {
+	ret := make([]CallFrame, len(s.frames))
+	for i, f := range s.frames {
+		ret[len(s.frames)-1-i] = f
+	}
+	return ret
+}
+
+// Depth returns the number of frames currently on the stack.
+func (s *CallStack) Depth() int {
+	return len(s.frames)
+}
+
+// Issues returns the number of issues found since the CallStack was created.
+func (s *CallStack) Issues() int {
+	return s.issues
+}
+
+// FormatBacktrace returns bt (as returned by Backtrace) as one frame per line. If symbols is non-nil
+// any targets found in it are shown by name.
+func FormatBacktrace(bt []CallFrame, symbols map[uint16]string) string {
+	var b strings.Builder
+	for i, f := range bt {
+		target := fmt.Sprintf("$%.4X", f.Target)
+		if name, ok := symbols[f.Target]; ok {
+			target = name
+		}
+		fmt.Fprintf(&b, "#%d %s called from %.4X by %s (S: %.2X)\n", i, target, f.PC, f.Kind, f.SP)
+	}
+	return b.String()
+}
+
+// issue reports a problem to the installed handler (if any).
+func (s *CallStack) issue(p *Chip, i StackIssue) {
+	s.issues++
+	if s.report == nil {
+		return
+	}
+	i.PC = p.opPC
+	i.Op = p.op
+	i.Trace = s.Backtrace()
+	s.report(i)
+}
+
+// push adds a frame. Any frames at or below its position on the stack were abandoned
+// (the space is being reused) so they're dropped.
+func (s *CallStack) push(f CallFrame) {
+	for len(s.frames) > 0 && s.frames[len(s.frames)-1].SP <= f.SP {
+		s.frames = s.frames[:len(s.frames)-1]
+	}
+	s.frames = append(s.frames, f)
+}
+
+// unwind drops any frames whose return address is entirely above sp (i.e. has been pulled).
+func (s *CallStack) unwind(sp uint8) {
+	for len(s.frames) > 0 {
+		f := s.frames[len(s.frames)-1]
+		if uint16(f.SP)+uint16(f.size()) > uint16(sp) {
+			return
+		}
+		s.frames = s.frames[:len(s.frames)-1]
+	}
+}
+
+// ret handles an RTS/RTI which has set the PC and S from the stack.
+func (s *CallStack) ret(p *Chip, rti bool) {
+	sp := p.S
+	// Find the frame the return address pulled (the 2 bytes below sp) came from. Normally that's
+	// the top frame and the pull ended exactly at its end but a mismatched RTS/RTI only lines up
+	// with part of it. Frames above it on the shadow stack (lower S) were entirely pulled already
+	// so they were skipped.
+	match := -1
+	for i := len(s.frames) - 1; i >= 0; i-- {
+		f := s.frames[i]
+		if uint16(f.SP)+1 > uint16(sp) {
+			break
+		}
+		if uint16(f.SP)+uint16(f.size())+1 >= uint16(sp) {
+			match = i
+			break
+		}
+	}
+	if match < 0 {
+		s.issue(p, StackIssue{Kind: STACK_NO_CALL, Got: p.PC})
+		s.unwind(sp)
+		return
+	}
+	f := s.frames[match]
+	if skipped := len(s.frames) - 1 - match; skipped > 0 {
+		s.issue(p, StackIssue{Kind: STACK_SKIPPED, Frame: &f, Got: p.PC, Skipped: skipped})
+	}
+	switch {
+	case rti != (f.Kind != CALL_JSR):
+		s.issue(p, StackIssue{Kind: STACK_WRONG_RETURN, Frame: &f, Got: p.PC})
+	case p.PC != f.ret():
+		s.issue(p, StackIssue{Kind: STACK_RETURN_ADDRESS, Frame: &f, Got: p.PC})
+	}
+	s.frames = s.frames[:match]
+}
+
+// done updates the stack once an instruction or interrupt sequence has completed.
+// It must be called before runningInterrupt/irqRaised are cleared.
+func (s *CallStack) done(p *Chip) {
+	kind := CALL_UNIMPLEMENTED
+	if p.runningInterrupt {
+		kind = p.interruptKind()
+	}
+	s.update(p, kind)
+}
+
+// update updates the stack for the instruction at opPC which just completed or, if interrupt is a valid
+// CallKind, for the interrupt sequence which just completed. Returns true if a frame was pushed.
+func (s *CallStack) update(p *Chip, interrupt CallKind) bool {
+	if interrupt != CALL_UNIMPLEMENTED {
+		s.push(CallFrame{Kind: interrupt, PC: p.opPC, Target: p.PC, Return: p.opPC, SP: p.S})
+		return true
+	}
+	switch p.op {
+	case 0x00: // BRK
+		s.push(CallFrame{Kind: CALL_BRK, PC: p.opPC, Target: p.PC, Return: p.opPC + 2, SP: p.S})
+		return true
+	case 0x20: // JSR
+		s.push(CallFrame{Kind: CALL_JSR, PC: p.opPC, Target: p.PC, Return: p.opPC + 2, SP: p.S})
+		return true
+	case 0x60: // RTS
+		s.ret(p, false)
+	case 0x40: // RTI
+		s.ret(p, true)
+	case 0x9A, 0x9B, 0xBB: // TXS, TAS, LAS (NMOS only but the rest leave S alone)
+		s.unwind(p.S)
+	}
+	return false
+}
+
+// interruptKind returns the CallKind for the interrupt sequence being run.
+func (p *Chip) interruptKind() CallKind {
+	if p.irqRaised == kIRQ_NMI {
+		return CALL_NMI
+	}
+	return CALL_IRQ
+}
+
+// stackWrap reports a push or pull which wrapped S. Called before S changes.
+func (s *CallStack) stackWrap(p *Chip, push bool) {
+	switch {
+	case push && p.S == 0x00:
+		s.issue(p, StackIssue{Kind: STACK_OVERFLOW})
+	case !push && p.S == 0xFF:
+		s.issue(p, StackIssue{Kind: STACK_UNDERFLOW})
+	}
+}
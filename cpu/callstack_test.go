@@ -0,0 +1,247 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCallStack(t *testing.T) {
+	tests := []struct {
+		name   string
+		prog   map[uint16][]uint8 // Programs to load. Execution starts at 0x1000.
+		s      uint8              // Initial S (0xFF if unset).
+		irqAt  int                // If non-zero raise IRQ before this instruction (counting from 1).
+		steps  int                // Instructions (or interrupts) to run.
+		trace  []uint16           // Targets of the backtrace (innermost first) once done.
+		issues []StackIssueKind
+	}{
+		{
+			name: "Nested calls",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20}, // JSR 2000
+				0x2000: {0x20, 0x00, 0x30}, // JSR 3000
+				0x3000: {0xEA},             // NOP
+			},
+			steps: 3,
+			trace: []uint16{0x3000, 0x2000},
+		},
+		{
+			name: "Calls return",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20, 0xEA}, // JSR 2000, NOP
+				0x2000: {0x20, 0x00, 0x30, 0x60}, // JSR 3000, RTS
+				0x3000: {0x60},                   // RTS
+			},
+			steps: 5,
+		},
+		{
+			name: "RTS without a call",
+			prog: map[uint16][]uint8{
+				0x1000: {0x60}, // RTS
+			},
+			s:      0xF0,
+			steps:  1,
+			issues: []StackIssueKind{STACK_NO_CALL},
+		},
+		{
+			name: "RTS trick isn't a return",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20},                         // JSR 2000
+				0x2000: {0xA9, 0x2F, 0x48, 0xA9, 0xFF, 0x48, 0x60}, // LDA #2F, PHA, LDA #FF, PHA, RTS
+				0x3000: {0xEA},                                     // NOP
+			},
+			steps:  7,
+			trace:  []uint16{0x2000},
+			issues: []StackIssueKind{STACK_NO_CALL},
+		},
+		{
+			name: "Return address overwritten",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20},                   // JSR 2000
+				0x2000: {0xA9, 0x40, 0x8D, 0xFF, 0x01, 0x60}, // LDA #40, STA 01FF, RTS
+			},
+			steps:  4,
+			issues: []StackIssueKind{STACK_RETURN_ADDRESS},
+		},
+		{
+			name: "RTI from JSR",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20}, // JSR 2000
+				0x2000: {0x40},             // RTI
+			},
+			s:      0xF0,
+			steps:  2,
+			issues: []StackIssueKind{STACK_WRONG_RETURN},
+		},
+		{
+			name: "Skipped frame",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20}, // JSR 2000
+				0x2000: {0x20, 0x00, 0x30}, // JSR 3000
+				0x3000: {0x68, 0x68, 0x60}, // PLA, PLA, RTS
+			},
+			steps:  5,
+			issues: []StackIssueKind{STACK_SKIPPED},
+		},
+		{
+			name: "TXS drops frames",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20},       // JSR 2000
+				0x2000: {0xA2, 0xFF, 0x9A, 0xEA}, // LDX #FF, TXS, NOP
+			},
+			steps: 4,
+		},
+		{
+			name: "Frame reused",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20},             // JSR 2000
+				0x2000: {0x68, 0x68, 0x20, 0x00, 0x30}, // PLA, PLA, JSR 3000
+				0x3000: {0xEA},
+			},
+			steps: 5,
+			trace: []uint16{0x3000},
+		},
+		{
+			name: "IRQ and RTI",
+			prog: map[uint16][]uint8{
+				0x1000: {0x20, 0x00, 0x20}, // JSR 2000
+				0x2000: {0x58, 0xEA, 0xEA}, // CLI, NOP, NOP
+				0x4000: {0xEA, 0x40},       // NOP, RTI
+			},
+			irqAt: 3,
+			steps: 5,
+			trace: []uint16{0x2000},
+		},
+		{
+			name: "Overflow",
+			prog: map[uint16][]uint8{
+				0x1000: {0x48, 0x48, 0x48}, // PHA, PHA, PHA
+			},
+			s:      0x01,
+			steps:  3,
+			issues: []StackIssueKind{STACK_OVERFLOW},
+		},
+		{
+			name: "Underflow",
+			prog: map[uint16][]uint8{
+				0x1000: {0x68}, // PLA
+			},
+			s:      0xFF,
+			steps:  1,
+			issues: []StackIssueKind{STACK_UNDERFLOW},
+		},
+	}
+	for _, test := range tests {
+		for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+			t.Run(fmt.Sprintf("%s %s", test.name, core), func(t *testing.T) {
+				irq := &testIRQ{}
+				c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Irq: irq, Core: core}, 0xEA, 0x0202)
+				for addr, prog := range test.prog {
+					copy(r.addr[addr:], prog)
+				}
+				r.addr[IRQ_VECTOR], r.addr[IRQ_VECTOR+1] = 0x00, 0x40
+				c.PC = 0x1000
+				c.S = 0xFF
+				if test.s != 0 {
+					c.S = test.s
+				}
+				var issues []StackIssue
+				cs := NewCallStack(func(i StackIssue) {
+					issues = append(issues, i)
+				})
+				c.SetCallStack(cs)
+				prof := NewProfiler()
+				c.SetProfiler(prof)
+				for i := 1; i <= test.steps; i++ {
+					if i == test.irqAt {
+						irq.s = true
+					}
+					info, err := c.Step()
+					if err != nil {
+						t.Fatalf("Step failed: %v", err)
+					}
+					if info.Interrupt {
+						irq.s = false
+					}
+				}
+				var got []uint16
+				for _, f := range cs.Backtrace() {
+					got = append(got, f.Target)
+				}
+				if fmt.Sprint(got) != fmt.Sprint(test.trace) {
+					t.Errorf("Bad backtrace. Got %.4X and want %.4X\n%s", got, test.trace, FormatBacktrace(cs.Backtrace(), nil))
+				}
+				// The profiler tracks frames the same way so its stack (outermost first) should match.
+				// The fast core doesn't update the profiler.
+				var stack []uint16
+				for i := len(test.trace) - 1; i >= 0; i-- {
+					stack = append(stack, test.trace[i])
+				}
+				if got := prof.Stack(); core == CORE_ACCURATE && fmt.Sprint(got) != fmt.Sprint(stack) {
+					t.Errorf("Bad profiler stack. Got %.4X and want %.4X", got, stack)
+				}
+				var kinds []StackIssueKind
+				for _, i := range issues {
+					kinds = append(kinds, i.Kind)
+				}
+				if fmt.Sprint(kinds) != fmt.Sprint(test.issues) {
+					t.Errorf("Bad issues. Got %v and want %v", issues, test.issues)
+				}
+				if got, want := cs.Issues(), len(test.issues); got != want {
+					t.Errorf("Bad issue count. Got %d and want %d", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestCallStackFormat(t *testing.T) {
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
+	copy(r.addr[0x1000:], []uint8{0x20, 0x00, 0x20}) // JSR 2000
+	copy(r.addr[0x2000:], []uint8{0x00, 0x00})       // BRK
+	r.addr[IRQ_VECTOR], r.addr[IRQ_VECTOR+1] = 0x00, 0x40
+	c.PC = 0x1000
+	c.S = 0xFF
+	var issue StackIssue
+	cs := NewCallStack(func(i StackIssue) {
+		issue = i
+	})
+	c.SetCallStack(cs)
+	for i := 0; i < 2; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	got := FormatBacktrace(cs.Backtrace(), map[uint16]string{0x2000: "sub"})
+	want := "#0 $4000 called from 2000 by BRK (S: FA)\n#1 sub called from 1000 by JSR (S: FD)\n"
+	if got != want {
+		t.Errorf("Bad backtrace.\nGot:\n%s\nWant:\n%s", got, want)
+	}
+
+	// Returning from the BRK with RTS is reported with the frame it ended.
+	r.addr[0x4000] = 0x60
+	if _, err := c.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if issue.Kind != STACK_WRONG_RETURN || issue.Frame == nil || issue.Frame.Kind != CALL_BRK || len(issue.Trace) != 2 {
+		t.Errorf("Bad issue: %+v", issue)
+	}
+	if s := issue.String(); !strings.Contains(s, "WRONG_RETURN at 4000") {
+		t.Errorf("Bad issue string: %s", s)
+	}
+
+	// Reset clears the stack.
+	for {
+		done, err := c.Reset()
+		if err != nil {
+			t.Fatalf("Reset failed: %v", err)
+		}
+		if done {
+			break
+		}
+	}
+	if got := cs.Depth(); got != 0 {
+		t.Errorf("Stack not cleared by reset. Depth %d", got)
+	}
+}
@@ -5,12 +5,13 @@ package cpu
 
 import (
 	"fmt"
-	"math/rand"
 	"time"
 
 	"github.com/jmchacon/6502/disassemble"
+	"github.com/jmchacon/6502/io"
 	"github.com/jmchacon/6502/irq"
 	"github.com/jmchacon/6502/memory"
+	"github.com/jmchacon/6502/pacer"
 )
 
 // CPUType is an enumeration of the valid CPU types.
@@ -22,6 +23,9 @@ const (
 	CPU_NMOS_RICOH                  // Ricoh version used in NES which is identical to NMOS except BCD mode is unimplmented.
 	CPU_NMOS_6510                   // NMOS 6510 variant which includes I/O ports mapped at addresses 0x0 and 0x1
 	CPU_CMOS                        // 65C02 CMOS version where undocumented opcodes are all explicit NOP.
+	CPU_NMOS_REV_A                  // Early NMOS revision A which has the ROR bug. ROR shifts left like ASL but without changing carry.
+	CPU_NMOS_6507                   // NMOS 6507 (used in the Atari 2600) which has only 13 address lines and no IRQ/NMI pins.
+	CPU_NMOS_6504                   // NMOS 6504 which has only 13 address lines and no NMI/RDY pins.
 	CPU_MAX                         // End of CPU enumerations.
 )
 
@@ -53,42 +57,105 @@ const (
 	NEGATIVE_ONE = uint8(0xFF)
 )
 
+const (
+	kPORT_DDR  = uint16(0x0000) // Address of the 6510 I/O port data direction register.
+	kPORT_DATA = uint16(0x0001) // Address of the 6510 I/O port data register.
+
+	kPORT_FLOAT_MASK = uint8(0xC0) // Bits 6/7 of the 6510 I/O port have no pins so they float when set as inputs.
+
+	// The number of cycles bits 6/7 of the 6510 I/O port hold their last output value once switched to input.
+	// This varies per chip but the value here matches what VICE uses based on measurements.
+	kPORT_FLOAT_CYCLES = 350000
+
+	kADDRESS_MASK_13 = uint16(0x1FFF) // Address mask for packages with only 13 address lines (6507/6504).
+)
+
 type Chip struct {
-	A                 uint8         // Accumulator register
-	X                 uint8         // X register
-	Y                 uint8         // Y register
-	S                 uint8         // Stack pointer
-	P                 uint8         // Status register
-	PC                uint16        // Program counter
-	clocks            int           // Total number of clock cycles since start.
-	debug             bool          // Controls whether Debug() emits data or not.
-	tickDone          bool          // True if TickDone() was called before the current Tick() call
-	irq               irq.Sender    // Interface for installing an IRQ sender.
-	nmi               irq.Sender    // Interface for installing an NMI sender.
-	rdy               irq.Sender    // Interface for installing a RDY handler. Technically not an interrupt source but signals the same (edge).
-	cpuType           CPUType       // Must be between UNIMPLEMENTED and MAX from above.
-	ram               memory.Bank   // Interface to implementation RAM.
-	clock             time.Duration // If non-zero indicates the cycle time per Tick (sleeps after processing to delay).
-	avgClock          time.Duration // Empirically determined average run time of an instruction (if clock is non-zero).
-	avgTime           time.Duration // Empirically determined average time that time.Now() calls take.
-	timeRuns          int           // The precomputed number of times to delay loop to meet the clock cycle above.
-	timeNeedAdjust    bool          // If true adds one to timeRuns every other cycle to account for the fact it undershoots by default.
-	timeAdjustCnt     float64       // The number of ticks we're off by (too slow) and need adjusting every so often.
-	timerTicks        float64       // Number of ticks in this sequence before resetting.
-	timerTicksReset   int           // At the tick we should reset our counting for adjustment.
-	reset             bool          // Whether reset has occurred.
-	op                uint8         // The current working opcode
-	opVal             uint8         // The 1st byte argument after the opcode (all instructions have this).
-	opTick            int           // Tick number for internal operation of opcode.
-	opAddr            uint16        // Address computed during opcode to be used for read/write (indirect, etc modes).
-	opDone            bool          // Stays false until the current opcode has completed all ticks.
-	addrDone          bool          // Stays false until the current opcode has completed any addressing mode ticks.
-	skipInterrupt     bool          // Skip interrupt processing on the next instruction.
-	prevSkipInterrupt bool          // Previous instruction skipped interrupt processing (so we shouldn't).
-	irqRaised         irqType       // Must be between UNIMPLEMENTED and MAX from above.
-	runningInterrupt  bool          // Whether we're running an interrupt setup or an opcode.
-	halted            bool          // If stopped due to a halt instruction
-	haltOpcode        uint8         // Opcode that caused the halt
+	A                 uint8                 // Accumulator register
+	X                 uint8                 // X register
+	Y                 uint8                 // Y register
+	S                 uint8                 // Stack pointer
+	P                 uint8                 // Status register
+	PC                uint16                // Program counter
+	clocks            int                   // Total number of clock cycles since start.
+	debug             bool                  // Controls whether Debug() emits data or not.
+	tickDone          bool                  // True if TickDone() was called before the current Tick() call
+	irq               irq.Sender            // Interface for installing an IRQ sender.
+	nmi               irq.Sender            // Interface for installing an NMI sender.
+	rdy               irq.Sender            // Interface for installing a RDY handler. Technically not an interrupt source but signals the same (edge).
+	cpuType           CPUType               // Must be between UNIMPLEMENTED and MAX from above.
+	ram               memory.Bank           // Interface to implementation RAM.
+	opcodes           *[256]opcode          // Opcode descriptors for cpuType.
+	addrMask          uint16                // Mask applied to every address put on the bus since some packages have fewer address lines.
+	clock             time.Duration         // If non-zero indicates the cycle time per Tick (paced by pacer).
+	avgClock          time.Duration         // Empirically determined average run time of an instruction (if clock is non-zero).
+	pacer             *pacer.Pacer          // If non-nil paces Tick() calls to clock.
+	reset             bool                  // Whether reset has occurred.
+	op                uint8                 // The current working opcode
+	opPC              uint16                // The PC value the current opcode (or interrupt) started at.
+	opVal             uint8                 // The 1st byte argument after the opcode (all instructions have this).
+	opTick            int                   // Tick number for internal operation of opcode.
+	opAddr            uint16                // Address computed during opcode to be used for read/write (indirect, etc modes).
+	opDone            bool                  // Stays false until the current opcode has completed all ticks.
+	addrDone          bool                  // Stays false until the current opcode has completed any addressing mode ticks.
+	skipInterrupt     bool                  // Skip interrupt processing on the next instruction.
+	prevSkipInterrupt bool                  // Previous instruction skipped interrupt processing (so we shouldn't).
+	irqRaised         irqType               // Must be between UNIMPLEMENTED and MAX from above.
+	runningInterrupt  bool                  // Whether we're running an interrupt setup or an opcode.
+	halted            bool                  // If stopped due to a halt instruction
+	haltOpcode        uint8                 // Opcode that caused the halt
+	waiting           bool                  // If idle due to a WAI instruction (CMOS only) until an interrupt is raised.
+	extraTick         bool                  // Set while a CMOS opcode is running an extra tick (decimal mode ADC/SBC, page crossing on a,x shifts).
+	portInput         io.PortIn8            // Interface for installing an I/O port input (6510 only).
+	portOutput        *portOut              // The output of the I/O port (6510 only).
+	portDDR           uint8                 // I/O port data direction register (6510 only).
+	portData          uint8                 // I/O port data register (6510 only).
+	portFloat         uint8                 // The values bits 6/7 last output before being switched to input (6510 only).
+	portFloatEnd      [2]int                // The clock counts at which bits 6/7 (respectively) decay to 0 once set as input (6510 only).
+	policy            *memory.PowerOnPolicy // Power on policy and source for any random behavior.
+	breakpoints       []breakpoint          // Installed breakpoints/watchpoints.
+	breakID           int                   // The last ID handed out by AddBreakpoint.
+	breakHit          *BreakpointHit        // If non-nil a breakpoint triggered during the current cycle.
+	opTrap            *OpcodeTrap           // If non-nil the opcode fetched during the current cycle was trapped.
+	breakResume       bool                  // Set after an execution breakpoint triggers so the next Tick() continues past it.
+	trace             *tracer               // If non-nil the trace output setup by SetTrace.
+	busLog            func(BusAccess)       // If non-nil called for every bus access.
+	profile           *Profiler             // If non-nil the profiler setup by SetProfiler.
+	calls             *CallStack            // If non-nil the shadow call stack setup by SetCallStack.
+	history           *History              // If non-nil the history setup by SetHistory.
+	ints              *InterruptStats       // If non-nil the interrupt stats setup by SetInterruptStats.
+	opActions         [OPCLASS_MAX]OpAction // What to do when running each class of opcode. Set by SetOpcodeAction.
+	opChecks          bool                  // Set if any class in opActions needs checking as opcodes are fetched.
+	opLog             func(OpcodeUse)       // If non-nil called for opcodes whose class is set to OPACTION_LOG.
+	magic             Magic                 // The constants and quirks used by the unstable opcodes.
+	busKind           BusKind               // Classification for the next bus access (BUS_DATA if unset).
+	busPending        *BusAccess            // An access held back until the end of the cycle so it can be classified.
+	so                io.PortIn1            // Interface for installing a SO (set overflow) input.
+	soLevel           bool                  // The level of SO as of the last Tick() (for edge detection).
+	sync              bool                  // The SYNC output. High when the last cycle run was an opcode fetch.
+	syncOutput        *syncOut              // The SYNC output as an io.PortOut1.
+	core              Core                  // The core Step() uses to run instructions.
+}
+
+// portOut holds the data for the 6510 I/O port output.
+type portOut struct {
+	p *Chip
+}
+
+// Output implements the interface for io.PortOut8. Any pins set as input are
+// assumed to be pulled up (as on the C64) so they show as high.
+func (o *portOut) Output() uint8 {
+	return o.p.portData | ^o.p.portDDR
+}
+
+// syncOut holds the data for the SYNC output.
+type syncOut struct {
+	p *Chip
+}
+
+// Output implements the interface for io.PortOut1.
+func (o *syncOut) Output() bool {
+	return o.p.sync
 }
 
 // A few custom error types to distinguish why the CPU stopped.
@@ -119,14 +186,33 @@ type ChipDef struct {
 	Cpu CPUType
 	// Ram is the RAM interface for this implementation.
 	Ram memory.Bank
-	// Irq is an optional IRQ source to trigger the IRQ line.
+	// Irq is an optional IRQ source to trigger the IRQ line. The 6507 has no IRQ pin so this must be nil there.
 	Irq irq.Sender
 	// Nmi is an optional IRQ source to trigger the NMI line (acts as edge trigger even though real HW is level).
+	// The 6507 and 6504 have no NMI pin so this must be nil there.
 	Nmi irq.Sender
 	// Rdy s an optional IRQ source to trigger the RDY line (which halts the CPU). This is not technically an IRQ but acts the same.
+	// NMOS parts only halt on read cycles (writes continue until the next read) while CMOS halts on any cycle.
+	// The 6504 has no RDY pin so this must be nil there.
 	Rdy irq.Sender
 	// Debug controls whether the Debug() function returns data or not.
 	Debug bool
+	// Port is an optional input source for the I/O port mapped at 0x0000/0x0001. Only used on the 6510.
+	// If this is nil any pins set as input are assumed to be pulled up and read as high.
+	Port io.PortIn8
+	// PowerOnPolicy if non-nil determines the register contents at power on and the source for any
+	// other random behavior (such as unstable undocumented opcodes). Otherwise these are random.
+	PowerOnPolicy *memory.PowerOnPolicy
+	// SO is an optional input for the SO (set overflow) pin. It's checked on each Tick() and a falling
+	// edge (Input() going from true to false) sets the V flag. If this is nil the pin is held high.
+	// The 6510, 6507 and 6504 have no SO pin so this is ignored there.
+	SO io.PortIn1
+	// Core selects how Step() runs instructions. If unset CORE_ACCURATE is used. See SetCore.
+	Core Core
+	// Magic if non-nil sets the constants and quirks used by the unstable opcodes. Otherwise DefaultMagic() is used.
+	// Every field is used as given (zero values aren't replaced with the defaults) so to change only some of them
+	// start from DefaultMagic() and set those on it.
+	Magic *Magic
 }
 
 // Init will create a new 65XX CPU of the type requested and return it in powered on state.
@@ -137,65 +223,99 @@ func Init(cpu *ChipDef) (*Chip, error) {
 	if cpu.Cpu <= CPU_UNIMPLMENTED || cpu.Cpu >= CPU_MAX {
 		return nil, InvalidCPUState{fmt.Sprintf("CPU type valid %d is invalid", cpu.Cpu)}
 	}
+	switch cpu.Cpu {
+	case CPU_NMOS_6507:
+		if cpu.Irq != nil || cpu.Nmi != nil {
+			return nil, InvalidCPUState{"6507 has no IRQ or NMI pins so Irq and Nmi must be nil"}
+		}
+	case CPU_NMOS_6504:
+		if cpu.Nmi != nil || cpu.Rdy != nil {
+			return nil, InvalidCPUState{"6504 has no NMI or RDY pins so Nmi and Rdy must be nil"}
+		}
+	}
+	core := cpu.Core
+	if core == CORE_UNIMPLEMENTED {
+		core = CORE_ACCURATE
+	}
+	if core < CORE_UNIMPLEMENTED || core >= CORE_MAX {
+		return nil, InvalidCPUState{fmt.Sprintf("core %d is invalid", cpu.Core)}
+	}
+	pol, err := memory.ResolvePowerOnPolicy(cpu.PowerOnPolicy)
+	if err != nil {
+		return nil, InvalidCPUState{fmt.Sprintf("invalid power on policy: %v", err)}
+	}
 	p := &Chip{
+		policy:   pol,
 		cpuType:  cpu.Cpu,
+		opcodes:  opcodeTables[cpu.Cpu],
 		debug:    cpu.Debug,
 		ram:      cpu.Ram,
 		irq:      cpu.Irq,
 		tickDone: true,
 		nmi:      cpu.Nmi,
 		rdy:      cpu.Rdy,
+		soLevel:  true,
+		core:     core,
+		magic:    DefaultMagic(),
+		addrMask: 0xFFFF,
+	}
+	if cpu.Magic != nil {
+		p.magic = *cpu.Magic
+	}
+	p.syncOutput = &syncOut{p}
+	switch p.cpuType {
+	case CPU_NMOS_6510:
+		p.portInput = cpu.Port
+		p.portOutput = &portOut{p}
+	case CPU_NMOS_6507, CPU_NMOS_6504:
+		// No SO pin.
+		p.addrMask = kADDRESS_MASK_13
+	default:
+		p.so = cpu.SO
 	}
 	return p, p.PowerOn()
 }
 
-// SetClock will take the given duration and compute the average delay for a fast operation
-// (consecutive time.Now() calls). This will then determine the number of times to call that
-// in a delay loop at the end of every instruction.
-// Will return an error if the system cannot compute a way to sleep in the amount of time required.
-// NOTE: This precomputes the delay for time.Now() so it takes some wall time to run per call.
-// TODO(jchacon): Implement on amd64 in terms of rdtsc instead as this is approximate at best and still has a decent amount of jitter.
-//                Or use golang.org/x/sys/unix and at least on unix use nanosleep calls (TBD windows?)
+// Port returns an io.PortOut8 for getting the current output pins of the I/O port
+// on a 6510. For any other CPU type this returns nil.
+func (p *Chip) Port() io.PortOut8 {
+	if p.portOutput == nil {
+		return nil
+	}
+	return p.portOutput
+}
+
+// Sync returns an io.PortOut1 for the SYNC output. This is high after a Tick() which
+// fetched an opcode (including the fetch which starts an interrupt sequence) and low otherwise.
+// While RDY holds the CPU it keeps the value from the last cycle which ran so a single step
+// circuit holding RDY while SYNC is high stops after each opcode fetch.
+func (p *Chip) Sync() io.PortOut1 {
+	return p.syncOutput
+}
+
+// SetClock sets the wall time each Tick() should take so the CPU runs at a given speed on its own.
+// Ticks are paced in slices (see the pacer package) so the CPU runs ahead and then sleeps instead of
+// delaying on every Tick(). A clock of 0 turns pacing off.
+// Will return an error if the host can't run a Tick() in the amount of time required.
+// NOTE: This measures the average time a Tick() takes so it takes some wall time to run per call.
+//       A system with more than one chip should pace all of them with a pacer.Pacer counting its master clock instead.
 func (p *Chip) SetClock(clk time.Duration) error {
 	p.clock = clk
-	p.timeRuns = 0
-	if clk != 0 {
-		var tot int64
-		// 10000000 calls should be sufficient to get a reasonable average.
-		const runs = int64(10000000)
-		for i := int64(0); i < runs; i++ {
-			s := time.Now()
-			diff := time.Since(s).Nanoseconds()
-			tot += diff
-		}
-		p.avgTime = time.Duration(float64(tot / runs))
-		// Now get the average clock cycles for an instruction
-		var err error
-		p.avgClock, err = getClockAverage()
-		if err != nil {
-			return err
-		}
-		if p.avgClock > p.clock {
-			return InvalidCPUState{fmt.Sprintf("can't set clock to %s as average time.Now() delay is %s", p.clock, p.avgClock)}
-		}
-		p.timeRuns = int((p.clock - p.avgClock) / p.avgTime)
-		// If we undershoot the desired clocks by more than 5% still then set things so
-		// we sleep an extra amount every N ticks to average out. Assuming no one is
-		// running the CPU for so few ticks this jitter is actually noticable.
-		if float64(p.timeRuns)*float64(p.avgTime)/float64(p.clock-p.avgClock) < 0.95 {
-			p.timeNeedAdjust = true
-			d := int64(p.clock-p.avgClock) - (int64(p.timeRuns) * int64(p.avgTime))
-			p.timeAdjustCnt = float64(p.avgTime) / float64(d)
-			// Assuming the above number isn't integral so we'll do adjustments by 10x.
-			// i.e. say it's 1.3. Since we can't add a sleep every 1.3 ticks we'll add 13 over 10 ticks
-			//      instead by adding extra for the ones where we need to adjust by > 1. This gives
-			//      much better jitter control than simply doing every other (as if often the case)
-			//      which still undershoots by quite a bit.
-			//      This could be even more accurate by going to more orders of magnitude but testing shows this
-			//      is pretty good until we get to something accurate by measuring the cycle timer. See Tick() for impl.
-			p.timerTicksReset = int(p.timeAdjustCnt * 10)
-			p.timerTicks = 0
-		}
+	p.pacer = nil
+	if clk == 0 {
+		return nil
+	}
+	var err error
+	p.avgClock, err = getClockAverage()
+	if err != nil {
+		return err
+	}
+	if p.avgClock > p.clock {
+		return InvalidCPUState{fmt.Sprintf("can't set clock to %s as average Tick() takes %s", p.clock, p.avgClock)}
+	}
+	p.pacer, err = pacer.Init(&pacer.PacerDef{Hz: float64(time.Second) / float64(clk)})
+	if err != nil {
+		return InvalidCPUState{fmt.Sprintf("can't set clock to %s: %v", p.clock, err)}
 	}
 	return nil
 }
@@ -228,7 +348,7 @@ func getClockAverage() (time.Duration, error) {
 	for _, test := range []uint8{0xA9, 0x6D} {
 		got := 0
 		r := &staticMemory{test}
-		c, err := Init(&ChipDef{CPU_NMOS, r, nil, nil, nil, false})
+		c, err := Init(&ChipDef{Cpu: CPU_NMOS, Ram: r})
 		if err != nil {
 			return 0, fmt.Errorf("getClockAverage init CPU: %v", err)
 		}
@@ -254,24 +374,26 @@ func getClockAverage() (time.Duration, error) {
 // Registers are random, stack is at random (though visual 6502 claims it's 0xFD due to a push P/PC in reset).
 // and P is cleared with interrupts disabled and decimal mode random (for NMOS).
 // The starting PC value is loaded from the reset vector.
+// The "random" values all come from the PowerOnPolicy passed to Init.
 // TODO(jchacon): See if any of this gets more defined on CMOS versions.
 func (p *Chip) PowerOn() error {
-	rand.Seed(time.Now().UnixNano())
+	v := p.policy.Values()
 	// This bit is always set.
 	flags := P_S1
 	// Randomize decimal state at startup for base NMOS types.
-	if p.cpuType == CPU_NMOS || p.cpuType == CPU_NMOS_6510 {
-		if rand.Float32() > 0.5 {
+	if p.cpuType != CPU_CMOS && p.cpuType != CPU_NMOS_RICOH {
+		if v.Bool() {
 			flags |= P_DECIMAL
 		}
 	}
 
 	// Randomize register contents
-	p.A = uint8(rand.Intn(256))
-	p.X = uint8(rand.Intn(256))
-	p.Y = uint8(rand.Intn(256))
-	p.S = uint8(rand.Intn(256))
+	p.A = v.Uint8()
+	p.X = v.Uint8()
+	p.Y = v.Uint8()
+	p.S = v.Uint8()
 	p.P = flags
+	p.portData = 0x00
 	// Reset to get everything else setup.
 	for {
 		done, err := p.Reset()
@@ -300,6 +422,16 @@ func (p *Chip) Reset() (bool, error) {
 		p.reset = true
 		p.tickDone = false
 		p.opTick = 0
+		p.sync = false
+		if p.profile != nil {
+			p.profile.reset()
+		}
+		if p.calls != nil {
+			p.calls.frames = p.calls.frames[:0]
+		}
+		if p.ints != nil {
+			p.ints.pending = [2]*InterruptEvent{}
+		}
 	}
 	p.opTick++
 	switch {
@@ -311,48 +443,59 @@ func (p *Chip) Reset() (bool, error) {
 		return false, nil
 	case p.opTick == 3:
 		// Standard first tick reads current PC value
-		_ = p.ram.Read(p.PC)
+		_ = p.readAs(BUS_INTERRUPT, p.PC)
 		p.PC++
 		// Reset other state now
 		p.halted = false
 		p.haltOpcode = 0x00
+		p.waiting = false
 		p.irqRaised = kIRQ_NONE
+		// All I/O port pins (6510) become inputs.
+		p.portDDR = 0x00
+		p.portFloatEnd = [2]int{}
 		return false, nil
 	case p.opTick == 4:
 		// Read another throw away value
-		_ = p.ram.Read(p.PC)
+		_ = p.readAs(BUS_INTERRUPT, p.PC)
 		p.PC++
 		return false, nil
 	case p.opTick == 5:
-		p.ram.Read(uint16(0x0100) + uint16(p.S))
+		p.readAs(BUS_INTERRUPT, uint16(0x0100)+uint16(p.S))
 		p.S--
 		return false, nil
 	case p.opTick == 6:
-		p.ram.Read(uint16(0x0100) + uint16(p.S))
+		p.readAs(BUS_INTERRUPT, uint16(0x0100)+uint16(p.S))
 		p.S--
 		return false, nil
 	case p.opTick == 7:
 		// Disable interrupts
 		p.P |= P_INTERRUPT
-		if p.cpuType == CPU_NMOS || p.cpuType == CPU_NMOS_6510 {
+		if p.cpuType != CPU_CMOS && p.cpuType != CPU_NMOS_RICOH {
 			p.P |= P_DECIMAL
 		}
-		p.ram.Read(uint16(0x0100) + uint16(p.S))
+		// CMOS always clears decimal mode.
+		if p.cpuType == CPU_CMOS {
+			p.P &^= P_DECIMAL
+		}
+		p.readAs(BUS_INTERRUPT, uint16(0x0100)+uint16(p.S))
 		p.S = 0xFD
 		return false, nil
 	case p.opTick == 8:
 		// Load PCL from reset vector
-		p.opVal = p.ram.Read(RESET_VECTOR)
+		p.opVal = p.readAs(BUS_VECTOR, RESET_VECTOR)
 		return false, nil
 	}
 	// case p.opTick == 9: PCH
-	p.PC = (uint16(p.ram.Read(RESET_VECTOR+1)) << 8) + uint16(p.opVal)
+	p.PC = (uint16(p.readAs(BUS_VECTOR, RESET_VECTOR+1)) << 8) + uint16(p.opVal)
 	if p.debug {
 		fmt.Printf("Reset vector read as 0x%.4X\n", p.PC)
 	}
 	p.reset = false
 	p.opTick = 0
 	p.tickDone = true
+	if p.history != nil {
+		p.history.boundary(p)
+	}
 	return true, nil
 }
 
@@ -362,39 +505,120 @@ func (p *Chip) Reset() (bool, error) {
 // For an NMOS cpu on a taken branch and an interrupt coming in immediately after will cause one
 // more instruction to be executed before the first interrupt instruction. This is accounted
 // for by executing this instruction before handling the interrupt (whose state is cached).
+// If a breakpoint triggers a BreakpointHit is returned. See BreakpointHit for details.
 func (p *Chip) Tick() error {
+	clocks := p.clocks
+	err := p.tick()
+	p.flushBus()
+	if p.profile != nil && p.clocks != clocks {
+		p.profile.tick(p)
+	}
+	if p.opTrap != nil {
+		trap := *p.opTrap
+		p.opTrap = nil
+		if err == nil {
+			err = trap
+		}
+	}
+	if p.breakHit != nil {
+		hit := *p.breakHit
+		p.breakHit = nil
+		// Any other error (such as a halt) takes precedence.
+		if err == nil {
+			return hit
+		}
+	}
+	return err
+}
+
+// sampleSO checks the SO input (if any) and sets the V flag on a falling edge.
+func (p *Chip) sampleSO() {
+	if p.so == nil {
+		return
+	}
+	in := p.so.Input()
+	if p.soLevel && !in {
+		p.P |= P_OVERFLOW
+	}
+	p.soLevel = in
+}
+
+// tick implements Tick() other than reporting breakpoints triggered during the cycle.
+func (p *Chip) tick() error {
 	if !p.tickDone {
 		p.opDone = true
 		return InvalidCPUState{"called Tick() without calling TickDone() at end of last cycle"}
 	}
 	p.tickDone = false
 
-	// If RDY is held high we do nothing and just return (time doesn't advance in the CPU).
-	// TODO(jchacon): Ok, this technically only works like this in combination with SYNC being held high as well.
-	//                Otherwise it acts like a single step and continues after the next clock.
-	//                But, the only use known right now was atari 2600 which tied SYNC high and RDY low at the same
-	//                time so "good enough".
-	if p.rdy != nil && p.rdy.Raised() {
+	// SO is edge triggered and sampled every cycle (even if RDY is held).
+	p.sampleSO()
+
+	// If RDY is held high and this cycle stops for it we do nothing and just return (time doesn't advance in the CPU).
+	// See rdyStall for which cycles stop.
+	if p.rdy != nil && p.rdy.Raised() && p.rdyStall() {
+		if p.profile != nil {
+			p.profile.stall(p)
+		}
 		return nil
 	}
-	p.clocks++
+	return p.runCycle()
+}
 
-	// Institute delay up front since we can return in N places below.
-	times := p.timeRuns
-	if p.timeNeedAdjust {
-		// Only add time if incrementing tick didn't jump by more than a single digit.
-		// i.e. if we're at 1.3 we tick at 0, 1.3, 2.6, 3.9 but not 5.2 as a result.
-		o := int(p.timerTicks) + 1
-		p.timerTicks += p.timeAdjustCnt
-		if o != int(p.timerTicks) {
-			times++
-		}
-		if int(p.timerTicks) >= p.timerTicksReset {
-			p.timerTicks = 0
+// rdyStall returns true if the current cycle stops while RDY is held.
+// NMOS parts only stop on read cycles. Any write cycles (i.e. the pushes in a JSR or the final writes
+// of a RMW instruction) continue and the CPU then stops on the next read. See nextCycleWrites.
+// CMOS parts stop on any cycle.
+// NOTE: While stopped no bus access happens at all. On an opcode fetch SYNC stays high for the whole
+//       time so single step circuits (RDY tied to SYNC) stop before each instruction.
+func (p *Chip) rdyStall() bool {
+	if p.cpuType == CPU_CMOS {
+		return true
+	}
+	return !p.nextCycleWrites()
+}
+
+// nextCycleWrites returns true if the next cycle on an NMOS part is a write. It only looks at the
+// current state so checking has no side effects.
+// Every cycle which doesn't write is a read. On NMOS the write cycles are at fixed ticks for each opcode
+// (indexed stores and RMW instructions always take the fixup tick) so this only needs the descriptor:
+// stores write on their last tick, RMW instructions on their last two (the dummy write and the result)
+// and the kOP_RUN opcodes which write are listed in nmosRunWrites. Interrupts push on ticks 3-5.
+func (p *Chip) nextCycleWrites() bool {
+	// An opcode fetch (or halted which doesn't touch the bus).
+	if p.opTick == 0 || p.halted {
+		return false
+	}
+	tick := p.opTick + 1
+	if p.runningInterrupt {
+		return tick >= 3 && tick <= 5
+	}
+	o := &p.opcodes[p.op]
+	switch o.kind {
+	case kOP_STORE:
+		return tick == o.cycles
+	case kOP_RMW:
+		return tick >= o.cycles-1
+	case kOP_RUN:
+		return nmosRunWrites[p.op]&(1<<uint(tick)) != 0
+	}
+	return false
+}
+
+// runCycle implements the rest of tick() once RDY has been checked.
+func (p *Chip) runCycle() error {
+	// Execution breakpoints stop before the next instruction starts so no clock is used.
+	if len(p.breakpoints) > 0 && p.opTick == 0 && !p.halted && !p.waiting {
+		if err := p.checkExecBreak(); err != nil {
+			return err
 		}
 	}
-	for i := 0; i < times; i++ {
-		_ = time.Now()
+	p.clocks++
+	p.sync = false
+
+	// Pace up front since we can return in N places below.
+	if p.pacer != nil {
+		p.pacer.Tick(1)
 	}
 	if p.irqRaised < kIRQ_NONE || p.irqRaised >= kIRQ_MAX {
 		p.opDone = true
@@ -406,11 +630,6 @@ func (p *Chip) Tick() error {
 		return HaltOpcode{p.haltOpcode}
 	}
 
-	// Increment up front so we're not zero based per se. i.e. each new instruction then
-	// starts at opTick == 1.
-	p.opTick++
-
-	// If we get a new interrupt while running one then NMI always wins until it's done.
 	var irq, nmi bool
 	if p.irq != nil {
 		irq = p.irq.Raised()
@@ -418,6 +637,20 @@ func (p *Chip) Tick() error {
 	if p.nmi != nil {
 		nmi = p.nmi.Raised()
 	}
+	// After a WAI we stay idle until an interrupt comes in. Then processing continues as normal.
+	if p.waiting {
+		if !irq && !nmi {
+			return nil
+		}
+		p.waiting = false
+	}
+
+	// Increment up front so we're not zero based per se. i.e. each new instruction then
+	// starts at opTick == 1.
+	p.opTick++
+
+	// If we get a new interrupt while running one then NMI always wins until it's done.
+	before := p.irqRaised
 	if irq || nmi {
 		switch p.irqRaised {
 		case kIRQ_NONE:
@@ -432,14 +665,26 @@ func (p *Chip) Tick() error {
 		}
 	}
 
+	if p.ints != nil {
+		p.ints.raised(p, before, p.clocks, p.opTick > 1)
+	}
+
 	switch {
 	case p.opTick == 1:
 		// If opTick is 1 it means we're starting a new instruction based on the PC value so grab the opcode now.
-		p.op = p.ram.Read(p.PC)
+		p.opPC = p.PC
+		p.sync = true
+		p.busKind = BUS_OPCODE
+		if p.irqRaised != kIRQ_NONE && !p.skipInterrupt {
+			// The opcode is thrown away and the interrupt sequence runs instead.
+			p.busKind = BUS_INTERRUPT
+		}
+		p.op = p.read(p.PC)
 
 		// Reset done state
 		p.opDone = false
 		p.addrDone = false
+		p.extraTick = false
 
 		// PC always advances on every opcode start except IRQ/HMI (unless we're skipping to run one more instruction).
 		if p.irqRaised == kIRQ_NONE || p.skipInterrupt {
@@ -449,13 +694,53 @@ func (p *Chip) Tick() error {
 		if p.irqRaised != kIRQ_NONE && !p.skipInterrupt {
 			p.runningInterrupt = true
 		}
-		return nil
+		if p.ints != nil {
+			p.ints.opcode(p)
+		}
+		var traceErr error
+		if p.trace != nil && !p.runningInterrupt {
+			traceErr = p.traceInstruction()
+		}
+		if len(p.breakpoints) > 0 {
+			switch {
+			case p.runningInterrupt && p.irqRaised == kIRQ_NMI:
+				p.checkBreak(BREAK_NMI, 0, 0)
+			case p.runningInterrupt:
+				p.checkBreak(BREAK_IRQ, 0, 0)
+			case p.op == 0x00:
+				p.checkBreak(BREAK_BRK, 0, 0)
+			}
+		}
+		if p.opChecks && !p.runningInterrupt {
+			// A trap is reported once this cycle is done and the next Tick() carries on with the opcode.
+			if trap := p.checkOpcode(); trap != nil {
+				p.opTrap = trap
+			}
+		}
+		// The CMOS 1 byte NOPs (columns 3 and B except WAI/STP) complete in this tick.
+		if !p.runningInterrupt && p.opcodes[p.op].cycles == 1 {
+			p.prevSkipInterrupt = false
+			if p.skipInterrupt {
+				p.skipInterrupt = false
+				p.prevSkipInterrupt = true
+			}
+			p.opDone = true
+			p.opTick = 0
+			if p.history != nil {
+				p.history.boundary(p)
+			}
+		}
+		return traceErr
 	case p.opTick == 2:
 		// All instructions fetch the value after the opcode (though some like BRK/PHP/etc ignore it).
 		// We keep it since some instructions such as absolute addr then require getting one
 		// more byte. So cache at this stage since we no idea if it's needed.
 		// NOTE: the PC doesn't increment here as that's dependent on addressing mode which will handle it.
-		p.opVal = p.ram.Read(p.PC)
+		p.busKind = BUS_OPERAND
+		if p.runningInterrupt {
+			p.busKind = BUS_INTERRUPT
+		}
+		p.opVal = p.read(p.PC)
 
 		// We've started a new instruction so no longer skipping interrupt processing.
 		p.prevSkipInterrupt = false
@@ -494,6 +779,9 @@ func (p *Chip) Tick() error {
 		return err
 	}
 	if p.opDone {
+		if p.calls != nil {
+			p.calls.done(p)
+		}
 		// So the next tick starts a new instruction
 		// It'll handle doing start of instruction reset on state (which includes resetting p.opDone, p.addrDone).
 		p.opTick = 0
@@ -502,6 +790,9 @@ func (p *Chip) Tick() error {
 			p.irqRaised = kIRQ_NONE
 		}
 		p.runningInterrupt = false
+		if p.history != nil {
+			p.history.boundary(p)
+		}
 	}
 	return nil
 }
@@ -519,795 +810,162 @@ func (p *Chip) InstructionDone() bool {
 	return p.opDone
 }
 
-func (p *Chip) processOpcode() (bool, error) {
-	// Opcode matric taken from:
-	// http://wiki.nesdev.com/w/index.php/CPU_unofficial_opcodes#Games_using_unofficial_opcodes
-	//
-	// NOTE: The above lists 0xAB as LAX #i but we call it OAL since it has odd behavior and needs
-	//       it's own code compared to other LAX. See 6502-NMOS.extra.opcodes below.
-	//
-	// Description of undocumented opcodes:
-	//
-	// http://www.ffd2.com/fridge/docs/6502-NMOS.extra.opcodes
-	// http://nesdev.com/6502_cpu.txt
-	// http://visual6502.org/wiki/index.php?title=6502_Opcode_8B_(XAA,_ANE)
-	//
-	// Opcode descriptions/timing/etc:
-	// http://obelisk.me.uk/6502/reference.html
+// Clocks returns the total number of clock cycles run since power on.
+func (p *Chip) Clocks() int {
+	return p.clocks
+}
 
-	var err error
+// StepInfo describes the instruction (or interrupt sequence) run by Step.
+type StepInfo struct {
+	PC        uint16 // The PC value the instruction (or interrupt) started at.
+	Opcode    uint8  // The opcode run. For an interrupt this is the opcode fetched (and then discarded) at PC.
+	Interrupt bool   // True if this was an IRQ/NMI sequence instead of an instruction.
+	Cycles    int    // The number of clock cycles run during this call. Ticks where RDY held the CPU aren't counted.
+	Idle      bool   // True if Step returned before the instruction finished since RDY is holding the CPU or it's waiting (WAI) for an interrupt.
+}
 
-	switch p.op {
-	case 0x00:
-		// BRK #i
-		p.opDone, err = p.iBRK()
-	case 0x01:
-		// ORA (d,x)
-		p.opDone, err = p.loadInstruction(p.addrIndirectX, p.iORA)
-	case 0x02:
-		// HLT
-		p.halted = true
-	case 0x03:
-		// SLO (d,x)
-		p.opDone, err = p.rmwInstruction(p.addrIndirectX, p.iSLO)
-	case 0x04:
-		// NOP d
-		p.opDone, err = p.addrZP(kLOAD_INSTRUCTION)
-	case 0x05:
-		// ORA d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.iORA)
-	case 0x06:
-		// ASL d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iASL)
-	case 0x07:
-		// SLO d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iSLO)
-	case 0x08:
-		// PHP
-		p.opDone, err = p.iPHP()
-	case 0x09:
-		// ORA #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iORA)
-	case 0x0A:
-		// ASL
-		p.opDone, err = p.iASLAcc()
-	case 0x0B:
-		// ANC #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iANC)
-	case 0x0C:
-		// NOP a
-		p.opDone, err = p.addrAbsolute(kLOAD_INSTRUCTION)
-	case 0x0D:
-		// ORA a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.iORA)
-	case 0x0E:
-		// ASL a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iASL)
-	case 0x0F:
-		// SLO a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iSLO)
-	case 0x10:
-		// BPL *+r
-		p.opDone, err = p.iBPL()
-	case 0x11:
-		// ORA (d),y
-		p.opDone, err = p.loadInstruction(p.addrIndirectY, p.iORA)
-	case 0x12:
-		// HLT
-		p.halted = true
-	case 0x13:
-		// SLO (d),y
-		p.opDone, err = p.rmwInstruction(p.addrIndirectY, p.iSLO)
-	case 0x14:
-		// NOP d,x
-		p.opDone, err = p.addrZPX(kLOAD_INSTRUCTION)
-	case 0x15:
-		// ORA d,x
-		p.opDone, err = p.loadInstruction(p.addrZPX, p.iORA)
-	case 0x16:
-		// ASL d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iASL)
-	case 0x17:
-		// SLO d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iSLO)
-	case 0x18:
-		// CLC
-		p.opDone, err = p.iCLC()
-	case 0x19:
-		// ORA a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.iORA)
-	case 0x1A:
-		// NOP
-		p.opDone, err = true, nil
-	case 0x1B:
-		// SLO a,y
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteY, p.iSLO)
-	case 0x1C:
-		// NOP a,x
-		p.opDone, err = p.addrAbsoluteX(kLOAD_INSTRUCTION)
-	case 0x1D:
-		// ORA a,x
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteX, p.iORA)
-	case 0x1E:
-		// ASL a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iASL)
-	case 0x1F:
-		// SLO a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iSLO)
-	case 0x20:
-		// JSR a
-		p.opDone, err = p.iJSR()
-	case 0x21:
-		// AND (d,x)
-		p.opDone, err = p.loadInstruction(p.addrIndirectX, p.iAND)
-	case 0x22:
-		// HLT
-		p.halted = true
-	case 0x23:
-		// RLA (d,x)
-		p.opDone, err = p.rmwInstruction(p.addrIndirectX, p.iRLA)
-	case 0x24:
-		// BIT d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.iBIT)
-	case 0x25:
-		// AND d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.iAND)
-	case 0x26:
-		// ROL d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iROL)
-	case 0x27:
-		// RLA d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iRLA)
-	case 0x28:
-		// PLP
-		p.opDone, err = p.iPLP()
-	case 0x29:
-		// AND #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iAND)
-	case 0x2A:
-		// ROL
-		p.opDone, err = p.iROLAcc()
-	case 0x2B:
-		// ANC #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iANC)
-	case 0x2C:
-		// BIT a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.iBIT)
-	case 0x2D:
-		// AND a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.iAND)
-	case 0x2E:
-		// ROL a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iROL)
-	case 0x2F:
-		// RLA a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iRLA)
-	case 0x30:
-		// BMI *+r
-		p.opDone, err = p.iBMI()
-	case 0x31:
-		// AND (d),y
-		p.opDone, err = p.loadInstruction(p.addrIndirectY, p.iAND)
-	case 0x32:
-		// HLT
-		p.halted = true
-	case 0x33:
-		// RLA (d),y
-		p.opDone, err = p.rmwInstruction(p.addrIndirectY, p.iRLA)
-	case 0x34:
-		// NOP d,x
-		p.opDone, err = p.addrZPX(kLOAD_INSTRUCTION)
-	case 0x35:
-		// AND d,x
-		p.opDone, err = p.loadInstruction(p.addrZPX, p.iAND)
-	case 0x36:
-		// ROL d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iROL)
-	case 0x37:
-		// RLA d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iRLA)
-	case 0x38:
-		// SEC
-		p.opDone, err = p.iSEC()
-	case 0x39:
-		// AND a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.iAND)
-	case 0x3A:
-		// NOP
-		p.opDone, err = true, nil
-	case 0x3B:
-		// RLA a,y
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteY, p.iRLA)
-	case 0x3C:
-		// NOP a,x
-		p.opDone, err = p.addrAbsoluteX(kLOAD_INSTRUCTION)
-	case 0x3D:
-		// AND a,x
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteX, p.iAND)
-	case 0x3E:
-		// ROL a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iROL)
-	case 0x3F:
-		// RLA a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iRLA)
-	case 0x40:
-		// RTI
-		p.opDone, err = p.iRTI()
-	case 0x41:
-		// EOR (d,x)
-		p.opDone, err = p.loadInstruction(p.addrIndirectX, p.iEOR)
-	case 0x42:
-		// HLT
-		p.halted = true
-	case 0x43:
-		// SRE (d,x)
-		p.opDone, err = p.rmwInstruction(p.addrIndirectX, p.iSRE)
-	case 0x44:
-		// NOP d
-		p.opDone, err = p.addrZP(kLOAD_INSTRUCTION)
-	case 0x45:
-		// EOR d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.iEOR)
-	case 0x46:
-		// LSR d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iLSR)
-	case 0x47:
-		// SRE d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iSRE)
-	case 0x48:
-		// PHA
-		p.opDone, err = p.iPHA()
-	case 0x49:
-		// EOR #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iEOR)
-	case 0x4A:
-		// LSR
-		p.opDone, err = p.iLSRAcc()
-	case 0x4B:
-		// ALR #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iALR)
-	case 0x4C:
-		// JMP a
-		p.opDone, err = p.iJMP()
-	case 0x4D:
-		// EOR a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.iEOR)
-	case 0x4E:
-		// LSR a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iLSR)
-	case 0x4F:
-		// SRE a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iSRE)
-	case 0x50:
-		// BVC *+r
-		p.opDone, err = p.iBVC()
-	case 0x51:
-		// EOR (d),y
-		p.opDone, err = p.loadInstruction(p.addrIndirectY, p.iEOR)
-	case 0x52:
-		// HLT
-		p.halted = true
-	case 0x53:
-		// SRE (d),y
-		p.opDone, err = p.rmwInstruction(p.addrIndirectY, p.iSRE)
-	case 0x54:
-		// NOP d,x
-		p.opDone, err = p.addrZPX(kLOAD_INSTRUCTION)
-	case 0x55:
-		// EOR d,x
-		p.opDone, err = p.loadInstruction(p.addrZPX, p.iEOR)
-	case 0x56:
-		// LSR d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iLSR)
-	case 0x57:
-		// SRE d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iSRE)
-	case 0x58:
-		// CLI
-		p.opDone, err = p.iCLI()
-	case 0x59:
-		// EOR a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.iEOR)
-	case 0x5A:
-		// NOP
-		p.opDone, err = true, nil
-	case 0x5B:
-		// SRE a,y
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteY, p.iSRE)
-	case 0x5C:
-		// NOP a,x
-		p.opDone, err = p.addrAbsoluteX(kLOAD_INSTRUCTION)
-	case 0x5D:
-		// EOR a,x
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteX, p.iEOR)
-	case 0x5E:
-		// LSR a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iLSR)
-	case 0x5F:
-		// SRE a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iSRE)
-	case 0x60:
-		// RTS
-		p.opDone, err = p.iRTS()
-	case 0x61:
-		// ADC (d,x)
-		p.opDone, err = p.loadInstruction(p.addrIndirectX, p.iADC)
-	case 0x62:
-		// HLT
-		p.halted = true
-	case 0x63:
-		// RRA (d,x)
-		p.opDone, err = p.rmwInstruction(p.addrIndirectX, p.iRRA)
-	case 0x64:
-		// NOP d
-		p.opDone, err = p.addrZP(kLOAD_INSTRUCTION)
-	case 0x65:
-		// ADC d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.iADC)
-	case 0x66:
-		// ROR d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iROR)
-	case 0x67:
-		// RRA d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iRRA)
-	case 0x68:
-		// PLA
-		p.opDone, err = p.iPLA()
-	case 0x69:
-		// ADC #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iADC)
-	case 0x6A:
-		// ROR
-		p.opDone, err = p.iRORAcc()
-	case 0x6B:
-		// ARR #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iARR)
-	case 0x6C:
-		// JMP (a)
-		p.opDone, err = p.iJMPIndirect()
-	case 0x6D:
-		// ADC a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.iADC)
-	case 0x6E:
-		// ROR a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iROR)
-	case 0x6F:
-		// RRA a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iRRA)
-	case 0x70:
-		// BVS *+r
-		p.opDone, err = p.iBVS()
-	case 0x71:
-		// ADC (d),y
-		p.opDone, err = p.loadInstruction(p.addrIndirectY, p.iADC)
-	case 0x72:
-		// HLT
-		p.halted = true
-	case 0x73:
-		// RRA (d),y
-		p.opDone, err = p.rmwInstruction(p.addrIndirectY, p.iRRA)
-	case 0x74:
-		// NOP d,x
-		p.opDone, err = p.addrZPX(kLOAD_INSTRUCTION)
-	case 0x75:
-		// ADC d,x
-		p.opDone, err = p.loadInstruction(p.addrZPX, p.iADC)
-	case 0x76:
-		// ROR d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iROR)
-	case 0x77:
-		// RRA d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iRRA)
-	case 0x78:
-		// SEI
-		p.opDone, err = p.iSEI()
-	case 0x79:
-		// ADC a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.iADC)
-	case 0x7A:
-		// NOP
-		p.opDone, err = true, nil
-	case 0x7B:
-		// RRA a,y
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteY, p.iRRA)
-	case 0x7C:
-		// NOP a,x
-		p.opDone, err = p.addrAbsoluteX(kLOAD_INSTRUCTION)
-	case 0x7D:
-		// ADC a,x
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteX, p.iADC)
-	case 0x7E:
-		// ROR a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iROR)
-	case 0x7F:
-		// RRA a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iRRA)
-	case 0x80:
-		// NOP #i
-		p.opDone, err = p.addrImmediate(kLOAD_INSTRUCTION)
-	case 0x81:
-		// STA (d,x)
-		p.opDone, err = p.storeInstruction(p.addrIndirectX, p.A)
-	case 0x82:
-		// NOP #i
-		p.opDone, err = p.addrImmediate(kLOAD_INSTRUCTION)
-	case 0x83:
-		// SAX (d,x)
-		p.opDone, err = p.storeInstruction(p.addrIndirectX, p.A&p.X)
-	case 0x84:
-		// STY d
-		p.opDone, err = p.storeInstruction(p.addrZP, p.Y)
-	case 0x85:
-		// STA d
-		p.opDone, err = p.storeInstruction(p.addrZP, p.A)
-	case 0x86:
-		// STX d
-		p.opDone, err = p.storeInstruction(p.addrZP, p.X)
-	case 0x87:
-		// SAX d
-		p.opDone, err = p.storeInstruction(p.addrZP, p.A&p.X)
-	case 0x88:
-		// DEY
-		p.opDone, err = p.loadRegister(&p.Y, p.Y-1)
-	case 0x89:
-		// NOP #i
-		p.opDone, err = p.addrImmediate(kLOAD_INSTRUCTION)
-	case 0x8A:
-		// TXA
-		p.opDone, err = p.loadRegister(&p.A, p.X)
-	case 0x8B:
-		// XAA #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iXAA)
-	case 0x8C:
-		// STY a
-		p.opDone, err = p.storeInstruction(p.addrAbsolute, p.Y)
-	case 0x8D:
-		// STA a
-		p.opDone, err = p.storeInstruction(p.addrAbsolute, p.A)
-	case 0x8E:
-		// STX a
-		p.opDone, err = p.storeInstruction(p.addrAbsolute, p.X)
-	case 0x8F:
-		// SAX a
-		p.opDone, err = p.storeInstruction(p.addrAbsolute, p.A&p.X)
-	case 0x90:
-		// BCC *+d
-		p.opDone, err = p.iBCC()
-	case 0x91:
-		// STA (d),y
-		p.opDone, err = p.storeInstruction(p.addrIndirectY, p.A)
-	case 0x92:
-		// HLT
-		p.halted = true
-	case 0x93:
-		// AHX (d),y
-		p.opDone, err = p.iAHX(p.addrIndirectY)
-	case 0x94:
-		// STY d,x
-		p.opDone, err = p.storeInstruction(p.addrZPX, p.Y)
-	case 0x95:
-		// STA d,x
-		p.opDone, err = p.storeInstruction(p.addrZPX, p.A)
-	case 0x96:
-		// STX d,y
-		p.opDone, err = p.storeInstruction(p.addrZPY, p.X)
-	case 0x97:
-		// SAX d,y
-		p.opDone, err = p.storeInstruction(p.addrZPY, p.A&p.X)
-	case 0x98:
-		// TYA
-		p.opDone, err = p.loadRegister(&p.A, p.Y)
-	case 0x99:
-		// STA a,y
-		p.opDone, err = p.storeInstruction(p.addrAbsoluteY, p.A)
-	case 0x9A:
-		// TXS
-		p.opDone, err, p.S = true, nil, p.X
-	case 0x9B:
-		// TAS a,y
-		p.opDone, err = p.iTAS()
-	case 0x9C:
-		// SHY a,x
-		p.opDone, err = p.iSHY(p.addrAbsoluteX)
-	case 0x9D:
-		// STA a,x
-		p.opDone, err = p.storeInstruction(p.addrAbsoluteX, p.A)
-	case 0x9E:
-		// SHX a,y
-		p.opDone, err = p.iSHX(p.addrAbsoluteY)
-	case 0x9F:
-		// AHX a,y
-		p.opDone, err = p.iAHX(p.addrAbsoluteY)
-	case 0xA0:
-		// LDY #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.loadRegisterY)
-	case 0xA1:
-		// LDA (d,x)
-		p.opDone, err = p.loadInstruction(p.addrIndirectX, p.loadRegisterA)
-	case 0xA2:
-		// LDX #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.loadRegisterX)
-	case 0xA3:
-		// LAX (d,x)
-		p.opDone, err = p.loadInstruction(p.addrIndirectX, p.iLAX)
-	case 0xA4:
-		// LDY d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.loadRegisterY)
-	case 0xA5:
-		// LDA d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.loadRegisterA)
-	case 0xA6:
-		// LDX d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.loadRegisterX)
-	case 0xA7:
-		// LAX d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.iLAX)
-	case 0xA8:
-		// TAY
-		p.opDone, err = p.loadRegister(&p.Y, p.A)
-	case 0xA9:
-		// LDA #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.loadRegisterA)
-	case 0xAA:
-		// TAX
-		p.opDone, err = p.loadRegister(&p.X, p.A)
-	case 0xAB:
-		// OAL #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iOAL)
-	case 0xAC:
-		// LDY a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.loadRegisterY)
-	case 0xAD:
-		// LDA a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.loadRegisterA)
-	case 0xAE:
-		// LDX a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.loadRegisterX)
-	case 0xAF:
-		// LAX a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.iLAX)
-	case 0xB0:
-		// BCS *+d
-		p.opDone, err = p.iBCS()
-	case 0xB1:
-		// LDA (d),y
-		p.opDone, err = p.loadInstruction(p.addrIndirectY, p.loadRegisterA)
-	case 0xB2:
-		// HLT
-		p.halted = true
-	case 0xB3:
-		// LAX (d),y
-		p.opDone, err = p.loadInstruction(p.addrIndirectY, p.iLAX)
-	case 0xB4:
-		// LDY d,x
-		p.opDone, err = p.loadInstruction(p.addrZPX, p.loadRegisterY)
-	case 0xB5:
-		// LDA d,x
-		p.opDone, err = p.loadInstruction(p.addrZPX, p.loadRegisterA)
-	case 0xB6:
-		// LDX d,y
-		p.opDone, err = p.loadInstruction(p.addrZPY, p.loadRegisterX)
-	case 0xB7:
-		// LAX d,y
-		p.opDone, err = p.loadInstruction(p.addrZPY, p.iLAX)
-	case 0xB8:
-		// CLV
-		p.opDone, err = p.iCLV()
-	case 0xB9:
-		// LDA a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.loadRegisterA)
-	case 0xBA:
-		// TSX
-		p.opDone, err = p.loadRegister(&p.X, p.S)
-	case 0xBB:
-		// LAS a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.iLAS)
-	case 0xBC:
-		// LDY a,x
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteX, p.loadRegisterY)
-	case 0xBD:
-		// LDA a,x
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteX, p.loadRegisterA)
-	case 0xBE:
-		// LDX a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.loadRegisterX)
-	case 0xBF:
-		// LAX a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.iLAX)
-	case 0xC0:
-		// CPY #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.compareY)
-	case 0xC1:
-		// CMP (d,x)
-		p.opDone, err = p.loadInstruction(p.addrIndirectX, p.compareA)
-	case 0xC2:
-		// NOP #i
-		p.opDone, err = p.addrImmediate(kLOAD_INSTRUCTION)
-	case 0xC3:
-		// DCP (d,X)
-		p.opDone, err = p.rmwInstruction(p.addrIndirectX, p.iDCP)
-	case 0xC4:
-		// CPY d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.compareY)
-	case 0xC5:
-		// CMP d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.compareA)
-	case 0xC6:
-		// DEC d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iDEC)
-	case 0xC7:
-		// DCP d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iDCP)
-	case 0xC8:
-		// INY
-		p.opDone, err = p.loadRegister(&p.Y, p.Y+1)
-	case 0xC9:
-		// CMP #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.compareA)
-	case 0xCA:
-		// DEX
-		p.opDone, err = p.loadRegister(&p.X, p.X-1)
-	case 0xCB:
-		// AXS #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iAXS)
-	case 0xCC:
-		// CPY a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.compareY)
-	case 0xCD:
-		// CMP a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.compareA)
-	case 0xCE:
-		// DEC a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iDEC)
-	case 0xCF:
-		// DCP a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iDCP)
-	case 0xD0:
-		// BNE *+r
-		p.opDone, err = p.iBNE()
-	case 0xD1:
-		// CMP (d),y
-		p.opDone, err = p.loadInstruction(p.addrIndirectY, p.compareA)
-	case 0xD2:
-		// HLT
-		p.halted = true
-	case 0xD3:
-		// DCP (d),y
-		p.opDone, err = p.rmwInstruction(p.addrIndirectY, p.iDCP)
-	case 0xD4:
-		// NOP d,x
-		p.opDone, err = p.addrZPX(kLOAD_INSTRUCTION)
-	case 0xD5:
-		// CMP d,x
-		p.opDone, err = p.loadInstruction(p.addrZPX, p.compareA)
-	case 0xD6:
-		// DEC d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iDEC)
-	case 0xD7:
-		// DCP d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iDCP)
-	case 0xD8:
-		// CLD
-		p.opDone, err = p.iCLD()
-	case 0xD9:
-		// CMP a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.compareA)
-	case 0xDA:
-		// NOP
-		p.opDone, err = true, nil
-	case 0xDB:
-		// DCP a,y
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteY, p.iDCP)
-	case 0xDC:
-		// NOP a,x
-		p.opDone, err = p.addrAbsoluteX(kLOAD_INSTRUCTION)
-	case 0xDD:
-		// CMP a,x
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteX, p.compareA)
-	case 0xDE:
-		// DEC a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iDEC)
-	case 0xDF:
-		// DCP a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iDCP)
-	case 0xE0:
-		// CPX #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.compareX)
-	case 0xE1:
-		// SBC (d,x)
-		p.opDone, err = p.loadInstruction(p.addrIndirectX, p.iSBC)
-	case 0xE2:
-		// NOP #i
-		p.opDone, err = p.addrImmediate(kLOAD_INSTRUCTION)
-	case 0xE3:
-		// ISC (d,x)
-		p.opDone, err = p.rmwInstruction(p.addrIndirectX, p.iISC)
-	case 0xE4:
-		// CPX d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.compareX)
-	case 0xE5:
-		// SBC d
-		p.opDone, err = p.loadInstruction(p.addrZP, p.iSBC)
-	case 0xE6:
-		// INC d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iINC)
-	case 0xE7:
-		// ISC d
-		p.opDone, err = p.rmwInstruction(p.addrZP, p.iISC)
-	case 0xE8:
-		// INX
-		p.opDone, err = p.loadRegister(&p.X, p.X+1)
-	case 0xE9:
-		// SBC #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iSBC)
-	case 0xEA:
-		// NOP
-		p.opDone, err = true, nil
-	case 0xEB:
-		// SBC #i
-		p.opDone, err = p.loadInstruction(p.addrImmediate, p.iSBC)
-	case 0xEC:
-		// CPX a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.compareX)
-	case 0xED:
-		// SBC a
-		p.opDone, err = p.loadInstruction(p.addrAbsolute, p.iSBC)
-	case 0xEE:
-		// INC a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iINC)
-	case 0xEF:
-		// ISC a
-		p.opDone, err = p.rmwInstruction(p.addrAbsolute, p.iISC)
-	case 0xF0:
-		// BEQ *+d
-		p.opDone, err = p.iBEQ()
-	case 0xF1:
-		// SBC (d),y
-		p.opDone, err = p.loadInstruction(p.addrIndirectY, p.iSBC)
-	case 0xF2:
-		// HLT
-		p.halted = true
-	case 0xF3:
-		// ISC (d),y
-		p.opDone, err = p.rmwInstruction(p.addrIndirectY, p.iISC)
-	case 0xF4:
-		// NOP d,x
-		p.opDone, err = p.addrZPX(kLOAD_INSTRUCTION)
-	case 0xF5:
-		// SBC d,x
-		p.opDone, err = p.loadInstruction(p.addrZPX, p.iSBC)
-	case 0xF6:
-		// INC d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iINC)
-	case 0xF7:
-		// ISC d,x
-		p.opDone, err = p.rmwInstruction(p.addrZPX, p.iISC)
-	case 0xF8:
-		// SED
-		p.opDone, err = p.iSED()
-	case 0xF9:
-		// SBC a,y
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteY, p.iSBC)
-	case 0xFA:
-		// NOP
-		p.opDone, err = true, nil
-	case 0xFB:
-		// ISC a,y
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteY, p.iISC)
-	case 0xFC:
-		// NOP a,x
-		p.opDone, err = p.addrAbsoluteX(kLOAD_INSTRUCTION)
-	case 0xFD:
-		// SBC a,x
-		p.opDone, err = p.loadInstruction(p.addrAbsoluteX, p.iSBC)
-	case 0xFE:
-		// INC a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iINC)
-	case 0xFF:
-		// ISC a,x
-		p.opDone, err = p.rmwInstruction(p.addrAbsoluteX, p.iISC)
-	}
-	return p.opDone, err
+// Step runs Tick()/TickDone() until the current instruction (or interrupt sequence) completes and returns
+// information about it. If an error occurs (including halts) it's returned along with the state so far.
+// Step doesn't spin waiting on external state. If a tick makes no progress since RDY is held or the CPU
+// is idle after a WAI, Step returns immediately with Idle set. The next call continues the same
+// instruction (and reports the same PC/Opcode) once the CPU can proceed.
+// If CORE_FAST is selected (see SetCore) instructions are run by that instead.
+func (p *Chip) Step() (StepInfo, error) {
+	if p.core == CORE_FAST && p.opTick == 0 {
+		return p.stepFast()
+	}
+	var info StepInfo
+	for {
+		clocks := p.clocks
+		waiting := p.waiting
+		// Check before and after since the final tick of an interrupt clears this.
+		interrupt := p.runningInterrupt
+		err := p.Tick()
+		p.TickDone()
+		info.Cycles += p.clocks - clocks
+		info.PC = p.opPC
+		info.Opcode = p.op
+		if interrupt || p.runningInterrupt {
+			info.Interrupt = true
+		}
+		if err != nil {
+			return info, err
+		}
+		if p.clocks == clocks || (waiting && p.waiting) {
+			info.Idle = true
+			return info, nil
+		}
+		if p.opDone {
+			return info, nil
+		}
+	}
+}
+
+// RunCycles runs Tick()/TickDone() n times and returns the number of clock cycles the CPU actually ran.
+// This will be less than n if RDY held the CPU on any of them. It stops early if an error occurs.
+func (p *Chip) RunCycles(n int) (int, error) {
+	clocks := p.clocks
+	for i := 0; i < n; i++ {
+		err := p.Tick()
+		p.TickDone()
+		if err != nil {
+			return p.clocks - clocks, err
+		}
+	}
+	return p.clocks - clocks, nil
+}
+
+// RunUntil calls Step until pred returns true (it's checked after each call) or an error occurs.
+// It returns the total number of clock cycles run.
+// NOTE: Since Step returns when the CPU is idle pred is also checked while RDY is held or during a WAI.
+//       If neither will ever be released pred must account for that or this never returns.
+func (p *Chip) RunUntil(pred func(*Chip) bool) (int, error) {
+	var cycles int
+	for {
+		info, err := p.Step()
+		cycles += info.Cycles
+		if err != nil {
+			return cycles, err
+		}
+		if pred(p) {
+			return cycles, nil
+		}
+	}
+}
+
+// read returns the value at the given address.
+// On a 6510 addresses 0x0000 and 0x0001 are the I/O port registers and never
+// go out to memory.
+func (p *Chip) read(addr uint16) uint8 {
+	addr &= p.addrMask
+	var val uint8
+	if p.cpuType == CPU_NMOS_6510 && addr <= kPORT_DATA {
+		val = p.portRead(addr)
+	} else {
+		val = p.ram.Read(addr)
+	}
+	p.logBus(addr, val, false)
+	if len(p.breakpoints) > 0 {
+		p.checkBreak(BREAK_READ, addr, val)
+	}
+	return val
+}
+
+// write stores the value at the given address.
+// On a 6510 addresses 0x0000 and 0x0001 are the I/O port registers and never
+// go out to memory.
+func (p *Chip) write(addr uint16, val uint8) {
+	addr &= p.addrMask
+	if p.history != nil {
+		p.history.write(p, addr, val)
+	}
+	p.logBus(addr, val, true)
+	if len(p.breakpoints) > 0 {
+		p.checkBreak(BREAK_WRITE, addr, val)
+	}
+	if p.cpuType == CPU_NMOS_6510 && addr <= kPORT_DATA {
+		p.portWrite(addr, val)
+		return
+	}
+	p.ram.Write(addr, val)
+}
+
+// portRead returns the value of the 6510 I/O port registers.
+// Reading data returns the output value for any pins set as output and the input
+// value for the rest. Bits 6/7 have no pins so as input they return the last value
+// they output until it decays.
+func (p *Chip) portRead(addr uint16) uint8 {
+	if addr == kPORT_DDR {
+		return p.portDDR
+	}
+	in := uint8(0xFF)
+	if p.portInput != nil {
+		in = p.portInput.Input()
+	}
+	val := (p.portData & p.portDDR) | (in & ^p.portDDR &^ kPORT_FLOAT_MASK)
+	for i, bit := range []uint8{0x40, 0x80} {
+		if p.portDDR&bit == 0x00 && p.clocks < p.portFloatEnd[i] {
+			val |= p.portFloat & bit
+		}
+	}
+	return val
+}
+
+// portWrite updates the 6510 I/O port registers.
+// Any of bits 6/7 being switched from output to input latch their current output value
+// which then slowly decays (see portRead).
+func (p *Chip) portWrite(addr uint16, val uint8) {
+	if addr == kPORT_DDR {
+		for i, bit := range []uint8{0x40, 0x80} {
+			if p.portDDR&bit != 0x00 && val&bit == 0x00 {
+				p.portFloat = (p.portFloat &^ bit) | (p.portData & bit)
+				p.portFloatEnd[i] = p.clocks + kPORT_FLOAT_CYCLES
+			}
+		}
+		p.portDDR = val
+		return
+	}
+	p.portData = val
 }
 
 // zeroCheck sets the Z flag based on the register contents.
@@ -1394,7 +1052,7 @@ func (p *Chip) addrZP(mode instructionMode) (bool, error) {
 		}
 		return done, nil
 	case p.opTick == 3:
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		done := true
 		if mode == kRMW_INSTRUCTION {
 			done = false
@@ -1402,7 +1060,7 @@ func (p *Chip) addrZP(mode instructionMode) (bool, error) {
 		return done, nil
 	}
 	// case p.opTick == 4:
-	p.ram.Write(p.opAddr, p.opVal)
+	p.rmwDummy()
 	return true, nil
 }
 
@@ -1441,7 +1099,7 @@ func (p *Chip) addrZPXY(mode instructionMode, reg uint8) (bool, error) {
 		return false, nil
 	case p.opTick == 3:
 		// Read from the ZP addr and then add the register for the real read later.
-		_ = p.ram.Read(p.opAddr)
+		_ = p.readAs(BUS_DUMMY, p.opAddr)
 		// Does this as a uint8 so it wraps as needed.
 		p.opAddr = uint16(uint8(p.opVal + reg))
 		done := false
@@ -1452,7 +1110,7 @@ func (p *Chip) addrZPXY(mode instructionMode, reg uint8) (bool, error) {
 		return done, nil
 	case p.opTick == 4:
 		// Now read from the final address.
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		done := true
 		if mode == kRMW_INSTRUCTION {
 			done = false
@@ -1460,7 +1118,7 @@ func (p *Chip) addrZPXY(mode instructionMode, reg uint8) (bool, error) {
 		return done, nil
 	}
 	// case p.opTick == 5:
-	p.ram.Write(p.opAddr, p.opVal)
+	p.rmwDummy()
 	return true, nil
 }
 
@@ -1482,18 +1140,18 @@ func (p *Chip) addrIndirectX(mode instructionMode) (bool, error) {
 		return false, nil
 	case p.opTick == 3:
 		// Read from the ZP addr. We'll add the X register as well for the real read next.
-		_ = p.ram.Read(p.opAddr)
+		_ = p.readAs(BUS_DUMMY, p.opAddr)
 		// Does this as a uint8 so it wraps as needed.
 		p.opAddr = uint16(uint8(p.opVal + p.X))
 		return false, nil
 	case p.opTick == 4:
 		// Read effective addr low byte.
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		// Setup opAddr for next read and handle wrapping
 		p.opAddr = uint16(uint8(p.opAddr&0x00FF) + 1)
 		return false, nil
 	case p.opTick == 5:
-		p.opAddr = (uint16(p.ram.Read(p.opAddr)) << 8) + uint16(p.opVal)
+		p.opAddr = (uint16(p.read(p.opAddr)) << 8) + uint16(p.opVal)
 		done := false
 		// For a store we're done since we have the address needed.
 		if mode == kSTORE_INSTRUCTION {
@@ -1501,7 +1159,7 @@ func (p *Chip) addrIndirectX(mode instructionMode) (bool, error) {
 		}
 		return done, nil
 	case p.opTick == 6:
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		done := true
 		if mode == kRMW_INSTRUCTION {
 			done = false
@@ -1509,7 +1167,7 @@ func (p *Chip) addrIndirectX(mode instructionMode) (bool, error) {
 		return done, nil
 	}
 	// case p.opTick == 7:
-	p.ram.Write(p.opAddr, p.opVal)
+	p.rmwDummy()
 	return true, nil
 }
 
@@ -1531,13 +1189,13 @@ func (p *Chip) addrIndirectY(mode instructionMode) (bool, error) {
 		return false, nil
 	case p.opTick == 3:
 		// Read from the ZP addr to start building our pointer.
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		// Setup opAddr for next read and handle wrapping
 		p.opAddr = uint16(uint8(p.opAddr&0x00FF) + 1)
 		return false, nil
 	case p.opTick == 4:
 		// Compute effective address and then add Y to it (possibly wrongly).
-		p.opAddr = (uint16(p.ram.Read(p.opAddr)) << 8) + uint16(p.opVal)
+		p.opAddr = (uint16(p.read(p.opAddr)) << 8) + uint16(p.opVal)
 		// Add Y but do it in a way which won't page wrap (if needed)
 		a := (p.opAddr & 0xFF00) + uint16(uint8(p.opAddr&0xFF)+p.Y)
 		p.opVal = 0
@@ -1549,7 +1207,11 @@ func (p *Chip) addrIndirectY(mode instructionMode) (bool, error) {
 		return false, nil
 	case p.opTick == 5:
 		t := p.opVal
-		p.opVal = p.ram.Read(p.opAddr)
+		// A store always reads here before the write. If the page was wrong the value is thrown away too.
+		if t != 0 || mode == kSTORE_INSTRUCTION {
+			p.busKind = BUS_DUMMY
+		}
+		p.opVal = p.read(p.opAddr)
 
 		// Check old opVal to see if it's non-zero. If so it means the Y addition
 		// crosses a page boundary and we'll have to fixup.
@@ -1571,7 +1233,7 @@ func (p *Chip) addrIndirectY(mode instructionMode) (bool, error) {
 		return done, nil
 	case p.opTick == 6:
 		// Optional (on load) in case adding Y went past a page boundary.
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		done := true
 		if mode == kRMW_INSTRUCTION {
 			done = false
@@ -1579,7 +1241,7 @@ func (p *Chip) addrIndirectY(mode instructionMode) (bool, error) {
 		return done, nil
 	}
 	// case p.opTick == 7:
-	p.ram.Write(p.opAddr, p.opVal)
+	p.rmwDummy()
 	return true, nil
 }
 
@@ -1600,7 +1262,7 @@ func (p *Chip) addrAbsolute(mode instructionMode) (bool, error) {
 		p.PC++
 		return false, nil
 	case p.opTick == 3:
-		p.opVal = p.ram.Read(p.PC)
+		p.opVal = p.readAs(BUS_OPERAND, p.PC)
 		p.PC++
 		p.opAddr |= (uint16(p.opVal) << 8)
 		done := false
@@ -1610,7 +1272,7 @@ func (p *Chip) addrAbsolute(mode instructionMode) (bool, error) {
 		return done, nil
 	case p.opTick == 4:
 		// For load and RMW instructions
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		done := true
 		if mode == kRMW_INSTRUCTION {
 			done = false
@@ -1618,7 +1280,7 @@ func (p *Chip) addrAbsolute(mode instructionMode) (bool, error) {
 		return done, nil
 	}
 	// case p.opTick == 5:
-	p.ram.Write(p.opAddr, p.opVal)
+	p.rmwDummy()
 	return true, nil
 }
 
@@ -1656,7 +1318,7 @@ func (p *Chip) addrAbsoluteXY(mode instructionMode, reg uint8) (bool, error) {
 		p.PC++
 		return false, nil
 	case p.opTick == 3:
-		p.opVal = p.ram.Read(p.PC)
+		p.opVal = p.readAs(BUS_OPERAND, p.PC)
 		p.PC++
 		p.opAddr |= (uint16(p.opVal) << 8)
 		// Add X but do it in a way which won't page wrap (if needed)
@@ -1670,7 +1332,11 @@ func (p *Chip) addrAbsoluteXY(mode instructionMode, reg uint8) (bool, error) {
 		return false, nil
 	case p.opTick == 4:
 		t := p.opVal
-		p.opVal = p.ram.Read(p.opAddr)
+		// A store always reads here before the write. If the page was wrong the value is thrown away too.
+		if t != 0 || mode == kSTORE_INSTRUCTION {
+			p.busKind = BUS_DUMMY
+		}
+		p.opVal = p.read(p.opAddr)
 		// Check old opVal to see if it's non-zero. If so it means the X addition
 		// crosses a page boundary and we'll have to fixup.
 		// For a load operation that means another tick to read the correct
@@ -1691,7 +1357,7 @@ func (p *Chip) addrAbsoluteXY(mode instructionMode, reg uint8) (bool, error) {
 		return done, nil
 	case p.opTick == 5:
 		// Optional (on load) in case adding X went past a page boundary.
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		done := true
 		if mode == kRMW_INSTRUCTION {
 			done = false
@@ -1699,10 +1365,92 @@ func (p *Chip) addrAbsoluteXY(mode instructionMode, reg uint8) (bool, error) {
 		return done, nil
 	}
 	// case p.opTick == 6:
-	p.ram.Write(p.opAddr, p.opVal)
+	p.rmwDummy()
 	return true, nil
 }
 
+// addrAbsoluteXShift implements absolute plus X mode - a,x for the CMOS ASL/LSR/ROL/ROR instructions.
+// Unlike every other RMW a,x instruction these only take the fixup tick if adding X crosses a page boundary
+// so they normally complete in 6 ticks instead of 7.
+// See addrAbsoluteX for arg/return specifics.
+func (p *Chip) addrAbsoluteXShift(mode instructionMode) (bool, error) {
+	switch {
+	case p.opTick <= 1 || p.opTick > 6:
+		return true, InvalidCPUState{fmt.Sprintf("addrAbsoluteXShift invalid opTick: %d", p.opTick)}
+	case p.opTick < 4:
+		// Address computation is identical.
+		return p.addrAbsoluteXY(mode, p.X)
+	case p.opTick == 4:
+		t := p.opVal
+		if t != 0 {
+			p.busKind = BUS_DUMMY
+		}
+		p.opVal = p.read(p.opAddr)
+		p.extraTick = false
+		if t != 0 {
+			// Wrong page so fixup and read again on the next tick.
+			p.opAddr += 0x0100
+			p.extraTick = true
+		}
+		return false, nil
+	case p.opTick == 5:
+		if p.extraTick {
+			p.opVal = p.read(p.opAddr)
+			return false, nil
+		}
+		p.rmwDummy()
+		return true, nil
+	}
+	// case p.opTick == 6:
+	p.extraTick = false
+	p.rmwDummy()
+	return true, nil
+}
+
+// addrIndirectZP implements the CMOS zero page indirect mode - (d)
+// returning the value in p.opVal and the address read in p.opAddr.
+// There are no RMW instructions using this mode.
+// Returns error on invalid tick.
+// The bool return value is true if this tick ends address processing.
+func (p *Chip) addrIndirectZP(mode instructionMode) (bool, error) {
+	switch {
+	case p.opTick <= 1 || p.opTick > 5:
+		return true, InvalidCPUState{fmt.Sprintf("addrIndirectZP invalid opTick: %d", p.opTick)}
+	case p.opTick == 2:
+		// Already read the value but need to bump the PC
+		p.opAddr = uint16(0x00FF & p.opVal)
+		p.PC++
+		return false, nil
+	case p.opTick == 3:
+		// Read from the ZP addr to start building our pointer.
+		p.opVal = p.read(p.opAddr)
+		// Setup opAddr for next read and handle wrapping
+		p.opAddr = uint16(uint8(p.opAddr&0x00FF) + 1)
+		return false, nil
+	case p.opTick == 4:
+		p.opAddr = (uint16(p.read(p.opAddr)) << 8) + uint16(p.opVal)
+		done := false
+		// For a store we're done since we have the address needed.
+		if mode == kSTORE_INSTRUCTION {
+			done = true
+		}
+		return done, nil
+	}
+	// case p.opTick == 5:
+	p.opVal = p.read(p.opAddr)
+	return true, nil
+}
+
+// rmwDummy implements the extra tick all RMW instructions take before the final write.
+// NMOS writes the unmodified value back to p.opAddr while CMOS does another read of it instead.
+func (p *Chip) rmwDummy() {
+	if p.cpuType == CPU_CMOS {
+		_ = p.readAs(BUS_DUMMY, p.opAddr)
+		return
+	}
+	p.writeAs(BUS_DUMMY, p.opAddr, p.opVal)
+}
+
 // loadRegister takes the val and inserts it into the register passed in. It then does
 // Z and N checks against the new value.
 // Always returns true and no error since this is a single tick operation.
@@ -1734,16 +1482,101 @@ func (p *Chip) loadRegisterY() (bool, error) {
 	return p.loadRegister(&p.Y, p.opVal)
 }
 
+// iNOP implements the implied NOP instructions (documented and not) which do nothing for a tick.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iNOP() (bool, error) {
+	return true, nil
+}
+
+// iTAX implements the TAX instruction copying A into X.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iTAX() (bool, error) {
+	return p.loadRegister(&p.X, p.A)
+}
+
+// iTAY implements the TAY instruction copying A into Y.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iTAY() (bool, error) {
+	return p.loadRegister(&p.Y, p.A)
+}
+
+// iTXA implements the TXA instruction copying X into A.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iTXA() (bool, error) {
+	return p.loadRegister(&p.A, p.X)
+}
+
+// iTYA implements the TYA instruction copying Y into A.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iTYA() (bool, error) {
+	return p.loadRegister(&p.A, p.Y)
+}
+
+// iTSX implements the TSX instruction copying S into X.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iTSX() (bool, error) {
+	return p.loadRegister(&p.X, p.S)
+}
+
+// iTXS implements the TXS instruction copying X into S. Unlike the other transfers no flags change.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iTXS() (bool, error) {
+	p.S = p.X
+	return true, nil
+}
+
+// iINX implements the INX instruction incrementing X.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iINX() (bool, error) {
+	return p.loadRegister(&p.X, p.X+1)
+}
+
+// iINY implements the INY instruction incrementing Y.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iINY() (bool, error) {
+	return p.loadRegister(&p.Y, p.Y+1)
+}
+
+// iDEX implements the DEX instruction decrementing X.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iDEX() (bool, error) {
+	return p.loadRegister(&p.X, p.X-1)
+}
+
+// iDEY implements the DEY instruction decrementing Y.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iDEY() (bool, error) {
+	return p.loadRegister(&p.Y, p.Y-1)
+}
+
+// iINA implements the CMOS INC A instruction incrementing A.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iINA() (bool, error) {
+	return p.loadRegister(&p.A, p.A+1)
+}
+
+// iDEA implements the CMOS DEC A instruction decrementing A.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iDEA() (bool, error) {
+	return p.loadRegister(&p.A, p.A-1)
+}
+
 // pushStack pushes the given byte onto the stack and adjusts the stack pointer accordingly.
 func (p *Chip) pushStack(val uint8) {
-	p.ram.Write(0x0100+uint16(p.S), val)
+	if p.calls != nil {
+		p.calls.stackWrap(p, true)
+	}
+	p.writeAs(BUS_STACK, 0x0100+uint16(p.S), val)
 	p.S--
 }
 
 // popStack pops the top byte off the stack and adjusts the stack pointer accordingly.
 func (p *Chip) popStack() uint8 {
+	if p.calls != nil {
+		p.calls.stackWrap(p, false)
+	}
 	p.S++
-	return p.ram.Read(0x0100 + uint16(p.S))
+	return p.readAs(BUS_STACK, 0x0100+uint16(p.S))
 }
 
 // branchNOP reads the next byte as the branch offset and increments the PC.
@@ -1774,27 +1607,36 @@ func (p *Chip) performBranch() (bool, error) {
 		if !p.prevSkipInterrupt {
 			p.skipInterrupt = true
 		}
-		// Per http://www.6502.org/tutorials/6502opcodes.html
-		// the wrong page is defined as the a different page than
-		// the next byte after the jump. i.e. current PC at the moment.
-
-		// Now compute the new PC but possibly wrong page.
-		// Stash the old one in p.opAddr so we can use in tick 4 if needed.
-		p.opAddr = p.PC
-		p.PC = (p.PC & 0xFF00) + uint16(uint8(p.PC&0x00FF)+p.opVal)
-		// It always triggers a bus read of the PC.
-		_ = p.ram.Read(p.PC)
-		if p.PC == (p.opAddr + uint16(int16(int8(p.opVal)))) {
-			return true, nil
-		}
-		return false, nil
+		return p.branchTaken(), nil
 	}
 	// case p.opTick == 4:
+	p.branchFixup()
+	return true, nil
+}
+
+// branchTaken computes the new PC for a taken branch using the offset in p.opVal.
+// Returns true if the branch is complete or false if the PC landed on the wrong
+// page and branchFixup needs to run on the next tick.
+func (p *Chip) branchTaken() bool {
+	// Per http://www.6502.org/tutorials/6502opcodes.html
+	// the wrong page is defined as the a different page than
+	// the next byte after the jump. i.e. current PC at the moment.
+
+	// Now compute the new PC but possibly wrong page.
+	// Stash the old one in p.opAddr so we can use in branchFixup if needed.
+	p.opAddr = p.PC
+	p.PC = (p.PC & 0xFF00) + uint16(uint8(p.PC&0x00FF)+p.opVal)
+	// It always triggers a bus read of the PC.
+	_ = p.readAs(BUS_DUMMY, p.PC)
+	return p.PC == (p.opAddr + uint16(int16(int8(p.opVal))))
+}
+
+// branchFixup sets the correct PC for a taken branch which crossed a page boundary.
+func (p *Chip) branchFixup() {
 	// Set correct PC value
 	p.PC = p.opAddr + uint16(int16(int8(p.opVal)))
 	// Always read the next opcode
-	_ = p.ram.Read(p.PC)
-	return true, nil
+	_ = p.readAs(BUS_DUMMY, p.PC)
 }
 
 const BRK = uint8(0x00)
@@ -1837,11 +1679,17 @@ func (p *Chip) runInterrupt(addr uint16, irq bool) (bool, error) {
 		p.pushStack(push)
 		return false, nil
 	case p.opTick == 6:
-		p.opVal = p.ram.Read(addr)
+		p.opVal = p.readAs(BUS_VECTOR, addr)
+		if irq && p.ints != nil {
+			p.ints.vector(p)
+		}
 		return false, nil
 	}
 	// case p.opTick == 7:
-	p.PC = (uint16(p.ram.Read(addr+1)) << 8) + uint16(p.opVal)
+	p.PC = (uint16(p.readAs(BUS_VECTOR, addr+1)) << 8) + uint16(p.opVal)
+	if irq && p.ints != nil {
+		p.ints.done(p)
+	}
 	// If we didn't previously skip an interrupt from processing make sure we execute the first instruction of
 	// a handler before firing again.
 	if irq && !p.prevSkipInterrupt {
@@ -1877,9 +1725,13 @@ func (p *Chip) iADC() (bool, error) {
 		bin := p.A + p.opVal + carry
 		p.overflowCheck(p.A, p.opVal, seq)
 		p.carryCheck(sum)
-		// TODO(jchacon): CMOS gets N/Z set correctly and needs implementing.
 		p.negativeCheck(seq)
 		p.zeroCheck(bin)
+		// CMOS sets N/Z correctly based on the BCD result.
+		if p.cpuType == CPU_CMOS {
+			p.negativeCheck(res)
+			p.zeroCheck(res)
+		}
 		p.A = res
 		return true, nil
 	}
@@ -1908,13 +1760,19 @@ func (p *Chip) iASLAcc() (bool, error) {
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iASL() (bool, error) {
 	new := p.opVal << 1
-	p.ram.Write(p.opAddr, new)
+	p.write(p.opAddr, new)
 	p.carryCheck(uint16(p.opVal) << 1)
 	p.zeroCheck(new)
 	p.negativeCheck(new)
 	return true, nil
 }
 
+// iBRA implements the CMOS BRA instruction which always branches.
+// Returns true when the branch has set the correct PC. Returns error on an invalid tick.
+func (p *Chip) iBRA() (bool, error) {
+	return p.performBranch()
+}
+
 // iBCC implements the BCC instruction and branches if C is clear.
 // Returns true when the branch has set the correct PC. Returns error on an invalid tick.
 func (p *Chip) iBCC() (bool, error) {
@@ -2073,7 +1931,7 @@ func (p *Chip) iJMP() (bool, error) {
 	}
 	// case p.opTick == 3:
 	// Get the next bit of the PC and assemble it.
-	v := p.ram.Read(p.PC)
+	v := p.readAs(BUS_OPERAND, p.PC)
 	p.opAddr = (uint16(v) << 8) + uint16(p.opVal)
 	p.PC = p.opAddr
 	return true, nil
@@ -2092,12 +1950,15 @@ func (p *Chip) iJMPIndirect() (bool, error) {
 		return true, InvalidCPUState{fmt.Sprintf("iJMPIndirect invalid opTick: %d", p.opTick)}
 	case p.opTick == 4:
 		// Read the low byte of the pointer and stash it in opVal
-		p.opVal = p.ram.Read(p.opAddr)
+		p.opVal = p.read(p.opAddr)
 		return false, nil
 	case p.opTick == 5:
 		// Read the high byte. On NMOS and CMOS this tick reads the wrong address if there was a page wrap.
 		a := (p.opAddr & 0xFF00) + uint16(uint8(p.opAddr&0xFF)+1)
-		v := p.ram.Read(a)
+		if p.cpuType == CPU_CMOS {
+			p.busKind = BUS_DUMMY
+		}
+		v := p.read(a)
 		if p.cpuType == CPU_CMOS {
 			// Just do a normal +1 now for CMOS so tick 6 reads the correct address no matter what.
 			// It may be a duplicate of this but that's fine.
@@ -2109,7 +1970,7 @@ func (p *Chip) iJMPIndirect() (bool, error) {
 		return true, nil
 	}
 	// case p.opTick == 6:
-	v := p.ram.Read(p.opAddr)
+	v := p.read(p.opAddr)
 	p.opAddr = (uint16(v) << 8) + uint16(p.opVal)
 	p.PC = p.opAddr
 	return true, nil
@@ -2131,8 +1992,7 @@ func (p *Chip) iJSR() (bool, error) {
 		// Not 100% sure what happens on this cycle.
 		// Per http://nesdev.com/6502_cpu.txt we read the current stack
 		// value because there needs to be a tick to make S correct.
-		p.S--
-		_ = p.popStack()
+		_ = p.readAs(BUS_DUMMY, 0x0100+uint16(p.S))
 		return false, nil
 	case p.opTick == 4:
 		p.pushStack(uint8((p.PC & 0xFF00) >> 8))
@@ -2142,7 +2002,7 @@ func (p *Chip) iJSR() (bool, error) {
 		return false, nil
 	}
 	// case p.opTick == 6:
-	p.PC = (uint16(p.ram.Read(p.PC)) << 8) + uint16(p.opVal)
+	p.PC = (uint16(p.readAs(BUS_OPERAND, p.PC)) << 8) + uint16(p.opVal)
 	return true, nil
 }
 
@@ -2161,7 +2021,7 @@ func (p *Chip) iLSRAcc() (bool, error) {
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iLSR() (bool, error) {
 	new := p.opVal >> 1
-	p.ram.Write(p.opAddr, new)
+	p.write(p.opAddr, new)
 	// Get bit0 from orig but in a 16 bit value and then shift it up into
 	// the carry position
 	p.carryCheck(uint16(p.opVal&0x01) << 8)
@@ -2170,41 +2030,76 @@ func (p *Chip) iLSR() (bool, error) {
 	return true, nil
 }
 
-// iPHA implements the PHA instruction and pushs X onto the stack.
+// pushRegister implements the PHA/PHX/PHY instructions and pushes the given register value onto the stack.
 // Returns true when done. Returns error on an invalid tick.
-func (p *Chip) iPHA() (bool, error) {
+func (p *Chip) pushRegister(val uint8) (bool, error) {
 	switch {
 	case p.opTick <= 1 || p.opTick > 3:
-		return true, InvalidCPUState{fmt.Sprintf("PHA invalid opTick %d", p.opTick)}
+		return true, InvalidCPUState{fmt.Sprintf("pushRegister invalid opTick %d", p.opTick)}
 	case p.opTick == 2:
 		// Nothing else happens here
 		return false, nil
 	}
 	// case p.opTick == 3:
-	p.pushStack(p.A)
+	p.pushStack(val)
 	return true, nil
 }
 
-// iPLA implements the PLA instruction and pops the stock into the accumulator.
+// pullRegister implements the PLA/PLX/PLY instructions and pops the stack into the given register.
 // Returns true when done. Returns error on an invalid tick.
-func (p *Chip) iPLA() (bool, error) {
+func (p *Chip) pullRegister(reg *uint8) (bool, error) {
 	switch {
 	case p.opTick <= 1 || p.opTick > 4:
-		return true, InvalidCPUState{fmt.Sprintf("PLA invalid opTick %d", p.opTick)}
+		return true, InvalidCPUState{fmt.Sprintf("pullRegister invalid opTick %d", p.opTick)}
 	case p.opTick == 2:
 		// Nothing else happens here
 		return false, nil
 	case p.opTick == 3:
 		// A read of the current stack happens while the CPU is incrementing S.
-		// Since our popStack does both of these together on this cycle it's just
-		// a throw away read.
-		p.S--
-		_ = p.popStack()
+		// Since our popStack does both of these together on the next cycle it's just
+		// a throw away read here.
+		_ = p.readAs(BUS_DUMMY, 0x0100+uint16(p.S))
 		return false, nil
 	}
 	// case p.opTick == 4:
 	// The real read
-	return p.loadRegister(&p.A, p.popStack())
+	return p.loadRegister(reg, p.popStack())
+}
+
+// iPHA implements the PHA instruction and pushs A onto the stack.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iPHA() (bool, error) {
+	return p.pushRegister(p.A)
+}
+
+// iPLA implements the PLA instruction and pops the stock into the accumulator.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iPLA() (bool, error) {
+	return p.pullRegister(&p.A)
+}
+
+// iPHX implements the CMOS PHX instruction and pushs X onto the stack.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iPHX() (bool, error) {
+	return p.pushRegister(p.X)
+}
+
+// iPLX implements the CMOS PLX instruction and pops the stack into X.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iPLX() (bool, error) {
+	return p.pullRegister(&p.X)
+}
+
+// iPHY implements the CMOS PHY instruction and pushs Y onto the stack.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iPHY() (bool, error) {
+	return p.pushRegister(p.Y)
+}
+
+// iPLY implements the CMOS PLY instruction and pops the stack into Y.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iPLY() (bool, error) {
+	return p.pullRegister(&p.Y)
 }
 
 // iPHP implements the PHP instructions for pushing P onto the stacks.
@@ -2239,10 +2134,9 @@ func (p *Chip) iPLP() (bool, error) {
 		return false, nil
 	case p.opTick == 3:
 		// A read of the current stack happens while the CPU is incrementing S.
-		// Since our popStack does both of these together on this cycle it's just
-		// a throw away read.
-		p.S--
-		_ = p.popStack()
+		// Since our popStack does both of these together on the next cycle it's just
+		// a throw away read here.
+		_ = p.readAs(BUS_DUMMY, 0x0100+uint16(p.S))
 		return false, nil
 	}
 	// case p.opTick == 4:
@@ -2270,7 +2164,7 @@ func (p *Chip) iROLAcc() (bool, error) {
 func (p *Chip) iROL() (bool, error) {
 	carry := p.P & P_CARRY
 	new := (p.opVal << 1) | carry
-	p.ram.Write(p.opAddr, new)
+	p.write(p.opAddr, new)
 	p.carryCheck(uint16(p.opVal) << 1)
 	p.zeroCheck(new)
 	p.negativeCheck(new)
@@ -2287,13 +2181,31 @@ func (p *Chip) iRORAcc() (bool, error) {
 	return p.loadRegister(&p.A, (p.A>>1)|carry)
 }
 
+// iRORAccRevA implements the ROR instruction directly on the accumulator for revision A parts.
+// This shifts left like ASL but doesn't change carry.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iRORAccRevA() (bool, error) {
+	return p.loadRegister(&p.A, p.A<<1)
+}
+
+// iRORRevA implements the ROR instruction on p.opAddr for revision A parts.
+// This shifts left like ASL but doesn't change carry.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iRORRevA() (bool, error) {
+	new := p.opVal << 1
+	p.write(p.opAddr, new)
+	p.zeroCheck(new)
+	p.negativeCheck(new)
+	return true, nil
+}
+
 // iROR implements the ROR instruction on p.opAddr.
 // It then sets all associated flags and adjust cycles as needed.
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iROR() (bool, error) {
 	carry := (p.P & P_CARRY) << 7
 	new := (p.opVal >> 1) | carry
-	p.ram.Write(p.opAddr, new)
+	p.write(p.opAddr, new)
 	// Just see if carry is set or not.
 	p.carryCheck((uint16(p.opVal) << 8) & 0x0100)
 	p.zeroCheck(new)
@@ -2312,10 +2224,9 @@ func (p *Chip) iRTI() (bool, error) {
 		return false, nil
 	case p.opTick == 3:
 		// A read of the current stack happens while the CPU is incrementing S.
-		// Since our popStack does both of these together on this cycle it's just
-		// a throw away read.
-		p.S--
-		_ = p.popStack()
+		// Since our popStack does both of these together on the next cycle it's just
+		// a throw away read here.
+		_ = p.readAs(BUS_DUMMY, 0x0100+uint16(p.S))
 		return false, nil
 	case p.opTick == 4:
 		// The real read for P
@@ -2346,10 +2257,9 @@ func (p *Chip) iRTS() (bool, error) {
 		return false, nil
 	case p.opTick == 3:
 		// A read of the current stack happens while the CPU is incrementing S.
-		// Since our popStack does both of these together on this cycle it's just
-		// a throw away read.
-		p.S--
-		_ = p.popStack()
+		// Since our popStack does both of these together on the next cycle it's just
+		// a throw away read here.
+		_ = p.readAs(BUS_DUMMY, 0x0100+uint16(p.S))
 		return false, nil
 	case p.opTick == 4:
 		// PCL
@@ -2362,7 +2272,7 @@ func (p *Chip) iRTS() (bool, error) {
 	}
 	// case p.opTick == 6:
 	// Read the current PC and then get it incremented for the next instruction.
-	_ = p.ram.Read(p.PC)
+	_ = p.readAs(BUS_DUMMY, p.PC)
 	p.PC++
 	return true, nil
 }
@@ -2379,16 +2289,29 @@ func (p *Chip) iSBC() (bool, error) {
 		// BCD details - http://6502.org/tutorials/decimal_mode.html
 		// Also http://nesdev.com/6502_cpu.txt but it has errors
 		aL := int8(p.A&0x0F) - int8(p.opVal&0x0F) + int8(carry) - 1
-		// Low nibble fixup
-		if aL < 0 {
-			aL = ((aL - 0x06) & 0x0F) - 0x10
-		}
-		sum := int16(p.A&0xF0) - int16(p.opVal&0xF0) + int16(aL)
-		// High nibble fixup
-		if sum < 0x0000 {
-			sum -= 0x60
+		var res uint8
+		if p.cpuType == CPU_CMOS {
+			// CMOS does the fixups against the full binary result instead (sequence 4 in the above).
+			sum := int16(p.A) - int16(p.opVal) + int16(carry) - 1
+			if sum < 0x0000 {
+				sum -= 0x60
+			}
+			if aL < 0 {
+				sum -= 0x06
+			}
+			res = uint8(sum & 0xFF)
+		} else {
+			// Low nibble fixup
+			if aL < 0 {
+				aL = ((aL - 0x06) & 0x0F) - 0x10
+			}
+			sum := int16(p.A&0xF0) - int16(p.opVal&0xF0) + int16(aL)
+			// High nibble fixup
+			if sum < 0x0000 {
+				sum -= 0x60
+			}
+			res = uint8(sum & 0xFF)
 		}
-		res := uint8(sum & 0xFF)
 
 		// Do normal binary math to set C,N,Z
 		b := p.A + ^p.opVal + carry
@@ -2398,6 +2321,11 @@ func (p *Chip) iSBC() (bool, error) {
 		// just treating as uint16 math is simpler to code.
 		p.carryCheck(uint16(p.A) + uint16(^p.opVal) + uint16(carry))
 		p.zeroCheck(b)
+		// CMOS sets N/Z correctly based on the BCD result.
+		if p.cpuType == CPU_CMOS {
+			p.negativeCheck(res)
+			p.zeroCheck(res)
+		}
 		p.A = res
 		return true, nil
 	}
@@ -2498,7 +2426,7 @@ func (p *Chip) iLAX() (bool, error) {
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iDCP() (bool, error) {
 	p.opVal -= 1
-	p.ram.Write(p.opAddr, p.opVal)
+	p.write(p.opAddr, p.opVal)
 	return p.compareA()
 }
 
@@ -2506,14 +2434,14 @@ func (p *Chip) iDCP() (bool, error) {
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iISC() (bool, error) {
 	p.opVal += 1
-	p.ram.Write(p.opAddr, p.opVal)
+	p.write(p.opAddr, p.opVal)
 	return p.iSBC()
 }
 
 // iSLO implements the undocumented opcode for SLO. This does an ASL on p.opAddr and then OR's it against A. Sets flags and carry.
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iSLO() (bool, error) {
-	p.ram.Write(p.opAddr, p.opVal<<1)
+	p.write(p.opAddr, p.opVal<<1)
 	p.carryCheck(uint16(p.opVal) << 1)
 	return p.loadRegister(&p.A, (p.opVal<<1)|p.A)
 }
@@ -2522,7 +2450,7 @@ func (p *Chip) iSLO() (bool, error) {
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iRLA() (bool, error) {
 	n := p.opVal<<1 | (p.P & P_CARRY)
-	p.ram.Write(p.opAddr, n)
+	p.write(p.opAddr, n)
 	p.carryCheck(uint16(p.opVal) << 1)
 	return p.loadRegister(&p.A, n&p.A)
 }
@@ -2530,7 +2458,7 @@ func (p *Chip) iRLA() (bool, error) {
 // iSRE implements the undocumented opcode for SRE. This does a LSR on p.opAddr and then EOR's it against A. Sets flags and carry.
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iSRE() (bool, error) {
-	p.ram.Write(p.opAddr, p.opVal>>1)
+	p.write(p.opAddr, p.opVal>>1)
 	// Old bit 0 becomes carry
 	p.carryCheck(uint16(p.opVal) << 8)
 	return p.loadRegister(&p.A, (p.opVal>>1)^p.A)
@@ -2540,7 +2468,7 @@ func (p *Chip) iSRE() (bool, error) {
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iRRA() (bool, error) {
 	n := ((p.P & P_CARRY) << 7) | p.opVal>>1
-	p.ram.Write(p.opAddr, n)
+	p.write(p.opAddr, n)
 	// Old bit 0 becomes carry
 	p.carryCheck((uint16(p.opVal) << 8) & 0x0100)
 	p.opVal = n
@@ -2548,18 +2476,23 @@ func (p *Chip) iRRA() (bool, error) {
 }
 
 // iXAA implements the undocumented opcode for XAA. We'll go with http://visual6502.org/wiki/index.php?title=6502_Opcode_8B_(XAA,_ANE)
-// for implementation and pick 0xEE as the constant. According to VICE this may break so might need to change it to 0xFF
+// for implementation and default to 0xEE as the constant (see Magic). According to VICE this may break so might need to change it to 0xFF
 // https://sourceforge.net/tracker/?func=detail&aid=2110948&group_id=223021&atid=1057617
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iXAA() (bool, error) {
-	return p.loadRegister(&p.A, (p.A|0xEE)&p.X&p.opVal)
+	return p.loadRegister(&p.A, (p.A|p.magic.XAA)&p.X&p.opVal)
 }
 
-// iOAL implements the undocumented opcode for OAL. This one acts a bit randomly. It somtimes does XAA and sometimes
-// does A=X=A&val.
+// iOAL implements the undocumented opcode for OAL. By default this one acts a bit randomly. It somtimes does XAA and sometimes
+// does A=X=A&val. If Magic.OALRandom isn't set it always does A=X=(A|Magic.OAL)&val instead.
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) iOAL() (bool, error) {
-	if rand.Float32() >= 0.5 {
+	if !p.magic.OALRandom {
+		v := (p.A | p.magic.OAL) & p.opVal
+		_, _ = p.loadRegister(&p.A, v)
+		return p.loadRegister(&p.X, v)
+	}
+	if p.policy.Rand.Float32() >= 0.5 {
 		return p.iXAA()
 	}
 	v := p.A & p.opVal
@@ -2570,7 +2503,7 @@ func (p *Chip) iOAL() (bool, error) {
 // store implements the STA/STX/STY instruction for storing a value (from a register) in RAM.
 // Always returns true since this takes one tick and never returns an error.
 func (p *Chip) store(val uint8, addr uint16) (bool, error) {
-	p.ram.Write(addr, val)
+	p.write(addr, val)
 	return true, nil
 }
 
@@ -2662,49 +2595,73 @@ func (p *Chip) iINC() (bool, error) {
 	return p.storeWithFlags(p.opVal+1, p.opAddr)
 }
 
-// iAHX implements the undocumented AHX instruction based on the addressing mode passed in.
-// The value stored is (A & X & (ADDR_HI + 1))
+// iSH implements the undocumented AHX/SHX/SHY/TAS stores based on the addressing mode passed in.
+// The value stored is (reg & (ADDR_HI + 1)) unless Magic.SHDropHigh is set in which case it's
+// just reg. index is the register used to compute the address and if adding it crossed a page
+// and Magic.SHPageCross is set the high byte of the address is replaced with the value stored.
 // Returns true when complete and any error.
-func (p *Chip) iAHX(addrFunc func(instructionMode) (bool, error)) (bool, error) {
+func (p *Chip) iSH(addr addrFunc, reg uint8, index uint8) (bool, error) {
 	// This is a store but we can't use storeInstruction since it depends on knowing p.opAddr
 	// for the final computed value so we have to do the addressing mode ourselves.
 	var err error
 	if !p.addrDone {
-		p.addrDone, err = addrFunc(kSTORE_INSTRUCTION)
+		p.addrDone, err = addr(p, kSTORE_INSTRUCTION)
 		return false, err
 	}
-	val := p.A & p.X & uint8((p.opAddr>>8)+1)
-	return p.store(val, p.opAddr)
+	val := reg
+	if !p.magic.SHDropHigh {
+		val &= uint8((p.opAddr >> 8) + 1)
+	}
+	a := p.opAddr
+	if p.magic.SHPageCross && uint8(a) < index {
+		a = uint16(val)<<8 | a&0x00FF
+	}
+	return p.store(val, a)
+}
+
+// iAHX implements the undocumented AHX instruction based on the addressing mode passed in.
+// The value stored is (A & X & (ADDR_HI + 1))
+// Returns true when complete and any error.
+func (p *Chip) iAHX(addr addrFunc) (bool, error) {
+	return p.iSH(addr, p.A&p.X, p.Y)
 }
 
 // iSHY implements the undocumented SHY instruction based on the addressing mode passed in.
 // The value stored is (Y & (ADDR_HI + 1))
 // Returns true when complete and any error.
-func (p *Chip) iSHY(addrFunc func(instructionMode) (bool, error)) (bool, error) {
-	// This is a store but we can't use storeInstruction since it depends on knowing p.opAddr
-	// for the final computed value so we have to do the addressing mode ourselves.
-	var err error
-	if !p.addrDone {
-		p.addrDone, err = addrFunc(kSTORE_INSTRUCTION)
-		return false, err
-	}
-	val := p.Y & uint8((p.opAddr>>8)+1)
-	return p.store(val, p.opAddr)
+func (p *Chip) iSHY(addr addrFunc) (bool, error) {
+	return p.iSH(addr, p.Y, p.X)
 }
 
 // iSHX implements the undocumented SHX instruction based on the addressing mode passed in.
 // The value stored is (X & (ADDR_HI + 1))
 // Returns true when complete and any error.
-func (p *Chip) iSHX(addrFunc func(instructionMode) (bool, error)) (bool, error) {
-	// This is a store but we can't use storeInstruction since it depends on knowing p.opAddr
-	// for the final computed value so we have to do the addressing mode ourselves.
-	var err error
-	if !p.addrDone {
-		p.addrDone, err = addrFunc(kSTORE_INSTRUCTION)
-		return false, err
-	}
-	val := p.X & uint8((p.opAddr>>8)+1)
-	return p.store(val, p.opAddr)
+func (p *Chip) iSHX(addr addrFunc) (bool, error) {
+	return p.iSH(addr, p.X, p.Y)
+}
+
+// iAHXIndirectY implements AHX (d),y
+// Returns true when complete and any error.
+func (p *Chip) iAHXIndirectY() (bool, error) {
+	return p.iAHX((*Chip).addrIndirectY)
+}
+
+// iAHXAbsoluteY implements AHX a,y
+// Returns true when complete and any error.
+func (p *Chip) iAHXAbsoluteY() (bool, error) {
+	return p.iAHX((*Chip).addrAbsoluteY)
+}
+
+// iSHYAbsoluteX implements SHY a,x
+// Returns true when complete and any error.
+func (p *Chip) iSHYAbsoluteX() (bool, error) {
+	return p.iSHY((*Chip).addrAbsoluteX)
+}
+
+// iSHXAbsoluteY implements SHX a,y
+// Returns true when complete and any error.
+func (p *Chip) iSHXAbsoluteY() (bool, error) {
+	return p.iSHX((*Chip).addrAbsoluteY)
 }
 
 // iTAS implements the undocumented TAS instruction which only has one addressing mode.
@@ -2712,7 +2669,7 @@ func (p *Chip) iSHX(addrFunc func(instructionMode) (bool, error)) (bool, error)
 // Returns true when complete and any error.
 func (p *Chip) iTAS() (bool, error) {
 	p.S = p.A & p.X
-	return p.iAHX(p.addrAbsoluteY)
+	return p.iAHX((*Chip).addrAbsoluteY)
 }
 
 // iLAS implements the undocumented LAS instruction.
@@ -2724,19 +2681,210 @@ func (p *Chip) iLAS() (bool, error) {
 	return p.loadRegister(&p.A, p.S)
 }
 
+// decimalTick runs the given ADC/SBC opFunc and then adds the extra tick CMOS takes when in decimal mode.
+// Returns true when complete and any error.
+func (p *Chip) decimalTick(op opFunc) (bool, error) {
+	if p.P&P_DECIMAL == 0x00 {
+		return op(p)
+	}
+	if !p.extraTick {
+		p.extraTick = true
+		_, err := op(p)
+		return false, err
+	}
+	// The result was already computed on the last tick so this is just to burn a cycle.
+	p.extraTick = false
+	return true, nil
+}
+
+// iADCCMOS implements the CMOS version of ADC which takes an extra tick in decimal mode.
+// Returns true when complete and any error.
+func (p *Chip) iADCCMOS() (bool, error) {
+	return p.decimalTick((*Chip).iADC)
+}
+
+// iSBCCMOS implements the CMOS version of SBC which takes an extra tick in decimal mode.
+// Returns true when complete and any error.
+func (p *Chip) iSBCCMOS() (bool, error) {
+	return p.decimalTick((*Chip).iSBC)
+}
+
+// iBITImmediate implements the CMOS BIT #i instruction. Unlike the other BIT modes this only sets Z.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iBITImmediate() (bool, error) {
+	p.zeroCheck(p.A & p.opVal)
+	return true, nil
+}
+
+// iTSB implements the CMOS TSB instruction. This sets Z based on A AND p.opVal and then writes
+// p.opVal OR'd with A back to p.opAddr.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iTSB() (bool, error) {
+	p.zeroCheck(p.A & p.opVal)
+	return p.store(p.opVal|p.A, p.opAddr)
+}
+
+// iTRB implements the CMOS TRB instruction. This sets Z based on A AND p.opVal and then writes
+// p.opVal with the bits in A cleared back to p.opAddr.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iTRB() (bool, error) {
+	p.zeroCheck(p.A & p.opVal)
+	return p.store(p.opVal&^p.A, p.opAddr)
+}
+
+// iRMB implements the CMOS RMB0-7 instructions which clear a bit in p.opVal and write it back to p.opAddr.
+// The bit is encoded in the upper nibble of the opcode.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iRMB() (bool, error) {
+	return p.store(p.opVal&^(1<<((p.op>>4)&0x07)), p.opAddr)
+}
+
+// iSMB implements the CMOS SMB0-7 instructions which set a bit in p.opVal and write it back to p.opAddr.
+// The bit is encoded in the upper nibble of the opcode.
+// Always returns true since this takes one tick and never returns an error.
+func (p *Chip) iSMB() (bool, error) {
+	return p.store(p.opVal|(1<<((p.op>>4)&0x07)), p.opAddr)
+}
+
+// iBBR implements the CMOS BBR0-7 instructions which branch if the given bit is clear in a zero page location.
+// Returns true when the branch has set the correct PC. Returns error on an invalid tick.
+func (p *Chip) iBBR() (bool, error) {
+	return p.branchBit(false)
+}
+
+// iBBS implements the CMOS BBS0-7 instructions which branch if the given bit is set in a zero page location.
+// Returns true when the branch has set the correct PC. Returns error on an invalid tick.
+func (p *Chip) iBBS() (bool, error) {
+	return p.branchBit(true)
+}
+
+// branchBit does the heavy lifting for BBR/BBS. The bit to test is encoded in the upper nibble of the
+// opcode and set determines whether the branch happens when it's set or clear.
+// These take 5 ticks plus one if the branch is taken and another if that crosses a page boundary.
+// Returns true when the branch has set the correct PC. Returns error on an invalid tick.
+func (p *Chip) branchBit(set bool) (bool, error) {
+	switch {
+	case p.opTick <= 1 || p.opTick > 7:
+		return true, InvalidCPUState{fmt.Sprintf("branchBit invalid opTick %d", p.opTick)}
+	case p.opTick == 2:
+		// Already read the ZP address but need to bump the PC
+		p.opAddr = uint16(0x00FF & p.opVal)
+		p.PC++
+		return false, nil
+	case p.opTick == 3:
+		p.opVal = p.read(p.opAddr)
+		return false, nil
+	case p.opTick == 4:
+		// Throw away read of the same address while the bit is tested.
+		_ = p.readAs(BUS_DUMMY, p.opAddr)
+		return false, nil
+	case p.opTick == 5:
+		bit := p.opVal&(1<<((p.op>>4)&0x07)) != 0x00
+		// Now read the branch offset.
+		p.opVal = p.readAs(BUS_OPERAND, p.PC)
+		p.PC++
+		return bit != set, nil
+	case p.opTick == 6:
+		return p.branchTaken(), nil
+	}
+	// case p.opTick == 7:
+	p.branchFixup()
+	return true, nil
+}
+
+// iJMPIndirectX implements the CMOS JMP (a,x) instruction for jumping through a pointer indexed by X.
+// Returns true when the PC is correct. Returns an error on an invalid tick.
+func (p *Chip) iJMPIndirectX() (bool, error) {
+	switch {
+	case p.opTick <= 1 || p.opTick > 6:
+		return true, InvalidCPUState{fmt.Sprintf("iJMPIndirectX invalid opTick: %d", p.opTick)}
+	case p.opTick == 2:
+		// opVal has already been read so start constructing the address
+		p.opAddr = 0x00FF & uint16(p.opVal)
+		p.PC++
+		return false, nil
+	case p.opTick == 3:
+		p.opAddr |= uint16(p.readAs(BUS_OPERAND, p.PC)) << 8
+		return false, nil
+	case p.opTick == 4:
+		// Throw away read while X is added (this can cross pages unlike NMOS indirect).
+		_ = p.readAs(BUS_DUMMY, p.PC)
+		p.opAddr += uint16(p.X)
+		return false, nil
+	case p.opTick == 5:
+		p.opVal = p.read(p.opAddr)
+		return false, nil
+	}
+	// case p.opTick == 6:
+	p.PC = (uint16(p.read(p.opAddr+1)) << 8) + uint16(p.opVal)
+	return true, nil
+}
+
+// iNOP8 implements the CMOS 0x5C NOP which reads a 2 byte argument and then takes 8 ticks in total.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iNOP8() (bool, error) {
+	switch {
+	case p.opTick <= 1 || p.opTick > 8:
+		return true, InvalidCPUState{fmt.Sprintf("NOP8 invalid opTick %d", p.opTick)}
+	case p.opTick < 4:
+		// Read the address like a store would but never use it.
+		_, err := p.addrAbsolute(kSTORE_INSTRUCTION)
+		return false, err
+	case p.opTick < 8:
+		// Throw away reads of the address from the argument.
+		_ = p.readAs(BUS_DUMMY, p.opAddr)
+		return false, nil
+	}
+	// case p.opTick == 8:
+	_ = p.readAs(BUS_DUMMY, p.opAddr)
+	return true, nil
+}
+
+// iWAI implements the CMOS WAI instruction which idles the CPU until an interrupt is raised.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iWAI() (bool, error) {
+	switch {
+	case p.opTick <= 1 || p.opTick > 3:
+		return true, InvalidCPUState{fmt.Sprintf("WAI invalid opTick %d", p.opTick)}
+	case p.opTick == 2:
+		// Nothing else happens here
+		return false, nil
+	}
+	// case p.opTick == 3:
+	// See Tick() for where this is cleared.
+	p.waiting = true
+	return true, nil
+}
+
+// iSTP implements the CMOS STP instruction which stops the CPU until a reset happens.
+// Returns true when done. Returns error on an invalid tick.
+func (p *Chip) iSTP() (bool, error) {
+	switch {
+	case p.opTick <= 1 || p.opTick > 3:
+		return true, InvalidCPUState{fmt.Sprintf("STP invalid opTick %d", p.opTick)}
+	case p.opTick == 2:
+		// Nothing else happens here
+		return false, nil
+	}
+	// case p.opTick == 3:
+	// This acts the same as a halt on NMOS. Only a reset clears it.
+	p.halted = true
+	return true, nil
+}
+
 // loadInstruction abstracts all load instruction opcodes. The address mode function is used to get the proper values loaded into p.opAddr and p.opVal.
 // Then on the same tick this is done the opFunc is called to load the appropriate register.
 // Returns true when complete and any error.
-func (p *Chip) loadInstruction(addrFunc func(instructionMode) (bool, error), opFunc func() (bool, error)) (bool, error) {
+func (p *Chip) loadInstruction(addr addrFunc, op opFunc) (bool, error) {
 	var err error
 	if !p.addrDone {
-		p.addrDone, err = addrFunc(kLOAD_INSTRUCTION)
+		p.addrDone, err = addr(p, kLOAD_INSTRUCTION)
 	}
 	if err != nil {
 		return true, err
 	}
 	if p.addrDone {
-		return opFunc()
+		return op(p)
 	}
 	return false, nil
 }
@@ -2745,25 +2893,50 @@ func (p *Chip) loadInstruction(addrFunc func(instructionMode) (bool, error), opF
 // This assumes the address mode function also handle the extra write rmw instructions perform.
 // Then on the next tick the opFunc is called to perform the final write operation.
 // Returns true when complete and any error.
-func (p *Chip) rmwInstruction(addrFunc func(instructionMode) (bool, error), opFunc func() (bool, error)) (bool, error) {
+func (p *Chip) rmwInstruction(addr addrFunc, op opFunc) (bool, error) {
 	var err error
 	if !p.addrDone {
-		p.addrDone, err = addrFunc(kRMW_INSTRUCTION)
+		p.addrDone, err = addr(p, kRMW_INSTRUCTION)
 		return false, err
 	}
-	return opFunc()
+	return op(p)
 }
 
 // storeInstruction abstracts all store instruction opcodes. The address mode function is used to get the proper values loaded into p.opAddr and p.opVal.
-// Then on the next tick the val passed is stored to p.opAddr.
+// Then on the next tick the value returned by val is stored to p.opAddr.
 // Returns true when complete and any error.
-func (p *Chip) storeInstruction(addrFunc func(instructionMode) (bool, error), val uint8) (bool, error) {
+func (p *Chip) storeInstruction(addr addrFunc, val func(*Chip) uint8) (bool, error) {
 	var err error
 	if !p.addrDone {
-		p.addrDone, err = addrFunc(kSTORE_INSTRUCTION)
+		p.addrDone, err = addr(p, kSTORE_INSTRUCTION)
 		return false, err
 	}
-	return p.store(val, p.opAddr)
+	return p.store(val(p), p.opAddr)
+}
+
+// regA returns A for storeInstruction (STA).
+func (p *Chip) regA() uint8 {
+	return p.A
+}
+
+// regX returns X for storeInstruction (STX).
+func (p *Chip) regX() uint8 {
+	return p.X
+}
+
+// regY returns Y for storeInstruction (STY).
+func (p *Chip) regY() uint8 {
+	return p.Y
+}
+
+// regAX returns A AND X for storeInstruction (the undocumented SAX).
+func (p *Chip) regAX() uint8 {
+	return p.A & p.X
+}
+
+// regZero returns 0x00 for storeInstruction (the CMOS STZ).
+func (p *Chip) regZero() uint8 {
+	return 0x00
 }
 
 func (p *Chip) Debug() string {
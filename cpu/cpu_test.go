@@ -70,16 +70,9 @@ func (r *flatMemory) PowerOn() {
 	r.addr[IRQ_VECTOR+1] = uint8((kIRQ & 0xFF00) >> 8)
 }
 
-func Step(c *Chip) (cycles int, err error) {
-	for {
-		err = c.Tick()
-		c.TickDone()
-		cycles++
-		if c.InstructionDone() {
-			break
-		}
-	}
-	return
+func Step(c *Chip) (int, error) {
+	info, err := c.Step()
+	return info.Cycles, err
 }
 
 func Setup(ftl func(string, ...interface{}), cpu *ChipDef, fill uint8, vector uint16) (*Chip, *flatMemory) {
@@ -382,7 +375,7 @@ func TestNOP(t *testing.T) {
 		test := test
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
-			c, r := Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, test.fill, test.haltVector)
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, test.fill, test.haltVector)
 			canonical := r
 
 			// Set things up so we execute 1000 NOP's before halting
@@ -504,11 +497,11 @@ func BenchmarkNOPandADC(b *testing.B) {
 		for _, test := range []uint8{0xA9, 0x6D} {
 			got := 0
 			var elapsed int64
-			c, r := Setup(b.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, test, (uint16(test)<<8)+uint16(test))
+			c, r := Setup(b.Fatalf, &ChipDef{Cpu: CPU_NMOS}, test, (uint16(test)<<8)+uint16(test))
 			if err := c.SetClock(clk); err != nil {
 				b.Fatalf("SetClock: %v", err)
 			}
-			b.Logf("avgTime: %s avgClock: %s timeRuns: %d timeAdjustCnt: %f needAdjust: %t", c.avgTime, c.avgClock, c.timeRuns, c.timeAdjustCnt, c.timeNeedAdjust)
+			b.Logf("avgClock: %s", c.avgClock)
 
 			r.addr[NMI_VECTOR] = test
 			r.addr[NMI_VECTOR+1] = test
@@ -568,7 +561,7 @@ func BenchmarkTime(b *testing.B) {
 
 func TestLoad(t *testing.T) {
 	// classic NOP and vector if executed should halt the processor.
-	c, r := Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 
 	r.addr[kRESET+0] = 0xA1 // LDA ($EA,x)
 	r.addr[kRESET+1] = 0xEA
@@ -669,7 +662,7 @@ func TestIRQandNMI(t *testing.T) {
 	const NMI = uint16(0x0202) // If executed should halt the processor but w'll put code at this PC.
 	// Setup callbacks and plumb into CPU.
 	var i, n testIRQ
-	c, r := Setup(t.Fatalf, &ChipDef{CPU_CMOS, nil, &i, &n, nil, false}, 0xEA, NMI) // Use CMOS to verify D flag always clears. Otherwise behavior is the same.
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_CMOS, Irq: &i, Nmi: &n}, 0xEA, NMI) // Use CMOS to verify D flag always clears. Otherwise behavior is the same.
 
 	r.addr[kIRQ+0] = 0x69 // ADC #AB
 	r.addr[kIRQ+1] = 0xAB
@@ -877,7 +870,7 @@ func TestIRQandNMI(t *testing.T) {
 
 func TestStore(t *testing.T) {
 	// classic NOP and vector if executed should halt the processor.
-	c, r := Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 
 	r.addr[kRESET+0] = 0x81 // STA ($EA,x)
 	r.addr[kRESET+1] = 0xEA
@@ -983,6 +976,7 @@ func TestROMs(t *testing.T) {
 		filename             string
 		cpu                  CPUType
 		nes                  bool
+		policy               *memory.PowerOnPolicy
 		init                 func(c *Chip)
 		startPC              uint16
 		traceLog             []verify
@@ -1009,6 +1003,23 @@ func TestROMs(t *testing.T) {
 			expectedCycles:       96241367,
 			expectedInstructions: 30646177,
 		},
+		{
+			name:     "Functional test CMOS",
+			filename: "6502_functional_test.bin",
+			cpu:      CPU_CMOS,
+			startPC:  0x400,
+			endCheck: func(oldPC uint16, c *Chip) bool {
+				return oldPC == c.PC
+			},
+			successCheck: func(oldPC uint16, c *Chip) error {
+				if c.PC == 0x3469 {
+					return nil
+				}
+				return fmt.Errorf("CPU looping at PC: 0x%.4X", oldPC)
+			},
+			expectedCycles:       96561324,
+			expectedInstructions: 30646177,
+		},
 		// The next tests (up to and including vsbx.bin) all come from http://nesdev.com/6502_cpu.txt
 		// NOTE: They are hard to debug even with the ring buffer since we don't snapshot memory
 		//       state and the test itself is self modifying code...So you'll have to use the register values
@@ -1202,7 +1213,10 @@ func TestROMs(t *testing.T) {
 			name:     "Undocumented opcodes test",
 			filename: "undocumented.bin",
 			cpu:      CPU_NMOS,
-			startPC:  0xC000,
+			// OAL acts randomly and the first choice made determines the path through the loop
+			// testing it. Seed this so the instruction/cycle counts are stable.
+			policy:  memory.NewSeededPowerOnPolicy(3),
+			startPC: 0xC000,
 			endCheck: func(oldPC uint16, c *Chip) bool {
 				return oldPC == c.PC
 			},
@@ -1212,8 +1226,7 @@ func TestROMs(t *testing.T) {
 				}
 				return nil
 			},
-			// We can't compute cycle counts since we're testing iOAL which has random behavior.
-			expectedCycles:       0,
+			expectedCycles:       5175,
 			expectedInstructions: 2435,
 		},
 		{
@@ -1317,7 +1330,7 @@ func TestROMs(t *testing.T) {
 			}
 			// Initialize as always but then we'll overwrite it with a ROM image.
 			// For this we'll use BRK and a vector which if executed should halt the processor.
-			c, r := Setup(t.Fatalf, &ChipDef{test.cpu, nil, nil, nil, nil, false}, 0x00, 0x0202)
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: test.cpu, PowerOnPolicy: test.policy}, 0x00, 0x0202)
 
 			// We're just assuming these aren't that large so reading into RAM is fine.
 			rom, err := ioutil.ReadFile(filepath.Join(testDir, test.filename))
@@ -1462,7 +1475,7 @@ func TestROMs(t *testing.T) {
 }
 
 func TestSetClock(t *testing.T) {
-	c, _ := Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	if err := c.SetClock(1 * time.Nanosecond); err == nil {
 		t.Error("Should have gotten an error for too short of a clock duration")
 	}
@@ -1482,7 +1495,7 @@ func TestSetClock(t *testing.T) {
 	if err := c.SetClock(1 * time.Second); err != nil {
 		t.Errorf("Unexpected error setting clock: %v", err)
 	}
-	t.Logf("avgTime: %s avgClock: %s timeRuns: %d timeAdjustCnt: %f needAdjust: %t", c.avgTime, c.avgClock, c.timeRuns, c.timeAdjustCnt, c.timeNeedAdjust)
+	t.Logf("avgClock: %s", c.avgClock)
 
 	s := time.Now()
 	err := c.Tick()
@@ -1495,29 +1508,104 @@ func TestSetClock(t *testing.T) {
 		t.Errorf("Unexpected error on execution: %v", err)
 	}
 
-	// We'll accept 90% here since it turns out a tight loop of time.Now calls (6M likely) gets lots of
-	// cpu caching and runs faster then expected. Of course in reality we're not planning on running
+	// We'll accept 90% here to allow for host timer granularity. Of course in reality we're not planning on running
 	// with 1 Hz clocks either...Also this is NOP which is the fastest instruction to run and not completely typical.
 	exp := time.Duration(float64(0.90) * float64(1*time.Second))
 	if got, want := diff, exp; got < want {
-		t.Errorf("Didn't run long enough. got %s and want at least %s for %d avg and %d runs", got, want, c.avgClock, c.timeRuns)
+		t.Errorf("Didn't run long enough. got %s and want at least %s for %s avg", got, want, c.avgClock)
 	}
 	t.Logf("Expected at least %s and got %s time diff (success)", exp, diff)
 }
 
+func TestPowerOnPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		cpu    CPUType
+		policy *memory.PowerOnPolicy
+		A      uint8
+		X      uint8
+		Y      uint8
+		S      uint8
+		P      uint8
+	}{
+		{
+			name:   "Zero",
+			cpu:    CPU_NMOS,
+			policy: &memory.PowerOnPolicy{Mode: memory.POWER_ON_ZERO},
+			A:      0x00,
+			X:      0x00,
+			Y:      0x00,
+			S:      0xFD, // Reset always ends with S here.
+			P:      P_S1 | P_INTERRUPT | P_DECIMAL,
+		},
+		{
+			name:   "FF",
+			cpu:    CPU_NMOS,
+			policy: &memory.PowerOnPolicy{Mode: memory.POWER_ON_FF},
+			A:      0xFF,
+			X:      0xFF,
+			Y:      0xFF,
+			S:      0xFD,
+			P:      P_S1 | P_INTERRUPT | P_DECIMAL,
+		},
+		{
+			name:   "FF CMOS",
+			cpu:    CPU_CMOS,
+			policy: &memory.PowerOnPolicy{Mode: memory.POWER_ON_FF},
+			A:      0xFF,
+			X:      0xFF,
+			Y:      0xFF,
+			S:      0xFD,
+			P:      P_S1 | P_INTERRUPT, // CMOS always clears D on reset.
+		},
+		{
+			name:   "Pattern",
+			cpu:    CPU_NMOS,
+			policy: &memory.PowerOnPolicy{Mode: memory.POWER_ON_PATTERN, Pattern: []uint8{0x01, 0x12, 0x34, 0x56, 0x78}},
+			A:      0x12,
+			X:      0x34,
+			Y:      0x56,
+			S:      0xFD,
+			P:      P_S1 | P_INTERRUPT | P_DECIMAL,
+		},
+	}
+	for _, test := range tests {
+		c, _ := Setup(t.Fatalf, &ChipDef{Cpu: test.cpu, PowerOnPolicy: test.policy}, 0xEA, 0x0202)
+		if c.A != test.A || c.X != test.X || c.Y != test.Y || c.S != test.S || c.P != test.P {
+			t.Errorf("%s: bad power on state. Got A: %.2X X: %.2X Y: %.2X S: %.2X P: %.2X and want A: %.2X X: %.2X Y: %.2X S: %.2X P: %.2X", test.name, c.A, c.X, c.Y, c.S, c.P, test.A, test.X, test.Y, test.S, test.P)
+		}
+	}
+
+	// The same seed should always give the same state.
+	c1, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, PowerOnPolicy: memory.NewSeededPowerOnPolicy(1)}, 0xEA, 0x0202)
+	c2, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, PowerOnPolicy: memory.NewSeededPowerOnPolicy(1)}, 0xEA, 0x0202)
+	if c1.A != c2.A || c1.X != c2.X || c1.Y != c2.Y || c1.S != c2.S || c1.P != c2.P {
+		t.Errorf("Seeded chips differ:\n%s\n%s", spew.Sdump(c1), spew.Sdump(c2))
+	}
+
+	for _, p := range []*memory.PowerOnPolicy{
+		{Mode: memory.POWER_ON_PATTERN},
+		{Mode: memory.POWER_ON_MAX},
+	} {
+		if _, err := Init(&ChipDef{Cpu: CPU_NMOS, Ram: &flatMemory{}, PowerOnPolicy: p}); err == nil {
+			t.Errorf("Didn't get error for invalid policy %+v", p)
+		}
+	}
+}
+
 func TestErrorStates(t *testing.T) {
 	// Don't use Setup since we actually are testing this fails on a bad CPU.
 	r := &flatMemory{
 		fillValue:  0xEA,
 		haltVector: 0x0202,
 	}
-	_, err := Init(&ChipDef{CPU_UNIMPLMENTED, r, nil, nil, nil, false})
+	_, err := Init(&ChipDef{Cpu: CPU_UNIMPLMENTED, Ram: r})
 	if err == nil {
 		t.Error("Didn't get an error for an invalid CPU?")
 	}
 	t.Logf("logging Error: %v", err)
 	// Now get a good one
-	c, _ := Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	_, err = c.Reset()
 	if err != nil {
 		t.Errorf("Unexpected error starting reset: %v", err)
@@ -1530,7 +1618,7 @@ func TestErrorStates(t *testing.T) {
 	}
 
 	// Now get a new one
-	c, _ = Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	if err := c.Tick(); err != nil {
 		t.Errorf("Unexpected error during double Tick (first call): %v", err)
 	}
@@ -1540,7 +1628,7 @@ func TestErrorStates(t *testing.T) {
 	}
 
 	// Now get a new one
-	c, _ = Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	// Set an invalid IRQ
 	c.irqRaised = kIRQ_UNIMPLMENTED
 	err = c.Tick()
@@ -1550,7 +1638,7 @@ func TestErrorStates(t *testing.T) {
 	}
 
 	// Now get a new one
-	c, _ = Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	// Invalid opTick for a BRK instruction should error.
 	// Start at 7 because Tick immediately increments it.
 	c.opTick = 7
@@ -1565,7 +1653,7 @@ func TestErrorStates(t *testing.T) {
 	}
 
 	// Now get a new one
-	c, _ = Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	// Invalid opTick
 	c.opTick = 9
 	err = c.Tick()
@@ -1575,7 +1663,7 @@ func TestErrorStates(t *testing.T) {
 	}
 
 	// Now get a new one
-	c, _ = Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	for i := 0x00; i < 0xFF; i++ {
 		c.op = uint8(i)
 		c.opTick = 0
@@ -1590,13 +1678,13 @@ func TestErrorStates(t *testing.T) {
 	}
 
 	// Get a new one and test an error case on indirect JMP and bad opTick.
-	c, _ = Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	c.opTick = 6
 	if _, err := c.iJMPIndirect(); err == nil {
 		t.Error("Didn't get error on bad optick for indirect JMP on NMOS")
 	}
 	// Do it again for CMOS
-	c, _ = Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, nil, false}, 0xEA, 0x0202)
+	c, _ = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
 	c.opTick = 7
 	if _, err := c.iJMPIndirect(); err == nil {
 		t.Error("Didn't get error on bad optick for indirect JMP on CMOS")
@@ -1605,7 +1693,7 @@ func TestErrorStates(t *testing.T) {
 
 func TestCMOSIndirectJmp(t *testing.T) {
 	// Fill with 0x6C
-	c, r := Setup(t.Fatalf, &ChipDef{CPU_CMOS, nil, nil, nil, nil, false}, 0x6C, 0x6C6C)
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_CMOS}, 0x6C, 0x6C6C)
 	r.addr[kRESET+1] = 0xFF // JMP (0x1FFF)
 	r.addr[kRESET+2] = 0x2F
 	r.addr[0x2FFF] = 0xAA // Final PC value 0x55AA
@@ -1636,7 +1724,7 @@ func TestRdy(t *testing.T) {
 	var rdy testIRQ
 	holdPC := kRESET
 	isDone := false
-	c, _ := Setup(t.Fatalf, &ChipDef{CPU_NMOS, nil, nil, nil, &rdy, false}, 0xA9, holdPC)
+	c, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Rdy: &rdy}, 0xA9, holdPC)
 	if got, want := c.PC, holdPC; got != want {
 		t.Fatalf("Initial PC value wrong. Got %.4X and want %.4X", got, want)
 	}
@@ -1674,3 +1762,766 @@ func TestRdy(t *testing.T) {
 		t.Fatalf("A register didn't load. Got %.2X and want %.2X", got, want)
 	}
 }
+
+func TestRdyWrites(t *testing.T) {
+	tests := []struct {
+		name   string
+		cpu    CPUType
+		prog   []uint8
+		before int // Ticks to run before raising RDY.
+		writes []uint16
+	}{
+		{
+			name:   "NMOS JSR",
+			cpu:    CPU_NMOS,
+			prog:   []uint8{0x20, 0x00, 0x40}, // JSR 4000
+			before: 3,
+			writes: []uint16{0x01FF, 0x01FE},
+		},
+		{
+			name:   "NMOS INC",
+			cpu:    CPU_NMOS,
+			prog:   []uint8{0xEE, 0x00, 0x30}, // INC 3000
+			before: 4,
+			writes: []uint16{0x3000, 0x3000},
+		},
+		{
+			name:   "NMOS LDA",
+			cpu:    CPU_NMOS,
+			prog:   []uint8{0xAD, 0x00, 0x30}, // LDA 3000
+			before: 2,
+		},
+		{
+			name:   "CMOS JSR",
+			cpu:    CPU_CMOS,
+			prog:   []uint8{0x20, 0x00, 0x40}, // JSR 4000
+			before: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var rdy testIRQ
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: test.cpu, Rdy: &rdy}, 0xEA, 0x0202)
+			for i, v := range test.prog {
+				r.addr[kRESET+uint16(i)] = v
+			}
+			c.S = 0xFF
+			if got, err := c.RunCycles(test.before); got != test.before || err != nil {
+				t.Fatalf("Can't run to start: %d - %v", got, err)
+			}
+			var log []BusAccess
+			c.SetBusLog(func(b BusAccess) { log = append(log, b) })
+			rdy.s = true
+			clocks := c.Clocks()
+			pc, s := c.PC, c.S
+			for i := 0; i < 10; i++ {
+				if err := c.Tick(); err != nil {
+					t.Fatalf("Tick failed: %v", err)
+				}
+				c.TickDone()
+			}
+			if got, want := c.Clocks()-clocks, len(test.writes); got != want {
+				t.Errorf("Wrong number of cycles ran with RDY held. Got %d and want %d", got, want)
+			}
+			if len(log) != len(test.writes) {
+				t.Fatalf("Wrong bus accesses with RDY held. Got %v and want writes to %v", log, test.writes)
+			}
+			for i, b := range log {
+				if !b.Write || b.Addr != test.writes[i] {
+					t.Errorf("Access %d wrong. Got %s and want write to %.4X", i, b, test.writes[i])
+				}
+			}
+			if len(test.writes) == 0 && (c.PC != pc || c.S != s) {
+				t.Errorf("State changed while stopped. PC: %.4X -> %.4X S: %.2X -> %.2X", pc, c.PC, s, c.S)
+			}
+			// Once released everything continues.
+			rdy.s = false
+			log = nil
+			if _, err := c.Step(); err != nil {
+				t.Fatalf("Step failed: %v", err)
+			}
+			// NMOS always stops on a read but CMOS may have stopped on a write.
+			if len(log) == 0 || (test.cpu != CPU_CMOS && log[0].Write) {
+				t.Errorf("Didn't continue with a read after release: %v", log)
+			}
+		})
+	}
+}
+
+func TestNextCycleWrites(t *testing.T) {
+	// Check the prediction before every tick of every opcode (with and without page crossings) and
+	// an interrupt against whether the tick actually wrote.
+	for _, cpu := range []CPUType{CPU_NMOS, CPU_NMOS_REV_A} {
+		for op := 0; op < 256; op++ {
+			for _, reg := range []uint8{0x00, 0xFF} {
+				for _, irq := range []bool{false, true} {
+					var i testIRQ
+					c, r := Setup(t.Fatalf, &ChipDef{Cpu: cpu, Irq: &i}, 0xEA, 0x0202)
+					if c.opcodes[op].kind == kOP_HALT {
+						continue
+					}
+					r.addr[kRESET] = uint8(op)
+					r.addr[kRESET+1] = 0x10
+					r.addr[kRESET+2] = 0x30
+					c.X, c.Y, c.S = reg, reg, 0xFF
+					c.P &^= P_INTERRUPT
+					i.s = irq
+					wrote := false
+					c.SetBusLog(func(b BusAccess) {
+						if b.Write {
+							wrote = true
+						}
+					})
+					for tick := 1; tick <= 8; tick++ {
+						want := c.nextCycleWrites()
+						wrote = false
+						if err := c.Tick(); err != nil {
+							t.Fatalf("%d: opcode %.2X irq %t tick %d failed: %v", cpu, op, irq, tick, err)
+						}
+						c.TickDone()
+						if wrote != want {
+							t.Errorf("%d: opcode %.2X X/Y %.2X irq %t tick %d: got write %t and predicted %t", cpu, op, reg, irq, tick, wrote, want)
+						}
+						if c.InstructionDone() {
+							break
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// testPin implements io.PortIn1 for testing input pins.
+type testPin struct {
+	v bool
+}
+
+func (p *testPin) Input() bool {
+	return p.v
+}
+
+func TestSyncSO(t *testing.T) {
+	var rdy testIRQ
+	so := &testPin{true}
+	c, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Rdy: &rdy, SO: so}, 0xEA, 0x0202)
+	sync := c.Sync()
+	tick := func() {
+		t.Helper()
+		if err := c.Tick(); err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+		c.TickDone()
+	}
+	// NOP is 2 cycles so SYNC alternates.
+	for i := 0; i < 6; i++ {
+		tick()
+		if got, want := sync.Output(), i%2 == 0; got != want {
+			t.Errorf("Bad SYNC on tick %d. Got %t and want %t", i, got, want)
+		}
+	}
+	// Holding RDY once SYNC goes high keeps it there (single step).
+	tick()
+	rdy.s = true
+	pc := c.PC
+	for i := 0; i < 5; i++ {
+		tick()
+		if !sync.Output() || c.PC != pc {
+			t.Fatalf("CPU didn't stop with SYNC high. SYNC: %t PC: 0x%.4X", sync.Output(), c.PC)
+		}
+	}
+	rdy.s = false
+	tick()
+	if sync.Output() {
+		t.Error("SYNC still high after release")
+	}
+
+	c.P &^= P_OVERFLOW
+	so.v = false
+	tick()
+	if c.P&P_OVERFLOW == 0x00 {
+		t.Error("V not set on falling edge of SO")
+	}
+	// Staying low isn't another edge.
+	c.P &^= P_OVERFLOW
+	tick()
+	if c.P&P_OVERFLOW != 0x00 {
+		t.Error("V set while SO held low")
+	}
+	so.v = true
+	tick()
+	if c.P&P_OVERFLOW != 0x00 {
+		t.Error("V set on rising edge of SO")
+	}
+	so.v = false
+	tick()
+	if c.P&P_OVERFLOW == 0x00 {
+		t.Error("V not set on 2nd falling edge of SO")
+	}
+
+	// The 6510 has no SO pin.
+	so.v = true
+	c, _ = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS_6510, SO: so}, 0xEA, 0x0202)
+	c.P &^= P_OVERFLOW
+	so.v = false
+	tick()
+	if c.P&P_OVERFLOW != 0x00 {
+		t.Error("V set by SO on 6510")
+	}
+}
+
+func TestStepAPI(t *testing.T) {
+	var irq, rdy testIRQ
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Irq: &irq, Rdy: &rdy}, 0xEA, 0x0202)
+	r.addr[kRESET+0] = 0xAD // LDA 0x1234
+	r.addr[kRESET+1] = 0x34
+	r.addr[kRESET+2] = 0x12
+	r.addr[0x1234] = 0x56
+	r.addr[kIRQ] = 0x02 // HLT
+	c.P &^= P_INTERRUPT
+
+	info, err := c.Step()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := (StepInfo{PC: kRESET, Opcode: 0xAD, Cycles: 4}); info != want || c.A != 0x56 {
+		t.Errorf("Bad step for LDA. Got %+v and want %+v (A: 0x%.2X)", info, want, c.A)
+	}
+
+	// Hold RDY partway through a NOP. Step shouldn't block and should pick up where it left off.
+	if _, err := c.RunCycles(1); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	rdy.s = true
+	for i := 0; i < 10; i++ {
+		info, err = c.Step()
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := (StepInfo{PC: kRESET + 3, Opcode: 0xEA, Idle: true}); info != want {
+			t.Fatalf("Bad step while stalled. Got %+v and want %+v", info, want)
+		}
+	}
+	if got, err := c.RunCycles(10); got != 0 || err != nil {
+		t.Fatalf("RunCycles ran while RDY held: %d - %v", got, err)
+	}
+	rdy.s = false
+	info, err = c.Step()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := (StepInfo{PC: kRESET + 3, Opcode: 0xEA, Cycles: 1}); info != want {
+		t.Errorf("Bad step after stall. Got %+v and want %+v", info, want)
+	}
+
+	// Run some NOPs and then take an interrupt.
+	cycles, err := c.RunUntil(func(c *Chip) bool { return c.PC == kRESET+8 })
+	if err != nil || cycles != 8 {
+		t.Fatalf("Bad RunUntil. Got %d cycles and err %v", cycles, err)
+	}
+	irq.s = true
+	info, err = c.Step()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	irq.s = false
+	if want := (StepInfo{PC: kRESET + 8, Opcode: 0xEA, Interrupt: true, Cycles: 7}); info != want || c.PC != kIRQ {
+		t.Errorf("Bad step for IRQ. Got %+v and want %+v (PC: 0x%.4X)", info, want, c.PC)
+	}
+
+	// Halts should be reported as errors and keep doing so.
+	for i := 0; i < 3; i++ {
+		info, err = c.Step()
+		if _, ok := err.(HaltOpcode); !ok {
+			t.Fatalf("Didn't get halt: %T - %v", err, err)
+		}
+		if info.PC != kIRQ || info.Opcode != 0x02 || info.Idle {
+			t.Errorf("Bad step for halt. Got %+v", info)
+		}
+	}
+	if _, err := c.RunUntil(func(*Chip) bool { return false }); err == nil {
+		t.Error("RunUntil didn't return error when halted")
+	}
+}
+
+func TestCMOSNOP(t *testing.T) {
+	tests := []struct {
+		name   string
+		ops    []uint8
+		cycles int
+		pcBump uint16
+	}{
+		{
+			name:   "NOP #i",
+			ops:    []uint8{0x02, 0x22, 0x42, 0x62, 0x82, 0xC2, 0xE2},
+			cycles: 2,
+			pcBump: 2,
+		},
+		{
+			name:   "NOP d",
+			ops:    []uint8{0x44},
+			cycles: 3,
+			pcBump: 2,
+		},
+		{
+			name:   "NOP d,x",
+			ops:    []uint8{0x54, 0xD4, 0xF4},
+			cycles: 4,
+			pcBump: 2,
+		},
+		{
+			name:   "NOP a",
+			ops:    []uint8{0xDC, 0xFC},
+			cycles: 4,
+			pcBump: 3,
+		},
+		{
+			name:   "NOP a (8 ticks)",
+			ops:    []uint8{0x5C},
+			cycles: 8,
+			pcBump: 3,
+		},
+		{
+			name: "1 byte NOP",
+			ops: []uint8{
+				0x03, 0x13, 0x23, 0x33, 0x43, 0x53, 0x63, 0x73, 0x83, 0x93, 0xA3, 0xB3, 0xC3, 0xD3, 0xE3, 0xF3,
+				0x0B, 0x1B, 0x2B, 0x3B, 0x4B, 0x5B, 0x6B, 0x7B, 0x8B, 0x9B, 0xAB, 0xBB, 0xEB, 0xFB,
+			},
+			cycles: 1,
+			pcBump: 1,
+		},
+	}
+	for _, test := range tests {
+		for _, op := range test.ops {
+			t.Run(fmt.Sprintf("%s - 0x%.2X", test.name, op), func(t *testing.T) {
+				c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_CMOS}, op, 0x0202)
+				canonical := *r
+				saved := *c
+				for i := 0; i < 1000; i++ {
+					pc := c.PC
+					cycles, err := Step(c)
+					if err != nil {
+						t.Fatalf("Unexpected error at PC: 0x%.4X - %v", pc, err)
+					}
+					if got, want := cycles, test.cycles; got != want {
+						t.Fatalf("Didn't cycle as expected. Got %d want %d on PC: 0x%.4X", got, want, pc)
+					}
+					if got, want := c.PC, pc+test.pcBump; got != want {
+						t.Fatalf("PC didn't increment by %d. Got 0x%.4X and started with 0x%.4X", test.pcBump, got, pc)
+					}
+					if saved.A != c.A || saved.X != c.X || saved.Y != c.Y || saved.S != c.S || saved.P != c.P {
+						t.Fatalf("Registers changed at PC: 0x%.4X\nGot  %v\nWwant %v", pc, c, saved)
+					}
+					if r.addr != canonical.addr {
+						t.Fatalf("Memory changed unexpectedly at PC: 0x%.4X", pc)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestCMOSInstructions(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []uint8
+		setup   func(c *Chip, r *flatMemory)
+		cycles  []int
+		check   func(c *Chip, r *flatMemory) error
+	}{
+		{
+			name:    "BRA",
+			program: []uint8{0x80, 0x10},
+			cycles:  []int{3},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := c.PC, kRESET+2+0x10; got != want {
+					return fmt.Errorf("PC wrong. Got 0x%.4X and want 0x%.4X", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "BRA page cross",
+			program: []uint8{0x80, 0xF0},
+			cycles:  []int{4},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := c.PC, kRESET+2-0x10; got != want {
+					return fmt.Errorf("PC wrong. Got 0x%.4X and want 0x%.4X", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "PHX/PLY/PHY/PLX",
+			program: []uint8{0xDA, 0x7A, 0x5A, 0xA0, 0x00, 0xFA},
+			setup: func(c *Chip, r *flatMemory) {
+				c.X = 0x80
+				c.Y = 0x00
+			},
+			cycles: []int{3, 4, 3, 2, 4},
+			check: func(c *Chip, r *flatMemory) error {
+				if c.X != 0x80 || c.Y != 0x00 {
+					return fmt.Errorf("registers wrong. Got X: 0x%.2X Y: 0x%.2X", c.X, c.Y)
+				}
+				if c.P&P_NEGATIVE == 0x00 {
+					return fmt.Errorf("N not set after PLX. P: 0x%.2X", c.P)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "STZ",
+			program: []uint8{0x64, 0x10, 0x74, 0x10, 0x9C, 0x00, 0x30, 0x9E, 0x00, 0x30},
+			setup: func(c *Chip, r *flatMemory) {
+				c.X = 0x01
+				r.addr[0x10] = 0xFF
+				r.addr[0x11] = 0xFF
+				r.addr[0x3000] = 0xFF
+				r.addr[0x3001] = 0xFF
+			},
+			cycles: []int{3, 4, 4, 5},
+			check: func(c *Chip, r *flatMemory) error {
+				for _, a := range []uint16{0x10, 0x11, 0x3000, 0x3001} {
+					if r.addr[a] != 0x00 {
+						return fmt.Errorf("0x%.4X not cleared. Got 0x%.2X", a, r.addr[a])
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:    "TSB/TRB",
+			program: []uint8{0x04, 0x10, 0x1C, 0x00, 0x30},
+			setup: func(c *Chip, r *flatMemory) {
+				c.A = 0x0F
+				r.addr[0x10] = 0xF0
+				r.addr[0x3000] = 0xFF
+			},
+			cycles: []int{5, 6},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := r.addr[0x10], uint8(0xFF); got != want {
+					return fmt.Errorf("TSB wrong. Got 0x%.2X and want 0x%.2X", got, want)
+				}
+				if got, want := r.addr[0x3000], uint8(0xF0); got != want {
+					return fmt.Errorf("TRB wrong. Got 0x%.2X and want 0x%.2X", got, want)
+				}
+				// TRB found bits in common so Z should be clear.
+				if c.P&P_ZERO != 0x00 {
+					return fmt.Errorf("Z set after TRB. P: 0x%.2X", c.P)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "INC A/DEC A",
+			program: []uint8{0x1A, 0x3A},
+			setup: func(c *Chip, r *flatMemory) {
+				c.A = 0x00
+			},
+			cycles: []int{2, 2},
+			check: func(c *Chip, r *flatMemory) error {
+				if c.A != 0x00 || c.P&P_ZERO == 0x00 {
+					return fmt.Errorf("A/P wrong. A: 0x%.2X P: 0x%.2X", c.A, c.P)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "BIT #i",
+			program: []uint8{0x89, 0xC0},
+			setup: func(c *Chip, r *flatMemory) {
+				c.A = 0x01
+				c.P = P_S1
+			},
+			cycles: []int{2},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := c.P, P_S1|P_ZERO; got != want {
+					return fmt.Errorf("P wrong. Got 0x%.2X and want 0x%.2X", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "BIT d,x/a,x",
+			program: []uint8{0x34, 0x10, 0x3C, 0xFF, 0x30},
+			setup: func(c *Chip, r *flatMemory) {
+				c.A = 0xFF
+				c.X = 0x01
+				c.P = P_S1
+				r.addr[0x11] = 0x40
+				r.addr[0x3100] = 0x80
+			},
+			cycles: []int{4, 5},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := c.P, P_S1|P_NEGATIVE; got != want {
+					return fmt.Errorf("P wrong. Got 0x%.2X and want 0x%.2X", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "(d) mode",
+			program: []uint8{0xB2, 0x20, 0x72, 0x20, 0x92, 0x22},
+			setup: func(c *Chip, r *flatMemory) {
+				c.P = P_S1
+				r.addr[0x20] = 0x00
+				r.addr[0x21] = 0x30
+				r.addr[0x22] = 0x01
+				r.addr[0x23] = 0x30
+				r.addr[0x3000] = 0x21
+			},
+			cycles: []int{5, 5, 5},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := r.addr[0x3001], uint8(0x42); got != want {
+					return fmt.Errorf("Stored value wrong. Got 0x%.2X and want 0x%.2X", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "RMB/SMB",
+			program: []uint8{0x07, 0x10, 0xF7, 0x11},
+			setup: func(c *Chip, r *flatMemory) {
+				r.addr[0x10] = 0xFF
+				r.addr[0x11] = 0x00
+			},
+			cycles: []int{5, 5},
+			check: func(c *Chip, r *flatMemory) error {
+				if r.addr[0x10] != 0xFE || r.addr[0x11] != 0x80 {
+					return fmt.Errorf("bits wrong. Got 0x%.2X and 0x%.2X", r.addr[0x10], r.addr[0x11])
+				}
+				return nil
+			},
+		},
+		{
+			name:    "BBR taken/BBS not taken",
+			program: []uint8{0x0F, 0x10, 0x03, 0x00, 0x00, 0x00, 0x8F, 0x10, 0x05},
+			setup: func(c *Chip, r *flatMemory) {
+				r.addr[0x10] = 0xFE
+			},
+			cycles: []int{6, 5},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := c.PC, kRESET+9; got != want {
+					return fmt.Errorf("PC wrong. Got 0x%.4X and want 0x%.4X", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "BBS page cross",
+			program: []uint8{0xFF, 0x10, 0xF0},
+			setup: func(c *Chip, r *flatMemory) {
+				r.addr[0x10] = 0x80
+			},
+			cycles: []int{7},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := c.PC, kRESET+3-0x10; got != want {
+					return fmt.Errorf("PC wrong. Got 0x%.4X and want 0x%.4X", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "JMP (a,x)",
+			program: []uint8{0x7C, 0xFF, 0x30},
+			setup: func(c *Chip, r *flatMemory) {
+				c.X = 0x01
+				r.addr[0x3100] = 0x34
+				r.addr[0x3101] = 0x12
+			},
+			cycles: []int{6},
+			check: func(c *Chip, r *flatMemory) error {
+				if got, want := c.PC, uint16(0x1234); got != want {
+					return fmt.Errorf("PC wrong. Got 0x%.4X and want 0x%.4X", got, want)
+				}
+				return nil
+			},
+		},
+		{
+			name:    "a,x RMW timing",
+			program: []uint8{0x1E, 0x00, 0x30, 0x5E, 0xFF, 0x30, 0xFE, 0x00, 0x30},
+			setup: func(c *Chip, r *flatMemory) {
+				c.X = 0x01
+				r.addr[0x3001] = 0x01
+				r.addr[0x3100] = 0x02
+			},
+			cycles: []int{6, 7, 7},
+			check: func(c *Chip, r *flatMemory) error {
+				if r.addr[0x3001] != 0x03 || r.addr[0x3100] != 0x01 {
+					return fmt.Errorf("RMW values wrong. Got 0x%.2X and 0x%.2X", r.addr[0x3001], r.addr[0x3100])
+				}
+				return nil
+			},
+		},
+		{
+			name:    "Decimal ADC/SBC",
+			program: []uint8{0xF8, 0x18, 0x69, 0x01, 0xE9, 0x01},
+			setup: func(c *Chip, r *flatMemory) {
+				c.A = 0x99
+			},
+			cycles: []int{2, 2, 3, 3},
+			check: func(c *Chip, r *flatMemory) error {
+				// 99 + 1 = 00 (carry set so no borrow) - 1 = 99 with N set based on the BCD result.
+				if got, want := c.A, uint8(0x99); got != want {
+					return fmt.Errorf("A wrong. Got 0x%.2X and want 0x%.2X", got, want)
+				}
+				if c.P&P_NEGATIVE == 0x00 || c.P&P_ZERO != 0x00 {
+					return fmt.Errorf("N/Z wrong. P: 0x%.2X", c.P)
+				}
+				return nil
+			},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_CMOS}, 0xEA, 0x0202)
+			copy(r.addr[kRESET:], test.program)
+			if test.setup != nil {
+				test.setup(c, r)
+			}
+			for i, want := range test.cycles {
+				pc := c.PC
+				got, err := Step(c)
+				if err != nil {
+					t.Fatalf("%d: Unexpected error at PC: 0x%.4X - %v", i, pc, err)
+				}
+				if got != want {
+					t.Errorf("%d: Wrong cycle count at PC: 0x%.4X. Got %d and want %d", i, pc, got, want)
+				}
+			}
+			if err := test.check(c, r); err != nil {
+				t.Errorf("%v\nstate: %s", err, spew.Sdump(c))
+			}
+		})
+	}
+}
+
+func TestCMOSWaitStop(t *testing.T) {
+	var irq testIRQ
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_CMOS, Irq: &irq}, 0xEA, 0x0202)
+	r.addr[kRESET] = 0xCB // WAI
+	r.addr[kIRQ] = 0xDB   // STP
+
+	cycles, err := Step(c)
+	if err != nil {
+		t.Fatalf("Unexpected error from WAI: %v", err)
+	}
+	if got, want := cycles, 3; got != want {
+		t.Errorf("WAI wrong cycle count. Got %d and want %d", got, want)
+	}
+	// Nothing should happen while waiting.
+	for i := 0; i < 100; i++ {
+		info, err := c.Step()
+		if err != nil {
+			t.Fatalf("Unexpected error while waiting: %v", err)
+		}
+		if !info.Idle {
+			t.Fatalf("Step not idle while waiting: %+v", info)
+		}
+		if got, want := c.PC, kRESET+1; got != want {
+			t.Fatalf("PC changed while waiting. Got 0x%.4X and want 0x%.4X", got, want)
+		}
+	}
+	// Raise an IRQ which should run the interrupt sequence and land on the STP.
+	irq.s = true
+	cycles, err = Step(c)
+	if err != nil {
+		t.Fatalf("Unexpected error from IRQ: %v", err)
+	}
+	irq.s = false
+	if got, want := cycles, 7; got != want {
+		t.Errorf("IRQ wrong cycle count. Got %d and want %d", got, want)
+	}
+	if got, want := c.PC, kIRQ; got != want {
+		t.Fatalf("PC wrong after IRQ. Got 0x%.4X and want 0x%.4X", got, want)
+	}
+	cycles, err = Step(c)
+	if got, want := cycles, 3; got != want {
+		t.Errorf("STP wrong cycle count. Got %d and want %d", got, want)
+	}
+	e, ok := err.(HaltOpcode)
+	if !ok || e.Opcode != 0xDB {
+		t.Fatalf("Didn't stop due to STP: %T - %v", err, err)
+	}
+	// Should stay stopped until reset.
+	pc := c.PC
+	if _, err := Step(c); err == nil || c.PC != pc {
+		t.Fatalf("CPU didn't stay stopped. PC: 0x%.4X - %v", c.PC, err)
+	}
+	for {
+		done, err := c.Reset()
+		if err != nil {
+			t.Fatalf("Reset returned error: %v", err)
+		}
+		if done {
+			break
+		}
+	}
+	if c.P&P_DECIMAL != 0x00 {
+		t.Errorf("Reset didn't clear D on CMOS. P: 0x%.2X", c.P)
+	}
+	if _, err := Step(c); err != nil {
+		t.Errorf("Still getting error after resetting - %v", err)
+	}
+}
+
+type testPort struct {
+	val uint8
+}
+
+func (t *testPort) Input() uint8 {
+	return t.val
+}
+
+func TestNMOS6510Port(t *testing.T) {
+	in := &testPort{0x10}
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS_6510, Port: in}, 0xEA, 0x0202)
+	r.addr[0x0000] = 0xAA
+	r.addr[0x0001] = 0x55
+	// LDA #$EF, STA $00, LDA #$87, STA $01, LDA $01, LDX $00
+	copy(r.addr[kRESET:], []uint8{0xA9, 0xEF, 0x85, 0x00, 0xA9, 0x87, 0x85, 0x01, 0xA5, 0x01, 0xA6, 0x00})
+	if got, want := c.Port().Output(), uint8(0xFF); got != want {
+		t.Errorf("Port output wrong after reset. Got 0x%.2X and want 0x%.2X", got, want)
+	}
+	for i := 0; i < 6; i++ {
+		if _, err := Step(c); err != nil {
+			t.Fatalf("Unexpected error at PC: 0x%.4X - %v", c.PC, err)
+		}
+	}
+	// Bit 4 is the only input so it comes from the port input.
+	if got, want := c.A, uint8(0x97); got != want {
+		t.Errorf("Port data read wrong. Got 0x%.2X and want 0x%.2X", got, want)
+	}
+	if got, want := c.X, uint8(0xEF); got != want {
+		t.Errorf("Port DDR read wrong. Got 0x%.2X and want 0x%.2X", got, want)
+	}
+	if got, want := c.Port().Output(), uint8(0x97); got != want {
+		t.Errorf("Port output wrong. Got 0x%.2X and want 0x%.2X", got, want)
+	}
+	// The underlying RAM should never have been touched.
+	if r.addr[0x0000] != 0xAA || r.addr[0x0001] != 0x55 {
+		t.Errorf("RAM at 0x0000/0x0001 changed. Got 0x%.2X 0x%.2X", r.addr[0x0000], r.addr[0x0001])
+	}
+
+	// Switch bits 6/7 to input. Bit 7 was high so it should float high until it decays.
+	c.write(0x0000, 0x2F)
+	if got, want := c.read(0x0001)&0xC0, uint8(0x80); got != want {
+		t.Errorf("Floating bits wrong. Got 0x%.2X and want 0x%.2X", got, want)
+	}
+	c.clocks += kPORT_FLOAT_CYCLES
+	if got, want := c.read(0x0001)&0xC0, uint8(0x00); got != want {
+		t.Errorf("Floating bits didn't decay. Got 0x%.2X and want 0x%.2X", got, want)
+	}
+
+	// A plain NMOS has no port so it should go to RAM.
+	c, r = Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Port: in}, 0xEA, 0x0202)
+	r.addr[0x0001] = 0x55
+	if got, want := c.read(0x0001), uint8(0x55); got != want {
+		t.Errorf("NMOS read of 0x0001 wrong. Got 0x%.2X and want 0x%.2X", got, want)
+	}
+	if c.Port() != nil {
+		t.Error("NMOS shouldn't have a port")
+	}
+}
@@ -0,0 +1,338 @@
+package cpu
+
+import (
+	"fmt"
+)
+
+// Core is an enumeration of the ways Step() can run instructions.
+type Core int
+
+const (
+	CORE_UNIMPLEMENTED Core = iota // Start of valid cores.
+	CORE_ACCURATE                  // Each instruction is run as a series of Tick() calls so every bus access happens on the correct cycle.
+	CORE_FAST                      // Each instruction is run in a single call. See SetCore for the differences.
+	CORE_MAX                       // End of cores.
+)
+
+// String implements fmt.Stringer for Core.
+func (c Core) String() string {
+	switch c {
+	case CORE_ACCURATE:
+		return "ACCURATE"
+	case CORE_FAST:
+		return "FAST"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(c))
+}
+
+// SetCore selects how Step() (and so RunUntil) runs instructions. Tick() is always cycle accurate
+// and the cores can be switched between any two calls.
+//
+// CORE_FAST runs a whole instruction (or interrupt sequence) at once. Registers, flags, memory contents
+// and cycle counts are identical to CORE_ACCURATE (including undocumented opcodes and decimal mode) but:
+//
+// The dummy reads/writes made while computing addresses and by RMW instructions aren't done.
+//
+// IRQ, NMI, RDY and SO are only checked between instructions.
+//
+// Bus log entries are only made for the accesses which remain and all report the first cycle of
+// the instruction. The profiler and the SYNC output aren't updated.
+//
+// This makes it suitable for systems which only need instruction level timing (or for running ahead
+// quickly) while ones which line up other chips on each cycle should use Tick() or CORE_ACCURATE.
+func (p *Chip) SetCore(c Core) error {
+	if c <= CORE_UNIMPLEMENTED || c >= CORE_MAX {
+		return InvalidCPUState{fmt.Sprintf("core %d is invalid", c)}
+	}
+	p.core = c
+	return nil
+}
+
+// Core returns the core Step() is currently using.
+func (p *Chip) Core() Core {
+	return p.core
+}
+
+// stepFast implements Step() for CORE_FAST. It must only be called between instructions.
+func (p *Chip) stepFast() (StepInfo, error) {
+	if !p.tickDone {
+		p.opDone = true
+		return StepInfo{PC: p.opPC, Opcode: p.op}, InvalidCPUState{"called Step() without calling TickDone() at end of last cycle"}
+	}
+	p.sampleSO()
+	if p.rdy != nil && p.rdy.Raised() {
+		// The opcode fetch is a read so every CPU type stops here.
+		return StepInfo{PC: p.opPC, Opcode: p.op, Idle: true}, nil
+	}
+	if len(p.breakpoints) > 0 && !p.halted && !p.waiting {
+		if err := p.checkExecBreak(); err != nil {
+			return StepInfo{PC: p.opPC, Opcode: p.op}, err
+		}
+	}
+	if p.irqRaised < kIRQ_NONE || p.irqRaised >= kIRQ_MAX {
+		p.opDone = true
+		return StepInfo{PC: p.opPC, Opcode: p.op}, InvalidCPUState{fmt.Sprintf("p.irqRaised is invalid: %d", p.irqRaised)}
+	}
+	if p.halted {
+		p.clocks++
+		if p.pacer != nil {
+			p.pacer.Tick(1)
+		}
+		return StepInfo{PC: p.opPC, Opcode: p.op, Cycles: 1}, HaltOpcode{p.haltOpcode}
+	}
+
+	var irq, nmi bool
+	if p.irq != nil {
+		irq = p.irq.Raised()
+	}
+	if p.nmi != nil {
+		nmi = p.nmi.Raised()
+	}
+	if p.waiting {
+		if !irq && !nmi {
+			p.clocks++
+			if p.pacer != nil {
+				p.pacer.Tick(1)
+			}
+			return StepInfo{PC: p.opPC, Opcode: p.op, Cycles: 1, Idle: true}, nil
+		}
+		p.waiting = false
+	}
+	before := p.irqRaised
+	switch {
+	case nmi && p.irqRaised != kIRQ_NMI:
+		p.irqRaised = kIRQ_NMI
+	case irq && p.irqRaised == kIRQ_NONE:
+		p.irqRaised = kIRQ_IRQ
+	}
+	if p.ints != nil {
+		// Sampled for the opcode fetch about to happen.
+		p.ints.raised(p, before, p.clocks+1, false)
+	}
+
+	start := p.clocks
+	// Counted now so the tracer sees the same clock as it does from Tick().
+	p.clocks++
+	p.opTick = 1
+	p.opPC = p.PC
+	p.opDone = false
+	p.addrDone = false
+	p.extraTick = false
+	p.runningInterrupt = p.irqRaised != kIRQ_NONE && !p.skipInterrupt
+	kind := BUS_OPCODE
+	if p.runningInterrupt {
+		kind = BUS_INTERRUPT
+	}
+	p.op = p.readAs(kind, p.PC)
+	if p.ints != nil {
+		p.ints.opcode(p)
+	}
+
+	var err error
+	if p.runningInterrupt {
+		err = p.fastInterrupt()
+	} else {
+		p.PC++
+		err = p.fastOpcode()
+	}
+	p.flushBus()
+	info := StepInfo{PC: p.opPC, Opcode: p.op, Interrupt: p.runningInterrupt, Cycles: p.clocks - start}
+	if p.pacer != nil {
+		p.pacer.Tick(info.Cycles)
+	}
+	_, trapped := err.(OpcodeTrap)
+	if trapped && p.opcodes[p.op].cycles != 1 {
+		// Only the fetch has run. This leaves the same state as the fetch cycle of Tick() so the next
+		// Step() finishes the opcode through Tick().
+		return info, err
+	}
+	p.opTick = 0
+	p.opDone = true
+	if p.calls != nil && (err == nil || trapped) {
+		p.calls.done(p)
+	}
+	if p.runningInterrupt && err == nil {
+		p.irqRaised = kIRQ_NONE
+	}
+	p.runningInterrupt = false
+	if p.history != nil && (err == nil || trapped) {
+		p.history.boundary(p)
+	}
+	if err != nil && !trapped {
+		p.haltOpcode = p.op
+		p.halted = true
+	}
+	if p.breakHit != nil {
+		hit := *p.breakHit
+		p.breakHit = nil
+		if err == nil {
+			err = hit
+		}
+	}
+	return info, err
+}
+
+// fastShiftSkip moves skipInterrupt into prevSkipInterrupt as runCycle does once an instruction starts.
+func (p *Chip) fastShiftSkip() {
+	p.prevSkipInterrupt = p.skipInterrupt
+	p.skipInterrupt = false
+}
+
+// fastInterrupt runs an IRQ/NMI sequence once the opcode has been fetched (and discarded).
+// The sequence itself is the same one Tick() uses since it has no dummy accesses to skip.
+func (p *Chip) fastInterrupt() error {
+	if len(p.breakpoints) > 0 {
+		if p.irqRaised == kIRQ_NMI {
+			p.checkBreak(BREAK_NMI, 0, 0)
+		} else {
+			p.checkBreak(BREAK_IRQ, 0, 0)
+		}
+	}
+	addr := IRQ_VECTOR
+	if p.irqRaised == kIRQ_NMI {
+		addr = NMI_VECTOR
+	}
+	for done := false; !done; {
+		p.clocks++
+		p.opTick++
+		if p.opTick == 2 {
+			p.opVal = p.readAs(BUS_INTERRUPT, p.PC)
+			p.fastShiftSkip()
+		}
+		var err error
+		if done, err = p.runInterrupt(addr, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fastOpcode runs the opcode in p.op once it has been fetched and the PC advanced past it.
+// p.clocks must already include the fetch and is advanced by the rest of the instruction.
+func (p *Chip) fastOpcode() error {
+	var traceErr error
+	if p.trace != nil {
+		traceErr = p.traceInstruction()
+	}
+	if len(p.breakpoints) > 0 && p.op == 0x00 {
+		p.checkBreak(BREAK_BRK, 0, 0)
+	}
+	if p.opChecks {
+		if trap := p.checkOpcode(); trap != nil {
+			if p.opcodes[p.op].cycles == 1 {
+				p.fastShiftSkip()
+			}
+			return *trap
+		}
+	}
+	o := &p.opcodes[p.op]
+	if o.cycles == 1 {
+		// The CMOS 1 byte NOPs are done with the fetch.
+		p.fastShiftSkip()
+		return traceErr
+	}
+
+	var err error
+	switch o.kind {
+	case kOP_LOAD, kOP_RMW, kOP_STORE, kOP_ADDR:
+		p.opVal = p.readAs(BUS_OPERAND, p.PC)
+		p.fastShiftSkip()
+		cycles := o.cycles
+		if p.fastAddr(o.mode, o.kind == kOP_STORE) {
+			switch {
+			case o.kind == kOP_LOAD || o.kind == kOP_ADDR:
+				cycles++
+			case o.mode == kMODE_ABSOLUTEX_SHIFT:
+				cycles++
+			}
+		}
+		switch o.kind {
+		case kOP_LOAD, kOP_RMW:
+			var done bool
+			if done, err = o.op(p); !done && err == nil {
+				// The CMOS decimal mode ADC/SBC tick.
+				cycles++
+				_, err = o.op(p)
+			}
+		case kOP_STORE:
+			_, err = p.store(o.val(p), p.opAddr)
+		}
+		// The fetch is already counted.
+		p.clocks += cycles - 1
+	default:
+		// Everything else either has no addressing to skip or does something unusual with it
+		// so run the same sequence Tick() does.
+		for done := false; !done; {
+			p.clocks++
+			p.opTick++
+			if p.opTick > 8 {
+				return InvalidCPUState{fmt.Sprintf("opTick %d too large (> 8)", p.opTick)}
+			}
+			if p.opTick == 2 {
+				p.opVal = p.readAs(BUS_OPERAND, p.PC)
+				p.fastShiftSkip()
+			}
+			done, err = p.processOpcode()
+			if p.opTick == 2 {
+				p.flushBus()
+			}
+			if p.halted {
+				return HaltOpcode{p.op}
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return traceErr
+}
+
+// fastAddr computes the effective address for mode into p.opAddr and (unless store is set) reads
+// the value there into p.opVal. p.opVal must hold the byte after the opcode and p.PC point at it.
+// The PC is advanced past the operand. No dummy accesses are made.
+// Returns true if indexing crossed a page boundary.
+func (p *Chip) fastAddr(mode addrMode, store bool) bool {
+	var cross bool
+	switch mode {
+	case kMODE_IMMEDIATE:
+		p.PC++
+		return false
+	case kMODE_ZP:
+		p.opAddr = uint16(p.opVal)
+	case kMODE_ZPX:
+		p.opAddr = uint16(p.opVal + p.X)
+	case kMODE_ZPY:
+		p.opAddr = uint16(p.opVal + p.Y)
+	case kMODE_INDIRECTX:
+		zp := p.opVal + p.X
+		lo := p.read(uint16(zp))
+		p.opAddr = uint16(p.read(uint16(zp+1)))<<8 | uint16(lo)
+	case kMODE_INDIRECTY, kMODE_INDIRECTZP:
+		lo := p.read(uint16(p.opVal))
+		base := uint16(p.read(uint16(p.opVal+1)))<<8 | uint16(lo)
+		p.opAddr = base
+		if mode == kMODE_INDIRECTY {
+			p.opAddr += uint16(p.Y)
+			cross = p.opAddr&0xFF00 != base&0xFF00
+		}
+	case kMODE_ABSOLUTE, kMODE_ABSOLUTEX, kMODE_ABSOLUTEY, kMODE_ABSOLUTEX_SHIFT:
+		p.PC++
+		base := uint16(p.readAs(BUS_OPERAND, p.PC))<<8 | uint16(p.opVal)
+		p.opAddr = base
+		switch mode {
+		case kMODE_ABSOLUTEX, kMODE_ABSOLUTEX_SHIFT:
+			p.opAddr += uint16(p.X)
+		case kMODE_ABSOLUTEY:
+			p.opAddr += uint16(p.Y)
+		}
+		cross = p.opAddr&0xFF00 != base&0xFF00
+	}
+	p.PC++
+	if !store {
+		p.opVal = p.read(p.opAddr)
+	}
+	return cross
+}
@@ -0,0 +1,230 @@
+package cpu
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmchacon/6502/memory"
+)
+
+// lockstep runs an accurate and a fast chip one instruction at a time and compares them after each.
+type lockstep struct {
+	t          *testing.T
+	chips      [2]*Chip
+	rams       [2]*flatMemory
+	instrs     int
+	interrupts int
+}
+
+func newLockstep(t *testing.T, def func() *ChipDef, fill uint8) *lockstep {
+	t.Helper()
+	l := &lockstep{t: t}
+	for i, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+		d := def()
+		d.Core = core
+		l.chips[i], l.rams[i] = Setup(t.Fatalf, d, fill, 0x0202)
+	}
+	return l
+}
+
+// each runs fn against both chips and rams.
+func (l *lockstep) each(fn func(c *Chip, r *flatMemory)) {
+	for i := range l.chips {
+		fn(l.chips[i], l.rams[i])
+	}
+}
+
+// step runs one instruction on both and returns false (after reporting an error) if they differ.
+func (l *lockstep) step() bool {
+	l.t.Helper()
+	var infos [2]StepInfo
+	var errs [2]error
+	for i, c := range l.chips {
+		infos[i], errs[i] = c.Step()
+	}
+	l.instrs++
+	if infos[0].Interrupt {
+		l.interrupts++
+	}
+	a, f := l.chips[0], l.chips[1]
+	if infos[0] != infos[1] || (errs[0] == nil) != (errs[1] == nil) {
+		l.t.Errorf("Instruction %d differs. Accurate %+v %v and fast %+v %v", l.instrs, infos[0], errs[0], infos[1], errs[1])
+		return false
+	}
+	if a.A != f.A || a.X != f.X || a.Y != f.Y || a.S != f.S || a.P != f.P || a.PC != f.PC || a.Clocks() != f.Clocks() {
+		l.t.Errorf("State differs after instruction %d at %.4X.\nAccurate PC: %.4X A: %.2X X: %.2X Y: %.2X S: %.2X P: %.2X clocks: %d\nFast     PC: %.4X A: %.2X X: %.2X Y: %.2X S: %.2X P: %.2X clocks: %d", l.instrs, infos[0].PC, a.PC, a.A, a.X, a.Y, a.S, a.P, a.Clocks(), f.PC, f.A, f.X, f.Y, f.S, f.P, f.Clocks())
+		return false
+	}
+	return true
+}
+
+// checkRAM reports the first address where the two memories differ.
+func (l *lockstep) checkRAM() {
+	l.t.Helper()
+	for i := range l.rams[0].addr {
+		if a, f := l.rams[0].addr[i], l.rams[1].addr[i]; a != f {
+			l.t.Errorf("Memory differs at %.4X. Accurate %.2X and fast %.2X", i, a, f)
+			return
+		}
+	}
+}
+
+func TestFastCoreROMs(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		cpu      CPUType
+		seed     int64 // Both chips use a policy with this seed (1 if unset) so they power on identically.
+		startPC  uint16
+		endPC    uint16 // The PC the ROM loops at on success.
+	}{
+		{
+			name:     "Functional test",
+			filename: "6502_functional_test.bin",
+			cpu:      CPU_NMOS,
+			startPC:  0x400,
+			endPC:    0x3469,
+		},
+		{
+			name:     "Functional test CMOS",
+			filename: "6502_functional_test.bin",
+			cpu:      CPU_CMOS,
+			startPC:  0x400,
+			endPC:    0x3469,
+		},
+		{
+			name:     "dadc test",
+			filename: "dadc.bin",
+			cpu:      CPU_NMOS,
+			startPC:  0xD000,
+			endPC:    0xD004,
+		},
+		{
+			name:     "dsbc test",
+			filename: "dsbc.bin",
+			cpu:      CPU_NMOS,
+			startPC:  0xD000,
+			endPC:    0xD004,
+		},
+		{
+			name:     "BCD test",
+			filename: "bcd_test.bin",
+			cpu:      CPU_NMOS,
+			startPC:  0xC000,
+			endPC:    0xC04B,
+		},
+		{
+			name:     "Undocumented opcodes test",
+			filename: "undocumented.bin",
+			cpu:      CPU_NMOS,
+			// OAL acts randomly so this also checks both cores draw from the policy the same way.
+			seed:    3,
+			startPC: 0xC000,
+			endPC:   0xC123,
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			rom, err := ioutil.ReadFile(filepath.Join(testDir, test.filename))
+			if err != nil {
+				t.Fatalf("Can't read ROM: %v", err)
+			}
+			seed := test.seed
+			if seed == 0 {
+				seed = 1
+			}
+			l := newLockstep(t, func() *ChipDef {
+				return &ChipDef{Cpu: test.cpu, PowerOnPolicy: memory.NewSeededPowerOnPolicy(seed)}
+			}, 0x00)
+			l.each(func(c *Chip, r *flatMemory) {
+				copy(r.addr[:], rom)
+				c.PC = test.startPC
+			})
+			for {
+				pc := l.chips[0].PC
+				if !l.step() {
+					break
+				}
+				if pc == l.chips[0].PC || pc == test.endPC {
+					if pc != test.endPC {
+						t.Errorf("Ended at %.4X and want %.4X", pc, test.endPC)
+					}
+					break
+				}
+			}
+			l.checkRAM()
+			t.Logf("Ran %d instructions", l.instrs)
+		})
+	}
+}
+
+func TestFastCoreInterrupts(t *testing.T) {
+	irqs := make(map[*Chip]*testIRQ)
+	nmis := make(map[*Chip]*testIRQ)
+	for _, cpu := range []CPUType{CPU_NMOS, CPU_CMOS} {
+		l := newLockstep(t, func() *ChipDef {
+			irq, nmi := &testIRQ{}, &testIRQ{}
+			return &ChipDef{Cpu: cpu, Irq: irq, Nmi: nmi, PowerOnPolicy: memory.NewSeededPowerOnPolicy(1)}
+		}, 0xEA)
+		l.each(func(c *Chip, r *flatMemory) {
+			irqs[c] = c.irq.(*testIRQ)
+			nmis[c] = c.nmi.(*testIRQ)
+			// CLI, SED, INC 10, ADC #$19, LDA (20),Y, JMP 1000
+			copy(r.addr[0x1000:], []uint8{0x58, 0xF8, 0xE6, 0x10, 0x69, 0x19, 0xB1, 0x20, 0x4C, 0x00, 0x10})
+			r.addr[0x20], r.addr[0x21] = 0xF0, 0x30
+			// Handler at 0x4000 is a NOP then RTI. A WAI on CMOS is run before it.
+			copy(r.addr[0x4000:], []uint8{0xEA, 0x40})
+			r.addr[IRQ_VECTOR], r.addr[IRQ_VECTOR+1] = 0x00, 0x40
+			r.addr[NMI_VECTOR], r.addr[NMI_VECTOR+1] = 0x00, 0x40
+			if cpu == CPU_CMOS {
+				r.addr[0x0FFF] = 0xCB // WAI
+			}
+			c.PC = 0x1000
+			c.Y = 0x20
+			if cpu == CPU_CMOS {
+				c.PC = 0x0FFF
+			}
+		})
+		for i := 0; i < 200; i++ {
+			l.each(func(c *Chip, r *flatMemory) {
+				irqs[c].s = i%17 >= 12
+				nmis[c].s = i%41 == 40
+				// Keep the WAI idle for a few calls before anything wakes it.
+				if i < 5 {
+					irqs[c].s = false
+				}
+			})
+			if !l.step() {
+				t.Fatalf("CPU %d: lockstep failed", cpu)
+			}
+		}
+		l.checkRAM()
+		if l.interrupts == 0 {
+			t.Errorf("CPU %d: no interrupts were run", cpu)
+		}
+	}
+}
+
+func TestSetCore(t *testing.T) {
+	c, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
+	if got, want := c.Core(), CORE_ACCURATE; got != want {
+		t.Errorf("Bad default core. Got %s and want %s", got, want)
+	}
+	if err := c.SetCore(CORE_FAST); err != nil {
+		t.Fatalf("Can't set core: %v", err)
+	}
+	if got, want := c.Core(), CORE_FAST; got != want {
+		t.Errorf("Bad core. Got %s and want %s", got, want)
+	}
+	for _, core := range []Core{CORE_UNIMPLEMENTED, CORE_MAX} {
+		if err := c.SetCore(core); err == nil {
+			t.Errorf("Didn't get error setting core %s", core)
+		}
+	}
+	if _, err := Init(&ChipDef{Cpu: CPU_NMOS, Ram: &flatMemory{}, Core: CORE_MAX}); err == nil {
+		t.Errorf("Didn't get error from Init with an invalid core")
+	}
+}
@@ -0,0 +1,335 @@
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/jmchacon/6502/memory"
+)
+
+// HistoryDef defines the parameters for a History.
+type HistoryDef struct {
+	// Instructions is the minimum number of instructions (or interrupt sequences) which can be stepped back over.
+	// Up to KeyframeEvery more are kept since history is discarded a keyframe at a time.
+	Instructions int
+	// KeyframeEvery is the number of instructions between keyframes. Stepping back restores the closest keyframe
+	// and then replays the writes since it so this trades memory for the time each step back takes.
+	KeyframeEvery int
+	// Save returns a snapshot of everything outside of the CPU which needs to go back in time (memory and any
+	// other chips). Load restores one. Both must be set or neither. If they aren't set the 64k address space of
+	// the memory.Bank the Chip was created with is copied through memory.Peek/Poke which is enough for plain RAM
+	// but not for banks with other state (I/O registers, bank switching, etc).
+	Save func() ([]byte, error)
+	Load func([]byte) error
+}
+
+// WriteRecord is a single write made by the CPU as recorded by a History.
+type WriteRecord struct {
+	Instruction int    // The position (see History.Position) before the instruction which wrote.
+	Cycle       int    // Value of Clocks() during the write.
+	PC          uint16 // The PC the instruction (or interrupt) started at.
+	Addr        uint16 // Address written.
+	Val         uint8  // Value written.
+}
+
+// String implements fmt.Stringer for WriteRecord.
+func (w WriteRecord) String() string {
+	return fmt.Sprintf("%d %d %.4X W %.4X %.2X", w.Instruction, w.Cycle, w.PC, w.Addr, w.Val)
+}
+
+// History records the execution of a Chip so it can be stepped backwards by instruction and rewound to
+// earlier marks (i.e. frames). Install one with SetHistory.
+//
+// The CPU state is kept for the start of every instruction along with a log of every write. Every
+// KeyframeEvery instructions a keyframe of everything else is also taken (see HistoryDef.Save). Going back
+// loads the closest keyframe, replays the writes from it through the memory.Bank and then restores the
+// CPU state. Anything after the point returned to is discarded so running forward again executes normally.
+//
+// Replayed writes go straight to memory (so I/O registers see them again) without any of the cycles
+// between them being run. If other chips matter they should be part of Save/Load and the history should
+// only be moved to a keyframe (i.e. with Mark/RewindMarks). The CallStack, Profiler and bus log aren't rewound.
+type History struct {
+	def        HistoryDef
+	chip       *Chip
+	entries    []histEntry // The start of each instruction from oldest to newest.
+	first      int         // Position of entries[0].
+	writes     []WriteRecord
+	firstWrite int        // Index of writes[0] in all the writes recorded.
+	keys       []keyframe // From oldest to newest. keys[0] is always entries[0].
+	mark       bool       // Set by Mark() so the next instruction start is a marked keyframe.
+	err        error      // Set if a keyframe couldn't be taken.
+}
+
+// histEntry is the state at the start of an instruction.
+type histEntry struct {
+	state  chipState
+	writes int // The number of writes recorded before this point.
+}
+
+// keyframe is a snapshot from Save taken at the start of an instruction.
+type keyframe struct {
+	pos  int // The position it was taken at.
+	data []byte
+	mark bool // Set if taken due to Mark().
+}
+
+// NewHistory returns an empty History.
+func NewHistory(def *HistoryDef) (*History, error) {
+	if def.Instructions <= 0 || def.KeyframeEvery <= 0 {
+		return nil, InvalidCPUState{fmt.Sprintf("history instructions %d and keyframe interval %d must be positive", def.Instructions, def.KeyframeEvery)}
+	}
+	if (def.Save == nil) != (def.Load == nil) {
+		return nil, InvalidCPUState{"history Save and Load must both be set or both be nil"}
+	}
+	return &History{def: *def}, nil
+}
+
+// SetHistory installs a History which records from the start of the next instruction (or now if the
+// CPU is between instructions). Passing nil removes it. Anything already recorded in h is discarded.
+func (p *Chip) SetHistory(h *History) {
+	if p.history != nil {
+		p.history.chip = nil
+	}
+	p.history = h
+	if h == nil {
+		return
+	}
+	h.chip = p
+	h.entries = nil
+	h.first = 0
+	h.writes = nil
+	h.firstWrite = 0
+	h.keys = nil
+	h.mark = false
+	h.err = nil
+	if p.opTick == 0 && !p.reset {
+		h.boundary(p)
+	}
+}
+
+// Position returns the number of instruction starts recorded since the History was installed less one.
+// This is the position the CPU is at (or the instruction it's in the middle of started at).
+// Returns -1 if nothing has been recorded yet.
+func (h *History) Position() int {
+	return h.first + len(h.entries) - 1
+}
+
+// Oldest returns the earliest position which can be returned to with Seek.
+func (h *History) Oldest() int {
+	return h.first
+}
+
+// Len returns the number of instructions which can be stepped back over.
+func (h *History) Len() int {
+	if h.chip == nil || len(h.entries) == 0 {
+		return 0
+	}
+	n := len(h.entries) - 1
+	if !h.atBoundary() {
+		// The partial instruction counts.
+		n++
+	}
+	return n
+}
+
+// atBoundary returns true if the CPU hasn't moved since the newest entry was recorded.
+func (h *History) atBoundary() bool {
+	p := h.chip
+	return p.opTick == 0 && !p.reset && p.clocks == int(h.entries[len(h.entries)-1].state.Clocks)
+}
+
+// StepBack undoes the last n instructions (or interrupt sequences). If the CPU is in the middle of an
+// instruction undoing it counts as one.
+func (h *History) StepBack(n int) error {
+	if n <= 0 || n > h.Len() {
+		return InvalidCPUState{fmt.Sprintf("can't step back %d instructions with %d recorded", n, h.Len())}
+	}
+	pos := h.Position() - n
+	if !h.atBoundary() {
+		pos++
+	}
+	return h.Seek(pos)
+}
+
+// Seek returns to the start of the instruction at position pos (see Position and WriteRecord.Instruction).
+// It must be between Oldest() and Position().
+func (h *History) Seek(pos int) error {
+	if h.chip == nil {
+		return InvalidCPUState{"history isn't installed"}
+	}
+	if h.err != nil {
+		return InvalidCPUState{fmt.Sprintf("history can't go back after failing to take a keyframe: %v", h.err)}
+	}
+	if pos < h.first || pos > h.Position() {
+		return InvalidCPUState{fmt.Sprintf("position %d isn't recorded. Have %d-%d", pos, h.first, h.Position())}
+	}
+	k := len(h.keys) - 1
+	for h.keys[k].pos > pos {
+		k--
+	}
+	if err := h.load(h.keys[k].data); err != nil {
+		return InvalidCPUState{fmt.Sprintf("can't load keyframe: %v", err)}
+	}
+	p := h.chip
+	e := &h.entries[pos-h.first]
+	for _, w := range h.writes[h.entries[h.keys[k].pos-h.first].writes-h.firstWrite : e.writes-h.firstWrite] {
+		// The 6510 I/O port is part of the CPU state.
+		if p.cpuType == CPU_NMOS_6510 && w.Addr <= kPORT_DATA {
+			continue
+		}
+		p.ram.Write(w.Addr, w.Val)
+	}
+	p.loadState(&e.state)
+	// The entry may have been recorded before TickDone() was called for the cycle.
+	p.tickDone = true
+	p.busPending = nil
+	p.breakHit = nil
+	p.breakResume = false
+
+	// Everything after this is gone.
+	h.writes = h.writes[:e.writes-h.firstWrite]
+	h.entries = h.entries[:pos-h.first+1]
+	h.keys = h.keys[:k+1]
+	h.mark = false
+	return nil
+}
+
+// Mark causes a marked keyframe to be taken at the start of the next instruction (or now if the CPU is
+// between instructions). RewindMarks can then return to it. This is intended to be called at the start
+// of each frame.
+func (h *History) Mark() {
+	if h.chip == nil || len(h.entries) == 0 || !h.atBoundary() {
+		h.mark = true
+		return
+	}
+	pos := h.Position()
+	if k := &h.keys[len(h.keys)-1]; k.pos == pos {
+		k.mark = true
+		return
+	}
+	data, err := h.save()
+	if err != nil {
+		if h.err == nil {
+			h.err = err
+		}
+		return
+	}
+	h.keys = append(h.keys, keyframe{pos: pos, data: data, mark: true})
+}
+
+// RewindMarks returns to the nth most recent mark (1 is the latest).
+func (h *History) RewindMarks(n int) error {
+	if n > 0 {
+		left := n
+		for k := len(h.keys) - 1; k >= 0; k-- {
+			if !h.keys[k].mark {
+				continue
+			}
+			if left--; left == 0 {
+				return h.Seek(h.keys[k].pos)
+			}
+		}
+	}
+	return InvalidCPUState{fmt.Sprintf("can't rewind %d marks with %d recorded", n, h.Marks())}
+}
+
+// Marks returns the number of marks which can be rewound to.
+func (h *History) Marks() int {
+	var n int
+	for _, k := range h.keys {
+		if k.mark {
+			n++
+		}
+	}
+	return n
+}
+
+// LastWrite returns the most recent recorded write to addr. Seek(w.Instruction) then returns to just
+// before the instruction which made it. Returns false if there isn't one in the history.
+func (h *History) LastWrite(addr uint16) (WriteRecord, bool) {
+	for i := len(h.writes) - 1; i >= 0; i-- {
+		if h.writes[i].Addr == addr {
+			return h.writes[i], true
+		}
+	}
+	return WriteRecord{}, false
+}
+
+// Writes returns all the recorded writes to addr from oldest to newest.
+func (h *History) Writes(addr uint16) []WriteRecord {
+	var ret []WriteRecord
+	for _, w := range h.writes {
+		if w.Addr == addr {
+			ret = append(ret, w)
+		}
+	}
+	return ret
+}
+
+// save takes a keyframe with def.Save or by copying the address space with memory.Peek.
+func (h *History) save() ([]byte, error) {
+	if h.def.Save != nil {
+		return h.def.Save()
+	}
+	ret := make([]byte, 1<<16)
+	for i := range ret {
+		ret[i] = memory.Peek(h.chip.ram, uint16(i))
+	}
+	return ret, nil
+}
+
+// load restores a keyframe from save (with memory.Poke if def.Load isn't set).
+func (h *History) load(data []byte) error {
+	if h.def.Load != nil {
+		return h.def.Load(data)
+	}
+	for i, v := range data {
+		memory.Poke(h.chip.ram, uint16(i), v)
+	}
+	return nil
+}
+
+// boundary records the start of an instruction. Called once the previous one completes.
+func (h *History) boundary(p *Chip) {
+	if h.err != nil {
+		// Recording stopped since there's no way back past this.
+		return
+	}
+	pos := h.Position() + 1
+	if len(h.keys) == 0 || h.mark || pos-h.keys[len(h.keys)-1].pos >= h.def.KeyframeEvery {
+		data, err := h.save()
+		if err != nil {
+			if h.err == nil {
+				h.err = err
+			}
+			return
+		}
+		h.keys = append(h.keys, keyframe{pos: pos, data: data, mark: h.mark})
+		h.mark = false
+	}
+	h.entries = append(h.entries, histEntry{state: p.saveState(), writes: h.firstWrite + len(h.writes)})
+
+	// Drop the oldest keyframe (and everything up to the next) as long as enough are left.
+	for len(h.keys) > 1 && h.Position()-h.keys[1].pos >= h.def.Instructions {
+		next := h.keys[1].pos
+		h.writes = h.writes[h.entries[next-h.first].writes-h.firstWrite:]
+		h.firstWrite = h.entries[next-h.first].writes
+		h.entries = h.entries[next-h.first:]
+		h.first = next
+		h.keys = h.keys[1:]
+	}
+}
+
+// write records a write by the current instruction.
+func (h *History) write(p *Chip, addr uint16, val uint8) {
+	if len(h.entries) == 0 {
+		// Nothing to return to before this.
+		return
+	}
+	h.writes = append(h.writes, WriteRecord{
+		Instruction: h.Position(),
+		Cycle:       p.clocks,
+		PC:          p.opPC,
+		Addr:        addr,
+		Val:         val,
+	})
+}
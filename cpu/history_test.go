@@ -0,0 +1,224 @@
+package cpu
+
+import (
+	"bytes"
+	"testing"
+)
+
+// historyProg loads a loop which writes to zero page, a table and the stack (via JSR) each time around.
+func historyProg(r *flatMemory) {
+	// LDX #00, INX, STX 20, TXA, STA 3000,X, JSR 2000, JMP 1002
+	copy(r.addr[0x1000:], []uint8{0xA2, 0x00, 0xE8, 0x86, 0x20, 0x8A, 0x9D, 0x00, 0x30, 0x20, 0x00, 0x20, 0x4C, 0x02, 0x10})
+	// INC 21, RTS
+	copy(r.addr[0x2000:], []uint8{0xE6, 0x21, 0x60})
+}
+
+// snapshot is the CPU and memory state at a point in time.
+type snapshot struct {
+	state []byte
+	mem   [65536]uint8
+}
+
+func takeSnapshot(t *testing.T, c *Chip, r *flatMemory) snapshot {
+	t.Helper()
+	st, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Can't save state: %v", err)
+	}
+	return snapshot{st, r.addr}
+}
+
+func checkSnapshot(t *testing.T, what string, c *Chip, r *flatMemory, want snapshot) {
+	t.Helper()
+	got := takeSnapshot(t, c, r)
+	if !bytes.Equal(got.state, want.state) {
+		t.Errorf("%s: CPU state differs. PC: %.4X A: %.2X X: %.2X S: %.2X clocks: %d", what, c.PC, c.A, c.X, c.S, c.Clocks())
+	}
+	for i := range got.mem {
+		if got.mem[i] != want.mem[i] {
+			t.Errorf("%s: memory differs at %.4X. Got %.2X and want %.2X", what, i, got.mem[i], want.mem[i])
+			return
+		}
+	}
+}
+
+func TestHistoryStepBack(t *testing.T) {
+	for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+		t.Run(core.String(), func(t *testing.T) {
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Core: core}, 0xEA, 0x0202)
+			historyProg(r)
+			c.PC = 0x1000
+			c.S = 0xFF
+			h, err := NewHistory(&HistoryDef{Instructions: 20, KeyframeEvery: 7})
+			if err != nil {
+				t.Fatalf("Can't create history: %v", err)
+			}
+			c.SetHistory(h)
+			var snaps []snapshot
+			for i := 0; i < 60; i++ {
+				snaps = append(snaps, takeSnapshot(t, c, r))
+				if _, err := c.Step(); err != nil {
+					t.Fatalf("Step failed: %v", err)
+				}
+			}
+			if got, want := h.Position(), 60; got != want {
+				t.Errorf("Bad position. Got %d and want %d", got, want)
+			}
+			n := h.Len()
+			if n < 20 || n > 20+7 {
+				t.Errorf("Bad history length %d. Want 20-27", n)
+			}
+			for i := 1; i <= n; i++ {
+				if err := h.StepBack(1); err != nil {
+					t.Fatalf("Can't step back %d: %v", i, err)
+				}
+				checkSnapshot(t, "step back", c, r, snaps[60-i])
+			}
+			if err := h.StepBack(1); err == nil {
+				t.Errorf("Didn't get error stepping back past the start")
+			}
+
+			// Running forward again repeats the same execution.
+			start := h.Position()
+			for i := start; i < 60; i++ {
+				checkSnapshot(t, "rerun", c, r, snaps[i])
+				if _, err := c.Step(); err != nil {
+					t.Fatalf("Step failed: %v", err)
+				}
+			}
+			if err := h.StepBack(5); err != nil {
+				t.Fatalf("Can't step back 5: %v", err)
+			}
+			checkSnapshot(t, "step back 5", c, r, snaps[55])
+		})
+	}
+}
+
+func TestHistoryPartialInstruction(t *testing.T) {
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
+	historyProg(r)
+	c.PC = 0x1000
+	h, err := NewHistory(&HistoryDef{Instructions: 10, KeyframeEvery: 4})
+	if err != nil {
+		t.Fatalf("Can't create history: %v", err)
+	}
+	c.SetHistory(h)
+	for i := 0; i < 4; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	// Part way into the STA 3000,X.
+	want := takeSnapshot(t, c, r)
+	if _, err := c.RunCycles(3); err != nil {
+		t.Fatalf("RunCycles failed: %v", err)
+	}
+	if got, want := h.Len(), 5; got != want {
+		t.Errorf("Bad history length. Got %d and want %d", got, want)
+	}
+	if err := h.StepBack(1); err != nil {
+		t.Fatalf("Can't step back: %v", err)
+	}
+	checkSnapshot(t, "partial", c, r, want)
+}
+
+func TestHistoryLastWrite(t *testing.T) {
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
+	historyProg(r)
+	c.PC = 0x1000
+	h, err := NewHistory(&HistoryDef{Instructions: 100, KeyframeEvery: 10})
+	if err != nil {
+		t.Fatalf("Can't create history: %v", err)
+	}
+	c.SetHistory(h)
+	for i := 0; i < 40; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	w, ok := h.LastWrite(0x0020)
+	if !ok {
+		t.Fatalf("No write to 0020 found")
+	}
+	if w.PC != 0x1003 || w.Val != c.X {
+		t.Errorf("Bad write record %s. Want PC 1003 and value %.2X", w, c.X)
+	}
+	if got, want := len(h.Writes(0x0020)), int(c.X); got != want {
+		t.Errorf("Bad number of writes to 0020. Got %d and want %d", got, want)
+	}
+	if _, ok := h.LastWrite(0x4000); ok {
+		t.Errorf("Found write to 4000 which was never written")
+	}
+	if err := h.Seek(w.Instruction); err != nil {
+		t.Fatalf("Can't seek: %v", err)
+	}
+	if c.PC != 0x1003 || r.addr[0x20] != w.Val-1 {
+		t.Errorf("Bad state before write. PC %.4X and 0020 %.2X. Want 1003 and %.2X", c.PC, r.addr[0x20], w.Val-1)
+	}
+}
+
+func TestHistoryMarks(t *testing.T) {
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
+	historyProg(r)
+	c.PC = 0x1000
+	var saves, loads int
+	h, err := NewHistory(&HistoryDef{
+		Instructions:  1000,
+		KeyframeEvery: 1000,
+		Save: func() ([]byte, error) {
+			saves++
+			return append([]byte(nil), r.addr[:]...), nil
+		},
+		Load: func(b []byte) error {
+			loads++
+			copy(r.addr[:], b)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Can't create history: %v", err)
+	}
+	c.SetHistory(h)
+	var marks []snapshot
+	for i := 0; i < 50; i++ {
+		if i%10 == 0 {
+			h.Mark()
+			marks = append(marks, takeSnapshot(t, c, r))
+		}
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	if got, want := h.Marks(), 5; got != want {
+		t.Errorf("Bad mark count. Got %d and want %d", got, want)
+	}
+	// The first mark shares the initial keyframe.
+	if got, want := saves, 5; got != want {
+		t.Errorf("Bad save count. Got %d and want %d", got, want)
+	}
+	if err := h.RewindMarks(2); err != nil {
+		t.Fatalf("Can't rewind: %v", err)
+	}
+	checkSnapshot(t, "rewind", c, r, marks[3])
+	if got, want := loads, 1; got != want {
+		t.Errorf("Bad load count. Got %d and want %d", got, want)
+	}
+	if got, want := h.Marks(), 4; got != want {
+		t.Errorf("Bad mark count after rewind. Got %d and want %d", got, want)
+	}
+	if err := h.RewindMarks(5); err == nil {
+		t.Errorf("Didn't get error rewinding too many marks")
+	}
+}
+
+func TestNewHistory(t *testing.T) {
+	for _, def := range []HistoryDef{
+		{Instructions: 0, KeyframeEvery: 1},
+		{Instructions: 1, KeyframeEvery: 0},
+		{Instructions: 1, KeyframeEvery: 1, Save: func() ([]byte, error) { return nil, nil }},
+	} {
+		if _, err := NewHistory(&def); err == nil {
+			t.Errorf("Didn't get error for %+v", def)
+		}
+	}
+}
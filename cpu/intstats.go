@@ -0,0 +1,245 @@
+package cpu
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InterruptEvent is the timing of a single IRQ or NMI from the line being raised to the handler starting.
+// All cycles are values of Clocks().
+type InterruptEvent struct {
+	NMI          bool   // True for an NMI, false for an IRQ.
+	Raised       int    // The cycle the CPU first saw the line raised.
+	Start        int    // The cycle the interrupt sequence started (the discarded opcode fetch or the BRK it took over).
+	Vector       int    // The cycle the low byte of the vector was fetched.
+	Latency      int    // Vector - Raised.
+	Handler      uint16 // The address the vector pointed to.
+	Delayed      bool   // True if an instruction had to finish (or run) before the sequence could start.
+	DelayPC      uint16 // If Delayed the PC of the last instruction which ran before the sequence.
+	DelayOp      uint8  // If Delayed the opcode of that instruction.
+	Instructions int    // The number of instructions which ran (or finished) between Raised and Start.
+	Skipped      bool   // True if the branch delay (a taken branch or the end of the previous handler) held it off for an instruction.
+}
+
+// String implements fmt.Stringer for InterruptEvent.
+func (i InterruptEvent) String() string {
+	kind := "IRQ"
+	if i.NMI {
+		kind = "NMI"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s raised %d vector %d latency %d handler %.4X", kind, i.Raised, i.Vector, i.Latency, i.Handler)
+	if i.Delayed {
+		fmt.Fprintf(&b, " delayed by %.2X at %.4X (%d instruction(s))", i.DelayOp, i.DelayPC, i.Instructions)
+	}
+	if i.Skipped {
+		b.WriteString(" skipped")
+	}
+	return b.String()
+}
+
+// LatencyHistogram counts interrupt events by latency (in cycles from Raised to Vector).
+type LatencyHistogram struct {
+	Counts map[int]int // The number of events seen for each latency.
+	Total  int         // The number of events counted.
+	Min    int         // The smallest latency seen. Only valid if Total is non-zero.
+	Max    int         // The largest latency seen. Only valid if Total is non-zero.
+	Sum    int         // The sum of all latencies seen.
+}
+
+// Mean returns the average latency or 0 if nothing has been counted.
+func (l LatencyHistogram) Mean() float64 {
+	if l.Total == 0 {
+		return 0
+	}
+	return float64(l.Sum) / float64(l.Total)
+}
+
+// Jitter returns the difference between the largest and smallest latencies seen.
+func (l LatencyHistogram) Jitter() int {
+	return l.Max - l.Min
+}
+
+// String implements fmt.Stringer for LatencyHistogram with one line per latency seen.
+func (l LatencyHistogram) String() string {
+	var keys []int
+	for k := range l.Counts {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d events min %d max %d mean %.2f\n", l.Total, l.Min, l.Max, l.Mean())
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%3d %d\n", k, l.Counts[k])
+	}
+	return b.String()
+}
+
+// add counts a latency.
+func (l *LatencyHistogram) add(latency int) {
+	if l.Counts == nil {
+		l.Counts = make(map[int]int)
+	}
+	if l.Total == 0 || latency < l.Min {
+		l.Min = latency
+	}
+	if l.Total == 0 || latency > l.Max {
+		l.Max = latency
+	}
+	l.Counts[latency]++
+	l.Total++
+	l.Sum += latency
+}
+
+// copy returns a deep copy so callers can't change the counts.
+func (l LatencyHistogram) copy() LatencyHistogram {
+	ret := l
+	ret.Counts = make(map[int]int, len(l.Counts))
+	for k, v := range l.Counts {
+		ret.Counts[k] = v
+	}
+	return ret
+}
+
+// InterruptStats records the timing of every IRQ and NMI the CPU runs. Install one with SetInterruptStats.
+// The latest events are kept individually and every event is counted in a histogram per interrupt type.
+//
+// An interrupt is considered raised once the CPU has latched it. With CORE_FAST that only happens between
+// instructions so latencies from Step() there are shorter than on hardware. An IRQ which is replaced by an
+// NMI before its sequence finishes isn't recorded.
+type InterruptStats struct {
+	events  []InterruptEvent
+	next    int
+	full    bool
+	irq     LatencyHistogram
+	nmi     LatencyHistogram
+	pending [2]*InterruptEvent // The IRQ and NMI (respectively) raised but not yet run.
+	fetch   int                // The cycle of the last opcode fetch.
+}
+
+// NewInterruptStats returns an empty InterruptStats which keeps the last records events.
+func NewInterruptStats(records int) (*InterruptStats, error) {
+	if records <= 0 {
+		return nil, InvalidCPUState{fmt.Sprintf("interrupt stats records %d must be positive", records)}
+	}
+	return &InterruptStats{events: make([]InterruptEvent, records)}, nil
+}
+
+// SetInterruptStats installs an InterruptStats which is updated as interrupts are raised and run.
+// Passing nil removes it.
+func (p *Chip) SetInterruptStats(s *InterruptStats) {
+	p.ints = s
+	if s != nil {
+		s.pending = [2]*InterruptEvent{}
+	}
+}
+
+// Events returns a copy of the recorded events from oldest to newest.
+func (s *InterruptStats) Events() []InterruptEvent {
+	if !s.full {
+		return append([]InterruptEvent(nil), s.events[:s.next]...)
+	}
+	return append(append([]InterruptEvent(nil), s.events[s.next:]...), s.events[:s.next]...)
+}
+
+// IRQ returns the latency histogram for IRQs.
+func (s *InterruptStats) IRQ() LatencyHistogram {
+	return s.irq.copy()
+}
+
+// NMI returns the latency histogram for NMIs.
+func (s *InterruptStats) NMI() LatencyHistogram {
+	return s.nmi.copy()
+}
+
+// Clear discards all events and counts. Any interrupt already raised is still recorded when it runs.
+func (s *InterruptStats) Clear() {
+	s.next = 0
+	s.full = false
+	s.irq = LatencyHistogram{}
+	s.nmi = LatencyHistogram{}
+}
+
+// raised is called once the interrupt lines have been sampled for the cycle at clock. before is
+// the value of irqRaised before sampling. mid is set if an instruction is part way through.
+func (s *InterruptStats) raised(p *Chip, before irqType, clock int, mid bool) {
+	if p.irqRaised == before || p.irqRaised == kIRQ_NONE {
+		return
+	}
+	i := 0
+	if p.irqRaised == kIRQ_NMI {
+		i = 1
+	}
+	if s.pending[i] != nil {
+		return
+	}
+	e := &InterruptEvent{NMI: i == 1, Raised: clock}
+	if mid {
+		e.Delayed = true
+		e.DelayPC = p.opPC
+		e.DelayOp = p.op
+		e.Instructions = 1
+	}
+	s.pending[i] = e
+}
+
+// opcode is called on each opcode fetch (including the ones starting an interrupt sequence).
+func (s *InterruptStats) opcode(p *Chip) {
+	s.fetch = p.clocks
+	if p.runningInterrupt {
+		return
+	}
+	for _, e := range s.pending {
+		if e == nil {
+			continue
+		}
+		e.Delayed = true
+		e.DelayPC = p.opPC
+		e.DelayOp = p.op
+		e.Instructions++
+		if p.skipInterrupt {
+			e.Skipped = true
+		}
+	}
+}
+
+// vector is called when the low byte of an IRQ/NMI vector is fetched.
+func (s *InterruptStats) vector(p *Chip) {
+	// The sequence may have started as a BRK or an IRQ which an NMI then took over.
+	if e := s.current(p); e != nil {
+		e.Start = s.fetch
+		e.Vector = p.clocks
+	}
+}
+
+// done is called once an IRQ/NMI sequence has loaded the PC with the handler address.
+func (s *InterruptStats) done(p *Chip) {
+	e := s.current(p)
+	// Whatever was latched has been serviced so anything still held starts over.
+	s.pending = [2]*InterruptEvent{}
+	if e == nil {
+		return
+	}
+	e.Handler = p.PC
+	e.Latency = e.Vector - e.Raised
+	if e.NMI {
+		s.nmi.add(e.Latency)
+	} else {
+		s.irq.add(e.Latency)
+	}
+	s.events[s.next] = *e
+	s.next++
+	if s.next >= len(s.events) {
+		s.next = 0
+		s.full = true
+	}
+}
+
+// current returns the pending event for the interrupt being run (if any).
+func (s *InterruptStats) current(p *Chip) *InterruptEvent {
+	if p.irqRaised == kIRQ_NMI {
+		return s.pending[1]
+	}
+	return s.pending[0]
+}
@@ -0,0 +1,177 @@
+package cpu
+
+import (
+	"fmt"
+	"testing"
+)
+
+// intStatsSetup returns a chip running NOPs at 0x1000 with both vectors pointing at a NOP, RTI handler at 0x4000.
+func intStatsSetup(t *testing.T, core Core) (*Chip, *flatMemory, *testIRQ, *testIRQ, *InterruptStats) {
+	t.Helper()
+	irq, nmi := &testIRQ{}, &testIRQ{}
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Irq: irq, Nmi: nmi, Core: core}, 0xEA, 0x0202)
+	copy(r.addr[0x4000:], []uint8{0xEA, 0x40})
+	r.addr[IRQ_VECTOR], r.addr[IRQ_VECTOR+1] = 0x00, 0x40
+	r.addr[NMI_VECTOR], r.addr[NMI_VECTOR+1] = 0x00, 0x40
+	c.PC = 0x1000
+	s, err := NewInterruptStats(4)
+	if err != nil {
+		t.Fatalf("Can't create stats: %v", err)
+	}
+	c.SetInterruptStats(s)
+	return c, r, irq, nmi, s
+}
+
+// stepToHandler steps until an interrupt sequence has run and then lowers both lines.
+func stepToHandler(t *testing.T, c *Chip, irq, nmi *testIRQ) {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		info, err := c.Step()
+		if err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+		if info.Interrupt {
+			irq.s = false
+			nmi.s = false
+			return
+		}
+	}
+	t.Fatalf("Interrupt never ran")
+}
+
+func TestInterruptStatsMidInstruction(t *testing.T) {
+	c, _, irq, nmi, s := intStatsSetup(t, CORE_ACCURATE)
+	if _, err := c.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	// Raise during the 2nd cycle of the NOP at 0x1001.
+	if _, err := c.RunCycles(1); err != nil {
+		t.Fatalf("RunCycles failed: %v", err)
+	}
+	raised := c.Clocks() + 1
+	irq.s = true
+	stepToHandler(t, c, irq, nmi)
+	want := InterruptEvent{
+		Raised:       raised,
+		Start:        raised + 1,
+		Vector:       raised + 6,
+		Latency:      6,
+		Handler:      0x4000,
+		Delayed:      true,
+		DelayPC:      0x1001,
+		DelayOp:      0xEA,
+		Instructions: 1,
+	}
+	ev := s.Events()
+	if len(ev) != 1 || ev[0] != want {
+		t.Errorf("Bad events.\nGot  %v\nWant [%v]", ev, want)
+	}
+}
+
+func TestInterruptStatsImmediate(t *testing.T) {
+	for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+		for _, isNMI := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s NMI %t", core, isNMI), func(t *testing.T) {
+				c, _, irq, nmi, s := intStatsSetup(t, core)
+				// Raised before the next opcode fetch so the sequence starts there.
+				raised := c.Clocks() + 1
+				if isNMI {
+					nmi.s = true
+				} else {
+					irq.s = true
+				}
+				stepToHandler(t, c, irq, nmi)
+				want := InterruptEvent{
+					NMI:     isNMI,
+					Raised:  raised,
+					Start:   raised,
+					Vector:  raised + 5,
+					Latency: 5,
+					Handler: 0x4000,
+				}
+				ev := s.Events()
+				if len(ev) != 1 || ev[0] != want {
+					t.Errorf("Bad events.\nGot  %v\nWant [%v]", ev, want)
+				}
+				h, other := s.IRQ(), s.NMI()
+				if isNMI {
+					h, other = other, h
+				}
+				if h.Total != 1 || h.Counts[5] != 1 || h.Min != 5 || h.Max != 5 || other.Total != 0 {
+					t.Errorf("Bad histograms. Got %+v and other %+v", h, other)
+				}
+			})
+		}
+	}
+}
+
+func TestInterruptStatsBranchDelay(t *testing.T) {
+	for _, isNMI := range []bool{false, true} {
+		t.Run(fmt.Sprintf("NMI %t", isNMI), func(t *testing.T) {
+			c, r, irq, nmi, s := intStatsSetup(t, CORE_ACCURATE)
+			// BCC *+2 which is taken without crossing a page so the next NOP runs before the interrupt.
+			copy(r.addr[0x1000:], []uint8{0x90, 0x00})
+			c.P &^= P_CARRY
+			// Raise during the 2nd cycle of the branch.
+			if _, err := c.RunCycles(1); err != nil {
+				t.Fatalf("RunCycles failed: %v", err)
+			}
+			raised := c.Clocks() + 1
+			if isNMI {
+				nmi.s = true
+			} else {
+				irq.s = true
+			}
+			stepToHandler(t, c, irq, nmi)
+			want := InterruptEvent{
+				NMI:          isNMI,
+				Raised:       raised,
+				Start:        raised + 4,
+				Vector:       raised + 9,
+				Latency:      9,
+				Handler:      0x4000,
+				Delayed:      true,
+				DelayPC:      0x1002,
+				DelayOp:      0xEA,
+				Instructions: 2,
+				Skipped:      true,
+			}
+			ev := s.Events()
+			if len(ev) != 1 || ev[0] != want {
+				t.Errorf("Bad events.\nGot  %v\nWant [%v]", ev, want)
+			}
+		})
+	}
+}
+
+func TestInterruptStatsHistogram(t *testing.T) {
+	c, _, irq, nmi, s := intStatsSetup(t, CORE_ACCURATE)
+	// Raise on each cycle of a NOP in turn (and the cycle after) so the latency varies.
+	for i := 0; i < 6; i++ {
+		if _, err := c.RunCycles(i % 3); err != nil {
+			t.Fatalf("RunCycles failed: %v", err)
+		}
+		irq.s = true
+		stepToHandler(t, c, irq, nmi)
+		// Run the handler (NOP, RTI) and the NOP after it.
+		for j := 0; j < 3; j++ {
+			if _, err := c.Step(); err != nil {
+				t.Fatalf("Step failed: %v", err)
+			}
+		}
+	}
+	h := s.IRQ()
+	if h.Total != 6 || h.Min != 5 || h.Max != 6 || h.Jitter() != 1 {
+		t.Errorf("Bad histogram: %s", h)
+	}
+	if got, want := len(s.Events()), 4; got != want {
+		t.Errorf("Bad number of events kept. Got %d and want %d", got, want)
+	}
+	s.Clear()
+	if h := s.IRQ(); h.Total != 0 || len(s.Events()) != 0 {
+		t.Errorf("Clear didn't reset: %s", h)
+	}
+	if _, err := NewInterruptStats(0); err == nil {
+		t.Errorf("Didn't get error for 0 records")
+	}
+}
@@ -0,0 +1,198 @@
+package cpu
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/jmchacon/6502/disassemble"
+)
+
+// OpClass is an enumeration of the classes of opcodes an OpAction can be set for.
+type OpClass int
+
+const (
+	OPCLASS_UNIMPLEMENTED OpClass = iota // Start of valid opcode classes.
+	OPCLASS_DOCUMENTED                   // Opcodes in the manufacturer documentation for the CPU type.
+	OPCLASS_UNDOCUMENTED                 // Undocumented opcodes which act the same on every chip (LAX, SAX, DCP, the extra NOPs, etc).
+	OPCLASS_UNSTABLE                     // Undocumented opcodes whose results vary between chips (XAA/ANE, OAL/LXA #i, AHX/SHX/SHY/TAS).
+	OPCLASS_HALT                         // Opcodes which lock up the CPU (HLT/KIL). NMOS only.
+	OPCLASS_MAX                          // End of opcode classes.
+)
+
+// String implements fmt.Stringer for OpClass.
+func (o OpClass) String() string {
+	switch o {
+	case OPCLASS_DOCUMENTED:
+		return "DOCUMENTED"
+	case OPCLASS_UNDOCUMENTED:
+		return "UNDOCUMENTED"
+	case OPCLASS_UNSTABLE:
+		return "UNSTABLE"
+	case OPCLASS_HALT:
+		return "HALT"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(o))
+}
+
+// OpAction is an enumeration of what happens when an opcode of a given class is run.
+type OpAction int
+
+const (
+	OPACTION_UNIMPLEMENTED OpAction = iota // Start of valid opcode actions.
+	OPACTION_ALLOW                         // Run it normally.
+	OPACTION_LOG                           // Report it to the function set with SetOpcodeLog and then run it normally.
+	OPACTION_TRAP                          // Return an OpcodeTrap error once it's fetched. The next Tick() or Step() runs it.
+	OPACTION_MAX                           // End of opcode actions.
+)
+
+// OpcodeUse describes an opcode being run which has OPACTION_LOG or OPACTION_TRAP set for its class.
+type OpcodeUse struct {
+	Class  OpClass
+	PC     uint16 // The PC the opcode was fetched from.
+	Opcode uint8
+}
+
+// String implements fmt.Stringer for OpcodeUse.
+func (o OpcodeUse) String() string {
+	return fmt.Sprintf("%s opcode 0x%.2X at PC 0x%.4X", o.Class, o.Opcode, o.PC)
+}
+
+// OpcodeTrap is the error returned when an opcode's class has OPACTION_TRAP set. Like BreakpointHit it
+// doesn't halt the CPU. Tick() returns it at the end of the cycle which fetched the opcode and Step()
+// returns it with only the fetch done (the 65C02 1 cycle NOPs have also completed). Either way the CPU
+// state can be inspected and then running continues with the rest of the opcode.
+type OpcodeTrap struct {
+	OpcodeUse
+}
+
+// Error implements the interface for error types.
+func (o OpcodeTrap) Error() string {
+	return fmt.Sprintf("trapped %s", o.OpcodeUse)
+}
+
+// Magic holds the constants and quirks the unstable opcodes depend on. These vary between chips
+// (and even with temperature) so they can be set to match a given batch. The zero value isn't the
+// default (XAA and OAL of 0 are valid constants) so to change some fields start from DefaultMagic().
+type Magic struct {
+	XAA         uint8 // XAA (0x8B) sets A = (A | XAA) & X & #i.
+	OAL         uint8 // OAL/LXA (0xAB) sets A = X = (A | OAL) & #i unless OALRandom is set.
+	OALRandom   bool  // If set OAL randomly (using the PowerOnPolicy source) either acts as XAA or sets A = X = A & #i.
+	SHDropHigh  bool  // If set AHX/SHX/SHY/TAS store the register value without the & (ADDR_HI + 1) term. Some chips drop it when RDY is involved.
+	SHPageCross bool  // If set and indexing AHX/SHX/SHY/TAS crossed a page the value stored also replaces the high byte of the address written.
+}
+
+// DefaultMagic returns the Magic used if ChipDef.Magic is nil.
+func DefaultMagic() Magic {
+	return Magic{XAA: 0xEE, OAL: 0xEE, OALRandom: true}
+}
+
+// SetMagic changes the constants and quirks used by the unstable opcodes. Every field of m is used.
+func (p *Chip) SetMagic(m Magic) {
+	p.magic = m
+}
+
+// Magic returns the constants and quirks currently used by the unstable opcodes.
+func (p *Chip) Magic() Magic {
+	return p.magic
+}
+
+// unstableOpcodes are the NMOS opcodes in OPCLASS_UNSTABLE.
+var unstableOpcodes = map[uint8]bool{
+	0x8B: true, // XAA #i
+	0x93: true, // AHX (d),y
+	0x9B: true, // TAS a,y
+	0x9C: true, // SHY a,x
+	0x9E: true, // SHX a,y
+	0x9F: true, // AHX a,y
+	0xAB: true, // OAL #i
+}
+
+// opcodeClasses holds the class of each opcode for each CPUType.
+var opcodeClasses = classifyOpcodes()
+
+// classifyOpcodes builds opcodeClasses. This works from the NMOS table and CMOS overrides
+// directly as opcodeTables isn't setup until init() runs.
+func classifyOpcodes() [CPU_MAX][256]OpClass {
+	var ret [CPU_MAX][256]OpClass
+	for cpu := CPU_NMOS; cpu < CPU_MAX; cpu++ {
+		for op := 0; op < 256; op++ {
+			o := nmosOpcodes[op]
+			c := OPCLASS_DOCUMENTED
+			switch {
+			case cpu == CPU_CMOS:
+				// Everything CMOS changed but didn't define is a NOP of some length.
+				// The rest are the documented NMOS opcodes.
+				if o, ok := cmosOverrides[uint8(op)]; ok && (o.kind == kOP_ADDR || o.cycles == 1 || op == 0x5C) {
+					c = OPCLASS_UNDOCUMENTED
+				}
+			case o.kind == kOP_HALT:
+				c = OPCLASS_HALT
+			case unstableOpcodes[uint8(op)]:
+				c = OPCLASS_UNSTABLE
+			case disassemble.Undocumented(uint8(op)):
+				c = OPCLASS_UNDOCUMENTED
+			}
+			ret[cpu][op] = c
+		}
+	}
+	return ret
+}
+
+// OpcodeClass returns the class of the given opcode on the given CPU type.
+func OpcodeClass(cpu CPUType, op uint8) (OpClass, error) {
+	if cpu <= CPU_UNIMPLMENTED || cpu >= CPU_MAX {
+		return OPCLASS_UNIMPLEMENTED, InvalidCPUState{fmt.Sprintf("CPU type %d is invalid", cpu)}
+	}
+	return opcodeClasses[cpu][op], nil
+}
+
+// SetOpcodeAction sets what happens when an opcode of class c is run. By default every class is
+// OPACTION_ALLOW. This is checked as each opcode is fetched (by both Tick() and Step()).
+func (p *Chip) SetOpcodeAction(c OpClass, a OpAction) error {
+	if c <= OPCLASS_UNIMPLEMENTED || c >= OPCLASS_MAX {
+		return InvalidCPUState{fmt.Sprintf("opcode class %d is invalid", c)}
+	}
+	if a <= OPACTION_UNIMPLEMENTED || a >= OPACTION_MAX {
+		return InvalidCPUState{fmt.Sprintf("opcode action %d is invalid", a)}
+	}
+	p.opActions[c] = a
+	p.opChecks = false
+	for _, a := range p.opActions {
+		if a == OPACTION_LOG || a == OPACTION_TRAP {
+			p.opChecks = true
+		}
+	}
+	return nil
+}
+
+// OpcodeAction returns what happens when an opcode of class c is run.
+func (p *Chip) OpcodeAction(c OpClass) OpAction {
+	if c <= OPCLASS_UNIMPLEMENTED || c >= OPCLASS_MAX || p.opActions[c] == OPACTION_UNIMPLEMENTED {
+		return OPACTION_ALLOW
+	}
+	return p.opActions[c]
+}
+
+// SetOpcodeLog sets the function called for opcodes whose class is set to OPACTION_LOG.
+// If fn is nil (the default) they're written with the standard log package instead.
+func (p *Chip) SetOpcodeLog(fn func(OpcodeUse)) {
+	p.opLog = fn
+}
+
+// checkOpcode applies the OpAction for the class of the opcode just fetched.
+// Returns an OpcodeTrap if it should stop.
+func (p *Chip) checkOpcode() *OpcodeTrap {
+	c := opcodeClasses[p.cpuType][p.op]
+	switch p.opActions[c] {
+	case OPACTION_LOG:
+		use := OpcodeUse{Class: c, PC: p.opPC, Opcode: p.op}
+		if p.opLog != nil {
+			p.opLog(use)
+			return nil
+		}
+		log.Printf("CPU: %s", use)
+	case OPACTION_TRAP:
+		return &OpcodeTrap{OpcodeUse{Class: c, PC: p.opPC, Opcode: p.op}}
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package cpu
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestOpcodeClass(t *testing.T) {
+	tests := []struct {
+		cpu  CPUType
+		op   uint8
+		want OpClass
+	}{
+		{CPU_NMOS, 0xA9, OPCLASS_DOCUMENTED},   // LDA #i
+		{CPU_NMOS, 0xEA, OPCLASS_DOCUMENTED},   // NOP
+		{CPU_NMOS, 0xA7, OPCLASS_UNDOCUMENTED}, // LAX d
+		{CPU_NMOS, 0x1A, OPCLASS_UNDOCUMENTED}, // NOP
+		{CPU_NMOS, 0xEB, OPCLASS_UNDOCUMENTED}, // SBC #i
+		{CPU_NMOS, 0x8B, OPCLASS_UNSTABLE},     // XAA #i
+		{CPU_NMOS, 0xAB, OPCLASS_UNSTABLE},     // OAL #i
+		{CPU_NMOS, 0x9E, OPCLASS_UNSTABLE},     // SHX a,y
+		{CPU_NMOS, 0x02, OPCLASS_HALT},         // HLT
+		{CPU_NMOS_6510, 0xF2, OPCLASS_HALT},    // HLT
+		{CPU_CMOS, 0xA9, OPCLASS_DOCUMENTED},   // LDA #i
+		{CPU_CMOS, 0x1A, OPCLASS_DOCUMENTED},   // INC
+		{CPU_CMOS, 0x07, OPCLASS_DOCUMENTED},   // RMB0 d
+		{CPU_CMOS, 0xDB, OPCLASS_DOCUMENTED},   // STP
+		{CPU_CMOS, 0x02, OPCLASS_UNDOCUMENTED}, // NOP #i
+		{CPU_CMOS, 0x03, OPCLASS_UNDOCUMENTED}, // NOP
+		{CPU_CMOS, 0x5C, OPCLASS_UNDOCUMENTED}, // NOP a (8 ticks)
+		{CPU_CMOS, 0xFC, OPCLASS_UNDOCUMENTED}, // NOP a
+	}
+	for _, test := range tests {
+		got, err := OpcodeClass(test.cpu, test.op)
+		if err != nil {
+			t.Errorf("CPU %d opcode %.2X: error %v", test.cpu, test.op, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("CPU %d opcode %.2X: got class %s and want %s", test.cpu, test.op, got, test.want)
+		}
+	}
+	if _, err := OpcodeClass(CPU_MAX, 0xEA); err == nil {
+		t.Errorf("Didn't get error for an invalid CPU type")
+	}
+}
+
+func TestOpcodeAction(t *testing.T) {
+	for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+		t.Run(core.String(), func(t *testing.T) {
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Core: core}, 0xEA, 0x0202)
+			// LDA #01, LAX 10, NOP, XAA #FF
+			copy(r.addr[0x1000:], []uint8{0xA9, 0x01, 0xA7, 0x10, 0xEA, 0x8B, 0xFF})
+			c.PC = 0x1000
+			if got, want := c.OpcodeAction(OPCLASS_UNSTABLE), OPACTION_ALLOW; got != want {
+				t.Errorf("Bad default action. Got %d and want %d", got, want)
+			}
+			var logged []OpcodeUse
+			c.SetOpcodeLog(func(u OpcodeUse) {
+				logged = append(logged, u)
+			})
+			if err := c.SetOpcodeAction(OPCLASS_UNDOCUMENTED, OPACTION_LOG); err != nil {
+				t.Fatalf("Can't set action: %v", err)
+			}
+			if err := c.SetOpcodeAction(OPCLASS_UNSTABLE, OPACTION_TRAP); err != nil {
+				t.Fatalf("Can't set action: %v", err)
+			}
+			for i := 0; i < 3; i++ {
+				if _, err := c.Step(); err != nil {
+					t.Fatalf("Step failed: %v", err)
+				}
+			}
+			want := []OpcodeUse{{Class: OPCLASS_UNDOCUMENTED, PC: 0x1002, Opcode: 0xA7}}
+			if fmt.Sprint(logged) != fmt.Sprint(want) {
+				t.Errorf("Bad logging. Got %v and want %v", logged, want)
+			}
+			a := c.A
+			_, err := c.Step()
+			trap, ok := err.(OpcodeTrap)
+			if !ok {
+				t.Fatalf("Didn't get OpcodeTrap. Got %T: %v", err, err)
+			}
+			if got, want := trap.OpcodeUse, (OpcodeUse{Class: OPCLASS_UNSTABLE, PC: 0x1005, Opcode: 0x8B}); got != want {
+				t.Errorf("Bad trap. Got %v and want %v", got, want)
+			}
+			if c.A != a {
+				t.Errorf("Trapped opcode ran. A changed from %.2X to %.2X", a, c.A)
+			}
+			// Running again carries on with the trapped opcode instead of halting.
+			if _, err := c.Step(); err != nil {
+				t.Fatalf("Step after trap failed: %v", err)
+			}
+			if got, want := c.A, (a|DefaultMagic().XAA)&c.X&0xFF; got != want {
+				t.Errorf("Trapped opcode didn't run. Got A: %.2X and want %.2X", got, want)
+			}
+			if got, want := c.PC, uint16(0x1007); got != want {
+				t.Errorf("Bad PC after trapped opcode. Got %.4X and want %.4X", got, want)
+			}
+
+			// Bad classes/actions.
+			for _, cl := range []OpClass{OPCLASS_UNIMPLEMENTED, OPCLASS_MAX} {
+				if err := c.SetOpcodeAction(cl, OPACTION_TRAP); err == nil {
+					t.Errorf("Didn't get error for class %d", cl)
+				}
+			}
+			for _, act := range []OpAction{OPACTION_UNIMPLEMENTED, OPACTION_MAX} {
+				if err := c.SetOpcodeAction(OPCLASS_HALT, act); err == nil {
+					t.Errorf("Didn't get error for action %d", act)
+				}
+			}
+		})
+	}
+}
+
+func TestOpcodeTrapOneCycle(t *testing.T) {
+	for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+		t.Run(core.String(), func(t *testing.T) {
+			// The 65C02 1 cycle NOPs are done with the fetch so they complete before the trap is returned.
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_CMOS, Core: core}, 0xEA, 0x0202)
+			copy(r.addr[0x1000:], []uint8{0x03, 0xA9, 0x01}) // NOP, LDA #01
+			c.PC = 0x1000
+			if err := c.SetOpcodeAction(OPCLASS_UNDOCUMENTED, OPACTION_TRAP); err != nil {
+				t.Fatalf("Can't set action: %v", err)
+			}
+			info, err := c.Step()
+			if _, ok := err.(OpcodeTrap); !ok {
+				t.Fatalf("Didn't get OpcodeTrap. Got %T: %v", err, err)
+			}
+			if info.Cycles != 1 || c.PC != 0x1001 {
+				t.Errorf("NOP didn't complete. Took %d cycles and PC is %.4X", info.Cycles, c.PC)
+			}
+			if _, err := c.Step(); err != nil {
+				t.Fatalf("Step after trap failed: %v", err)
+			}
+			if c.A != 0x01 || c.PC != 0x1003 {
+				t.Errorf("LDA didn't run after the trap. A: %.2X PC: %.4X", c.A, c.PC)
+			}
+		})
+	}
+}
+
+func TestMagic(t *testing.T) {
+	tests := []struct {
+		name  string
+		magic *Magic
+		op    uint8
+		a, x  uint8 // Expected results
+	}{
+		{
+			name: "XAA default",
+			op:   0x8B,
+			a:    0x0E,
+			x:    0x0F,
+		},
+		{
+			name:  "XAA 0xFF",
+			magic: &Magic{XAA: 0xFF},
+			op:    0x8B,
+			a:     0x0F,
+			x:     0x0F,
+		},
+		{
+			name:  "OAL 0x00",
+			magic: &Magic{OAL: 0x00},
+			op:    0xAB,
+			a:     0x30,
+			x:     0x30,
+		},
+		{
+			name:  "OAL 0xEE",
+			magic: &Magic{OAL: 0xEE},
+			op:    0xAB,
+			a:     0x3E,
+			x:     0x3E,
+		},
+	}
+	for _, test := range tests {
+		for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+			t.Run(fmt.Sprintf("%s %s", test.name, core), func(t *testing.T) {
+				// A starts as 0x30, X as 0x0F and the immediate value is 0x3F.
+				c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Core: core, Magic: test.magic}, 0xEA, 0x0202)
+				copy(r.addr[0x1000:], []uint8{test.op, 0x3F})
+				c.PC = 0x1000
+				c.A = 0x30
+				c.X = 0x0F
+				if _, err := c.Step(); err != nil {
+					t.Fatalf("Step failed: %v", err)
+				}
+				if c.A != test.a || c.X != test.x {
+					t.Errorf("Bad result. Got A: %.2X X: %.2X and want A: %.2X X: %.2X", c.A, c.X, test.a, test.x)
+				}
+			})
+		}
+	}
+	c, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
+	if got, want := c.Magic(), DefaultMagic(); got != want {
+		t.Errorf("Bad default magic. Got %+v and want %+v", got, want)
+	}
+	c.SetMagic(Magic{XAA: 0xFF})
+	if got, want := c.Magic().XAA, uint8(0xFF); got != want {
+		t.Errorf("SetMagic didn't apply. Got %.2X and want %.2X", got, want)
+	}
+}
+
+func TestMagicSH(t *testing.T) {
+	// sh returns DefaultMagic() with the AHX/SHX/SHY/TAS quirks set as given.
+	sh := func(drop, cross bool) *Magic {
+		m := DefaultMagic()
+		m.SHDropHigh = drop
+		m.SHPageCross = cross
+		return &m
+	}
+	tests := []struct {
+		name  string
+		magic *Magic
+		op    uint8
+		base  uint16 // The absolute operand. Indexing is always by 0x20.
+		addr  uint16 // Expected address written
+		val   uint8  // and the value there.
+	}{
+		{
+			name: "SHX default",
+			op:   0x9E,
+			base: 0x10F0,
+			addr: 0x1110,
+			val:  0x12,
+		},
+		{
+			name:  "SHX drop high",
+			magic: sh(true, false),
+			op:    0x9E,
+			base:  0x10F0,
+			addr:  0x1110,
+			val:   0xF7,
+		},
+		{
+			name:  "SHX page cross",
+			magic: sh(false, true),
+			op:    0x9E,
+			base:  0x10F0,
+			addr:  0x1210,
+			val:   0x12,
+		},
+		{
+			name:  "SHX page cross no cross",
+			magic: sh(false, true),
+			op:    0x9E,
+			base:  0x1000,
+			addr:  0x1020,
+			val:   0x11,
+		},
+		{
+			name:  "SHY drop high and page cross",
+			magic: sh(true, true),
+			op:    0x9C,
+			base:  0x10F0,
+			addr:  0x7F10,
+			val:   0x7F,
+		},
+		{
+			name:  "AHX page cross",
+			magic: sh(false, true),
+			op:    0x9F,
+			base:  0x10F0,
+			addr:  0x1210,
+			val:   0x12,
+		},
+		{
+			name:  "TAS drop high",
+			magic: sh(true, false),
+			op:    0x9B,
+			base:  0x10F0,
+			addr:  0x1110,
+			val:   0x37,
+		},
+	}
+	for _, test := range tests {
+		for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+			t.Run(fmt.Sprintf("%s %s", test.name, core), func(t *testing.T) {
+				// A starts as 0x3F and X as 0xF7. The index register (Y for SHX/AHX/TAS and X for SHY) is 0x20
+				// and SHY stores Y which is 0x7F.
+				c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Core: core, Magic: test.magic}, 0xEA, 0x0202)
+				copy(r.addr[0x1000:], []uint8{test.op, uint8(test.base), uint8(test.base >> 8)})
+				c.PC = 0x1000
+				c.A = 0x3F
+				c.X = 0xF7
+				c.Y = 0x20
+				if test.op == 0x9C {
+					c.X = 0x20
+					c.Y = 0x7F
+				}
+				r.addr[test.addr] = 0x00
+				if _, err := c.Step(); err != nil {
+					t.Fatalf("Step failed: %v", err)
+				}
+				if got := r.addr[test.addr]; got != test.val {
+					t.Errorf("Bad store. Got %.2X at %.4X and want %.2X", got, test.addr, test.val)
+				}
+			})
+		}
+	}
+}
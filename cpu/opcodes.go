@@ -0,0 +1,547 @@
+package cpu
+
+import (
+	"fmt"
+)
+
+// opKind is an enumeration of the ways an opcode runs on each tick.
+type opKind int
+
+const (
+	kOP_UNIMPLEMENTED opKind = iota // Start of valid opcode kinds.
+	kOP_RUN                         // op runs every tick and handles its own sequencing (implied, stack, branches, etc).
+	kOP_LOAD                        // Addressing runs until done and then op on the same tick. See loadInstruction.
+	kOP_RMW                         // Addressing runs until done (including the RMW dummy write) and then op on the next tick. See rmwInstruction.
+	kOP_STORE                       // Addressing runs until done and then the value from val is stored on the next tick. See storeInstruction.
+	kOP_ADDR                        // Only addressing runs (as a load). Used for the NOPs which read their operand.
+	kOP_HALT                        // Halts the CPU (HLT/KIL).
+	kOP_MAX                         // End of opcode kinds.
+)
+
+// opFunc runs a tick of an instruction (or its final operation once addressing is done).
+// Returns true when the instruction is complete and any error.
+type opFunc func(*Chip) (bool, error)
+
+// addrFunc runs a tick of an addressing mode for the given instruction mode.
+// Returns true when addressing is complete and any error.
+type addrFunc func(*Chip, instructionMode) (bool, error)
+
+// addrMode is an enumeration of the addressing modes used by kOP_LOAD, kOP_RMW, kOP_STORE and kOP_ADDR opcodes.
+type addrMode int
+
+const (
+	kMODE_UNIMPLEMENTED   addrMode = iota // Start of valid addressing modes.
+	kMODE_IMMEDIATE                       // #i
+	kMODE_ZP                              // d
+	kMODE_ZPX                             // d,x
+	kMODE_ZPY                             // d,y
+	kMODE_INDIRECTX                       // (d,x)
+	kMODE_INDIRECTY                       // (d),y
+	kMODE_ABSOLUTE                        // a
+	kMODE_ABSOLUTEX                       // a,x
+	kMODE_ABSOLUTEY                       // a,y
+	kMODE_ABSOLUTEX_SHIFT                 // a,x for the CMOS shifts/rotates which skip the fixup tick unless a page is crossed.
+	kMODE_INDIRECTZP                      // (d) (CMOS only).
+	kMODE_MAX                             // End of addressing modes.
+)
+
+// addrFuncs maps each addrMode to the function which runs it a tick at a time.
+var addrFuncs = [kMODE_MAX]addrFunc{
+	kMODE_IMMEDIATE:       (*Chip).addrImmediate,
+	kMODE_ZP:              (*Chip).addrZP,
+	kMODE_ZPX:             (*Chip).addrZPX,
+	kMODE_ZPY:             (*Chip).addrZPY,
+	kMODE_INDIRECTX:       (*Chip).addrIndirectX,
+	kMODE_INDIRECTY:       (*Chip).addrIndirectY,
+	kMODE_ABSOLUTE:        (*Chip).addrAbsolute,
+	kMODE_ABSOLUTEX:       (*Chip).addrAbsoluteX,
+	kMODE_ABSOLUTEY:       (*Chip).addrAbsoluteY,
+	kMODE_ABSOLUTEX_SHIFT: (*Chip).addrAbsoluteXShift,
+	kMODE_INDIRECTZP:      (*Chip).addrIndirectZP,
+}
+
+// opcode describes how to run an opcode. These are computed once so each tick only has to index a table
+// and switch on kind instead of rebuilding closures for the addressing mode and operation.
+type opcode struct {
+	kind   opKind
+	mode   addrMode          // Addressing mode for kOP_LOAD, kOP_RMW, kOP_STORE and kOP_ADDR.
+	op     opFunc            // Operation for kOP_RUN, kOP_LOAD and kOP_RMW.
+	val    func(*Chip) uint8 // Value to store for kOP_STORE. Evaluated on the tick the store happens.
+	cycles int               // Base cycles assuming no page crossings, branches not taken and CMOS decimal mode off. Zero for halts.
+}
+
+// nmosOpcodes describes every opcode on the NMOS variants.
+//
+// Opcode matric taken from:
+// http://wiki.nesdev.com/w/index.php/CPU_unofficial_opcodes#Games_using_unofficial_opcodes
+//
+// NOTE: The above lists 0xAB as LAX #i but we call it OAL since it has odd behavior and needs it's own
+// code compared to other LAX. See 6502-NMOS.extra.opcodes below.
+//
+// Description of undocumented opcodes:
+//
+// http://www.ffd2.com/fridge/docs/6502-NMOS.extra.opcodes
+// http://nesdev.com/6502_cpu.txt
+// http://visual6502.org/wiki/index.php?title=6502_Opcode_8B_(XAA,_ANE)
+//
+// Opcode descriptions/timing/etc:
+// http://obelisk.me.uk/6502/reference.html
+var nmosOpcodes = [256]opcode{
+	0x00: {kind: kOP_RUN, op: (*Chip).iBRK, cycles: 7},                                  // BRK #i
+	0x01: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).iORA, cycles: 6},          // ORA (d,x)
+	0x02: {kind: kOP_HALT},                                                              // HLT
+	0x03: {kind: kOP_RMW, mode: kMODE_INDIRECTX, op: (*Chip).iSLO, cycles: 8},           // SLO (d,x)
+	0x04: {kind: kOP_ADDR, mode: kMODE_ZP, cycles: 3},                                   // NOP d
+	0x05: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iORA, cycles: 3},                 // ORA d
+	0x06: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iASL, cycles: 5},                  // ASL d
+	0x07: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSLO, cycles: 5},                  // SLO d
+	0x08: {kind: kOP_RUN, op: (*Chip).iPHP, cycles: 3},                                  // PHP
+	0x09: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iORA, cycles: 2},          // ORA #i
+	0x0A: {kind: kOP_RUN, op: (*Chip).iASLAcc, cycles: 2},                               // ASL
+	0x0B: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iANC, cycles: 2},          // ANC #i
+	0x0C: {kind: kOP_ADDR, mode: kMODE_ABSOLUTE, cycles: 4},                             // NOP a
+	0x0D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iORA, cycles: 4},           // ORA a
+	0x0E: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iASL, cycles: 6},            // ASL a
+	0x0F: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iSLO, cycles: 6},            // SLO a
+	0x10: {kind: kOP_RUN, op: (*Chip).iBPL, cycles: 2},                                  // BPL *+r
+	0x11: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).iORA, cycles: 5},          // ORA (d),y
+	0x12: {kind: kOP_HALT},                                                              // HLT
+	0x13: {kind: kOP_RMW, mode: kMODE_INDIRECTY, op: (*Chip).iSLO, cycles: 8},           // SLO (d),y
+	0x14: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                  // NOP d,x
+	0x15: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).iORA, cycles: 4},                // ORA d,x
+	0x16: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iASL, cycles: 6},                 // ASL d,x
+	0x17: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iSLO, cycles: 6},                 // SLO d,x
+	0x18: {kind: kOP_RUN, op: (*Chip).iCLC, cycles: 2},                                  // CLC
+	0x19: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iORA, cycles: 4},          // ORA a,y
+	0x1A: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 2},                                  // NOP
+	0x1B: {kind: kOP_RMW, mode: kMODE_ABSOLUTEY, op: (*Chip).iSLO, cycles: 7},           // SLO a,y
+	0x1C: {kind: kOP_ADDR, mode: kMODE_ABSOLUTEX, cycles: 4},                            // NOP a,x
+	0x1D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).iORA, cycles: 4},          // ORA a,x
+	0x1E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iASL, cycles: 7},           // ASL a,x
+	0x1F: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iSLO, cycles: 7},           // SLO a,x
+	0x20: {kind: kOP_RUN, op: (*Chip).iJSR, cycles: 6},                                  // JSR a
+	0x21: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).iAND, cycles: 6},          // AND (d,x)
+	0x22: {kind: kOP_HALT},                                                              // HLT
+	0x23: {kind: kOP_RMW, mode: kMODE_INDIRECTX, op: (*Chip).iRLA, cycles: 8},           // RLA (d,x)
+	0x24: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iBIT, cycles: 3},                 // BIT d
+	0x25: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iAND, cycles: 3},                 // AND d
+	0x26: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iROL, cycles: 5},                  // ROL d
+	0x27: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRLA, cycles: 5},                  // RLA d
+	0x28: {kind: kOP_RUN, op: (*Chip).iPLP, cycles: 4},                                  // PLP
+	0x29: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iAND, cycles: 2},          // AND #i
+	0x2A: {kind: kOP_RUN, op: (*Chip).iROLAcc, cycles: 2},                               // ROL
+	0x2B: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iANC, cycles: 2},          // ANC #i
+	0x2C: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iBIT, cycles: 4},           // BIT a
+	0x2D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iAND, cycles: 4},           // AND a
+	0x2E: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iROL, cycles: 6},            // ROL a
+	0x2F: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iRLA, cycles: 6},            // RLA a
+	0x30: {kind: kOP_RUN, op: (*Chip).iBMI, cycles: 2},                                  // BMI *+r
+	0x31: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).iAND, cycles: 5},          // AND (d),y
+	0x32: {kind: kOP_HALT},                                                              // HLT
+	0x33: {kind: kOP_RMW, mode: kMODE_INDIRECTY, op: (*Chip).iRLA, cycles: 8},           // RLA (d),y
+	0x34: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                  // NOP d,x
+	0x35: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).iAND, cycles: 4},                // AND d,x
+	0x36: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iROL, cycles: 6},                 // ROL d,x
+	0x37: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iRLA, cycles: 6},                 // RLA d,x
+	0x38: {kind: kOP_RUN, op: (*Chip).iSEC, cycles: 2},                                  // SEC
+	0x39: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iAND, cycles: 4},          // AND a,y
+	0x3A: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 2},                                  // NOP
+	0x3B: {kind: kOP_RMW, mode: kMODE_ABSOLUTEY, op: (*Chip).iRLA, cycles: 7},           // RLA a,y
+	0x3C: {kind: kOP_ADDR, mode: kMODE_ABSOLUTEX, cycles: 4},                            // NOP a,x
+	0x3D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).iAND, cycles: 4},          // AND a,x
+	0x3E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iROL, cycles: 7},           // ROL a,x
+	0x3F: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iRLA, cycles: 7},           // RLA a,x
+	0x40: {kind: kOP_RUN, op: (*Chip).iRTI, cycles: 6},                                  // RTI
+	0x41: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).iEOR, cycles: 6},          // EOR (d,x)
+	0x42: {kind: kOP_HALT},                                                              // HLT
+	0x43: {kind: kOP_RMW, mode: kMODE_INDIRECTX, op: (*Chip).iSRE, cycles: 8},           // SRE (d,x)
+	0x44: {kind: kOP_ADDR, mode: kMODE_ZP, cycles: 3},                                   // NOP d
+	0x45: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iEOR, cycles: 3},                 // EOR d
+	0x46: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iLSR, cycles: 5},                  // LSR d
+	0x47: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSRE, cycles: 5},                  // SRE d
+	0x48: {kind: kOP_RUN, op: (*Chip).iPHA, cycles: 3},                                  // PHA
+	0x49: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iEOR, cycles: 2},          // EOR #i
+	0x4A: {kind: kOP_RUN, op: (*Chip).iLSRAcc, cycles: 2},                               // LSR
+	0x4B: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iALR, cycles: 2},          // ALR #i
+	0x4C: {kind: kOP_RUN, op: (*Chip).iJMP, cycles: 3},                                  // JMP a
+	0x4D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iEOR, cycles: 4},           // EOR a
+	0x4E: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iLSR, cycles: 6},            // LSR a
+	0x4F: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iSRE, cycles: 6},            // SRE a
+	0x50: {kind: kOP_RUN, op: (*Chip).iBVC, cycles: 2},                                  // BVC *+r
+	0x51: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).iEOR, cycles: 5},          // EOR (d),y
+	0x52: {kind: kOP_HALT},                                                              // HLT
+	0x53: {kind: kOP_RMW, mode: kMODE_INDIRECTY, op: (*Chip).iSRE, cycles: 8},           // SRE (d),y
+	0x54: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                  // NOP d,x
+	0x55: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).iEOR, cycles: 4},                // EOR d,x
+	0x56: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iLSR, cycles: 6},                 // LSR d,x
+	0x57: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iSRE, cycles: 6},                 // SRE d,x
+	0x58: {kind: kOP_RUN, op: (*Chip).iCLI, cycles: 2},                                  // CLI
+	0x59: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iEOR, cycles: 4},          // EOR a,y
+	0x5A: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 2},                                  // NOP
+	0x5B: {kind: kOP_RMW, mode: kMODE_ABSOLUTEY, op: (*Chip).iSRE, cycles: 7},           // SRE a,y
+	0x5C: {kind: kOP_ADDR, mode: kMODE_ABSOLUTEX, cycles: 4},                            // NOP a,x
+	0x5D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).iEOR, cycles: 4},          // EOR a,x
+	0x5E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iLSR, cycles: 7},           // LSR a,x
+	0x5F: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iSRE, cycles: 7},           // SRE a,x
+	0x60: {kind: kOP_RUN, op: (*Chip).iRTS, cycles: 6},                                  // RTS
+	0x61: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).iADC, cycles: 6},          // ADC (d,x)
+	0x62: {kind: kOP_HALT},                                                              // HLT
+	0x63: {kind: kOP_RMW, mode: kMODE_INDIRECTX, op: (*Chip).iRRA, cycles: 8},           // RRA (d,x)
+	0x64: {kind: kOP_ADDR, mode: kMODE_ZP, cycles: 3},                                   // NOP d
+	0x65: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iADC, cycles: 3},                 // ADC d
+	0x66: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iROR, cycles: 5},                  // ROR d
+	0x67: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRRA, cycles: 5},                  // RRA d
+	0x68: {kind: kOP_RUN, op: (*Chip).iPLA, cycles: 4},                                  // PLA
+	0x69: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iADC, cycles: 2},          // ADC #i
+	0x6A: {kind: kOP_RUN, op: (*Chip).iRORAcc, cycles: 2},                               // ROR
+	0x6B: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iARR, cycles: 2},          // ARR #i
+	0x6C: {kind: kOP_RUN, op: (*Chip).iJMPIndirect, cycles: 5},                          // JMP (a)
+	0x6D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iADC, cycles: 4},           // ADC a
+	0x6E: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iROR, cycles: 6},            // ROR a
+	0x6F: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iRRA, cycles: 6},            // RRA a
+	0x70: {kind: kOP_RUN, op: (*Chip).iBVS, cycles: 2},                                  // BVS *+r
+	0x71: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).iADC, cycles: 5},          // ADC (d),y
+	0x72: {kind: kOP_HALT},                                                              // HLT
+	0x73: {kind: kOP_RMW, mode: kMODE_INDIRECTY, op: (*Chip).iRRA, cycles: 8},           // RRA (d),y
+	0x74: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                  // NOP d,x
+	0x75: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).iADC, cycles: 4},                // ADC d,x
+	0x76: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iROR, cycles: 6},                 // ROR d,x
+	0x77: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iRRA, cycles: 6},                 // RRA d,x
+	0x78: {kind: kOP_RUN, op: (*Chip).iSEI, cycles: 2},                                  // SEI
+	0x79: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iADC, cycles: 4},          // ADC a,y
+	0x7A: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 2},                                  // NOP
+	0x7B: {kind: kOP_RMW, mode: kMODE_ABSOLUTEY, op: (*Chip).iRRA, cycles: 7},           // RRA a,y
+	0x7C: {kind: kOP_ADDR, mode: kMODE_ABSOLUTEX, cycles: 4},                            // NOP a,x
+	0x7D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).iADC, cycles: 4},          // ADC a,x
+	0x7E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iROR, cycles: 7},           // ROR a,x
+	0x7F: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iRRA, cycles: 7},           // RRA a,x
+	0x80: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                            // NOP #i
+	0x81: {kind: kOP_STORE, mode: kMODE_INDIRECTX, val: (*Chip).regA, cycles: 6},        // STA (d,x)
+	0x82: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                            // NOP #i
+	0x83: {kind: kOP_STORE, mode: kMODE_INDIRECTX, val: (*Chip).regAX, cycles: 6},       // SAX (d,x)
+	0x84: {kind: kOP_STORE, mode: kMODE_ZP, val: (*Chip).regY, cycles: 3},               // STY d
+	0x85: {kind: kOP_STORE, mode: kMODE_ZP, val: (*Chip).regA, cycles: 3},               // STA d
+	0x86: {kind: kOP_STORE, mode: kMODE_ZP, val: (*Chip).regX, cycles: 3},               // STX d
+	0x87: {kind: kOP_STORE, mode: kMODE_ZP, val: (*Chip).regAX, cycles: 3},              // SAX d
+	0x88: {kind: kOP_RUN, op: (*Chip).iDEY, cycles: 2},                                  // DEY
+	0x89: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                            // NOP #i
+	0x8A: {kind: kOP_RUN, op: (*Chip).iTXA, cycles: 2},                                  // TXA
+	0x8B: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iXAA, cycles: 2},          // XAA #i
+	0x8C: {kind: kOP_STORE, mode: kMODE_ABSOLUTE, val: (*Chip).regY, cycles: 4},         // STY a
+	0x8D: {kind: kOP_STORE, mode: kMODE_ABSOLUTE, val: (*Chip).regA, cycles: 4},         // STA a
+	0x8E: {kind: kOP_STORE, mode: kMODE_ABSOLUTE, val: (*Chip).regX, cycles: 4},         // STX a
+	0x8F: {kind: kOP_STORE, mode: kMODE_ABSOLUTE, val: (*Chip).regAX, cycles: 4},        // SAX a
+	0x90: {kind: kOP_RUN, op: (*Chip).iBCC, cycles: 2},                                  // BCC *+d
+	0x91: {kind: kOP_STORE, mode: kMODE_INDIRECTY, val: (*Chip).regA, cycles: 6},        // STA (d),y
+	0x92: {kind: kOP_HALT},                                                              // HLT
+	0x93: {kind: kOP_RUN, op: (*Chip).iAHXIndirectY, cycles: 6},                         // AHX (d),y
+	0x94: {kind: kOP_STORE, mode: kMODE_ZPX, val: (*Chip).regY, cycles: 4},              // STY d,x
+	0x95: {kind: kOP_STORE, mode: kMODE_ZPX, val: (*Chip).regA, cycles: 4},              // STA d,x
+	0x96: {kind: kOP_STORE, mode: kMODE_ZPY, val: (*Chip).regX, cycles: 4},              // STX d,y
+	0x97: {kind: kOP_STORE, mode: kMODE_ZPY, val: (*Chip).regAX, cycles: 4},             // SAX d,y
+	0x98: {kind: kOP_RUN, op: (*Chip).iTYA, cycles: 2},                                  // TYA
+	0x99: {kind: kOP_STORE, mode: kMODE_ABSOLUTEY, val: (*Chip).regA, cycles: 5},        // STA a,y
+	0x9A: {kind: kOP_RUN, op: (*Chip).iTXS, cycles: 2},                                  // TXS
+	0x9B: {kind: kOP_RUN, op: (*Chip).iTAS, cycles: 5},                                  // TAS a,y
+	0x9C: {kind: kOP_RUN, op: (*Chip).iSHYAbsoluteX, cycles: 5},                         // SHY a,x
+	0x9D: {kind: kOP_STORE, mode: kMODE_ABSOLUTEX, val: (*Chip).regA, cycles: 5},        // STA a,x
+	0x9E: {kind: kOP_RUN, op: (*Chip).iSHXAbsoluteY, cycles: 5},                         // SHX a,y
+	0x9F: {kind: kOP_RUN, op: (*Chip).iAHXAbsoluteY, cycles: 5},                         // AHX a,y
+	0xA0: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).loadRegisterY, cycles: 2}, // LDY #i
+	0xA1: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).loadRegisterA, cycles: 6}, // LDA (d,x)
+	0xA2: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).loadRegisterX, cycles: 2}, // LDX #i
+	0xA3: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).iLAX, cycles: 6},          // LAX (d,x)
+	0xA4: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).loadRegisterY, cycles: 3},        // LDY d
+	0xA5: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).loadRegisterA, cycles: 3},        // LDA d
+	0xA6: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).loadRegisterX, cycles: 3},        // LDX d
+	0xA7: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iLAX, cycles: 3},                 // LAX d
+	0xA8: {kind: kOP_RUN, op: (*Chip).iTAY, cycles: 2},                                  // TAY
+	0xA9: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).loadRegisterA, cycles: 2}, // LDA #i
+	0xAA: {kind: kOP_RUN, op: (*Chip).iTAX, cycles: 2},                                  // TAX
+	0xAB: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iOAL, cycles: 2},          // OAL #i
+	0xAC: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).loadRegisterY, cycles: 4},  // LDY a
+	0xAD: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).loadRegisterA, cycles: 4},  // LDA a
+	0xAE: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).loadRegisterX, cycles: 4},  // LDX a
+	0xAF: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iLAX, cycles: 4},           // LAX a
+	0xB0: {kind: kOP_RUN, op: (*Chip).iBCS, cycles: 2},                                  // BCS *+d
+	0xB1: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).loadRegisterA, cycles: 5}, // LDA (d),y
+	0xB2: {kind: kOP_HALT},                                                              // HLT
+	0xB3: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).iLAX, cycles: 5},          // LAX (d),y
+	0xB4: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).loadRegisterY, cycles: 4},       // LDY d,x
+	0xB5: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).loadRegisterA, cycles: 4},       // LDA d,x
+	0xB6: {kind: kOP_LOAD, mode: kMODE_ZPY, op: (*Chip).loadRegisterX, cycles: 4},       // LDX d,y
+	0xB7: {kind: kOP_LOAD, mode: kMODE_ZPY, op: (*Chip).iLAX, cycles: 4},                // LAX d,y
+	0xB8: {kind: kOP_RUN, op: (*Chip).iCLV, cycles: 2},                                  // CLV
+	0xB9: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).loadRegisterA, cycles: 4}, // LDA a,y
+	0xBA: {kind: kOP_RUN, op: (*Chip).iTSX, cycles: 2},                                  // TSX
+	0xBB: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iLAS, cycles: 4},          // LAS a,y
+	0xBC: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).loadRegisterY, cycles: 4}, // LDY a,x
+	0xBD: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).loadRegisterA, cycles: 4}, // LDA a,x
+	0xBE: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).loadRegisterX, cycles: 4}, // LDX a,y
+	0xBF: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iLAX, cycles: 4},          // LAX a,y
+	0xC0: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).compareY, cycles: 2},      // CPY #i
+	0xC1: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).compareA, cycles: 6},      // CMP (d,x)
+	0xC2: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                            // NOP #i
+	0xC3: {kind: kOP_RMW, mode: kMODE_INDIRECTX, op: (*Chip).iDCP, cycles: 8},           // DCP (d,X)
+	0xC4: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).compareY, cycles: 3},             // CPY d
+	0xC5: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).compareA, cycles: 3},             // CMP d
+	0xC6: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iDEC, cycles: 5},                  // DEC d
+	0xC7: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iDCP, cycles: 5},                  // DCP d
+	0xC8: {kind: kOP_RUN, op: (*Chip).iINY, cycles: 2},                                  // INY
+	0xC9: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).compareA, cycles: 2},      // CMP #i
+	0xCA: {kind: kOP_RUN, op: (*Chip).iDEX, cycles: 2},                                  // DEX
+	0xCB: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iAXS, cycles: 2},          // AXS #i
+	0xCC: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).compareY, cycles: 4},       // CPY a
+	0xCD: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).compareA, cycles: 4},       // CMP a
+	0xCE: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iDEC, cycles: 6},            // DEC a
+	0xCF: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iDCP, cycles: 6},            // DCP a
+	0xD0: {kind: kOP_RUN, op: (*Chip).iBNE, cycles: 2},                                  // BNE *+r
+	0xD1: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).compareA, cycles: 5},      // CMP (d),y
+	0xD2: {kind: kOP_HALT},                                                              // HLT
+	0xD3: {kind: kOP_RMW, mode: kMODE_INDIRECTY, op: (*Chip).iDCP, cycles: 8},           // DCP (d),y
+	0xD4: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                  // NOP d,x
+	0xD5: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).compareA, cycles: 4},            // CMP d,x
+	0xD6: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iDEC, cycles: 6},                 // DEC d,x
+	0xD7: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iDCP, cycles: 6},                 // DCP d,x
+	0xD8: {kind: kOP_RUN, op: (*Chip).iCLD, cycles: 2},                                  // CLD
+	0xD9: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).compareA, cycles: 4},      // CMP a,y
+	0xDA: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 2},                                  // NOP
+	0xDB: {kind: kOP_RMW, mode: kMODE_ABSOLUTEY, op: (*Chip).iDCP, cycles: 7},           // DCP a,y
+	0xDC: {kind: kOP_ADDR, mode: kMODE_ABSOLUTEX, cycles: 4},                            // NOP a,x
+	0xDD: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).compareA, cycles: 4},      // CMP a,x
+	0xDE: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iDEC, cycles: 7},           // DEC a,x
+	0xDF: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iDCP, cycles: 7},           // DCP a,x
+	0xE0: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).compareX, cycles: 2},      // CPX #i
+	0xE1: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).iSBC, cycles: 6},          // SBC (d,x)
+	0xE2: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                            // NOP #i
+	0xE3: {kind: kOP_RMW, mode: kMODE_INDIRECTX, op: (*Chip).iISC, cycles: 8},           // ISC (d,x)
+	0xE4: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).compareX, cycles: 3},             // CPX d
+	0xE5: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iSBC, cycles: 3},                 // SBC d
+	0xE6: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iINC, cycles: 5},                  // INC d
+	0xE7: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iISC, cycles: 5},                  // ISC d
+	0xE8: {kind: kOP_RUN, op: (*Chip).iINX, cycles: 2},                                  // INX
+	0xE9: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iSBC, cycles: 2},          // SBC #i
+	0xEA: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 2},                                  // NOP
+	0xEB: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iSBC, cycles: 2},          // SBC #i
+	0xEC: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).compareX, cycles: 4},       // CPX a
+	0xED: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iSBC, cycles: 4},           // SBC a
+	0xEE: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iINC, cycles: 6},            // INC a
+	0xEF: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iISC, cycles: 6},            // ISC a
+	0xF0: {kind: kOP_RUN, op: (*Chip).iBEQ, cycles: 2},                                  // BEQ *+d
+	0xF1: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).iSBC, cycles: 5},          // SBC (d),y
+	0xF2: {kind: kOP_HALT},                                                              // HLT
+	0xF3: {kind: kOP_RMW, mode: kMODE_INDIRECTY, op: (*Chip).iISC, cycles: 8},           // ISC (d),y
+	0xF4: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                  // NOP d,x
+	0xF5: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).iSBC, cycles: 4},                // SBC d,x
+	0xF6: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iINC, cycles: 6},                 // INC d,x
+	0xF7: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iISC, cycles: 6},                 // ISC d,x
+	0xF8: {kind: kOP_RUN, op: (*Chip).iSED, cycles: 2},                                  // SED
+	0xF9: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iSBC, cycles: 4},          // SBC a,y
+	0xFA: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 2},                                  // NOP
+	0xFB: {kind: kOP_RMW, mode: kMODE_ABSOLUTEY, op: (*Chip).iISC, cycles: 7},           // ISC a,y
+	0xFC: {kind: kOP_ADDR, mode: kMODE_ABSOLUTEX, cycles: 4},                            // NOP a,x
+	0xFD: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).iSBC, cycles: 4},          // SBC a,x
+	0xFE: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iINC, cycles: 7},           // INC a,x
+	0xFF: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iISC, cycles: 7},           // ISC a,x
+}
+
+// cmosOverrides are the opcodes which differ on the CMOS 65C02. Any opcode not listed here acts identically to NMOS.
+//
+// Opcode matrix and timing taken from:
+// http://www.6502.org/tutorials/65c02opcodes.html
+// https://www.westerndesigncenter.com/wdc/documentation/w65c02s.pdf
+var cmosOverrides = map[uint8]opcode{
+	0x02: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                             // NOP #i
+	0x03: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x04: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iTSB, cycles: 5},                   // TSB d
+	0x07: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRMB, cycles: 5},                   // RMB0 d
+	0x0B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x0C: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iTSB, cycles: 6},             // TSB a
+	0x0F: {kind: kOP_RUN, op: (*Chip).iBBR, cycles: 5},                                   // BBR0 d,*+r
+	0x12: {kind: kOP_LOAD, mode: kMODE_INDIRECTZP, op: (*Chip).iORA, cycles: 5},          // ORA (d)
+	0x13: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x14: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iTRB, cycles: 5},                   // TRB d
+	0x17: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRMB, cycles: 5},                   // RMB1 d
+	0x1A: {kind: kOP_RUN, op: (*Chip).iINA, cycles: 2},                                   // INC
+	0x1B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x1C: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iTRB, cycles: 6},             // TRB a
+	0x1E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX_SHIFT, op: (*Chip).iASL, cycles: 6},      // ASL a,x
+	0x1F: {kind: kOP_RUN, op: (*Chip).iBBR, cycles: 5},                                   // BBR1 d,*+r
+	0x22: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                             // NOP #i
+	0x23: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x27: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRMB, cycles: 5},                   // RMB2 d
+	0x2B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x2F: {kind: kOP_RUN, op: (*Chip).iBBR, cycles: 5},                                   // BBR2 d,*+r
+	0x32: {kind: kOP_LOAD, mode: kMODE_INDIRECTZP, op: (*Chip).iAND, cycles: 5},          // AND (d)
+	0x33: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x34: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).iBIT, cycles: 4},                 // BIT d,x
+	0x37: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRMB, cycles: 5},                   // RMB3 d
+	0x3A: {kind: kOP_RUN, op: (*Chip).iDEA, cycles: 2},                                   // DEC
+	0x3B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x3C: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).iBIT, cycles: 4},           // BIT a,x
+	0x3E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX_SHIFT, op: (*Chip).iROL, cycles: 6},      // ROL a,x
+	0x3F: {kind: kOP_RUN, op: (*Chip).iBBR, cycles: 5},                                   // BBR3 d,*+r
+	0x42: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                             // NOP #i
+	0x43: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x44: {kind: kOP_ADDR, mode: kMODE_ZP, cycles: 3},                                    // NOP d
+	0x47: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRMB, cycles: 5},                   // RMB4 d
+	0x4B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x4F: {kind: kOP_RUN, op: (*Chip).iBBR, cycles: 5},                                   // BBR4 d,*+r
+	0x52: {kind: kOP_LOAD, mode: kMODE_INDIRECTZP, op: (*Chip).iEOR, cycles: 5},          // EOR (d)
+	0x53: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x54: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                   // NOP d,x
+	0x57: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRMB, cycles: 5},                   // RMB5 d
+	0x5A: {kind: kOP_RUN, op: (*Chip).iPHY, cycles: 3},                                   // PHY
+	0x5B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x5C: {kind: kOP_RUN, op: (*Chip).iNOP8, cycles: 8},                                  // NOP a (8 ticks)
+	0x5E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX_SHIFT, op: (*Chip).iLSR, cycles: 6},      // LSR a,x
+	0x5F: {kind: kOP_RUN, op: (*Chip).iBBR, cycles: 5},                                   // BBR5 d,*+r
+	0x61: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).iADCCMOS, cycles: 6},       // ADC (d,x)
+	0x62: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                             // NOP #i
+	0x63: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x64: {kind: kOP_STORE, mode: kMODE_ZP, val: (*Chip).regZero, cycles: 3},             // STZ d
+	0x65: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iADCCMOS, cycles: 3},              // ADC d
+	0x67: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRMB, cycles: 5},                   // RMB6 d
+	0x69: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iADCCMOS, cycles: 2},       // ADC #i
+	0x6B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x6C: {kind: kOP_RUN, op: (*Chip).iJMPIndirect, cycles: 6},                           // JMP (a) (no page wrap bug so an extra tick)
+	0x6D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iADCCMOS, cycles: 4},        // ADC a
+	0x6F: {kind: kOP_RUN, op: (*Chip).iBBR, cycles: 5},                                   // BBR6 d,*+r
+	0x71: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).iADCCMOS, cycles: 5},       // ADC (d),y
+	0x72: {kind: kOP_LOAD, mode: kMODE_INDIRECTZP, op: (*Chip).iADCCMOS, cycles: 5},      // ADC (d)
+	0x73: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x74: {kind: kOP_STORE, mode: kMODE_ZPX, val: (*Chip).regZero, cycles: 4},            // STZ d,x
+	0x75: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).iADCCMOS, cycles: 4},             // ADC d,x
+	0x77: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRMB, cycles: 5},                   // RMB7 d
+	0x79: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iADCCMOS, cycles: 4},       // ADC a,y
+	0x7A: {kind: kOP_RUN, op: (*Chip).iPLY, cycles: 4},                                   // PLY
+	0x7B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x7C: {kind: kOP_RUN, op: (*Chip).iJMPIndirectX, cycles: 6},                          // JMP (a,x)
+	0x7D: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).iADCCMOS, cycles: 4},       // ADC a,x
+	0x7E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX_SHIFT, op: (*Chip).iROR, cycles: 6},      // ROR a,x
+	0x7F: {kind: kOP_RUN, op: (*Chip).iBBR, cycles: 5},                                   // BBR7 d,*+r
+	0x80: {kind: kOP_RUN, op: (*Chip).iBRA, cycles: 3},                                   // BRA *+r
+	0x82: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                             // NOP #i
+	0x83: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x87: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSMB, cycles: 5},                   // SMB0 d
+	0x89: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iBITImmediate, cycles: 2},  // BIT #i
+	0x8B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x8F: {kind: kOP_RUN, op: (*Chip).iBBS, cycles: 5},                                   // BBS0 d,*+r
+	0x92: {kind: kOP_STORE, mode: kMODE_INDIRECTZP, val: (*Chip).regA, cycles: 5},        // STA (d)
+	0x93: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x97: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSMB, cycles: 5},                   // SMB1 d
+	0x9B: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0x9C: {kind: kOP_STORE, mode: kMODE_ABSOLUTE, val: (*Chip).regZero, cycles: 4},       // STZ a
+	0x9E: {kind: kOP_STORE, mode: kMODE_ABSOLUTEX, val: (*Chip).regZero, cycles: 5},      // STZ a,x
+	0x9F: {kind: kOP_RUN, op: (*Chip).iBBS, cycles: 5},                                   // BBS1 d,*+r
+	0xA3: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xA7: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSMB, cycles: 5},                   // SMB2 d
+	0xAB: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xAF: {kind: kOP_RUN, op: (*Chip).iBBS, cycles: 5},                                   // BBS2 d,*+r
+	0xB2: {kind: kOP_LOAD, mode: kMODE_INDIRECTZP, op: (*Chip).loadRegisterA, cycles: 5}, // LDA (d)
+	0xB3: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xB7: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSMB, cycles: 5},                   // SMB3 d
+	0xBB: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xBF: {kind: kOP_RUN, op: (*Chip).iBBS, cycles: 5},                                   // BBS3 d,*+r
+	0xC2: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                             // NOP #i
+	0xC3: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xC7: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSMB, cycles: 5},                   // SMB4 d
+	0xCB: {kind: kOP_RUN, op: (*Chip).iWAI, cycles: 3},                                   // WAI
+	0xCF: {kind: kOP_RUN, op: (*Chip).iBBS, cycles: 5},                                   // BBS4 d,*+r
+	0xD2: {kind: kOP_LOAD, mode: kMODE_INDIRECTZP, op: (*Chip).compareA, cycles: 5},      // CMP (d)
+	0xD3: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xD4: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                   // NOP d,x
+	0xD7: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSMB, cycles: 5},                   // SMB5 d
+	0xDA: {kind: kOP_RUN, op: (*Chip).iPHX, cycles: 3},                                   // PHX
+	0xDB: {kind: kOP_RUN, op: (*Chip).iSTP, cycles: 0},                                   // STP
+	0xDC: {kind: kOP_ADDR, mode: kMODE_ABSOLUTE, cycles: 4},                              // NOP a
+	0xDF: {kind: kOP_RUN, op: (*Chip).iBBS, cycles: 5},                                   // BBS5 d,*+r
+	0xE1: {kind: kOP_LOAD, mode: kMODE_INDIRECTX, op: (*Chip).iSBCCMOS, cycles: 6},       // SBC (d,x)
+	0xE2: {kind: kOP_ADDR, mode: kMODE_IMMEDIATE, cycles: 2},                             // NOP #i
+	0xE3: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xE5: {kind: kOP_LOAD, mode: kMODE_ZP, op: (*Chip).iSBCCMOS, cycles: 3},              // SBC d
+	0xE7: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSMB, cycles: 5},                   // SMB6 d
+	0xE9: {kind: kOP_LOAD, mode: kMODE_IMMEDIATE, op: (*Chip).iSBCCMOS, cycles: 2},       // SBC #i
+	0xEB: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xED: {kind: kOP_LOAD, mode: kMODE_ABSOLUTE, op: (*Chip).iSBCCMOS, cycles: 4},        // SBC a
+	0xEF: {kind: kOP_RUN, op: (*Chip).iBBS, cycles: 5},                                   // BBS6 d,*+r
+	0xF1: {kind: kOP_LOAD, mode: kMODE_INDIRECTY, op: (*Chip).iSBCCMOS, cycles: 5},       // SBC (d),y
+	0xF2: {kind: kOP_LOAD, mode: kMODE_INDIRECTZP, op: (*Chip).iSBCCMOS, cycles: 5},      // SBC (d)
+	0xF3: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xF4: {kind: kOP_ADDR, mode: kMODE_ZPX, cycles: 4},                                   // NOP d,x
+	0xF5: {kind: kOP_LOAD, mode: kMODE_ZPX, op: (*Chip).iSBCCMOS, cycles: 4},             // SBC d,x
+	0xF7: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iSMB, cycles: 5},                   // SMB7 d
+	0xF9: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEY, op: (*Chip).iSBCCMOS, cycles: 4},       // SBC a,y
+	0xFA: {kind: kOP_RUN, op: (*Chip).iPLX, cycles: 4},                                   // PLX
+	0xFB: {kind: kOP_RUN, op: (*Chip).iNOP, cycles: 1},                                   // NOP (completes in the opcode fetch tick)
+	0xFC: {kind: kOP_ADDR, mode: kMODE_ABSOLUTE, cycles: 4},                              // NOP a
+	0xFD: {kind: kOP_LOAD, mode: kMODE_ABSOLUTEX, op: (*Chip).iSBCCMOS, cycles: 4},       // SBC a,x
+	0xFF: {kind: kOP_RUN, op: (*Chip).iBBS, cycles: 5},                                   // BBS7 d,*+r
+}
+
+// revAOverrides are the opcodes which differ on NMOS revision A parts. Any opcode not listed here acts identically to NMOS.
+// These shipped before ROR worked so it shifts left instead (the undocumented opcodes built on it are unchanged).
+var revAOverrides = map[uint8]opcode{
+	0x66: {kind: kOP_RMW, mode: kMODE_ZP, op: (*Chip).iRORRevA, cycles: 5},        // ROR d
+	0x6A: {kind: kOP_RUN, op: (*Chip).iRORAccRevA, cycles: 2},                     // ROR
+	0x6E: {kind: kOP_RMW, mode: kMODE_ABSOLUTE, op: (*Chip).iRORRevA, cycles: 6},  // ROR a
+	0x76: {kind: kOP_RMW, mode: kMODE_ZPX, op: (*Chip).iRORRevA, cycles: 6},       // ROR d,x
+	0x7E: {kind: kOP_RMW, mode: kMODE_ABSOLUTEX, op: (*Chip).iRORRevA, cycles: 7}, // ROR a,x
+}
+
+// nmosRunWrites holds the ticks (bit N set for tick N) which write for the NMOS kOP_RUN opcodes which write.
+// See nextCycleWrites.
+var nmosRunWrites = [256]uint16{
+	0x00: 1<<3 | 1<<4 | 1<<5, // BRK
+	0x08: 1 << 3,             // PHP
+	0x20: 1<<4 | 1<<5,        // JSR a
+	0x48: 1 << 3,             // PHA
+	0x93: 1 << 6,             // AHX (d),y
+	0x9B: 1 << 5,             // TAS a,y
+	0x9C: 1 << 5,             // SHY a,x
+	0x9E: 1 << 5,             // SHX a,y
+	0x9F: 1 << 5,             // AHX a,y
+}
+
+// opcodeTables holds the descriptors for each CPUType.
+var opcodeTables [CPU_MAX]*[256]opcode
+
+func init() {
+	cmos := nmosOpcodes
+	for op, o := range cmosOverrides {
+		cmos[op] = o
+	}
+	opcodeTables[CPU_NMOS] = &nmosOpcodes
+	opcodeTables[CPU_NMOS_RICOH] = &nmosOpcodes
+	opcodeTables[CPU_NMOS_6510] = &nmosOpcodes
+	opcodeTables[CPU_CMOS] = &cmos
+	revA := nmosOpcodes
+	for op, o := range revAOverrides {
+		revA[op] = o
+	}
+	opcodeTables[CPU_NMOS_REV_A] = &revA
+	opcodeTables[CPU_NMOS_6507] = &nmosOpcodes
+	opcodeTables[CPU_NMOS_6504] = &nmosOpcodes
+}
+
+// processOpcode runs the current opcode for a tick.
+// Returns true when the opcode is complete and any error.
+func (p *Chip) processOpcode() (bool, error) {
+	o := &p.opcodes[p.op]
+	switch o.kind {
+	case kOP_RUN:
+		return o.op(p)
+	case kOP_LOAD:
+		return p.loadInstruction(addrFuncs[o.mode], o.op)
+	case kOP_RMW:
+		return p.rmwInstruction(addrFuncs[o.mode], o.op)
+	case kOP_STORE:
+		return p.storeInstruction(addrFuncs[o.mode], o.val)
+	case kOP_ADDR:
+		return addrFuncs[o.mode](p, kLOAD_INSTRUCTION)
+	case kOP_HALT:
+		p.halted = true
+		return p.opDone, nil
+	}
+	return true, InvalidCPUState{fmt.Sprintf("opcode 0x%.2X has invalid kind %d", p.op, o.kind)}
+}
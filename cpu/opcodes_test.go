@@ -0,0 +1,52 @@
+package cpu
+
+import (
+	"testing"
+)
+
+func TestOpcodeCycles(t *testing.T) {
+	// Flags for each conditional branch so it isn't taken.
+	notTaken := map[uint8]uint8{
+		0x10: P_NEGATIVE, // BPL
+		0x30: 0x00,       // BMI
+		0x50: P_OVERFLOW, // BVC
+		0x70: 0x00,       // BVS
+		0x90: P_CARRY,    // BCC
+		0xB0: 0x00,       // BCS
+		0xD0: P_ZERO,     // BNE
+		0xF0: 0x00,       // BEQ
+	}
+	for _, cpu := range []CPUType{CPU_NMOS, CPU_NMOS_RICOH, CPU_NMOS_6510, CPU_CMOS} {
+		for op := 0; op < 256; op++ {
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: cpu}, 0x00, 0x0202)
+			// Every operand is 0x00 so all addresses are in zero page and no indexing crosses a page.
+			for i := range r.addr {
+				r.addr[i] = 0x00
+			}
+			// BBR tests zero page 0x00 so set every bit to skip the branch.
+			if cpu == CPU_CMOS && op&0x8F == 0x0F {
+				r.addr[0x0000] = 0xFF
+			}
+			r.addr[0x1000] = uint8(op)
+			c.PC = 0x1000
+			c.A, c.X, c.Y, c.S = 0x00, 0x00, 0x00, 0xFF
+			c.P = P_S1 | P_B | notTaken[uint8(op)]
+
+			want := c.opcodes[op].cycles
+			info, err := c.Step()
+			if want == 0 {
+				if err == nil {
+					t.Errorf("CPU %d: opcode 0x%.2X didn't halt", cpu, op)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("CPU %d: opcode 0x%.2X got error: %v", cpu, op, err)
+				continue
+			}
+			if got := info.Cycles; got != want {
+				t.Errorf("CPU %d: opcode 0x%.2X took %d cycles and table has %d", cpu, op, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package cpu
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ProfileCount holds the counters a Profiler keeps for a PC.
+type ProfileCount struct {
+	Instructions int // Number of instructions started at the PC which completed.
+	Cycles       int // Clock cycles used by those instructions (including Stalls).
+	Stalls       int // Cycles where RDY held the CPU after the instruction (i.e. a STA WSYNC on the 2600).
+}
+
+// add accumulates o into c.
+func (c *ProfileCount) add(o ProfileCount) {
+	c.Instructions += o.Instructions
+	c.Cycles += o.Cycles
+	c.Stalls += o.Stalls
+}
+
+// profNode is a single point in the call tree. Each distinct path of calls gets its own node.
+type profNode struct {
+	parent   *profNode
+	site     uint16 // PC of the JSR/BRK (or interrupted instruction) in parent which entered this node.
+	entry    uint16 // The address called (i.e. JSR target or interrupt vector destination).
+	children map[[2]uint16]*profNode
+	pcs      map[uint16]*ProfileCount
+}
+
+// Profiler counts instructions and cycles per PC for code run on a Chip. Cycles are also attributed to
+// the current call stack which is tracked the same way as a CallStack (which the Profiler keeps its own
+// copy of). See CallStack for how calls and returns are matched.
+// The cycles of an interrupt sequence are counted against the first instruction of the handler
+// (but aren't counted as an instruction there).
+type Profiler struct {
+	root      *profNode
+	calls     CallStack
+	nodes     []*profNode // The node for each frame in calls.
+	flat      [0x10000]ProfileCount
+	pending   int      // Cycles run so far by the current instruction/interrupt.
+	interrupt CallKind // Set to the kind of interrupt if the current sequence is one instead of an instruction.
+	idle      bool     // Set once the CPU halts (or waits after a WAI) so further cycles aren't counted as instructions.
+}
+
+// NewProfiler returns an empty Profiler. Install it with SetProfiler.
+func NewProfiler() *Profiler {
+	pr := &Profiler{}
+	pr.Clear()
+	return pr
+}
+
+// SetProfiler starts counting into prof. Passing nil stops profiling. The same Profiler can be
+// installed again later to continue accumulating.
+func (p *Chip) SetProfiler(prof *Profiler) {
+	p.profile = prof
+	if prof != nil {
+		prof.pending = 0
+		prof.interrupt = CALL_UNIMPLEMENTED
+		prof.idle = p.halted || p.waiting
+	}
+}
+
+// Clear discards all counters and the call stack.
+func (pr *Profiler) Clear() {
+	pr.root = newProfNode(nil, 0, 0)
+	pr.calls.frames = pr.calls.frames[:0]
+	pr.nodes = pr.nodes[:0]
+	pr.flat = [0x10000]ProfileCount{}
+	pr.pending = 0
+	pr.interrupt = CALL_UNIMPLEMENTED
+}
+
+// PC returns the counters for the instruction at addr.
+func (pr *Profiler) PC(addr uint16) ProfileCount {
+	return pr.flat[addr]
+}
+
+// Total returns the counters summed across all PCs.
+func (pr *Profiler) Total() ProfileCount {
+	var t ProfileCount
+	for _, c := range pr.flat {
+		t.add(c)
+	}
+	return t
+}
+
+// Stack returns the entry addresses of the frames on the shadow call stack from outermost to innermost.
+// Code outside any call isn't included so this is empty at the top level.
+func (pr *Profiler) Stack() []uint16 {
+	var ret []uint16
+	for _, n := range pr.nodes {
+		ret = append(ret, n.entry)
+	}
+	return ret
+}
+
+// newProfNode returns a node for entry called from site within parent.
+func newProfNode(parent *profNode, site, entry uint16) *profNode {
+	return &profNode{
+		parent:   parent,
+		site:     site,
+		entry:    entry,
+		children: make(map[[2]uint16]*profNode),
+		pcs:      make(map[uint16]*ProfileCount),
+	}
+}
+
+// count adds c against addr for the current frame.
+func (pr *Profiler) count(addr uint16, c ProfileCount) {
+	n := pr.top()
+	pc := n.pcs[addr]
+	if pc == nil {
+		pc = &ProfileCount{}
+		n.pcs[addr] = pc
+	}
+	pc.add(c)
+	pr.flat[addr].add(c)
+}
+
+// top returns the node for the innermost frame.
+func (pr *Profiler) top() *profNode {
+	if len(pr.nodes) == 0 {
+		return pr.root
+	}
+	return pr.nodes[len(pr.nodes)-1]
+}
+
+// update updates the call stack once an instruction (or interrupt sequence) completes and keeps the nodes
+// in step with its frames.
+func (pr *Profiler) update(p *Chip, interrupt CallKind) {
+	if !pr.calls.update(p, interrupt) {
+		pr.nodes = pr.nodes[:pr.calls.Depth()]
+		return
+	}
+	// A push may have dropped abandoned frames before adding the new one.
+	pr.nodes = pr.nodes[:pr.calls.Depth()-1]
+	parent := pr.top()
+	f := pr.calls.frames[len(pr.calls.frames)-1]
+	k := [2]uint16{f.PC, f.Target}
+	n := parent.children[k]
+	if n == nil {
+		n = newProfNode(parent, f.PC, f.Target)
+		parent.children[k] = n
+	}
+	pr.nodes = append(pr.nodes, n)
+}
+
+// reset drops back to the top level after the CPU is reset.
+func (pr *Profiler) reset() {
+	pr.calls.frames = pr.calls.frames[:0]
+	pr.nodes = pr.nodes[:0]
+	pr.pending = 0
+	pr.interrupt = CALL_UNIMPLEMENTED
+	pr.idle = false
+}
+
+// stall accounts for a cycle where RDY held the CPU. This happens between instructions (NMOS) or
+// within one (CMOS). Either way the instruction at opPC is the one waiting.
+func (pr *Profiler) stall(p *Chip) {
+	pr.count(p.opPC, ProfileCount{Cycles: 1, Stalls: 1})
+}
+
+// tick accounts for a cycle run by the CPU.
+func (pr *Profiler) tick(p *Chip) {
+	if pr.idle {
+		if p.halted || p.waiting {
+			pr.count(p.opPC, ProfileCount{Cycles: 1})
+			return
+		}
+		pr.idle = false
+	}
+	pr.pending++
+	if !p.opDone {
+		// By the time the sequence completes runningInterrupt and irqRaised have been cleared.
+		pr.interrupt = CALL_UNIMPLEMENTED
+		if p.runningInterrupt {
+			pr.interrupt = p.interruptKind()
+		}
+		return
+	}
+	cycles := pr.pending
+	pr.pending = 0
+	pr.idle = p.halted || p.waiting
+	if pr.interrupt != CALL_UNIMPLEMENTED {
+		pr.update(p, pr.interrupt)
+		pr.interrupt = CALL_UNIMPLEMENTED
+		pr.count(p.PC, ProfileCount{Cycles: cycles})
+		return
+	}
+	pr.count(p.opPC, ProfileCount{Instructions: 1, Cycles: cycles})
+	pr.update(p, CALL_UNIMPLEMENTED)
+}
+
+// WritePprof writes the profile in the gzip compressed protobuf format used by pprof so it can be
+// viewed with "go tool pprof". Each subroutine (and interrupt handler) is a function and each PC is a
+// location within it using the PC as the line number. Code not inside any call is the function "[root]".
+// If symbols is non-nil it's used to name functions by entry address. Otherwise they're named $XXXX.
+func (pr *Profiler) WritePprof(w io.Writer, symbols map[uint16]string) error {
+	pb := &pprofBuilder{
+		strings:   map[string]int64{"": 0},
+		strs:      []string{""},
+		funcs:     make(map[string]uint64),
+		locations: make(map[[2]uint64]uint64),
+	}
+	for _, t := range [][2]string{{"instructions", "count"}, {"cycles", "count"}, {"stalls", "count"}} {
+		var vt protoBuf
+		vt.int64(1, pb.str(t[0]))
+		vt.int64(2, pb.str(t[1]))
+		pb.out.message(1, &vt)
+	}
+	pb.node(pr.root, symbols)
+
+	var m protoBuf
+	m.uint64(1, 1)
+	m.uint64(2, 0)
+	m.uint64(3, 0x10000)
+	m.int64(5, pb.str("6502"))
+	m.bool(7, true)
+	m.bool(9, true)
+	pb.out.message(3, &m)
+	pb.out.b = append(pb.out.b, pb.locs.b...)
+	pb.out.b = append(pb.out.b, pb.fns.b...)
+
+	var period protoBuf
+	period.int64(1, pb.str("cycles"))
+	period.int64(2, pb.str("count"))
+	pb.out.message(11, &period)
+	pb.out.int64(12, 1)
+	pb.out.int64(14, pb.str("cycles"))
+	// The string table has to be complete so it goes last.
+	for _, s := range pb.strs {
+		pb.out.str(6, s)
+	}
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(pb.out.b); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// pprofBuilder accumulates the pieces of a pprof profile.proto message.
+type pprofBuilder struct {
+	out       protoBuf // The Profile message being built.
+	locs      protoBuf // Location entries (appended to out once complete).
+	fns       protoBuf // Function entries (appended to out once complete).
+	strings   map[string]int64
+	strs      []string
+	funcs     map[string]uint64
+	locations map[[2]uint64]uint64
+}
+
+// str returns the string table index for s (adding it if needed).
+func (pb *pprofBuilder) str(s string) int64 {
+	if i, ok := pb.strings[s]; ok {
+		return i
+	}
+	i := int64(len(pb.strs))
+	pb.strings[s] = i
+	pb.strs = append(pb.strs, s)
+	return i
+}
+
+// function returns the function ID for the frame n (adding it if needed).
+func (pb *pprofBuilder) function(n *profNode, symbols map[uint16]string) uint64 {
+	name := "[root]"
+	if n.parent != nil {
+		name = fmt.Sprintf("$%.4X", n.entry)
+		if s, ok := symbols[n.entry]; ok {
+			name = s
+		}
+	}
+	if id, ok := pb.funcs[name]; ok {
+		return id
+	}
+	id := uint64(len(pb.funcs) + 1)
+	pb.funcs[name] = id
+	var f protoBuf
+	f.uint64(1, id)
+	f.int64(2, pb.str(name))
+	f.int64(3, pb.str(name))
+	f.int64(5, int64(n.entry))
+	pb.fns.message(5, &f)
+	return id
+}
+
+// location returns the location ID for addr within function fn (adding it if needed).
+func (pb *pprofBuilder) location(fn uint64, addr uint16) uint64 {
+	k := [2]uint64{fn, uint64(addr)}
+	if id, ok := pb.locations[k]; ok {
+		return id
+	}
+	id := uint64(len(pb.locations) + 1)
+	pb.locations[k] = id
+	var line protoBuf
+	line.uint64(1, fn)
+	line.int64(2, int64(addr))
+	var l protoBuf
+	l.uint64(1, id)
+	l.uint64(2, 1)
+	l.uint64(3, uint64(addr))
+	l.message(4, &line)
+	pb.locs.message(4, &l)
+	return id
+}
+
+// node adds samples for every PC counted in n and then recurses into its children.
+func (pb *pprofBuilder) node(n *profNode, symbols map[uint16]string) {
+	// The caller part of the stack is the same for every PC so compute it once.
+	var callers []uint64
+	for c := n; c.parent != nil; c = c.parent {
+		callers = append(callers, pb.location(pb.function(c.parent, symbols), c.site))
+	}
+	fn := pb.function(n, symbols)
+	var addrs []int
+	for a := range n.pcs {
+		addrs = append(addrs, int(a))
+	}
+	sort.Ints(addrs)
+	for _, a := range addrs {
+		c := n.pcs[uint16(a)]
+		var s protoBuf
+		s.packed(1, append([]uint64{pb.location(fn, uint16(a))}, callers...))
+		s.packed(2, []uint64{uint64(c.Instructions), uint64(c.Cycles), uint64(c.Stalls)})
+		pb.out.message(2, &s)
+	}
+	var keys [][2]uint16
+	for k := range n.children {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	for _, k := range keys {
+		pb.node(n.children[k], symbols)
+	}
+}
+
+// protoBuf is a minimal protobuf encoder for the handful of wire types pprof needs.
+type protoBuf struct {
+	b []byte
+}
+
+// varint appends x in base 128 varint encoding.
+func (p *protoBuf) varint(x uint64) {
+	for x >= 0x80 {
+		p.b = append(p.b, byte(x)|0x80)
+		x >>= 7
+	}
+	p.b = append(p.b, byte(x))
+}
+
+// key appends the tag for field with wire type typ.
+func (p *protoBuf) key(field int, typ uint64) {
+	p.varint(uint64(field)<<3 | typ)
+}
+
+// uint64 appends field as a varint. Zero values are omitted as in proto3.
+func (p *protoBuf) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	p.key(field, 0)
+	p.varint(x)
+}
+
+// int64 appends field as a varint. Zero values are omitted as in proto3.
+func (p *protoBuf) int64(field int, x int64) {
+	p.uint64(field, uint64(x))
+}
+
+// bool appends field as a varint. False is omitted as in proto3.
+func (p *protoBuf) bool(field int, x bool) {
+	if x {
+		p.uint64(field, 1)
+	}
+}
+
+// str appends field as a length delimited string. Unlike the scalars empty strings are still
+// written since the pprof string table relies on position.
+func (p *protoBuf) str(field int, s string) {
+	p.key(field, 2)
+	p.varint(uint64(len(s)))
+	p.b = append(p.b, s...)
+}
+
+// message appends m as a length delimited field.
+func (p *protoBuf) message(field int, m *protoBuf) {
+	p.key(field, 2)
+	p.varint(uint64(len(m.b)))
+	p.b = append(p.b, m.b...)
+}
+
+// packed appends xs as a packed repeated varint field.
+func (p *protoBuf) packed(field int, xs []uint64) {
+	var m protoBuf
+	for _, x := range xs {
+		m.varint(x)
+	}
+	p.message(field, &m)
+}
@@ -0,0 +1,252 @@
+package cpu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestProfiler(t *testing.T) {
+	var irq, rdy testIRQ
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Irq: &irq, Rdy: &rdy}, 0xEA, 0x0202)
+	const R = kRESET
+	for addr, prog := range map[uint16][]uint8{
+		R:      {0x20, 0x00, 0x40, 0x4C, uint8((R + 3) & 0xFF), uint8((R + 3) >> 8)}, // JSR 4000, JMP *
+		0x4000: {0xEA, 0x20, 0x00, 0x41, 0x60},                                       // NOP, JSR 4100, RTS
+		0x4100: {0xEA, 0x60},                                                         // NOP, RTS
+		0x5000: {0x40},                                                               // RTI
+	} {
+		for i, v := range prog {
+			r.addr[addr+uint16(i)] = v
+		}
+	}
+	r.addr[IRQ_VECTOR] = 0x00
+	r.addr[IRQ_VECTOR+1] = 0x50
+	c.S = 0xFF
+	c.P &^= P_INTERRUPT
+
+	prof := NewProfiler()
+	c.SetProfiler(prof)
+	step := func(stack ...uint16) {
+		t.Helper()
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+		if got := prof.Stack(); fmt.Sprint(got) != fmt.Sprint(stack) {
+			t.Fatalf("Bad stack after instruction at %.4X. Got %.4X and want %.4X", c.opPC, got, stack)
+		}
+	}
+	step(0x4000)         // JSR
+	step(0x4000)         // NOP
+	step(0x4000, 0x4100) // JSR
+	step(0x4000, 0x4100) // NOP
+	step(0x4000)         // RTS
+	step()               // RTS
+	step()               // JMP
+	irq.s = true
+	step(0x5000) // IRQ
+	irq.s = false
+	step() // RTI
+	// Stalls on the next opcode fetch count against the RTI (but outside the handler since it returned).
+	rdy.s = true
+	for i := 0; i < 3; i++ {
+		if err := c.Tick(); err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+		c.TickDone()
+	}
+	rdy.s = false
+	step()
+
+	for _, test := range []struct {
+		pc   uint16
+		want ProfileCount
+	}{
+		{R, ProfileCount{Instructions: 1, Cycles: 6}},
+		{R + 3, ProfileCount{Instructions: 2, Cycles: 6}},
+		{0x4000, ProfileCount{Instructions: 1, Cycles: 2}},
+		{0x4001, ProfileCount{Instructions: 1, Cycles: 6}},
+		{0x4004, ProfileCount{Instructions: 1, Cycles: 6}},
+		{0x4100, ProfileCount{Instructions: 1, Cycles: 2}},
+		{0x4101, ProfileCount{Instructions: 1, Cycles: 6}},
+		{0x5000, ProfileCount{Instructions: 1, Cycles: 7 + 6 + 3, Stalls: 3}},
+	} {
+		if got := prof.PC(test.pc); got != test.want {
+			t.Errorf("Bad count for %.4X. Got %+v and want %+v", test.pc, got, test.want)
+		}
+	}
+	if got, want := prof.Total(), (ProfileCount{Instructions: 9, Cycles: 50, Stalls: 3}); got != want {
+		t.Errorf("Bad total. Got %+v and want %+v", got, want)
+	}
+
+	var b bytes.Buffer
+	if err := prof.WritePprof(&b, map[uint16]string{0x4000: "outer"}); err != nil {
+		t.Fatalf("Can't write profile: %v", err)
+	}
+	samples := decodePprof(t, b.Bytes())
+	want := map[string][3]uint64{
+		"1FFE [root]":                             {1, 6, 0},
+		"2001 [root]":                             {2, 6, 0},
+		"4000 outer <- 1FFE [root]":               {1, 2, 0},
+		"4001 outer <- 1FFE [root]":               {1, 6, 0},
+		"4004 outer <- 1FFE [root]":               {1, 6, 0},
+		"4100 $4100 <- 4001 outer <- 1FFE [root]": {1, 2, 0},
+		"4101 $4100 <- 4001 outer <- 1FFE [root]": {1, 6, 0},
+		"5000 $5000 <- 2001 [root]":               {1, 13, 0},
+		"5000 [root]":                             {0, 3, 3},
+	}
+	if len(samples) != len(want) {
+		t.Errorf("Wrong number of samples. Got %v and want %v", samples, want)
+	}
+	for k, v := range want {
+		if got := samples[k]; got != v {
+			t.Errorf("Bad sample for %q. Got %v and want %v", k, got, v)
+		}
+	}
+
+	// Removing it stops counting and Clear empties it.
+	c.SetProfiler(nil)
+	step()
+	if got := prof.PC(R + 3).Instructions; got != 2 {
+		t.Errorf("Counted with the profiler removed. Got %d instructions", got)
+	}
+	prof.Clear()
+	if got := prof.Total(); got != (ProfileCount{}) {
+		t.Errorf("Counts left after Clear: %+v", got)
+	}
+}
+
+// decodePprof parses enough of a pprof profile to return each sample keyed by its stack
+// (as "PC function" from leaf to root joined by " <- ") along with its values.
+func decodePprof(t *testing.T, data []byte) map[string][3]uint64 {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Profile isn't gzipped: %v", err)
+	}
+	raw, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("Can't decompress profile: %v", err)
+	}
+	var strs []string
+	funcs := make(map[uint64]uint64)
+	type loc struct{ fn, line uint64 }
+	locs := make(map[uint64]loc)
+	var samples [][2][]uint64
+	for _, f := range decodeProto(t, raw) {
+		switch f.num {
+		case 2:
+			var s [2][]uint64
+			for _, sf := range decodeProto(t, f.b) {
+				if sf.num == 1 || sf.num == 2 {
+					s[sf.num-1] = decodePacked(t, sf.b)
+				}
+			}
+			samples = append(samples, s)
+		case 4:
+			var id uint64
+			var l loc
+			for _, lf := range decodeProto(t, f.b) {
+				switch lf.num {
+				case 1:
+					id = lf.v
+				case 4:
+					for _, ll := range decodeProto(t, lf.b) {
+						switch ll.num {
+						case 1:
+							l.fn = ll.v
+						case 2:
+							l.line = ll.v
+						}
+					}
+				}
+			}
+			locs[id] = l
+		case 5:
+			var id, name uint64
+			for _, ff := range decodeProto(t, f.b) {
+				switch ff.num {
+				case 1:
+					id = ff.v
+				case 2:
+					name = ff.v
+				}
+			}
+			funcs[id] = name
+		case 6:
+			strs = append(strs, string(f.b))
+		}
+	}
+	ret := make(map[string][3]uint64)
+	for _, s := range samples {
+		var stack []string
+		for _, id := range s[0] {
+			l := locs[id]
+			stack = append(stack, fmt.Sprintf("%.4X %s", l.line, strs[funcs[l.fn]]))
+		}
+		var v [3]uint64
+		copy(v[:], s[1])
+		ret[strings.Join(stack, " <- ")] = v
+	}
+	return ret
+}
+
+// protoField is a single decoded protobuf field. v is set for varints and b for length delimited fields.
+type protoField struct {
+	num int
+	v   uint64
+	b   []byte
+}
+
+func decodeVarint(t *testing.T, b []byte) (uint64, int) {
+	t.Helper()
+	var x uint64
+	for i := 0; i < len(b); i++ {
+		x |= uint64(b[i]&0x7F) << (7 * uint(i))
+		if b[i] < 0x80 {
+			return x, i + 1
+		}
+	}
+	t.Fatalf("Truncated varint")
+	return 0, 0
+}
+
+func decodeProto(t *testing.T, b []byte) []protoField {
+	t.Helper()
+	var ret []protoField
+	for len(b) > 0 {
+		k, n := decodeVarint(t, b)
+		b = b[n:]
+		f := protoField{num: int(k >> 3)}
+		switch k & 7 {
+		case 0:
+			f.v, n = decodeVarint(t, b)
+			b = b[n:]
+		case 2:
+			l, n := decodeVarint(t, b)
+			b = b[n:]
+			if uint64(len(b)) < l {
+				t.Fatalf("Truncated field %d", f.num)
+			}
+			f.b, b = b[:l], b[l:]
+		default:
+			t.Fatalf("Unexpected wire type %d for field %d", k&7, f.num)
+		}
+		ret = append(ret, f)
+	}
+	return ret
+}
+
+func decodePacked(t *testing.T, b []byte) []uint64 {
+	t.Helper()
+	var ret []uint64
+	for len(b) > 0 {
+		v, n := decodeVarint(t, b)
+		ret = append(ret, v)
+		b = b[n:]
+	}
+	return ret
+}
@@ -0,0 +1,178 @@
+package cpu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// kSTATE_VERSION is the current version of the encoding done by MarshalBinary.
+	// This must be bumped any time chipState changes.
+	kSTATE_VERSION = uint8(4)
+)
+
+// kSTATE_MAGIC starts every encoded state so random data is rejected early.
+var kSTATE_MAGIC = [4]byte{'6', '5', '0', '2'}
+
+// chipState is the fixed layout used to encode a Chip. Everything here is a fixed size
+// type so encoding/binary can handle it directly.
+// Anything which is wiring (RAM, IRQ/NMI/RDY sources, SO/I/O port input) or host specific
+// (clock calibration, debug) isn't part of this and is kept from the Chip being restored into.
+type chipState struct {
+	Magic             [4]byte
+	Version           uint8
+	Cpu               int32
+	A                 uint8
+	X                 uint8
+	Y                 uint8
+	S                 uint8
+	P                 uint8
+	PC                uint16
+	Clocks            int64
+	TickDone          bool
+	Reset             bool
+	Op                uint8
+	OpPC              uint16
+	OpVal             uint8
+	OpTick            int32
+	OpAddr            uint16
+	OpDone            bool
+	AddrDone          bool
+	SkipInterrupt     bool
+	PrevSkipInterrupt bool
+	IrqRaised         int32
+	RunningInterrupt  bool
+	Halted            bool
+	HaltOpcode        uint8
+	Waiting           bool
+	ExtraTick         bool
+	PortDDR           uint8
+	PortData          uint8
+	PortFloat         uint8
+	PortFloatEnd      [2]int64
+	SOLevel           bool
+	Sync              bool
+	BreakResume       bool
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler and returns a versioned encoding
+// of the complete CPU state. This can be taken at any point (including mid instruction,
+// interrupt or reset sequence) and then passed to UnmarshalBinary to continue from exactly
+// the same spot.
+// NOTE: Memory isn't included so the memory.Bank used must be saved/restored separately.
+func (p *Chip) MarshalBinary() ([]byte, error) {
+	s := p.saveState()
+	var b bytes.Buffer
+	if err := binary.Write(&b, binary.LittleEndian, &s); err != nil {
+		return nil, fmt.Errorf("can't encode state: %v", err)
+	}
+	return b.Bytes(), nil
+}
+
+// saveState returns the current state in the layout MarshalBinary encodes.
+func (p *Chip) saveState() chipState {
+	return chipState{
+		Magic:             kSTATE_MAGIC,
+		Version:           kSTATE_VERSION,
+		Cpu:               int32(p.cpuType),
+		A:                 p.A,
+		X:                 p.X,
+		Y:                 p.Y,
+		S:                 p.S,
+		P:                 p.P,
+		PC:                p.PC,
+		Clocks:            int64(p.clocks),
+		TickDone:          p.tickDone,
+		Reset:             p.reset,
+		Op:                p.op,
+		OpPC:              p.opPC,
+		OpVal:             p.opVal,
+		OpTick:            int32(p.opTick),
+		OpAddr:            p.opAddr,
+		OpDone:            p.opDone,
+		AddrDone:          p.addrDone,
+		SkipInterrupt:     p.skipInterrupt,
+		PrevSkipInterrupt: p.prevSkipInterrupt,
+		IrqRaised:         int32(p.irqRaised),
+		RunningInterrupt:  p.runningInterrupt,
+		Halted:            p.halted,
+		HaltOpcode:        p.haltOpcode,
+		Waiting:           p.waiting,
+		ExtraTick:         p.extraTick,
+		PortDDR:           p.portDDR,
+		PortData:          p.portData,
+		PortFloat:         p.portFloat,
+		PortFloatEnd:      [2]int64{int64(p.portFloatEnd[0]), int64(p.portFloatEnd[1])},
+		SOLevel:           p.soLevel,
+		Sync:              p.sync,
+		BreakResume:       p.breakResume,
+	}
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler and restores the CPU state from
+// data previously returned by MarshalBinary. The Chip must have been created with Init
+// for the same CPU type. On error the Chip is left unchanged.
+// Bus accesses held back for classification (see SetBusLog) are always reported by the end of Tick()
+// so there's never one outstanding in a saved state. Any outstanding in the Chip being restored into
+// is dropped.
+func (p *Chip) UnmarshalBinary(data []byte) error {
+	var s chipState
+	r := bytes.NewReader(data)
+	if err := binary.Read(r, binary.LittleEndian, &s); err != nil {
+		return fmt.Errorf("can't decode state: %v", err)
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("can't decode state: %d extra bytes", r.Len())
+	}
+	if s.Magic != kSTATE_MAGIC {
+		return fmt.Errorf("can't decode state: bad magic %q", s.Magic[:])
+	}
+	if s.Version != kSTATE_VERSION {
+		return fmt.Errorf("can't decode state: unsupported version %d (want %d)", s.Version, kSTATE_VERSION)
+	}
+	if got, want := CPUType(s.Cpu), p.cpuType; got != want {
+		return InvalidCPUState{fmt.Sprintf("state is for CPU type %d and can't be restored into type %d", got, want)}
+	}
+	if irq := irqType(s.IrqRaised); irq <= kIRQ_UNIMPLMENTED || irq >= kIRQ_MAX {
+		return InvalidCPUState{fmt.Sprintf("state has invalid irqRaised: %d", irq)}
+	}
+	p.loadState(&s)
+	return nil
+}
+
+// loadState restores the state from s. It must already have been validated.
+func (p *Chip) loadState(s *chipState) {
+	p.A = s.A
+	p.X = s.X
+	p.Y = s.Y
+	p.S = s.S
+	p.P = s.P
+	p.PC = s.PC
+	p.clocks = int(s.Clocks)
+	p.tickDone = s.TickDone
+	p.reset = s.Reset
+	p.op = s.Op
+	p.opPC = s.OpPC
+	p.opVal = s.OpVal
+	p.opTick = int(s.OpTick)
+	p.opAddr = s.OpAddr
+	p.opDone = s.OpDone
+	p.addrDone = s.AddrDone
+	p.skipInterrupt = s.SkipInterrupt
+	p.prevSkipInterrupt = s.PrevSkipInterrupt
+	p.irqRaised = irqType(s.IrqRaised)
+	p.runningInterrupt = s.RunningInterrupt
+	p.halted = s.Halted
+	p.haltOpcode = s.HaltOpcode
+	p.waiting = s.Waiting
+	p.extraTick = s.ExtraTick
+	p.portDDR = s.PortDDR
+	p.portData = s.PortData
+	p.portFloat = s.PortFloat
+	p.portFloatEnd = [2]int{int(s.PortFloatEnd[0]), int(s.PortFloatEnd[1])}
+	p.soLevel = s.SOLevel
+	p.sync = s.Sync
+	p.breakResume = s.BreakResume
+	p.busPending = nil
+}
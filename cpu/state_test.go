@@ -0,0 +1,195 @@
+package cpu
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// tickBoth runs 2 chips in lockstep and verifies their state and RAM never diverge.
+func tickBoth(t *testing.T, a, b *Chip, ra, rb *flatMemory, ticks int) {
+	t.Helper()
+	for i := 0; i < ticks; i++ {
+		errA := a.Tick()
+		a.TickDone()
+		errB := b.Tick()
+		b.TickDone()
+		if (errA == nil) != (errB == nil) {
+			t.Fatalf("%d: errors differ: %v and %v", i, errA, errB)
+		}
+		sa, err := a.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%d: can't marshal: %v", i, err)
+		}
+		sb, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%d: can't marshal: %v", i, err)
+		}
+		if !bytes.Equal(sa, sb) {
+			t.Fatalf("%d: states differ:\n%x\n%x", i, sa, sb)
+		}
+		if ra.addr != rb.addr {
+			t.Fatalf("%d: RAM differs", i)
+		}
+		if errA != nil {
+			return
+		}
+	}
+}
+
+// restore snapshots the given chip and then returns a new chip (and memory) restored from it.
+func restore(t *testing.T, c *Chip, r *flatMemory, def *ChipDef) (*Chip, *flatMemory) {
+	t.Helper()
+	state, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Can't marshal: %v", err)
+	}
+	n, nr := Setup(t.Fatalf, def, r.fillValue, r.haltVector)
+	nr.addr = r.addr
+	if err := n.UnmarshalBinary(state); err != nil {
+		t.Fatalf("Can't unmarshal: %v", err)
+	}
+	return n, nr
+}
+
+func TestStateMidInstruction(t *testing.T) {
+	for _, cpu := range []CPUType{CPU_NMOS, CPU_CMOS} {
+		c, r := Setup(t.Fatalf, &ChipDef{Cpu: cpu}, 0x00, 0x0202)
+		rom, err := ioutil.ReadFile(filepath.Join(testDir, "6502_functional_test.bin"))
+		if err != nil {
+			t.Fatalf("Can't read ROM: %v", err)
+		}
+		for i, b := range rom {
+			r.addr[i] = uint8(b)
+		}
+		c.PC = 0x400
+		// Stop on an odd tick so it's very likely partway through an instruction.
+		for i := 0; i < 100001; i++ {
+			if err := c.Tick(); err != nil {
+				t.Fatalf("%d: Tick error: %v", cpu, err)
+			}
+			c.TickDone()
+		}
+		if c.InstructionDone() {
+			t.Fatalf("%d: snapshot not mid instruction", cpu)
+		}
+		n, nr := restore(t, c, r, &ChipDef{Cpu: cpu})
+		tickBoth(t, c, n, r, nr, 100000)
+	}
+}
+
+func TestStateMidInterrupt(t *testing.T) {
+	var i testIRQ
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS, Irq: &i}, 0xEA, 0x0202)
+	r.addr[kIRQ] = 0x40 // RTI
+	c.P &= ^P_INTERRUPT
+	i.s = true
+	// Run into the middle of the IRQ sequence.
+	for c.irqRaised != kIRQ_IRQ || !c.runningInterrupt || c.opTick < 3 {
+		if err := c.Tick(); err != nil {
+			t.Fatalf("Tick error: %v", err)
+		}
+		c.TickDone()
+	}
+	i.s = false
+	var ni testIRQ
+	n, nr := restore(t, c, r, &ChipDef{Cpu: CPU_NMOS, Irq: &ni})
+	tickBoth(t, c, n, r, nr, 50)
+	if c.PC == kIRQ || n.PC == kIRQ {
+		t.Errorf("Didn't leave IRQ handler: PC 0x%.4X and 0x%.4X", c.PC, n.PC)
+	}
+}
+
+func TestStateMidReset(t *testing.T) {
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS_6510}, 0xEA, 0x0202)
+	if done, err := c.Reset(); done || err != nil {
+		t.Fatalf("Reset didn't start: %t %v", done, err)
+	}
+	n, nr := restore(t, c, r, &ChipDef{Cpu: CPU_NMOS_6510})
+	for {
+		d1, err1 := c.Reset()
+		c.TickDone()
+		d2, err2 := n.Reset()
+		n.TickDone()
+		if d1 != d2 || err1 != nil || err2 != nil {
+			t.Fatalf("Reset differs: %t %v - %t %v", d1, err1, d2, err2)
+		}
+		if d1 {
+			break
+		}
+	}
+	if c.PC != kRESET || n.PC != kRESET {
+		t.Fatalf("Bad PC after reset: 0x%.4X and 0x%.4X", c.PC, n.PC)
+	}
+	tickBoth(t, c, n, r, nr, 20)
+}
+
+func TestStateBreakpoint(t *testing.T) {
+	def := &ChipDef{Cpu: CPU_NMOS}
+	c, r := Setup(t.Fatalf, def, 0xEA, 0x0202)
+	if _, err := c.AddBreakpoint(Breakpoint{Kind: BREAK_EXEC, Addr: kRESET + 1}); err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	if _, err := c.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if _, err := c.Step(); err == nil {
+		t.Fatalf("Didn't hit breakpoint")
+	}
+	// A state saved at the breakpoint continues past it once restored.
+	n, nr := restore(t, c, r, def)
+	if _, err := n.AddBreakpoint(Breakpoint{Kind: BREAK_EXEC, Addr: kRESET + 1}); err != nil {
+		t.Fatalf("Can't add breakpoint: %v", err)
+	}
+	tickBoth(t, c, n, r, nr, 10)
+}
+
+func TestStateErrors(t *testing.T) {
+	c, _ := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
+	good, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Can't marshal: %v", err)
+	}
+	mod := func(f func(b []byte)) []byte {
+		b := append([]byte{}, good...)
+		f(b)
+		return b
+	}
+	// A state with an invalid irqRaised can only come from a bad encoder so fake one.
+	c.irqRaised = kIRQ_MAX
+	badIRQ, err := c.MarshalBinary()
+	if err != nil {
+		t.Fatalf("Can't marshal: %v", err)
+	}
+	tests := []struct {
+		name string
+		cpu  CPUType
+		data []byte
+	}{
+		{"Empty", CPU_NMOS, nil},
+		{"Truncated", CPU_NMOS, good[:len(good)-1]},
+		{"Trailing", CPU_NMOS, append(append([]byte{}, good...), 0x00)},
+		{"Magic", CPU_NMOS, mod(func(b []byte) { b[0] = 'X' })},
+		{"Version", CPU_NMOS, mod(func(b []byte) { b[4] = kSTATE_VERSION + 1 })},
+		{"CPU type", CPU_CMOS, good},
+		{"IRQ", CPU_NMOS, badIRQ},
+	}
+	for _, test := range tests {
+		n, _ := Setup(t.Fatalf, &ChipDef{Cpu: test.cpu}, 0xEA, 0x0202)
+		saved, err := n.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: can't marshal: %v", test.name, err)
+		}
+		if err := n.UnmarshalBinary(test.data); err == nil {
+			t.Errorf("%s: didn't get error", test.name)
+		}
+		now, err := n.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: can't marshal: %v", test.name, err)
+		}
+		if !bytes.Equal(saved, now) {
+			t.Errorf("%s: chip changed on error", test.name)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package cpu
+
+import (
+	"fmt"
+
+	"github.com/jmchacon/6502/disassemble"
+)
+
+// cmosDecimalOpcodes are the CMOS ADC/SBC opcodes which take an extra tick in decimal mode.
+var cmosDecimalOpcodes = map[uint8]bool{
+	0x61: true, 0x65: true, 0x69: true, 0x6D: true, 0x71: true, 0x72: true, 0x75: true, 0x79: true, 0x7D: true, // ADC
+	0xE1: true, 0xE5: true, 0xE9: true, 0xED: true, 0xF1: true, 0xF2: true, 0xF5: true, 0xF9: true, 0xFD: true, // SBC
+}
+
+// opcodeTimings holds the timing of each opcode for each CPUType.
+var opcodeTimings [CPU_MAX][256]disassemble.Timing
+
+// init builds opcodeTimings. This has to happen in init() (instead of a var initializer) as it
+// depends on opcodeTables which is also setup in init() (which runs first as opcodes.go sorts earlier).
+func init() {
+	for cpu := CPU_NMOS; cpu < CPU_MAX; cpu++ {
+		for op := 0; op < 256; op++ {
+			opcodeTimings[cpu][op] = timing(cpu, uint8(op), &opcodeTables[cpu][op])
+		}
+	}
+}
+
+// timing computes the Timing for op on the given CPU type from its descriptor.
+func timing(cpu CPUType, op uint8, o *opcode) disassemble.Timing {
+	t := disassemble.Timing{Cycles: o.cycles}
+	switch {
+	case o.kind == kOP_HALT, cpu == CPU_CMOS && op == 0xDB:
+		// HLT and STP.
+		t.Halt = true
+	case op&0x1F == 0x10:
+		// The conditional branches.
+		t.BranchTaken = 1
+		t.PageCross = 1
+	case cpu == CPU_CMOS && op == 0x80:
+		// BRA is always taken so that's in the base cycles.
+		t.PageCross = 1
+	case cpu == CPU_CMOS && op&0x0F == 0x0F:
+		// BBR/BBS
+		t.BranchTaken = 1
+		t.PageCross = 1
+	case o.kind == kOP_LOAD || o.kind == kOP_ADDR:
+		switch o.mode {
+		case kMODE_ABSOLUTEX, kMODE_ABSOLUTEY, kMODE_INDIRECTY:
+			t.PageCross = 1
+		}
+	case o.mode == kMODE_ABSOLUTEX_SHIFT:
+		t.PageCross = 1
+	}
+	if o.kind == kOP_RMW && cpu != CPU_CMOS {
+		t.DummyWrite = true
+	}
+	if cpu == CPU_CMOS && cmosDecimalOpcodes[op] {
+		t.Decimal = 1
+	}
+	return t
+}
+
+// OpcodeTiming returns the cycle timing of the given opcode on the given CPU type.
+// This is metadata only and matches what Tick() and Step() do when running it.
+func OpcodeTiming(cpu CPUType, op uint8) (disassemble.Timing, error) {
+	if cpu <= CPU_UNIMPLMENTED || cpu >= CPU_MAX {
+		return disassemble.Timing{}, InvalidCPUState{fmt.Sprintf("CPU type %d is invalid", cpu)}
+	}
+	return opcodeTimings[cpu][op], nil
+}
+
+// Timings returns a copy of the cycle timing of every opcode on the given CPU type.
+// This is suitable for passing to disassemble.StepTimed.
+func Timings(cpu CPUType) (*[256]disassemble.Timing, error) {
+	if cpu <= CPU_UNIMPLMENTED || cpu >= CPU_MAX {
+		return nil, InvalidCPUState{fmt.Sprintf("CPU type %d is invalid", cpu)}
+	}
+	ret := opcodeTimings[cpu]
+	return &ret, nil
+}
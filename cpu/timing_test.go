@@ -0,0 +1,102 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/jmchacon/6502/disassemble"
+)
+
+func TestOpcodeTiming(t *testing.T) {
+	tests := []struct {
+		cpu  CPUType
+		op   uint8
+		want disassemble.Timing
+		str  string
+	}{
+		{CPU_NMOS, 0xA9, disassemble.Timing{Cycles: 2}, "2"},                                   // LDA #i
+		{CPU_NMOS, 0xBD, disassemble.Timing{Cycles: 4, PageCross: 1}, "4+p"},                   // LDA a,x
+		{CPU_NMOS, 0xB1, disassemble.Timing{Cycles: 5, PageCross: 1}, "5+p"},                   // LDA (d),y
+		{CPU_NMOS, 0x9D, disassemble.Timing{Cycles: 5}, "5"},                                   // STA a,x
+		{CPU_NMOS, 0xFE, disassemble.Timing{Cycles: 7, DummyWrite: true}, "7"},                 // INC a,x
+		{CPU_NMOS, 0xD0, disassemble.Timing{Cycles: 2, BranchTaken: 1, PageCross: 1}, "2+t+p"}, // BNE *+r
+		{CPU_NMOS, 0x1C, disassemble.Timing{Cycles: 4, PageCross: 1}, "4+p"},                   // NOP a,x
+		{CPU_NMOS, 0x69, disassemble.Timing{Cycles: 2}, "2"},                                   // ADC #i
+		{CPU_NMOS, 0x02, disassemble.Timing{Halt: true}, "HLT"},                                // HLT
+		{CPU_NMOS_REV_A, 0x6E, disassemble.Timing{Cycles: 6, DummyWrite: true}, "6"},           // ROR a
+		{CPU_NMOS_6507, 0x06, disassemble.Timing{Cycles: 5, DummyWrite: true}, "5"},            // ASL d
+		{CPU_CMOS, 0xFE, disassemble.Timing{Cycles: 7}, "7"},                                   // INC a,x
+		{CPU_CMOS, 0x1E, disassemble.Timing{Cycles: 6, PageCross: 1}, "6+p"},                   // ASL a,x
+		{CPU_CMOS, 0x69, disassemble.Timing{Cycles: 2, Decimal: 1}, "2+d"},                     // ADC #i
+		{CPU_CMOS, 0xF2, disassemble.Timing{Cycles: 5, Decimal: 1}, "5+d"},                     // SBC (d)
+		{CPU_CMOS, 0x80, disassemble.Timing{Cycles: 3, PageCross: 1}, "3+p"},                   // BRA *+r
+		{CPU_CMOS, 0x8F, disassemble.Timing{Cycles: 5, BranchTaken: 1, PageCross: 1}, "5+t+p"}, // BBS0 d,*+r
+		{CPU_CMOS, 0x03, disassemble.Timing{Cycles: 1}, "1"},                                   // NOP
+		{CPU_CMOS, 0xDB, disassemble.Timing{Halt: true}, "HLT"},                                // STP
+	}
+	for _, test := range tests {
+		got, err := OpcodeTiming(test.cpu, test.op)
+		if err != nil {
+			t.Errorf("CPU %d opcode %.2X: error %v", test.cpu, test.op, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("CPU %d opcode %.2X: got %+v and want %+v", test.cpu, test.op, got, test.want)
+		}
+		if got.String() != test.str {
+			t.Errorf("CPU %d opcode %.2X: got string %q and want %q", test.cpu, test.op, got, test.str)
+		}
+	}
+	if _, err := OpcodeTiming(CPU_MAX, 0xEA); err == nil {
+		t.Errorf("Didn't get error for an invalid CPU type")
+	}
+}
+
+// TestTimingsMatchExecution runs the load and branch opcodes with and without page crossings
+// and checks the cycles used match the metadata.
+func TestTimingsMatchExecution(t *testing.T) {
+	for _, cpu := range []CPUType{CPU_NMOS, CPU_CMOS} {
+		timings, err := Timings(cpu)
+		if err != nil {
+			t.Fatalf("Can't get timings: %v", err)
+		}
+		tests := []struct {
+			name  string
+			prog  []uint8
+			cross bool
+			taken bool
+		}{
+			{"LDA a,x", []uint8{0xBD, 0x00, 0x20}, false, false},
+			{"LDA a,x cross", []uint8{0xBD, 0xFF, 0x20}, true, false},
+			{"LDA (d),y cross", []uint8{0xB1, 0x40}, true, false},
+			{"BCC not taken", []uint8{0x90, 0x10}, false, false},
+			{"BCS taken", []uint8{0xB0, 0x10}, false, true},
+			{"BCS taken cross", []uint8{0xB0, 0x80}, true, true},
+			{"ROL a,x", []uint8{0x3E, 0x00, 0x20}, false, false},
+			{"ROL a,x cross", []uint8{0x3E, 0xFF, 0x20}, true, false},
+		}
+		for _, test := range tests {
+			c, r := Setup(t.Fatalf, &ChipDef{Cpu: cpu}, 0xEA, 0x0202)
+			copy(r.addr[0x1000:], test.prog)
+			r.addr[0x40], r.addr[0x41] = 0xFF, 0x20
+			c.PC = 0x1000
+			c.X, c.Y = 1, 1
+			c.P |= P_CARRY
+			c.P &^= P_DECIMAL
+			info, err := c.Step()
+			if err != nil {
+				t.Fatalf("%d %s: Step failed: %v", cpu, test.name, err)
+			}
+			tm := timings[test.prog[0]]
+			want := tm.Cycles
+			if test.taken {
+				want += tm.BranchTaken
+			}
+			if test.cross {
+				want += tm.PageCross
+			}
+			if info.Cycles != want {
+				t.Errorf("%d %s: ran %d cycles and timing %s gives %d", cpu, test.name, info.Cycles, tm, want)
+			}
+		}
+	}
+}
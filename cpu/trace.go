@@ -0,0 +1,458 @@
+package cpu
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jmchacon/6502/disassemble"
+	"github.com/jmchacon/6502/memory"
+)
+
+// TraceFormat is an enumeration of the supported per instruction trace formats.
+type TraceFormat int
+
+const (
+	TRACE_UNIMPLEMENTED TraceFormat = iota // Start of valid trace enumerations.
+	TRACE_NESTEST                          // nestest.log format where CYC is the PPU dot (CPU cycles * 3 mod 341).
+	TRACE_NINTENDULATOR                    // Same as TRACE_NESTEST except CYC is the CPU cycle count.
+	TRACE_MESEN                            // Mesen style output controlled by a template.
+	TRACE_MAX                              // End of trace enumerations.
+)
+
+// kMESEN_DEFAULT is the template used for TRACE_MESEN if one isn't given.
+const kMESEN_DEFAULT = "[PC]  [ByteCode,9] [Disassembly][EffectiveAddress][MemoryValue][Align,48] A:[A] X:[X] Y:[Y] P:[P] SP:[SP] CYC:[CycleCount]"
+
+// TraceDef defines the trace output for a Chip.
+type TraceDef struct {
+	// Format is the output format for each line.
+	Format TraceFormat
+	// Output is where each line is written.
+	Output io.Writer
+	// Template is only used for TRACE_MESEN and controls each line. Tags are of the form [Name] or [Name,N]
+	// where N pads the value (left justified) to N characters. Anything not in a tag is copied as is.
+	// Valid tags:
+	//   PC               - PC of the instruction (4 hex digits).
+	//   ByteCode         - Instruction bytes in hex separated by spaces.
+	//   Disassembly      - Mnemonic and operand (i.e. LDA $1234,X).
+	//   EffectiveAddress - " @ $XXXX" for modes where the final address isn't in the operand, otherwise empty.
+	//   MemoryValue      - " = $XX" for the value at the final address if there is one, otherwise empty.
+	//   A, X, Y, P, SP   - Register values (2 hex digits).
+	//   Flags            - P as NV-BDIZC with lower case for clear bits.
+	//   CycleCount       - CPU cycles since tracing started at the start of the instruction.
+	//   Align            - Pads the line with spaces to column N.
+	// If empty a default template close to TRACE_NESTEST is used.
+	Template string
+}
+
+// traceTag is a parsed piece of a TRACE_MESEN template. If name is empty lit is output as is.
+type traceTag struct {
+	name  string
+	width int
+	lit   string
+}
+
+// validTraceTags are all the tags allowed in a TRACE_MESEN template.
+var validTraceTags = map[string]bool{
+	"PC":               true,
+	"ByteCode":         true,
+	"Disassembly":      true,
+	"EffectiveAddress": true,
+	"MemoryValue":      true,
+	"A":                true,
+	"X":                true,
+	"Y":                true,
+	"P":                true,
+	"SP":               true,
+	"Flags":            true,
+	"CycleCount":       true,
+	"Align":            true,
+}
+
+// tracer holds the trace setup for a Chip.
+type tracer struct {
+	format TraceFormat
+	out    io.Writer
+	tags   []traceTag
+	base   int // Clock count when tracing started.
+}
+
+// SetTrace starts writing a line for each instruction executed (interrupt sequences aren't traced)
+// as defined by def. Passing nil turns tracing off.
+// Lines are written at the start of each instruction showing the state before it runs.
+// Cycle counts in the output are relative to when SetTrace was called.
+// Computing the effective address and value uses memory.Peek so only banks which don't implement
+// memory.Debug see extra reads.
+// Any error writing a line is returned from Tick() but otherwise execution continues normally.
+func (p *Chip) SetTrace(def *TraceDef) error {
+	if def == nil {
+		p.trace = nil
+		return nil
+	}
+	if def.Format <= TRACE_UNIMPLEMENTED || def.Format >= TRACE_MAX {
+		return InvalidCPUState{fmt.Sprintf("trace format %d is invalid", def.Format)}
+	}
+	if def.Output == nil {
+		return InvalidCPUState{"trace output must be non-nil"}
+	}
+	t := &tracer{
+		format: def.Format,
+		out:    def.Output,
+		base:   p.clocks,
+	}
+	if def.Format == TRACE_MESEN {
+		tmpl := def.Template
+		if tmpl == "" {
+			tmpl = kMESEN_DEFAULT
+		}
+		var err error
+		if t.tags, err = parseTraceTemplate(tmpl); err != nil {
+			return InvalidCPUState{fmt.Sprintf("invalid trace template: %v", err)}
+		}
+	}
+	p.trace = t
+	return nil
+}
+
+// parseTraceTemplate breaks a TRACE_MESEN template into tags and literals.
+func parseTraceTemplate(tmpl string) ([]traceTag, error) {
+	var tags []traceTag
+	for len(tmpl) > 0 {
+		i := strings.IndexByte(tmpl, '[')
+		if i == -1 {
+			tags = append(tags, traceTag{lit: tmpl})
+			break
+		}
+		if i > 0 {
+			tags = append(tags, traceTag{lit: tmpl[:i]})
+		}
+		tmpl = tmpl[i+1:]
+		j := strings.IndexByte(tmpl, ']')
+		if j == -1 {
+			return nil, fmt.Errorf("unterminated tag [%s", tmpl)
+		}
+		tag := traceTag{name: tmpl[:j]}
+		tmpl = tmpl[j+1:]
+		if k := strings.IndexByte(tag.name, ','); k != -1 {
+			w, err := strconv.Atoi(tag.name[k+1:])
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid width in tag [%s]", tag.name)
+			}
+			tag.name, tag.width = tag.name[:k], w
+		}
+		if !validTraceTags[tag.name] {
+			return nil, fmt.Errorf("unknown tag [%s]", tag.name)
+		}
+		if tag.name == "Align" && tag.width == 0 {
+			return nil, fmt.Errorf("tag [Align] requires a width")
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// traceInfo is everything about an instruction needed to format a trace line.
+type traceInfo struct {
+	pc      uint16
+	bytes   []uint8
+	op      string
+	undoc   bool
+	mode    disassemble.Mode
+	operand uint16 // Operand from the instruction (8 or 16 bits depending on mode).
+	ptr     uint16 // Indexed zero page address for MODE_ZPX/ZPY/INDIRECTX and pointer for MODE_INDIRECTY/INDIRECT.
+	addr    uint16 // Final address (or the jump/branch target).
+	val     uint8  // Value at addr.
+	hasVal  bool   // Whether val is meaningful.
+}
+
+// traceRead returns the value at addr for tracing. This avoids any CPU side effects (such as watchpoints)
+// and uses memory.Peek so banks which support it aren't changed either.
+func (p *Chip) traceRead(addr uint16) uint8 {
+	addr &= p.addrMask
+	if p.cpuType == CPU_NMOS_6510 && addr <= kPORT_DATA {
+		return p.portRead(addr)
+	}
+	return memory.Peek(p.ram, addr)
+}
+
+// traceRead16 returns the little endian value at addr. If zp is true the high byte wraps in zero page.
+func (p *Chip) traceRead16(addr uint16, zp bool) uint16 {
+	hi := addr + 1
+	if zp {
+		hi = uint16(uint8(hi))
+	}
+	return uint16(p.traceRead(addr)) | uint16(p.traceRead(hi))<<8
+}
+
+// traceDecode computes the traceInfo for the instruction at pc.
+func (p *Chip) traceDecode(pc uint16) traceInfo {
+	o := p.traceRead(pc)
+	op, mode := disassemble.Decode(o)
+	undoc := disassemble.Undocumented(o)
+	if p.cpuType == CPU_CMOS {
+		op, mode = disassemble.DecodeCMOS(o)
+		undoc = disassemble.UndocumentedCMOS(o)
+	}
+	t := traceInfo{
+		pc:    pc,
+		op:    op,
+		undoc: undoc,
+		mode:  mode,
+	}
+	// BRK is listed as immediate since it skips a byte but shows as implied.
+	if o == 0x00 {
+		t.mode = disassemble.MODE_IMPLIED
+	}
+	for i := 0; i < t.mode.Bytes(); i++ {
+		t.bytes = append(t.bytes, p.traceRead(pc+uint16(i)))
+	}
+	if len(t.bytes) > 1 {
+		t.operand = uint16(t.bytes[1])
+	}
+	if len(t.bytes) > 2 {
+		t.operand |= uint16(t.bytes[2]) << 8
+	}
+	t.hasVal = true
+	switch t.mode {
+	case disassemble.MODE_IMMEDIATE, disassemble.MODE_IMPLIED:
+		t.hasVal = false
+	case disassemble.MODE_ZP, disassemble.MODE_ABSOLUTE:
+		t.addr = t.operand
+		if op == "JMP" || op == "JSR" {
+			t.hasVal = false
+		}
+	case disassemble.MODE_ZPX:
+		t.ptr = uint16(uint8(t.operand) + p.X)
+		t.addr = t.ptr
+	case disassemble.MODE_ZPY:
+		t.ptr = uint16(uint8(t.operand) + p.Y)
+		t.addr = t.ptr
+	case disassemble.MODE_INDIRECTX:
+		t.ptr = uint16(uint8(t.operand) + p.X)
+		t.addr = p.traceRead16(t.ptr, true)
+	case disassemble.MODE_INDIRECTY:
+		t.ptr = p.traceRead16(t.operand, true)
+		t.addr = t.ptr + uint16(p.Y)
+	case disassemble.MODE_ABSOLUTEX:
+		t.addr = t.operand + uint16(p.X)
+	case disassemble.MODE_ABSOLUTEY:
+		t.addr = t.operand + uint16(p.Y)
+	case disassemble.MODE_INDIRECT:
+		// NMOS doesn't carry into the high byte when the pointer is at the end of a page.
+		lo := p.traceRead(t.operand)
+		hiAddr := (t.operand & 0xFF00) | uint16(uint8(t.operand+1))
+		if p.cpuType == CPU_CMOS {
+			hiAddr = t.operand + 1
+		}
+		t.addr = uint16(lo) | uint16(p.traceRead(hiAddr))<<8
+		t.hasVal = false
+	case disassemble.MODE_RELATIVE:
+		t.addr = pc + 2 + uint16(int16(int8(t.operand)))
+		t.hasVal = false
+	case disassemble.MODE_INDIRECTZP:
+		t.ptr = uint16(uint8(t.operand))
+		t.addr = p.traceRead16(t.ptr, true)
+	case disassemble.MODE_INDIRECTABSX:
+		t.ptr = t.operand + uint16(p.X)
+		t.addr = p.traceRead16(t.ptr, false)
+		t.hasVal = false
+	case disassemble.MODE_ZPRELATIVE:
+		// The value tested is in zero page and addr is the branch target.
+		t.ptr = uint16(t.bytes[1])
+		t.addr = pc + 3 + uint16(int16(int8(t.bytes[2])))
+		t.val = p.traceRead(t.ptr)
+		t.hasVal = false
+	}
+	if t.hasVal {
+		t.val = p.traceRead(t.addr)
+	}
+	return t
+}
+
+// accumulator returns true if the instruction is an accumulator form (i.e. ASL A).
+func (t *traceInfo) accumulator() bool {
+	if t.mode != disassemble.MODE_IMPLIED {
+		return false
+	}
+	switch t.op {
+	case "ASL", "LSR", "ROL", "ROR", "INC", "DEC":
+		return true
+	}
+	return false
+}
+
+// byteCode returns the instruction bytes as hex separated by spaces.
+func (t *traceInfo) byteCode() string {
+	var b []string
+	for _, v := range t.bytes {
+		b = append(b, fmt.Sprintf("%.2X", v))
+	}
+	return strings.Join(b, " ")
+}
+
+// nestest returns the disassembly in nestest.log format (including effective address and value).
+func (t *traceInfo) nestest() string {
+	op := t.op
+	if op == "ISC" {
+		op = "ISB"
+	}
+	switch t.mode {
+	case disassemble.MODE_IMMEDIATE:
+		return fmt.Sprintf("%s #$%.2X", op, t.operand)
+	case disassemble.MODE_ZP:
+		return fmt.Sprintf("%s $%.2X = %.2X", op, t.operand, t.val)
+	case disassemble.MODE_ZPX:
+		return fmt.Sprintf("%s $%.2X,X @ %.2X = %.2X", op, t.operand, t.ptr, t.val)
+	case disassemble.MODE_ZPY:
+		return fmt.Sprintf("%s $%.2X,Y @ %.2X = %.2X", op, t.operand, t.ptr, t.val)
+	case disassemble.MODE_INDIRECTX:
+		return fmt.Sprintf("%s ($%.2X,X) @ %.2X = %.4X = %.2X", op, t.operand, t.ptr, t.addr, t.val)
+	case disassemble.MODE_INDIRECTY:
+		return fmt.Sprintf("%s ($%.2X),Y = %.4X @ %.4X = %.2X", op, t.operand, t.ptr, t.addr, t.val)
+	case disassemble.MODE_ABSOLUTE:
+		if !t.hasVal {
+			return fmt.Sprintf("%s $%.4X", op, t.operand)
+		}
+		return fmt.Sprintf("%s $%.4X = %.2X", op, t.operand, t.val)
+	case disassemble.MODE_ABSOLUTEX:
+		return fmt.Sprintf("%s $%.4X,X @ %.4X = %.2X", op, t.operand, t.addr, t.val)
+	case disassemble.MODE_ABSOLUTEY:
+		return fmt.Sprintf("%s $%.4X,Y @ %.4X = %.2X", op, t.operand, t.addr, t.val)
+	case disassemble.MODE_INDIRECT:
+		return fmt.Sprintf("%s ($%.4X) = %.4X", op, t.operand, t.addr)
+	case disassemble.MODE_RELATIVE:
+		return fmt.Sprintf("%s $%.4X", op, t.addr)
+	case disassemble.MODE_INDIRECTZP:
+		return fmt.Sprintf("%s ($%.2X) = %.4X = %.2X", op, t.operand&0xFF, t.addr, t.val)
+	case disassemble.MODE_INDIRECTABSX:
+		return fmt.Sprintf("%s ($%.4X,X) = %.4X", op, t.operand, t.addr)
+	case disassemble.MODE_ZPRELATIVE:
+		return fmt.Sprintf("%s $%.2X = %.2X,$%.4X", op, t.ptr, t.val, t.addr)
+	}
+	if t.accumulator() {
+		return op + " A"
+	}
+	return op
+}
+
+// disassembly returns the mnemonic and operand only (Mesen style).
+func (t *traceInfo) disassembly() string {
+	switch t.mode {
+	case disassemble.MODE_IMMEDIATE:
+		return fmt.Sprintf("%s #$%.2X", t.op, t.operand)
+	case disassemble.MODE_ZP:
+		return fmt.Sprintf("%s $%.2X", t.op, t.operand)
+	case disassemble.MODE_ZPX:
+		return fmt.Sprintf("%s $%.2X,X", t.op, t.operand)
+	case disassemble.MODE_ZPY:
+		return fmt.Sprintf("%s $%.2X,Y", t.op, t.operand)
+	case disassemble.MODE_INDIRECTX:
+		return fmt.Sprintf("%s ($%.2X,X)", t.op, t.operand)
+	case disassemble.MODE_INDIRECTY:
+		return fmt.Sprintf("%s ($%.2X),Y", t.op, t.operand)
+	case disassemble.MODE_ABSOLUTE:
+		return fmt.Sprintf("%s $%.4X", t.op, t.operand)
+	case disassemble.MODE_ABSOLUTEX:
+		return fmt.Sprintf("%s $%.4X,X", t.op, t.operand)
+	case disassemble.MODE_ABSOLUTEY:
+		return fmt.Sprintf("%s $%.4X,Y", t.op, t.operand)
+	case disassemble.MODE_INDIRECT:
+		return fmt.Sprintf("%s ($%.4X)", t.op, t.operand)
+	case disassemble.MODE_RELATIVE:
+		return fmt.Sprintf("%s $%.4X", t.op, t.addr)
+	case disassemble.MODE_INDIRECTZP:
+		return fmt.Sprintf("%s ($%.2X)", t.op, t.operand&0xFF)
+	case disassemble.MODE_INDIRECTABSX:
+		return fmt.Sprintf("%s ($%.4X,X)", t.op, t.operand)
+	case disassemble.MODE_ZPRELATIVE:
+		return fmt.Sprintf("%s $%.2X,$%.4X", t.op, t.ptr, t.addr)
+	}
+	if t.accumulator() {
+		return t.op + " A"
+	}
+	return t.op
+}
+
+// effectiveAddress returns the Mesen style effective address (if one applies).
+func (t *traceInfo) effectiveAddress() string {
+	switch t.mode {
+	case disassemble.MODE_ZPX, disassemble.MODE_ZPY, disassemble.MODE_INDIRECTX, disassemble.MODE_INDIRECTY, disassemble.MODE_ABSOLUTEX, disassemble.MODE_ABSOLUTEY, disassemble.MODE_INDIRECT, disassemble.MODE_INDIRECTZP, disassemble.MODE_INDIRECTABSX:
+		return fmt.Sprintf(" @ $%.4X", t.addr)
+	}
+	return ""
+}
+
+// traceInstruction writes the trace line for the instruction starting at p.opPC.
+// This must be called on the first tick of an instruction before anything other than the opcode fetch happens.
+func (p *Chip) traceInstruction() error {
+	t := p.traceDecode(p.opPC)
+	// The current tick has already been counted.
+	cycles := p.clocks - 1 - p.trace.base
+	var line string
+	switch p.trace.format {
+	case TRACE_NESTEST, TRACE_NINTENDULATOR:
+		mark := ' '
+		if t.undoc {
+			mark = '*'
+		}
+		cyc := fmt.Sprintf("%3d", (cycles*3)%341)
+		if p.trace.format == TRACE_NINTENDULATOR {
+			cyc = strconv.Itoa(cycles)
+		}
+		line = fmt.Sprintf("%.4X  %-8s %c%-31s A:%.2X X:%.2X Y:%.2X P:%.2X SP:%.2X CYC:%s\n", t.pc, t.byteCode(), mark, t.nestest(), p.A, p.X, p.Y, p.P, p.S, cyc)
+	case TRACE_MESEN:
+		var b strings.Builder
+		for _, tag := range p.trace.tags {
+			var v string
+			switch tag.name {
+			case "":
+				b.WriteString(tag.lit)
+				continue
+			case "Align":
+				if n := tag.width - b.Len(); n > 0 {
+					b.WriteString(strings.Repeat(" ", n))
+				}
+				continue
+			case "PC":
+				v = fmt.Sprintf("%.4X", t.pc)
+			case "ByteCode":
+				v = t.byteCode()
+			case "Disassembly":
+				v = t.disassembly()
+			case "EffectiveAddress":
+				v = t.effectiveAddress()
+			case "MemoryValue":
+				if t.hasVal {
+					v = fmt.Sprintf(" = $%.2X", t.val)
+				}
+			case "A":
+				v = fmt.Sprintf("%.2X", p.A)
+			case "X":
+				v = fmt.Sprintf("%.2X", p.X)
+			case "Y":
+				v = fmt.Sprintf("%.2X", p.Y)
+			case "P":
+				v = fmt.Sprintf("%.2X", p.P)
+			case "SP":
+				v = fmt.Sprintf("%.2X", p.S)
+			case "Flags":
+				const names = "NV-BDIZC"
+				for i := 0; i < 8; i++ {
+					c := names[i]
+					if p.P&(0x80>>uint(i)) == 0 && c != '-' {
+						c += 'a' - 'A'
+					}
+					v += string(c)
+				}
+			case "CycleCount":
+				v = strconv.Itoa(cycles)
+			}
+			b.WriteString(fmt.Sprintf("%-*s", tag.width, v))
+		}
+		b.WriteString("\n")
+		line = b.String()
+	}
+	_, err := io.WriteString(p.trace.out, line)
+	return err
+}
@@ -0,0 +1,201 @@
+package cpu
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTraceNestest(t *testing.T) {
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS_RICOH}, 0x00, 0x0202)
+	rom, err := ioutil.ReadFile(filepath.Join(testDir, "nestest.nes"))
+	if err != nil {
+		t.Fatalf("Can't read ROM: %v", err)
+	}
+	// Map the first PRG ROM into place past the 16 byte header. A single bank is mirrored
+	// at 0x8000 as well and the log includes reads from there.
+	for i := 0; i < 16*1024; i++ {
+		r.addr[0x8000+i] = rom[16+i]
+		r.addr[0xC000+i] = rom[16+i]
+	}
+	// The APU/IO registers read back as 0xFF in the log.
+	for i := 0x4000; i < 0x4020; i++ {
+		r.addr[i] = 0xFF
+	}
+	f, err := os.Open(filepath.Join(testDir, "nestest.log"))
+	if err != nil {
+		t.Fatalf("Can't open log: %v", err)
+	}
+	defer f.Close()
+	var want []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		want = append(want, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Can't read log: %v", err)
+	}
+
+	c.A, c.X, c.Y, c.S = 0x00, 0x00, 0x00, 0xFD
+	c.PC = 0xC000
+	var b bytes.Buffer
+	if err := c.SetTrace(&TraceDef{Format: TRACE_NESTEST, Output: &b}); err != nil {
+		t.Fatalf("Can't set trace: %v", err)
+	}
+	for i := range want {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step %d failed: %v", i, err)
+		}
+	}
+	got := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(got) != len(want) {
+		t.Errorf("Wrong number of lines. Got %d and want %d", len(got), len(want))
+	}
+	for i := range want {
+		if i >= len(got) {
+			break
+		}
+		if got[i] != want[i] {
+			t.Fatalf("Trace mismatch at line %d\nGot  %q\nWant %q", i+1, got[i], want[i])
+		}
+	}
+
+	// Turning it off stops output.
+	if err := c.SetTrace(nil); err != nil {
+		t.Fatalf("Can't disable trace: %v", err)
+	}
+	l := b.Len()
+	if _, err := c.Step(); err != nil {
+		t.Fatalf("Step failed: %v", err)
+	}
+	if b.Len() != l {
+		t.Errorf("Trace output after disabling: %q", b.String()[l:])
+	}
+}
+
+func TestTraceFormats(t *testing.T) {
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS}, 0xEA, 0x0202)
+	r.addr[0x1000] = 0xA2 // LDX #01
+	r.addr[0x1001] = 0x01
+	r.addr[0x1002] = 0xBD // LDA 12FF,X
+	r.addr[0x1003] = 0xFF
+	r.addr[0x1004] = 0x12
+	r.addr[0x1005] = 0x4A // LSR A
+	r.addr[0x1300] = 0x56
+	c.PC = 0x1000
+	c.P = 0x24
+	c.A, c.X, c.Y, c.S = 0x00, 0x00, 0x00, 0xFD
+
+	var b bytes.Buffer
+	if err := c.SetTrace(&TraceDef{Format: TRACE_MESEN, Output: &b, Template: "[PC] [ByteCode,8]|[Disassembly][EffectiveAddress][MemoryValue][Align,40][Flags] A:[A] CYC:[CycleCount]"}); err != nil {
+		t.Fatalf("Can't set trace: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	want := "1000 A2 01   |LDX #$01                  nv-bdIzc A:00 CYC:0\n" +
+		"1002 BD FF 12|LDA $12FF,X @ $1300 = $56 nv-bdIzc A:00 CYC:2\n" +
+		"1005 4A      |LSR A                     nv-bdIzc A:56 CYC:7\n"
+	if got := b.String(); got != want {
+		t.Errorf("Bad Mesen trace.\nGot\n%s\nWant\n%s", got, want)
+	}
+
+	b.Reset()
+	c.PC = 0x1002
+	if err := c.SetTrace(&TraceDef{Format: TRACE_NINTENDULATOR, Output: &b}); err != nil {
+		t.Fatalf("Can't set trace: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	want = "1002  BD FF 12  LDA $12FF,X @ 1300 = 56         A:2B X:01 Y:00 P:24 SP:FD CYC:0\n" +
+		"1005  4A        LSR A                           A:56 X:01 Y:00 P:24 SP:FD CYC:5\n"
+	if got := b.String(); got != want {
+		t.Errorf("Bad Nintendulator trace.\nGot\n%s\nWant\n%s", got, want)
+	}
+
+	for _, def := range []*TraceDef{
+		{Format: TRACE_UNIMPLEMENTED, Output: &b},
+		{Format: TRACE_MAX, Output: &b},
+		{Format: TRACE_NESTEST},
+		{Format: TRACE_MESEN, Output: &b, Template: "[PC"},
+		{Format: TRACE_MESEN, Output: &b, Template: "[Foo]"},
+		{Format: TRACE_MESEN, Output: &b, Template: "[A,x]"},
+		{Format: TRACE_MESEN, Output: &b, Template: "[Align]"},
+	} {
+		if err := c.SetTrace(def); err == nil {
+			t.Errorf("Didn't get error for %+v", def)
+		}
+	}
+}
+
+func TestTraceCMOS(t *testing.T) {
+	c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_CMOS}, 0xEA, 0x0202)
+	for i, b := range []uint8{
+		0x80, 0x02, // BRA +2
+		0xEA, 0xEA, // NOP NOP (skipped)
+		0x9C, 0x00, 0x20, // STZ 2000
+		0x12, 0x10, // ORA (10)
+		0x1A,             // INC A
+		0x7C, 0x00, 0x11, // JMP (1100,X)
+	} {
+		r.addr[0x1000+i] = b
+	}
+	r.addr[0x0010] = 0x00
+	r.addr[0x0011] = 0x30
+	r.addr[0x3000] = 0x5A
+	r.addr[0x1102] = 0x00
+	r.addr[0x1103] = 0x14
+	r.addr[0x1400] = 0x0F // BBR0 20,+4
+	r.addr[0x1401] = 0x20
+	r.addr[0x1402] = 0x04
+	r.addr[0x1403] = 0x03 // Reserved 1 byte NOP
+	r.addr[0x0020] = 0xFE
+	r.addr[0x2000] = 0xFF
+	c.PC = 0x1000
+	c.P = 0x24
+	c.A, c.X, c.Y, c.S = 0x00, 0x02, 0x00, 0xFD
+
+	var b bytes.Buffer
+	if err := c.SetTrace(&TraceDef{Format: TRACE_MESEN, Output: &b, Template: "[PC] [ByteCode,8]|[Disassembly][EffectiveAddress][MemoryValue]"}); err != nil {
+		t.Fatalf("Can't set trace: %v", err)
+	}
+	for i := 0; i < 6; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	want := "1000 80 02   |BRA $1004\n" +
+		"1004 9C 00 20|STZ $2000 = $FF\n" +
+		"1007 12 10   |ORA ($10) @ $3000 = $5A\n" +
+		"1009 1A      |INC A\n" +
+		"100A 7C 00 11|JMP ($1100,X) @ $1400\n" +
+		"1400 0F 20 04|BBR0 $20,$1407\n"
+	if got := b.String(); got != want {
+		t.Errorf("Bad Mesen trace.\nGot\n%s\nWant\n%s", got, want)
+	}
+
+	b.Reset()
+	if err := c.SetTrace(&TraceDef{Format: TRACE_NESTEST, Output: &b}); err != nil {
+		t.Fatalf("Can't set trace: %v", err)
+	}
+	c.PC = 0x1400
+	for i := 0; i < 2; i++ {
+		if _, err := c.Step(); err != nil {
+			t.Fatalf("Step failed: %v", err)
+		}
+	}
+	want = "1400  0F 20 04  BBR0 $20 = FE,$1407             A:5B X:02 Y:00 P:24 SP:FD CYC:  0\n" +
+		"1407  EA        NOP                             A:5B X:02 Y:00 P:24 SP:FD CYC: 18\n"
+	if got := b.String(); got != want {
+		t.Errorf("Bad nestest trace.\nGot\n%s\nWant\n%s", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package cpu
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRORRevA(t *testing.T) {
+	for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+		for _, carry := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s carry %t", core, carry), func(t *testing.T) {
+				c, r := Setup(t.Fatalf, &ChipDef{Cpu: CPU_NMOS_REV_A, Core: core}, 0xEA, 0x0202)
+				// ROR, ROR 10, ROR 1000,X
+				copy(r.addr[0x1000:], []uint8{0x6A, 0x66, 0x10, 0x7E, 0x00, 0x10})
+				r.addr[0x0010] = 0x40
+				c.PC = 0x1000
+				c.A = 0x81
+				c.X = 0x01
+				c.P &^= P_CARRY
+				if carry {
+					c.P |= P_CARRY
+				}
+				tests := []struct {
+					addr uint16 // 0 for A.
+					want uint8
+					n, z bool
+				}{
+					{0, 0x02, false, false},
+					{0x0010, 0x80, true, false},
+					{0x1001, 0xCC, true, false},
+				}
+				for _, test := range tests {
+					if _, err := c.Step(); err != nil {
+						t.Fatalf("Step failed: %v", err)
+					}
+					got := c.A
+					if test.addr != 0 {
+						got = r.addr[test.addr]
+					}
+					if got != test.want {
+						t.Errorf("Bad ROR result at %.4X. Got %.2X and want %.2X", test.addr, got, test.want)
+					}
+					if n, z := c.P&P_NEGATIVE != 0, c.P&P_ZERO != 0; n != test.n || z != test.z {
+						t.Errorf("Bad flags at %.4X. Got N: %t Z: %t and want N: %t Z: %t", test.addr, n, z, test.n, test.z)
+					}
+					if got := c.P&P_CARRY != 0; got != carry {
+						t.Errorf("Carry changed at %.4X. Got %t and want %t", test.addr, got, carry)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestAddressMask(t *testing.T) {
+	for _, cpu := range []CPUType{CPU_NMOS_6507, CPU_NMOS_6504} {
+		for _, core := range []Core{CORE_ACCURATE, CORE_FAST} {
+			t.Run(fmt.Sprintf("%d %s", cpu, core), func(t *testing.T) {
+				c, r := Setup(t.Fatalf, &ChipDef{Cpu: cpu, Core: core}, 0xEA, 0x0202)
+				// LDA FFFC, STA 3000
+				copy(r.addr[0x1000:], []uint8{0xAD, 0xFC, 0xFF, 0x8D, 0x00, 0x30})
+				r.addr[0x1FFC] = 0x5A
+				r.addr[0xFFFC] = 0xA5
+				c.PC = 0x1000
+				for i := 0; i < 2; i++ {
+					if _, err := c.Step(); err != nil {
+						t.Fatalf("Step failed: %v", err)
+					}
+				}
+				if got, want := c.A, uint8(0x5A); got != want {
+					t.Errorf("Read wasn't masked. Got %.2X and want %.2X", got, want)
+				}
+				if got, want := r.addr[0x1000], uint8(0x5A); got != want {
+					t.Errorf("Write wasn't masked. Got %.2X at 1000 and want %.2X", got, want)
+				}
+				if got := r.addr[0x3000]; got == 0x5A {
+					t.Errorf("Write went to 3000")
+				}
+			})
+		}
+	}
+}
+
+func TestPinVariants(t *testing.T) {
+	tests := []struct {
+		name string
+		def  *ChipDef
+	}{
+		{"6507 IRQ", &ChipDef{Cpu: CPU_NMOS_6507, Irq: &testIRQ{}}},
+		{"6507 NMI", &ChipDef{Cpu: CPU_NMOS_6507, Nmi: &testIRQ{}}},
+		{"6504 NMI", &ChipDef{Cpu: CPU_NMOS_6504, Nmi: &testIRQ{}}},
+		{"6504 RDY", &ChipDef{Cpu: CPU_NMOS_6504, Rdy: &testIRQ{}}},
+	}
+	for _, test := range tests {
+		test.def.Ram = &flatMemory{}
+		if _, err := Init(test.def); err == nil {
+			t.Errorf("%s: didn't get error", test.name)
+		}
+	}
+	// The pins which do exist are still allowed.
+	for _, def := range []*ChipDef{
+		{Cpu: CPU_NMOS_6507, Rdy: &testIRQ{}},
+		{Cpu: CPU_NMOS_6504, Irq: &testIRQ{}},
+	} {
+		def.Ram = &flatMemory{}
+		if _, err := Init(def); err != nil {
+			t.Errorf("CPU %d: unexpected error %v", def.Cpu, err)
+		}
+	}
+}
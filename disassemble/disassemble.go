@@ -7,800 +7,946 @@ import (
 	"github.com/jmchacon/6502/memory"
 )
 
+// Mode is an enumeration of the 6502 addressing modes.
+type Mode int
+
 const (
-	kMODE_IMMEDIATE = iota
-	kMODE_ZP
-	kMODE_ZPX
-	kMODE_ZPY
-	kMODE_INDIRECTX
-	kMODE_INDIRECTY
-	kMODE_ABSOLUTE
-	kMODE_ABSOLUTEX
-	kMODE_ABSOLUTEY
-	kMODE_INDIRECT
-	kMODE_IMPLIED
-	kMODE_RELATIVE
+	MODE_IMMEDIATE    Mode = iota // #i
+	MODE_ZP                       // d
+	MODE_ZPX                      // d,x
+	MODE_ZPY                      // d,y
+	MODE_INDIRECTX                // (d,x)
+	MODE_INDIRECTY                // (d),y
+	MODE_ABSOLUTE                 // a
+	MODE_ABSOLUTEX                // a,x
+	MODE_ABSOLUTEY                // a,y
+	MODE_INDIRECT                 // (a)
+	MODE_IMPLIED                  // No arguments (including accumulator forms such as ASL A).
+	MODE_RELATIVE                 // Branch offset.
+	MODE_INDIRECTZP               // (d) (CMOS only).
+	MODE_INDIRECTABSX             // (a,x) (CMOS only).
+	MODE_ZPRELATIVE               // d,*+r (CMOS only). Zero page address followed by a branch offset.
 )
 
-// Step will take the given PC value and disassemble the instruction at that location
-// returning a string for the disassembly and the bytes forward the PC should move to get to
-// the next instruction. This does not interpret the instructions so LDA, JMP, LDA in memory
-// will disassemble as that sequence and not follow the JMP.
-// This always reads at least one byte past the current PC so make sure that address is valid.
-func Step(pc uint16, r memory.Bank) (string, int) {
-	// All instructions read a 2nd byte generally so just do that now.
-	pc1 := r.Read(pc + 1)
-	// Setup a 16 bit value so it can be added the the PC for branch offsets.
-	// Sign extend it as needed.
-	pc116 := uint16(int16(int8(pc1)))
-	// And preread the 2nd byte for 3 byte instructions.
-	pc2 := r.Read(pc + 2)
+// Bytes returns the total instruction length (opcode included) for the addressing mode.
+func (m Mode) Bytes() int {
+	switch m {
+	case MODE_IMPLIED:
+		return 1
+	case MODE_ABSOLUTE, MODE_ABSOLUTEX, MODE_ABSOLUTEY, MODE_INDIRECT, MODE_INDIRECTABSX, MODE_ZPRELATIVE:
+		return 3
+	}
+	return 2
+}
+
+// Timing describes how many cycles an opcode takes. The cpu package provides a table of these for each
+// CPU type (see cpu.Timings) since the values vary between them.
+type Timing struct {
+	Cycles      int  // Base cycles assuming no page crossings, branches not taken and decimal mode off.
+	PageCross   int  // Extra cycles when indexing crosses a page boundary (or a taken branch lands on another page).
+	BranchTaken int  // Extra cycles when a branch is taken.
+	Decimal     int  // Extra cycles when the D flag is set.
+	DummyWrite  bool // If true a RMW opcode writes the unmodified value back before writing the result.
+	Halt        bool // If true the opcode halts the CPU so Cycles is meaningless.
+}
+
+// String implements fmt.Stringer for Timing in the usual listing notation. The base cycles are followed by
+// +p for a page crossing penalty, +t for a taken branch penalty and +d for a decimal mode penalty.
+// Penalties of more than one cycle include the count (i.e. +2p). Halts are HLT.
+func (t Timing) String() string {
+	if t.Halt {
+		return "HLT"
+	}
+	out := fmt.Sprintf("%d", t.Cycles)
+	for _, p := range []struct {
+		n int
+		s string
+	}{
+		{t.BranchTaken, "t"},
+		{t.PageCross, "p"},
+		{t.Decimal, "d"},
+	} {
+		switch {
+		case p.n == 1:
+			out += "+" + p.s
+		case p.n > 1:
+			out += fmt.Sprintf("+%d%s", p.n, p.s)
+		}
+	}
+	return out
+}
+
+// documented is the set of mnemonics from the original NMOS documentation.
+var documented = map[string]bool{
+	"ADC": true, "AND": true, "ASL": true, "BCC": true, "BCS": true, "BEQ": true, "BIT": true, "BMI": true,
+	"BNE": true, "BPL": true, "BRK": true, "BVC": true, "BVS": true, "CLC": true, "CLD": true, "CLI": true,
+	"CLV": true, "CMP": true, "CPX": true, "CPY": true, "DEC": true, "DEX": true, "DEY": true, "EOR": true,
+	"INC": true, "INX": true, "INY": true, "JMP": true, "JSR": true, "LDA": true, "LDX": true, "LDY": true,
+	"LSR": true, "NOP": true, "ORA": true, "PHA": true, "PHP": true, "PLA": true, "PLP": true, "ROL": true,
+	"ROR": true, "RTI": true, "RTS": true, "SBC": true, "SEC": true, "SED": true, "SEI": true, "STA": true,
+	"STX": true, "STY": true, "TAX": true, "TAY": true, "TSX": true, "TXA": true, "TXS": true, "TYA": true,
+}
+
+// Undocumented returns true if the given opcode isn't one of the documented NMOS opcodes.
+// This includes all NOP variants other than 0xEA and the duplicate SBC at 0xEB.
+func Undocumented(o uint8) bool {
+	op, _ := Decode(o)
+	if !documented[op] {
+		return true
+	}
+	return (op == "NOP" && o != 0xEA) || o == 0xEB
+}
 
-	// When done this will have op and the mode determined and byte count
+// cmosOpcode is an entry in cmosOpcodes.
+type cmosOpcode struct {
+	op   string
+	mode Mode
+}
+
+// cmosOpcodes are the opcodes which decode differently on the CMOS 65C02. Any opcode not listed
+// here decodes the same as NMOS.
+var cmosOpcodes = map[uint8]cmosOpcode{
+	0x02: {"NOP", MODE_IMMEDIATE}, 0x03: {"NOP", MODE_IMPLIED}, 0x04: {"TSB", MODE_ZP}, 0x07: {"RMB0", MODE_ZP},
+	0x0B: {"NOP", MODE_IMPLIED}, 0x0C: {"TSB", MODE_ABSOLUTE}, 0x0F: {"BBR0", MODE_ZPRELATIVE},
+	0x12: {"ORA", MODE_INDIRECTZP}, 0x13: {"NOP", MODE_IMPLIED}, 0x14: {"TRB", MODE_ZP}, 0x17: {"RMB1", MODE_ZP},
+	0x1A: {"INC", MODE_IMPLIED}, 0x1B: {"NOP", MODE_IMPLIED}, 0x1C: {"TRB", MODE_ABSOLUTE}, 0x1F: {"BBR1", MODE_ZPRELATIVE},
+	0x22: {"NOP", MODE_IMMEDIATE}, 0x23: {"NOP", MODE_IMPLIED}, 0x27: {"RMB2", MODE_ZP}, 0x2B: {"NOP", MODE_IMPLIED},
+	0x2F: {"BBR2", MODE_ZPRELATIVE},
+	0x32: {"AND", MODE_INDIRECTZP}, 0x33: {"NOP", MODE_IMPLIED}, 0x34: {"BIT", MODE_ZPX}, 0x37: {"RMB3", MODE_ZP},
+	0x3A: {"DEC", MODE_IMPLIED}, 0x3B: {"NOP", MODE_IMPLIED}, 0x3C: {"BIT", MODE_ABSOLUTEX}, 0x3F: {"BBR3", MODE_ZPRELATIVE},
+	0x42: {"NOP", MODE_IMMEDIATE}, 0x43: {"NOP", MODE_IMPLIED}, 0x44: {"NOP", MODE_ZP}, 0x47: {"RMB4", MODE_ZP},
+	0x4B: {"NOP", MODE_IMPLIED}, 0x4F: {"BBR4", MODE_ZPRELATIVE},
+	0x52: {"EOR", MODE_INDIRECTZP}, 0x53: {"NOP", MODE_IMPLIED}, 0x54: {"NOP", MODE_ZPX}, 0x57: {"RMB5", MODE_ZP},
+	0x5A: {"PHY", MODE_IMPLIED}, 0x5B: {"NOP", MODE_IMPLIED}, 0x5C: {"NOP", MODE_ABSOLUTE}, 0x5F: {"BBR5", MODE_ZPRELATIVE},
+	0x62: {"NOP", MODE_IMMEDIATE}, 0x63: {"NOP", MODE_IMPLIED}, 0x64: {"STZ", MODE_ZP}, 0x67: {"RMB6", MODE_ZP},
+	0x6B: {"NOP", MODE_IMPLIED}, 0x6F: {"BBR6", MODE_ZPRELATIVE},
+	0x72: {"ADC", MODE_INDIRECTZP}, 0x73: {"NOP", MODE_IMPLIED}, 0x74: {"STZ", MODE_ZPX}, 0x77: {"RMB7", MODE_ZP},
+	0x7A: {"PLY", MODE_IMPLIED}, 0x7B: {"NOP", MODE_IMPLIED}, 0x7C: {"JMP", MODE_INDIRECTABSX}, 0x7F: {"BBR7", MODE_ZPRELATIVE},
+	0x80: {"BRA", MODE_RELATIVE}, 0x82: {"NOP", MODE_IMMEDIATE}, 0x83: {"NOP", MODE_IMPLIED}, 0x87: {"SMB0", MODE_ZP},
+	0x89: {"BIT", MODE_IMMEDIATE}, 0x8B: {"NOP", MODE_IMPLIED}, 0x8F: {"BBS0", MODE_ZPRELATIVE},
+	0x92: {"STA", MODE_INDIRECTZP}, 0x93: {"NOP", MODE_IMPLIED}, 0x97: {"SMB1", MODE_ZP}, 0x9B: {"NOP", MODE_IMPLIED},
+	0x9C: {"STZ", MODE_ABSOLUTE}, 0x9E: {"STZ", MODE_ABSOLUTEX}, 0x9F: {"BBS1", MODE_ZPRELATIVE},
+	0xA3: {"NOP", MODE_IMPLIED}, 0xA7: {"SMB2", MODE_ZP}, 0xAB: {"NOP", MODE_IMPLIED}, 0xAF: {"BBS2", MODE_ZPRELATIVE},
+	0xB2: {"LDA", MODE_INDIRECTZP}, 0xB3: {"NOP", MODE_IMPLIED}, 0xB7: {"SMB3", MODE_ZP}, 0xBB: {"NOP", MODE_IMPLIED},
+	0xBF: {"BBS3", MODE_ZPRELATIVE},
+	0xC2: {"NOP", MODE_IMMEDIATE}, 0xC3: {"NOP", MODE_IMPLIED}, 0xC7: {"SMB4", MODE_ZP}, 0xCB: {"WAI", MODE_IMPLIED},
+	0xCF: {"BBS4", MODE_ZPRELATIVE},
+	0xD2: {"CMP", MODE_INDIRECTZP}, 0xD3: {"NOP", MODE_IMPLIED}, 0xD4: {"NOP", MODE_ZPX}, 0xD7: {"SMB5", MODE_ZP},
+	0xDA: {"PHX", MODE_IMPLIED}, 0xDB: {"STP", MODE_IMPLIED}, 0xDC: {"NOP", MODE_ABSOLUTE}, 0xDF: {"BBS5", MODE_ZPRELATIVE},
+	0xE2: {"NOP", MODE_IMMEDIATE}, 0xE3: {"NOP", MODE_IMPLIED}, 0xE7: {"SMB6", MODE_ZP}, 0xEB: {"NOP", MODE_IMPLIED},
+	0xEF: {"BBS6", MODE_ZPRELATIVE},
+	0xF2: {"SBC", MODE_INDIRECTZP}, 0xF3: {"NOP", MODE_IMPLIED}, 0xF4: {"NOP", MODE_ZPX}, 0xF7: {"SMB7", MODE_ZP},
+	0xFA: {"PLX", MODE_IMPLIED}, 0xFB: {"NOP", MODE_IMPLIED}, 0xFC: {"NOP", MODE_ABSOLUTE}, 0xFF: {"BBS7", MODE_ZPRELATIVE},
+}
+
+// DecodeCMOS is the same as Decode but for the CMOS 65C02 opcodes. All of the NMOS undocumented
+// opcodes are either new instructions or NOPs on the 65C02.
+func DecodeCMOS(o uint8) (string, Mode) {
+	if c, ok := cmosOpcodes[o]; ok {
+		return c.op, c.mode
+	}
+	return Decode(o)
+}
+
+// UndocumentedCMOS returns true if the given opcode is one of the reserved NOPs on the CMOS 65C02.
+func UndocumentedCMOS(o uint8) bool {
+	op, _ := DecodeCMOS(o)
+	return op == "NOP" && o != 0xEA
+}
+
+// Decode returns the mnemonic and addressing mode for the given opcode.
+// Opcodes which have no known implementation return UNIMPLEMENTED.
+func Decode(o uint8) (string, Mode) {
 	var op string
-	mode := kMODE_IMPLIED
-	o := r.Read(pc)
+	mode := MODE_IMPLIED
 	switch o {
 	case 0x00:
 		op = "BRK"
-		mode = kMODE_IMMEDIATE // Ok, not really but the byte after BRK is read and skipped.
+		mode = MODE_IMMEDIATE // Ok, not really but the byte after BRK is read and skipped.
 	case 0x01:
 		op = "ORA"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x02:
 		op = "HLT"
 	case 0x03:
 		op = "SLO"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x04:
 		op = "NOP"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x05:
 		op = "ORA"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x06:
 		op = "ASL"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x07:
 		op = "SLO"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x08:
 		op = "PHP"
 	case 0x09:
 		op = "ORA"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x0A:
 		op = "ASL"
 	case 0x0B:
 		op = "ANC"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x0C:
 		op = "NOP"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x0D:
 		op = "ORA"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x0E:
 		op = "ASL"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x0F:
 		op = "SLO"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x10:
 		op = "BPL"
-		mode = kMODE_RELATIVE
+		mode = MODE_RELATIVE
 	case 0x11:
 		op = "ORA"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x12:
 		op = "HLT"
 	case 0x13:
 		op = "SLO"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x14:
 		op = "NOP"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x15:
 		op = "ORA"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x16:
 		op = "ASL"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x17:
 		op = "SLO"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x18:
 		op = "CLC"
 	case 0x19:
 		op = "ORA"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x1A:
 		op = "NOP"
 	case 0x1B:
 		op = "SLO"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x1C:
 		op = "NOP"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x1D:
 		op = "ORA"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x1E:
 		op = "ASL"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x1F:
 		op = "SLO"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x20:
 		op = "JSR"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x21:
 		op = "AND"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x22:
 		op = "HLT"
 	case 0x23:
 		op = "RLA"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x24:
 		op = "BIT"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x25:
 		op = "AND"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x26:
 		op = "ROL"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x27:
 		op = "RLA"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x28:
 		op = "PLP"
 	case 0x29:
 		op = "AND"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x2A:
 		op = "ROL"
 	case 0x2B:
 		op = "ANC"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x2C:
 		op = "BIT"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x2D:
 		op = "AND"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x2E:
 		op = "ROL"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x2F:
 		op = "RLA"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x30:
 		op = "BMI"
-		mode = kMODE_RELATIVE
+		mode = MODE_RELATIVE
 	case 0x31:
 		op = "AND"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x32:
 		op = "HLT"
 	case 0x33:
 		op = "RLA"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x34:
 		op = "NOP"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x35:
 		op = "AND"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x36:
 		op = "ROL"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x37:
 		op = "RLA"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x38:
 		op = "SEC"
 	case 0x39:
 		op = "AND"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x3A:
 		op = "NOP"
 	case 0x3B:
 		op = "RLA"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x3C:
 		op = "NOP"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x3D:
 		op = "AND"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x3E:
 		op = "ROL"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x3F:
 		op = "RLA"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x40:
 		op = "RTI"
 	case 0x41:
 		op = "EOR"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x42:
 		op = "HLT"
 	case 0x43:
 		op = "SRE"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x44:
 		op = "NOP"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x45:
 		op = "EOR"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x46:
 		op = "LSR"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x47:
 		op = "SRE"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x48:
 		op = "PHA"
 	case 0x49:
 		op = "EOR"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x4A:
 		op = "LSR"
 	case 0x4B:
 		op = "ALR"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x4C:
 		op = "JMP"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x4D:
 		op = "EOR"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x4E:
 		op = "LSR"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x4F:
 		op = "SRE"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x50:
 		op = "BVC"
-		mode = kMODE_RELATIVE
+		mode = MODE_RELATIVE
 	case 0x51:
 		op = "EOR"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x52:
 		op = "HLT"
 	case 0x53:
 		op = "SRE"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x54:
 		op = "NOP"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x55:
 		op = "EOR"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x56:
 		op = "LSR"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x57:
 		op = "SRE"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x58:
 		op = "CLI"
 	case 0x59:
 		op = "EOR"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x5A:
 		op = "NOP"
 	case 0x5B:
 		op = "SRE"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x5C:
 		op = "NOP"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x5D:
 		op = "EOR"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x5E:
 		op = "LSR"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x5F:
 		op = "SRE"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x60:
 		op = "RTS"
 	case 0x61:
 		op = "ADC"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x62:
 		op = "HLT"
 	case 0x63:
 		op = "RRA"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x64:
 		op = "NOP"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x65:
 		op = "ADC"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x66:
 		op = "ROR"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x67:
 		op = "RRA"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x68:
 		op = "PLA"
 	case 0x69:
 		op = "ADC"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x6A:
 		op = "ROR"
 	case 0x6B:
 		op = "ARR"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x6C:
 		op = "JMP"
-		mode = kMODE_INDIRECT
+		mode = MODE_INDIRECT
 	case 0x6D:
 		op = "ADC"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x6E:
 		op = "ROR"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x6F:
 		op = "RRA"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x70:
 		op = "BVS"
-		mode = kMODE_RELATIVE
+		mode = MODE_RELATIVE
 	case 0x71:
 		op = "ADC"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x72:
 		op = "HLT"
 	case 0x73:
 		op = "RRA"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x74:
 		op = "NOP"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x75:
 		op = "ADC"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x76:
 		op = "ROR"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x77:
 		op = "RRA"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x78:
 		op = "SEI"
 	case 0x79:
 		op = "ADC"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x7A:
 		op = "NOP"
 	case 0x7B:
 		op = "RRA"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x7C:
 		op = "NOP"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x7D:
 		op = "ADC"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x7E:
 		op = "ROR"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x7F:
 		op = "RRA"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x80:
 		op = "NOP"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x81:
 		op = "STA"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x82:
 		op = "NOP"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x83:
 		op = "SAX"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0x84:
 		op = "STY"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x85:
 		op = "STA"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x86:
 		op = "STX"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x87:
 		op = "SAX"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0x88:
 		op = "DEY"
 	case 0x89:
 		op = "NOP"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x8A:
 		op = "TXA"
 	case 0x8B:
 		op = "XAA"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0x8C:
 		op = "STY"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x8D:
 		op = "STA"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x8E:
 		op = "STX"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x8F:
 		op = "SAX"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0x90:
 		op = "BCC"
-		mode = kMODE_RELATIVE
+		mode = MODE_RELATIVE
 	case 0x91:
 		op = "STA"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x92:
 		op = "HLT"
 	case 0x93:
 		op = "AHX"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0x94:
 		op = "STY"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x95:
 		op = "STA"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0x96:
 		op = "STX"
-		mode = kMODE_ZPY
+		mode = MODE_ZPY
 	case 0x97:
 		op = "SAX"
-		mode = kMODE_ZPY
+		mode = MODE_ZPY
 	case 0x98:
 		op = "TYA"
 	case 0x99:
 		op = "STA"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x9A:
 		op = "TXS"
 	case 0x9B:
 		op = "TAS"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x9C:
 		op = "SHY"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x9D:
 		op = "STA"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0x9E:
 		op = "SHX"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0x9F:
 		op = "AHX"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xA0:
 		op = "LDY"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xA1:
 		op = "LDA"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0xA2:
 		op = "LDX"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xA3:
 		op = "LAX"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0xA4:
 		op = "LDY"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xA5:
 		op = "LDA"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xA6:
 		op = "LDX"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xA7:
 		op = "LAX"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xA8:
 		op = "TAY"
 	case 0xA9:
 		op = "LDA"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xAA:
 		op = "TAX"
 	case 0xAB:
 		op = "OAL"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xAC:
 		op = "LDY"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xAD:
 		op = "LDA"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xAE:
 		op = "LDX"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xAF:
 		op = "LAX"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xB0:
 		op = "BCS"
-		mode = kMODE_RELATIVE
+		mode = MODE_RELATIVE
 	case 0xB1:
 		op = "LDA"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0xB2:
 		op = "HLT"
 	case 0xB3:
 		op = "LAX"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0xB4:
 		op = "LDY"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xB5:
 		op = "LDA"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xB6:
 		op = "LDX"
-		mode = kMODE_ZPY
+		mode = MODE_ZPY
 	case 0xB7:
 		op = "LAX"
-		mode = kMODE_ZPY
+		mode = MODE_ZPY
 	case 0xB8:
 		op = "CLV"
 	case 0xB9:
 		op = "LDA"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xBA:
 		op = "TSX"
 	case 0xBB:
 		op = "LAS"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xBC:
 		op = "LDY"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xBD:
 		op = "LDA"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xBE:
 		op = "LDX"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xBF:
 		op = "LAX"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xC0:
 		op = "CPY"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xC1:
 		op = "CMP"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0xC2:
 		op = "NOP"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xC3:
 		op = "DCP"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0xC4:
 		op = "CPY"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xC5:
 		op = "CMP"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xC6:
 		op = "DEC"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xC7:
 		op = "DCP"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xC8:
 		op = "INY"
 	case 0xC9:
 		op = "CMP"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xCA:
 		op = "DEX"
 	case 0xCB:
 		op = "AXS"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xCC:
 		op = "CPY"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xCD:
 		op = "CMP"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xCE:
 		op = "DEC"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xCF:
 		op = "DCP"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xD0:
 		op = "BNE"
-		mode = kMODE_RELATIVE
+		mode = MODE_RELATIVE
 	case 0xD1:
 		op = "CMP"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0xD2:
 		op = "HLT"
 	case 0xD3:
 		op = "DCP"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0xD4:
 		op = "NOP"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xD5:
 		op = "CMP"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xD6:
 		op = "DEC"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xD7:
 		op = "DCP"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xD8:
 		op = "CLD"
 	case 0xD9:
 		op = "CMP"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xDA:
 		op = "NOP"
 	case 0xDB:
 		op = "DCP"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xDC:
 		op = "NOP"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xDD:
 		op = "CMP"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xDE:
 		op = "DEC"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xDF:
 		op = "DCP"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xE0:
 		op = "CPX"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xE1:
 		op = "SBC"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0xE2:
 		op = "NOP"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xE3:
 		op = "ISC"
-		mode = kMODE_INDIRECTX
+		mode = MODE_INDIRECTX
 	case 0xE4:
 		op = "CPX"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xE5:
 		op = "SBC"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xE6:
 		op = "INC"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xE7:
 		op = "ISC"
-		mode = kMODE_ZP
+		mode = MODE_ZP
 	case 0xE8:
 		op = "INX"
 	case 0xE9:
 		op = "SBC"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xEA:
 		op = "NOP"
 	case 0xEB:
 		op = "SBC"
-		mode = kMODE_IMMEDIATE
+		mode = MODE_IMMEDIATE
 	case 0xEC:
 		op = "CPX"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xED:
 		op = "SBC"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xEE:
 		op = "INC"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xEF:
 		op = "ISC"
-		mode = kMODE_ABSOLUTE
+		mode = MODE_ABSOLUTE
 	case 0xF0:
 		op = "BEQ"
-		mode = kMODE_RELATIVE
+		mode = MODE_RELATIVE
 	case 0xF1:
 		op = "SBC"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0xF2:
 		op = "HLT"
 	case 0xF3:
 		op = "ISC"
-		mode = kMODE_INDIRECTY
+		mode = MODE_INDIRECTY
 	case 0xF4:
 		op = "NOP"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xF5:
 		op = "SBC"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xF6:
 		op = "INC"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xF7:
 		op = "ISC"
-		mode = kMODE_ZPX
+		mode = MODE_ZPX
 	case 0xF8:
 		op = "SED"
 	case 0xF9:
 		op = "SBC"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xFA:
 		op = "NOP"
 	case 0xFB:
 		op = "ISC"
-		mode = kMODE_ABSOLUTEY
+		mode = MODE_ABSOLUTEY
 	case 0xFC:
 		op = "NOP"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xFD:
 		op = "SBC"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xFE:
 		op = "INC"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	case 0xFF:
 		op = "ISC"
-		mode = kMODE_ABSOLUTEX
+		mode = MODE_ABSOLUTEX
 	default:
 		op = "UNIMPLEMENTED"
 	}
+	return op, mode
+}
+
+// Step will take the given PC value and disassemble the instruction at that location
+// returning a string for the disassembly and the bytes forward the PC should move to get to
+// the next instruction. This does not interpret the instructions so LDA, JMP, LDA in memory
+// will disassemble as that sequence and not follow the JMP.
+// This always reads at least one byte past the current PC so make sure that address is valid.
+// Memory is read with memory.Peek so disassembling a running program doesn't change it.
+func Step(pc uint16, r memory.Bank) (string, int) {
+	// All instructions read a 2nd byte generally so just do that now.
+	pc1 := memory.Peek(r, pc+1)
+	// Setup a 16 bit value so it can be added the the PC for branch offsets.
+	// Sign extend it as needed.
+	pc116 := uint16(int16(int8(pc1)))
+	// And preread the 2nd byte for 3 byte instructions.
+	pc2 := memory.Peek(r, pc+2)
+
+	o := memory.Peek(r, pc)
+	op, mode := Decode(o)
 
 	count := 2 // Default byte count, adjusted below.
 	out := fmt.Sprintf("%.4X %.2X ", pc, o)
 	switch mode {
-	case kMODE_IMMEDIATE:
+	case MODE_IMMEDIATE:
 		out += fmt.Sprintf("%.2X      %s #%.2X       ", pc1, op, pc1)
-	case kMODE_ZP:
+	case MODE_ZP:
 		out += fmt.Sprintf("%.2X      %s %.2X        ", pc1, op, pc1)
-	case kMODE_ZPX:
+	case MODE_ZPX:
 		out += fmt.Sprintf("%.2X      %s %.2X,X      ", pc1, op, pc1)
-	case kMODE_ZPY:
+	case MODE_ZPY:
 		out += fmt.Sprintf("%.2X      %s %.2X,Y      ", pc1, op, pc1)
-	case kMODE_INDIRECTX:
+	case MODE_INDIRECTX:
 		out += fmt.Sprintf("%.2X      %s (%.2X,X)    ", pc1, op, pc1)
-	case kMODE_INDIRECTY:
+	case MODE_INDIRECTY:
 		out += fmt.Sprintf("%.2X      %s (%.2X),Y    ", pc1, op, pc1)
-	case kMODE_ABSOLUTE:
+	case MODE_ABSOLUTE:
 		out += fmt.Sprintf("%.2X %.2X   %s %.2X%.2X      ", pc1, pc2, op, pc2, pc1)
 		count++
-	case kMODE_ABSOLUTEX:
+	case MODE_ABSOLUTEX:
 		out += fmt.Sprintf("%.2X %.2X   %s %.2X%.2X,X    ", pc1, pc2, op, pc2, pc1)
 		count++
-	case kMODE_ABSOLUTEY:
+	case MODE_ABSOLUTEY:
 		out += fmt.Sprintf("%.2X %.2X   %s %.2X%.2X,Y    ", pc1, pc2, op, pc2, pc1)
 		count++
-	case kMODE_INDIRECT:
+	case MODE_INDIRECT:
 		out += fmt.Sprintf("%.2X %.2X   %s (%.2X%.2X)    ", pc1, pc2, op, pc2, pc1)
 		count++
-	case kMODE_IMPLIED:
+	case MODE_IMPLIED:
 		out += fmt.Sprintf("        %s           ", op)
 		count--
-	case kMODE_RELATIVE:
+	case MODE_RELATIVE:
 		out += fmt.Sprintf("%.2X      %s %.2X (%.4X) ", pc1, op, pc1, pc+pc116+2)
 	default:
 		panic(fmt.Sprintf("Invalid mode: %d", mode))
 	}
 	return out, count
 }
+
+// StepTimed is the same as Step but appends the cycle timing (see Timing.String) for the opcode from the given
+// table to the disassembly.
+func StepTimed(pc uint16, r memory.Bank, timings *[256]Timing) (string, int) {
+	out, count := Step(pc, r)
+	return fmt.Sprintf("%s %s", out, timings[memory.Peek(r, pc)]), count
+}
@@ -16,6 +16,7 @@ import (
 	"strings"
 
 	"github.com/jmchacon/6502/c64basic"
+	"github.com/jmchacon/6502/cpu"
 	"github.com/jmchacon/6502/disassemble"
 	"github.com/jmchacon/6502/memory"
 )
@@ -23,12 +24,13 @@ import (
 var (
 	startPC = flag.Int("start_pc", 0x0000, "PC value to start disassembling")
 	offset  = flag.Int("offset", 0x0000, "Offset into RAM to start loading data. All other RAM will be zero'd out. Ignored for PRG files.")
+	cycles  = flag.Bool("cycles", false, "If true annotate each instruction with its NMOS cycle timing")
 )
 
 func main() {
 	flag.Parse()
 	if len(flag.Args()) != 1 {
-		log.Fatalf("Invalid command: %s [-start_pc <PC> -offset <offset>] <filename>", os.Args[0])
+		log.Fatalf("Invalid command: %s [-start_pc <PC> -offset <offset> -cycles] <filename>", os.Args[0])
 	}
 	fn := flag.Args()[0]
 
@@ -41,7 +43,7 @@ func main() {
 		fmt.Println("C64 program file")
 	}
 
-	f, err := memory.New8BitRAMBank(1<<16, nil)
+	f, err := memory.New8BitRAMBank(1<<16, nil, nil)
 	if err != nil {
 		log.Fatalf("Can't initialize RAM: %v", err)
 	}
@@ -85,10 +87,20 @@ func main() {
 			pc = newPC
 		}
 	}
+	timings, err := cpu.Timings(cpu.CPU_NMOS)
+	if err != nil {
+		log.Fatalf("Can't get cycle timings: %v", err)
+	}
 	cnt := 0
 	// Can't base it on PC since it may rollover so just disassemble until we run out of buffer.
 	for cnt < len(b) {
-		dis, off := disassemble.Step(pc, f)
+		var dis string
+		var off int
+		if *cycles {
+			dis, off = disassemble.StepTimed(pc, f, timings)
+		} else {
+			dis, off = disassemble.Step(pc, f)
+		}
 		pc += uint16(off)
 		cnt += off
 		fmt.Printf("%s\n", dis)
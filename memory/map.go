@@ -0,0 +1,265 @@
+package memory
+
+import (
+	"fmt"
+)
+
+// Access is an enumeration of which bus cycles a Region responds to.
+type Access int
+
+const (
+	ACCESS_READ_WRITE Access = iota // Reads and writes. This is the default.
+	ACCESS_READ                     // Only reads. Writes fall through to lower priority regions (i.e. ROM overlaying RAM).
+	ACCESS_WRITE                    // Only writes. Reads fall through to lower priority regions.
+	ACCESS_MAX                      // End of access enumerations.
+)
+
+// String implements fmt.Stringer for Access.
+func (a Access) String() string {
+	switch a {
+	case ACCESS_READ_WRITE:
+		return "READ_WRITE"
+	case ACCESS_READ:
+		return "READ"
+	case ACCESS_WRITE:
+		return "WRITE"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(a))
+}
+
+// Region describes a range of the address space which a Map routes to a Bank.
+type Region struct {
+	// Name is used in errors and returned by Decode. Optional.
+	Name string
+	// Base is the first address of the region.
+	Base uint16
+	// Size is the number of addresses covered (1-65536). Base+Size must not go past the top of the address space.
+	Size int
+	// Mask (if non-zero) is ANDed with the address before it's passed to Bank. This mirrors a smaller Bank
+	// across the region. If zero the full address is passed.
+	Mask uint16
+	// Bank handles accesses to the region. It should be created with the Map as its Parent.
+	Bank Bank
+	// Priority decides which region wins where regions overlap (highest wins). Overlapping regions
+	// with the same priority which respond to the same access are an error.
+	Priority int
+	// Access determines which accesses the region responds to.
+	Access Access
+}
+
+// mapRegion is a Region added to a Map with the mask resolved.
+type mapRegion struct {
+	Region
+	mask   uint16
+	driver Driver // Set if Bank implements Driver.
+}
+
+// mapPage is one 256 byte page of a Map. If the whole page routes to the same region for reads (or writes)
+// then read (or write) is set and reads (or writes) is nil. Otherwise the per address table is used.
+// A nil region is unmapped.
+type mapPage struct {
+	read   *mapRegion
+	write  *mapRegion
+	reads  *[256]*mapRegion
+	writes *[256]*mapRegion
+}
+
+// Map implements Bank by routing each address to a Region. Regions are added with Add and compiled into
+// a table of 256 pages so each access is a couple of lookups no matter how many regions there are.
+// Reads of unmapped addresses (and any bits a Bank doesn't drive, see Driver) are decided by the
+// OpenBus policy and writes to unmapped addresses are ignored. Every access (mapped or not) updates
+// DatabusVal like any other Bank.
+type Map struct {
+	parent     Bank
+	databusVal uint8
+	openBus    OpenBus
+	regions    []*mapRegion
+	pages      [256]mapPage
+}
+
+// NewMap returns an empty Map. parent is returned from Parent and may be nil.
+// Undriven bits float (see OPEN_BUS_FLOAT) until SetOpenBus is called.
+func NewMap(parent Bank) *Map {
+	return &Map{parent: parent}
+}
+
+// SetOpenBus sets what undriven data bits read as.
+func (m *Map) SetOpenBus(o OpenBus) error {
+	if o < OPEN_BUS_FLOAT || o >= OPEN_BUS_MAX {
+		return fmt.Errorf("invalid open bus policy %d", o)
+	}
+	m.openBus = o
+	return nil
+}
+
+// Add adds the region to the map and recompiles the page table. On error the map is unchanged.
+func (m *Map) Add(r Region) error {
+	if r.Bank == nil {
+		return fmt.Errorf("region %q has no Bank", r.Name)
+	}
+	if r.Size <= 0 || int(r.Base)+r.Size > 1<<16 {
+		return fmt.Errorf("region %q base 0x%.4X size %d doesn't fit in the address space", r.Name, r.Base, r.Size)
+	}
+	if r.Access < ACCESS_READ_WRITE || r.Access >= ACCESS_MAX {
+		return fmt.Errorf("region %q has invalid access %d", r.Name, r.Access)
+	}
+	mr := &mapRegion{Region: r, mask: r.Mask}
+	if mr.mask == 0 {
+		mr.mask = 0xFFFF
+	}
+	mr.driver, _ = r.Bank.(Driver)
+	regions := append(append([]*mapRegion(nil), m.regions...), mr)
+	pages, err := compileMap(regions)
+	if err != nil {
+		return err
+	}
+	m.regions = regions
+	m.pages = *pages
+	return nil
+}
+
+// compileMap builds the page table for the given regions.
+func compileMap(regions []*mapRegion) (*[256]mapPage, error) {
+	var pages [256]mapPage
+	for pg := range pages {
+		var reads, writes [256]*mapRegion
+		for off := 0; off < 256; off++ {
+			addr := pg<<8 | off
+			for _, r := range regions {
+				if addr < int(r.Base) || addr >= int(r.Base)+r.Size {
+					continue
+				}
+				var err error
+				if r.Access != ACCESS_WRITE {
+					if reads[off], err = pick(reads[off], r, addr); err != nil {
+						return nil, err
+					}
+				}
+				if r.Access != ACCESS_READ {
+					if writes[off], err = pick(writes[off], r, addr); err != nil {
+						return nil, err
+					}
+				}
+			}
+		}
+		pages[pg].read, pages[pg].reads = compress(&reads)
+		pages[pg].write, pages[pg].writes = compress(&writes)
+	}
+	return &pages, nil
+}
+
+// pick returns whichever of cur and r has the higher priority. It's an error if they're the same.
+func pick(cur, r *mapRegion, addr int) (*mapRegion, error) {
+	switch {
+	case cur == nil || r.Priority > cur.Priority:
+		return r, nil
+	case r.Priority == cur.Priority:
+		return nil, fmt.Errorf("regions %q and %q overlap at 0x%.4X with the same priority %d", cur.Name, r.Name, addr, r.Priority)
+	}
+	return cur, nil
+}
+
+// compress returns the single region a page routes to if every entry is the same. Otherwise it
+// returns a copy of the per address table.
+func compress(t *[256]*mapRegion) (*mapRegion, *[256]*mapRegion) {
+	for _, r := range t {
+		if r != t[0] {
+			ret := *t
+			return nil, &ret
+		}
+	}
+	return t[0], nil
+}
+
+// lookup returns the region which handles addr (or nil if unmapped).
+func (m *Map) lookup(addr uint16, write bool) *mapRegion {
+	pg := &m.pages[addr>>8]
+	if write {
+		if pg.writes != nil {
+			return pg.writes[addr&0xFF]
+		}
+		return pg.write
+	}
+	if pg.reads != nil {
+		return pg.reads[addr&0xFF]
+	}
+	return pg.read
+}
+
+// Decode returns the region which handles a read (or write if write is set) of addr.
+// Returns false if the address is unmapped for that access.
+func (m *Map) Decode(addr uint16, write bool) (Region, bool) {
+	r := m.lookup(addr, write)
+	if r == nil {
+		return Region{}, false
+	}
+	return r.Region, true
+}
+
+// Read implements the interface for Bank by reading from the region mapped at addr.
+func (m *Map) Read(addr uint16) uint8 {
+	m.databusVal = m.resolve(addr, false)
+	return m.databusVal
+}
+
+// resolve returns the value read from addr (using Peek if peek is set) with the open bus policy
+// applied to any bits which aren't driven.
+func (m *Map) resolve(addr uint16, peek bool) uint8 {
+	r := m.lookup(addr, false)
+	if r == nil {
+		return m.openBus.Resolve(0x00, 0x00, m.databusVal)
+	}
+	addr &= r.mask
+	var val uint8
+	if peek {
+		val = Peek(r.Bank, addr)
+	} else {
+		val = r.Bank.Read(addr)
+	}
+	if r.driver != nil {
+		val = m.openBus.Resolve(val, r.driver.Driven(addr), m.databusVal)
+	}
+	return val
+}
+
+// Write implements the interface for Bank by writing to the region mapped at addr.
+func (m *Map) Write(addr uint16, val uint8) {
+	m.databusVal = val
+	if r := m.lookup(addr, true); r != nil {
+		r.Bank.Write(addr&r.mask, val)
+	}
+}
+
+// Peek implements the interface for Debug by peeking the region a read of addr would go to.
+func (m *Map) Peek(addr uint16) uint8 {
+	return m.resolve(addr, true)
+}
+
+// Poke implements the interface for Debug by poking the region a write of addr would go to.
+func (m *Map) Poke(addr uint16, val uint8) {
+	if r := m.lookup(addr, true); r != nil {
+		Poke(r.Bank, addr&r.mask, val)
+	}
+}
+
+// PowerOn implements the interface for Bank and calls PowerOn once for each distinct Bank in the map
+// in the order they were added.
+func (m *Map) PowerOn() {
+	seen := make(map[Bank]bool)
+	for _, r := range m.regions {
+		if !seen[r.Bank] {
+			seen[r.Bank] = true
+			r.Bank.PowerOn()
+		}
+	}
+}
+
+// Parent implements the interface for returning a possible parent memory.Bank.
+func (m *Map) Parent() Bank {
+	return m.parent
+}
+
+// DatabusVal returns the most recent seen databus item.
+func (m *Map) DatabusVal() uint8 {
+	return m.databusVal
+}
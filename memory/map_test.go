@@ -0,0 +1,103 @@
+package memory
+
+import (
+	"testing"
+)
+
+func newRAM(t *testing.T, size int, parent Bank) Bank {
+	t.Helper()
+	r, err := New8BitRAMBank(size, parent, &PowerOnPolicy{Mode: POWER_ON_ZERO})
+	if err != nil {
+		t.Fatalf("Can't create RAM: %v", err)
+	}
+	r.PowerOn()
+	return r
+}
+
+func TestMap(t *testing.T) {
+	m := NewMap(nil)
+	ram := newRAM(t, 1<<16, m)
+	zp := newRAM(t, 128, m)
+	rom := newRAM(t, 1<<13, m)
+	for i := 0; i < 1<<13; i++ {
+		rom.Write(uint16(i), 0xEA)
+	}
+	for _, r := range []Region{
+		{Name: "ram", Base: 0x0000, Size: 0xC000, Bank: ram},
+		// 128 bytes mirrored across 0x0000-0x03FF over the top of the RAM.
+		{Name: "zp", Base: 0x0000, Size: 0x0400, Mask: 0x007F, Bank: zp, Priority: 1},
+		// ROM which only answers reads so writes go to the RAM below.
+		{Name: "rom", Base: 0xA000, Size: 0x2000, Bank: rom, Priority: 1, Access: ACCESS_READ},
+		// Top 8k is ROM only.
+		{Name: "kernal", Base: 0xE000, Size: 0x2000, Bank: rom},
+	} {
+		if err := m.Add(r); err != nil {
+			t.Fatalf("Can't add region %q: %v", r.Name, err)
+		}
+	}
+
+	m.Write(0x0010, 0x55)
+	if got, want := m.Read(0x0390), uint8(0x55); got != want {
+		t.Errorf("Mirror not applied. Got %.2X and want %.2X", got, want)
+	}
+	if got := ram.Read(0x0010); got != 0x00 {
+		t.Errorf("Write to zp went to RAM. Got %.2X", got)
+	}
+
+	m.Write(0xA123, 0x42)
+	if got, want := m.Read(0xA123), uint8(0xEA); got != want {
+		t.Errorf("ROM overlay read. Got %.2X and want %.2X", got, want)
+	}
+	if got, want := ram.Read(0xA123), uint8(0x42); got != want {
+		t.Errorf("Write didn't fall through to RAM. Got %.2X and want %.2X", got, want)
+	}
+
+	// Unmapped reads return the last databus value.
+	m.Write(0x1000, 0x77)
+	if got, want := m.Read(0xC000), uint8(0x77); got != want {
+		t.Errorf("Open bus read. Got %.2X and want %.2X", got, want)
+	}
+	if got, want := LatestDatabusVal(zp), uint8(0x77); got != want {
+		t.Errorf("Bad databus value through parent. Got %.2X and want %.2X", got, want)
+	}
+
+	if r, ok := m.Decode(0xA000, true); !ok || r.Name != "ram" {
+		t.Errorf("Bad decode for write of A000: %+v %t", r, ok)
+	}
+	if r, ok := m.Decode(0xA000, false); !ok || r.Name != "rom" {
+		t.Errorf("Bad decode for read of A000: %+v %t", r, ok)
+	}
+	if _, ok := m.Decode(0xD000, false); ok {
+		t.Errorf("D000 decoded but is unmapped")
+	}
+}
+
+func TestMapErrors(t *testing.T) {
+	m := NewMap(nil)
+	ram := newRAM(t, 256, m)
+	if err := m.Add(Region{Name: "ram", Base: 0x1000, Size: 0x100, Bank: ram}); err != nil {
+		t.Fatalf("Can't add region: %v", err)
+	}
+	for _, r := range []Region{
+		{Name: "no bank", Size: 1},
+		{Name: "zero size", Bank: ram},
+		{Name: "too big", Base: 0xFF00, Size: 0x101, Bank: ram},
+		{Name: "bad access", Size: 1, Bank: ram, Access: ACCESS_MAX},
+		{Name: "overlap", Base: 0x10FF, Size: 2, Bank: ram},
+	} {
+		if err := m.Add(r); err == nil {
+			t.Errorf("%s: didn't get error", r.Name)
+		}
+	}
+	// Failed adds don't change the map.
+	if _, ok := m.Decode(0x1100, false); ok {
+		t.Errorf("Failed region was added")
+	}
+	// A higher priority overlap is fine.
+	if err := m.Add(Region{Name: "write", Base: 0x10FF, Size: 2, Bank: ram, Access: ACCESS_WRITE, Priority: 1}); err != nil {
+		t.Errorf("Can't add write only region: %v", err)
+	}
+	if r, ok := m.Decode(0x10FF, false); !ok || r.Name != "ram" {
+		t.Errorf("Bad decode for read of 10FF: %+v %t", r, ok)
+	}
+}
@@ -6,8 +6,6 @@ package memory
 
 import (
 	"fmt"
-	"math/rand"
-	"time"
 )
 
 type Bank interface {
@@ -28,6 +26,37 @@ type Bank interface {
 	DatabusVal() uint8
 }
 
+// Debug is implemented by Banks which can be inspected and changed without side effects. Debuggers,
+// disassemblers and anything else which only watches memory should use Peek and Poke (below) so
+// looking at a running system never changes how it runs.
+type Debug interface {
+	// Peek returns the value at addr without any side effects (bank switching, clearing
+	// interrupt flags, updating DatabusVal, etc). For registers this is the value a Read would return.
+	Peek(addr uint16) uint8
+	// Poke changes the value at addr without any side effects. For ROM this patches the contents.
+	// Registers which can't be set without side effects are left unchanged.
+	Poke(addr uint16, val uint8)
+}
+
+// Peek returns the value at addr from b using Debug if it's implemented. Otherwise it falls back
+// to Read (which may have side effects).
+func Peek(b Bank, addr uint16) uint8 {
+	if d, ok := b.(Debug); ok {
+		return d.Peek(addr)
+	}
+	return b.Read(addr)
+}
+
+// Poke sets the value at addr in b using Debug if it's implemented. Otherwise it falls back
+// to Write (which may have side effects).
+func Poke(b Bank, addr uint16, val uint8) {
+	if d, ok := b.(Debug); ok {
+		d.Poke(addr, val)
+		return
+	}
+	b.Write(addr, val)
+}
+
 // LatestDatabusVal hunts up a chain of Banks until it finds the outermost one and
 // return the DatabusVal from it.
 func LatestDatabusVal(b Bank) uint8 {
@@ -44,19 +73,26 @@ type ram struct {
 	ram        []uint8
 	parent     Bank
 	databusVal uint8
+	policy     *PowerOnPolicy
 }
 
 // New8BitRAMBank creates a R/W RAM bank of the given size. Size must be a power of 2.
 // If this is smaller than 64k (uint16 max) aliasing will occur on Read/Write.
-func New8BitRAMBank(size int, parent Bank) (Bank, error) {
+// The policy determines the contents after PowerOn. If nil the RAM is randomized.
+func New8BitRAMBank(size int, parent Bank, policy *PowerOnPolicy) (Bank, error) {
 	if size%2 != 0 {
 		return nil, fmt.Errorf("invalid size: %d must be a power of 2", size)
 	}
 	if size > 1<<16 {
 		return nil, fmt.Errorf("invalid size: %d is bigger than 64k", size)
 	}
+	pol, err := ResolvePowerOnPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
 	b := &ram{
 		parent: parent,
+		policy: pol,
 	}
 	// Go ahead and completely preallocate this now.
 	b.ram = make([]uint8, size, size)
@@ -80,12 +116,19 @@ func (r *ram) Write(addr uint16, val uint8) {
 	r.ram[addr] = val
 }
 
-// PowerOn implements the interface for memory.Bank and randomizes the RAM.
+// Peek implements the interface for Debug. Address is clipped based on length of ram buffer.
+func (r *ram) Peek(addr uint16) uint8 {
+	return r.ram[addr&uint16(len(r.ram)-1)]
+}
+
+// Poke implements the interface for Debug. Address is clipped based on length of ram buffer.
+func (r *ram) Poke(addr uint16, val uint8) {
+	r.ram[addr&uint16(len(r.ram)-1)] = val
+}
+
+// PowerOn implements the interface for memory.Bank and fills the RAM based on the PowerOnPolicy.
 func (r *ram) PowerOn() {
-	rand.Seed(time.Now().UnixNano())
-	for i := range r.ram {
-		r.ram[i] = uint8(rand.Intn(256))
-	}
+	r.policy.Fill(r.ram)
 }
 
 // Parent implements the interface for returning a possible parent memory.Bank.
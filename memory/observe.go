@@ -0,0 +1,166 @@
+package memory
+
+import (
+	"fmt"
+)
+
+// BusEvent is a single access reported to an observer.
+type BusEvent struct {
+	Cycle int    // The value of the clock function (see NewObserved) at the time of the access.
+	Addr  uint16 // Address accessed.
+	Val   uint8  // Value read or written.
+	Write bool   // True for a write, false for a read.
+}
+
+// String implements fmt.Stringer for BusEvent.
+func (b BusEvent) String() string {
+	rw := "R"
+	if b.Write {
+		rw = "W"
+	}
+	return fmt.Sprintf("%d %s %.4X %.2X", b.Cycle, rw, b.Addr, b.Val)
+}
+
+// observer is a single callback added with Observe.
+type observer struct {
+	id         int
+	start, end uint16 // Inclusive.
+	access     Access
+	fn         func(BusEvent)
+}
+
+// Observed wraps a Bank and calls observers attached to ranges of addresses as they're read or written.
+// It can wrap any Bank in a tree (normally the top one handed to the CPU). Parent and DatabusVal are passed
+// through to the wrapped Bank so the databus chain is unchanged.
+// When no observers cover a page the only overhead is a single table lookup per access.
+// Peek and Poke (see Debug) are passed through without calling observers.
+type Observed struct {
+	bank      Bank
+	clock     func() int
+	next      int
+	observers []*observer
+	pages     [256][]*observer // The observers covering any part of each page.
+}
+
+// NewObserved returns an Observed wrapping b. clock (which may be nil and can be set later with SetClock)
+// provides the cycle reported in each BusEvent. Normally this is the Clocks method of the CPU.
+func NewObserved(b Bank, clock func() int) *Observed {
+	return &Observed{bank: b, clock: clock}
+}
+
+// SetClock sets the function used to provide the cycle in each BusEvent. If nil the cycle is always 0.
+func (o *Observed) SetClock(clock func() int) {
+	o.clock = clock
+}
+
+// Bank returns the wrapped Bank.
+func (o *Observed) Bank() Bank {
+	return o.bank
+}
+
+// Observe attaches fn to the addresses from start to end (inclusive). It's called after each access of the
+// kind(s) given by access with the value read or written. fn must not Read or Write the Observed (or anything
+// below it) but may use Peek. Returns an id for Remove.
+func (o *Observed) Observe(start, end uint16, access Access, fn func(BusEvent)) (int, error) {
+	if end < start {
+		return 0, fmt.Errorf("observer range end 0x%.4X is before start 0x%.4X", end, start)
+	}
+	if access < ACCESS_READ_WRITE || access >= ACCESS_MAX {
+		return 0, fmt.Errorf("invalid observer access %d", access)
+	}
+	if fn == nil {
+		return 0, fmt.Errorf("observer function must be non-nil")
+	}
+	o.next++
+	ob := &observer{
+		id:     o.next,
+		start:  start,
+		end:    end,
+		access: access,
+		fn:     fn,
+	}
+	o.observers = append(o.observers, ob)
+	o.rebuild()
+	return ob.id, nil
+}
+
+// Remove detaches the observer with the given id from Observe.
+func (o *Observed) Remove(id int) error {
+	for i, ob := range o.observers {
+		if ob.id == id {
+			o.observers = append(o.observers[:i:i], o.observers[i+1:]...)
+			o.rebuild()
+			return nil
+		}
+	}
+	return fmt.Errorf("no observer with id %d", id)
+}
+
+// rebuild recomputes the per page observer lists.
+func (o *Observed) rebuild() {
+	o.pages = [256][]*observer{}
+	for _, ob := range o.observers {
+		for pg := int(ob.start >> 8); pg <= int(ob.end>>8); pg++ {
+			o.pages[pg] = append(o.pages[pg], ob)
+		}
+	}
+}
+
+// fire calls the observers in obs which cover addr for the given access.
+func (o *Observed) fire(obs []*observer, addr uint16, val uint8, write bool) {
+	e := BusEvent{Addr: addr, Val: val, Write: write}
+	if o.clock != nil {
+		e.Cycle = o.clock()
+	}
+	for _, ob := range obs {
+		if addr < ob.start || addr > ob.end {
+			continue
+		}
+		if (write && ob.access == ACCESS_READ) || (!write && ob.access == ACCESS_WRITE) {
+			continue
+		}
+		ob.fn(e)
+	}
+}
+
+// Read implements the interface for Bank by reading from the wrapped Bank and then calling any observers.
+func (o *Observed) Read(addr uint16) uint8 {
+	val := o.bank.Read(addr)
+	if obs := o.pages[addr>>8]; obs != nil {
+		o.fire(obs, addr, val, false)
+	}
+	return val
+}
+
+// Write implements the interface for Bank by writing to the wrapped Bank and then calling any observers.
+func (o *Observed) Write(addr uint16, val uint8) {
+	o.bank.Write(addr, val)
+	if obs := o.pages[addr>>8]; obs != nil {
+		o.fire(obs, addr, val, true)
+	}
+}
+
+// Peek implements the interface for Debug without calling any observers.
+func (o *Observed) Peek(addr uint16) uint8 {
+	return Peek(o.bank, addr)
+}
+
+// Poke implements the interface for Debug without calling any observers.
+func (o *Observed) Poke(addr uint16, val uint8) {
+	Poke(o.bank, addr, val)
+}
+
+// PowerOn implements the interface for Bank and powers on the wrapped Bank.
+func (o *Observed) PowerOn() {
+	o.bank.PowerOn()
+}
+
+// Parent implements the interface for Bank and returns the parent of the wrapped Bank.
+func (o *Observed) Parent() Bank {
+	return o.bank.Parent()
+}
+
+// DatabusVal implements the interface for Bank and returns the databus value of the wrapped Bank.
+func (o *Observed) DatabusVal() uint8 {
+	return o.bank.DatabusVal()
+}
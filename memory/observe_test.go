@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestObserved(t *testing.T) {
+	parent := NewMap(nil)
+	ram := newRAM(t, 1<<16, parent)
+	clock := 0
+	o := NewObserved(ram, func() int { return clock })
+
+	var got []BusEvent
+	record := func(e BusEvent) {
+		got = append(got, e)
+	}
+	reads, err := o.Observe(0x1000, 0x10FF, ACCESS_READ, record)
+	if err != nil {
+		t.Fatalf("Can't observe: %v", err)
+	}
+	if _, err := o.Observe(0x10F0, 0x2000, ACCESS_WRITE, record); err != nil {
+		t.Fatalf("Can't observe: %v", err)
+	}
+	if _, err := o.Observe(0x3000, 0x3000, ACCESS_READ_WRITE, record); err != nil {
+		t.Fatalf("Can't observe: %v", err)
+	}
+
+	o.Write(0x1000, 0x11) // Not observed.
+	clock++
+	o.Read(0x1000)
+	clock++
+	o.Write(0x10F8, 0x22)
+	clock++
+	o.Read(0x10F8)
+	clock++
+	o.Write(0x3000, 0x33)
+	clock++
+	o.Read(0x3000)
+	o.Read(0x3001) // Not observed.
+	o.Peek(0x3000) // Never observed.
+	o.Poke(0x3000, 0x44)
+
+	want := []BusEvent{
+		{Cycle: 1, Addr: 0x1000, Val: 0x11},
+		{Cycle: 2, Addr: 0x10F8, Val: 0x22, Write: true},
+		{Cycle: 3, Addr: 0x10F8, Val: 0x22},
+		{Cycle: 4, Addr: 0x3000, Val: 0x33, Write: true},
+		{Cycle: 5, Addr: 0x3000, Val: 0x33},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Bad events.\nGot  %v\nWant %v", got, want)
+	}
+
+	if err := o.Remove(reads); err != nil {
+		t.Fatalf("Can't remove: %v", err)
+	}
+	got = nil
+	o.Read(0x1000)
+	if len(got) != 0 {
+		t.Errorf("Removed observer called: %v", got)
+	}
+	if err := o.Remove(reads); err == nil {
+		t.Errorf("Didn't get error removing twice")
+	}
+
+	// The databus chain is unchanged.
+	if o.Parent() != parent {
+		t.Errorf("Parent isn't passed through")
+	}
+	o.Write(0x4000, 0x55)
+	if got, want := o.DatabusVal(), uint8(0x55); got != want {
+		t.Errorf("Bad databus value. Got %.2X and want %.2X", got, want)
+	}
+
+	for _, test := range []struct {
+		start, end uint16
+		access     Access
+		fn         func(BusEvent)
+	}{
+		{0x2000, 0x1000, ACCESS_READ, record},
+		{0x1000, 0x2000, ACCESS_MAX, record},
+		{0x1000, 0x2000, ACCESS_READ, nil},
+	} {
+		if _, err := o.Observe(test.start, test.end, test.access, test.fn); err == nil {
+			t.Errorf("Didn't get error for %.4X-%.4X access %s", test.start, test.end, test.access)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package memory
+
+import (
+	"fmt"
+)
+
+// OpenBus is an enumeration of what data bits nothing drives read as.
+type OpenBus int
+
+const (
+	OPEN_BUS_FLOAT     OpenBus = iota // Undriven bits hold the last value seen on the databus. This is the default and matches most NMOS systems (including the 2600).
+	OPEN_BUS_PULL_LOW                 // Undriven bits are pulled low and read as 0.
+	OPEN_BUS_PULL_HIGH                // Undriven bits are pulled high and read as 1.
+	OPEN_BUS_MAX                      // End of open bus enumerations.
+)
+
+// String implements fmt.Stringer for OpenBus.
+func (o OpenBus) String() string {
+	switch o {
+	case OPEN_BUS_FLOAT:
+		return "FLOAT"
+	case OPEN_BUS_PULL_LOW:
+		return "PULL_LOW"
+	case OPEN_BUS_PULL_HIGH:
+		return "PULL_HIGH"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(o))
+}
+
+// Resolve returns what a read sees when a device drives val onto the bits set in driven.
+// last is the previous value on the databus which floating bits keep.
+func (o OpenBus) Resolve(val, driven, last uint8) uint8 {
+	switch o {
+	case OPEN_BUS_PULL_LOW:
+		return val & driven
+	case OPEN_BUS_PULL_HIGH:
+		return val&driven | ^driven
+	}
+	return val&driven | last&^driven
+}
+
+// Driver is implemented by Banks which don't drive every data bit on reads (i.e. the TIA only drives D7/D6).
+// A Bank which doesn't implement it is assumed to drive all 8 bits for every address.
+type Driver interface {
+	// Driven returns a mask of the data bits driven by a read of addr.
+	Driven(addr uint16) uint8
+}
+
+// Driven returns the data bits b drives on a read of addr. See Driver.
+func Driven(b Bank, addr uint16) uint8 {
+	if d, ok := b.(Driver); ok {
+		return d.Driven(addr)
+	}
+	return 0xFF
+}
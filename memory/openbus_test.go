@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"testing"
+)
+
+// partial is a Bank which only drives the top 2 data bits (like the TIA).
+type partial struct {
+	Bank
+}
+
+func (p *partial) Driven(addr uint16) uint8 {
+	return 0xC0
+}
+
+func TestOpenBus(t *testing.T) {
+	tests := []struct {
+		policy   OpenBus
+		unmapped uint8
+		partial  uint8
+	}{
+		{OPEN_BUS_FLOAT, 0x5A, 0x9A},
+		{OPEN_BUS_PULL_LOW, 0x00, 0x80},
+		{OPEN_BUS_PULL_HIGH, 0xFF, 0xBF},
+	}
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			m := NewMap(nil)
+			ram := newRAM(t, 256, m)
+			if err := m.Add(Region{Name: "ram", Base: 0x0000, Size: 0x100, Bank: ram}); err != nil {
+				t.Fatalf("Can't add region: %v", err)
+			}
+			if err := m.Add(Region{Name: "partial", Base: 0x0100, Size: 0x100, Mask: 0xFF, Bank: &partial{ram}}); err != nil {
+				t.Fatalf("Can't add region: %v", err)
+			}
+			if err := m.SetOpenBus(test.policy); err != nil {
+				t.Fatalf("Can't set policy: %v", err)
+			}
+			m.Write(0x0010, 0xA5)
+
+			m.Write(0x0000, 0x5A)
+			if got, want := m.Read(0x8000), test.unmapped; got != want {
+				t.Errorf("Bad unmapped read. Got %.2X and want %.2X", got, want)
+			}
+			if got, want := m.DatabusVal(), test.unmapped; got != want {
+				t.Errorf("Bad databus value. Got %.2X and want %.2X", got, want)
+			}
+
+			m.Write(0x0000, 0x5A)
+			if got, want := m.Peek(0x0110), test.partial; got != want {
+				t.Errorf("Bad partial peek. Got %.2X and want %.2X", got, want)
+			}
+			if got, want := m.Read(0x0110), test.partial; got != want {
+				t.Errorf("Bad partial read. Got %.2X and want %.2X", got, want)
+			}
+			// Fully driven banks are never changed.
+			if got, want := m.Read(0x0010), uint8(0xA5); got != want {
+				t.Errorf("Bad RAM read. Got %.2X and want %.2X", got, want)
+			}
+		})
+	}
+	if err := NewMap(nil).SetOpenBus(OPEN_BUS_MAX); err == nil {
+		t.Errorf("Didn't get error for invalid policy")
+	}
+}
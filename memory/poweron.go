@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// PowerOnMode is an enumeration of the ways state can be initialized at power on.
+type PowerOnMode int
+
+const (
+	POWER_ON_RANDOM  PowerOnMode = iota // Random values from the policy source. This is the default and matches real hardware.
+	POWER_ON_ZERO                       // All bits are set to zero.
+	POWER_ON_FF                         // All bits are set to one.
+	POWER_ON_PATTERN                    // The given pattern is repeated across all state.
+	POWER_ON_MAX                        // End of power on enumerations.
+)
+
+// PowerOnPolicy defines how a chip (or RAM) initializes its state at power on along with the
+// random source used for any other non-deterministic behavior it has. A nil policy (or the zero value)
+// gives random state from a source seeded with the current time.
+// Sharing a policy with an explicit Rand between chips in one system makes the whole system
+// reproducible as long as they are initialized in the same order.
+// A *rand.Rand isn't safe for concurrent use so a policy shouldn't be shared between
+// emulators running in different goroutines.
+type PowerOnPolicy struct {
+	// Mode determines the values used for power on state.
+	Mode PowerOnMode
+	// Rand is the source used for POWER_ON_RANDOM and any other randomness. If nil a source
+	// seeded from the current time will be used.
+	Rand *rand.Rand
+	// Pattern is repeated across power on state for POWER_ON_PATTERN. It must be non-empty for that mode.
+	Pattern []uint8
+}
+
+// NewSeededPowerOnPolicy returns a policy for random power on state which will always
+// generate the same values for a given seed.
+func NewSeededPowerOnPolicy(seed int64) *PowerOnPolicy {
+	return &PowerOnPolicy{
+		Mode: POWER_ON_RANDOM,
+		Rand: rand.New(rand.NewSource(seed)),
+	}
+}
+
+// ResolvePowerOnPolicy validates the given policy and returns a copy of it with all
+// defaults filled in (including the random source). Chips should call this once at init
+// time and use the result from then on.
+func ResolvePowerOnPolicy(p *PowerOnPolicy) (*PowerOnPolicy, error) {
+	r := &PowerOnPolicy{}
+	if p != nil {
+		*r = *p
+	}
+	if r.Mode < POWER_ON_RANDOM || r.Mode >= POWER_ON_MAX {
+		return nil, fmt.Errorf("invalid power on mode: %d", r.Mode)
+	}
+	if r.Mode == POWER_ON_PATTERN && len(r.Pattern) == 0 {
+		return nil, errors.New("power on pattern must be non-empty")
+	}
+	if r.Rand == nil {
+		r.Rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return r, nil
+}
+
+// Values returns a new stream of power on values for this policy. For POWER_ON_PATTERN
+// each stream starts at the beginning of the pattern.
+// The policy must have come from ResolvePowerOnPolicy.
+func (p *PowerOnPolicy) Values() *PowerOnValues {
+	return &PowerOnValues{p: p}
+}
+
+// Fill sets every byte in b based on the policy.
+// The policy must have come from ResolvePowerOnPolicy.
+func (p *PowerOnPolicy) Fill(b []uint8) {
+	v := p.Values()
+	for i := range b {
+		b[i] = v.Uint8()
+	}
+}
+
+// PowerOnValues hands out successive power on values for a PowerOnPolicy.
+type PowerOnValues struct {
+	p   *PowerOnPolicy
+	off int
+}
+
+// Uint8 returns the next byte of power on state.
+func (v *PowerOnValues) Uint8() uint8 {
+	switch v.p.Mode {
+	case POWER_ON_ZERO:
+		return 0x00
+	case POWER_ON_FF:
+		return 0xFF
+	case POWER_ON_PATTERN:
+		val := v.p.Pattern[v.off%len(v.p.Pattern)]
+		v.off++
+		return val
+	}
+	return uint8(v.p.Rand.Intn(256))
+}
+
+// Intn returns the next power on value in the range [0,n). For the fixed modes this
+// is the next byte modulo n.
+func (v *PowerOnValues) Intn(n int) int {
+	if v.p.Mode == POWER_ON_RANDOM {
+		return v.p.Rand.Intn(n)
+	}
+	return int(v.Uint8()) % n
+}
+
+// Bool returns the next power on value as a single bit.
+func (v *PowerOnValues) Bool() bool {
+	return v.Intn(2) == 1
+}
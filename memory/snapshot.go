@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"fmt"
+)
+
+// kPAGE_SIZE is the granularity PagedRAM tracks dirty state and shares data with snapshots.
+const kPAGE_SIZE = 256
+
+// PagedRAM is a R/W RAM Bank like New8BitRAMBank which stores its contents as 256 byte pages.
+// It tracks which pages have been written and can take copy-on-write snapshots. Taking a snapshot
+// or restoring one only copies page pointers and a page is copied the first time it's written
+// after that. This makes cloning state for search (or rewinding) cost proportional to what
+// actually changed rather than the size of the RAM.
+type PagedRAM struct {
+	pages      []*[kPAGE_SIZE]uint8
+	owned      []bool // If false the page is shared with a snapshot and must be copied before writing.
+	dirty      []bool
+	mask       uint16
+	parent     Bank
+	databusVal uint8
+	policy     *PowerOnPolicy
+}
+
+// Snapshot is an immutable copy of the contents of a PagedRAM. It shares unchanged pages with the
+// PagedRAM and any other snapshots so holding many of them is cheap.
+type Snapshot struct {
+	pages []*[kPAGE_SIZE]uint8
+	mask  uint16
+}
+
+// NewPagedRAMBank creates a PagedRAM of the given size. Size must be a power of 2 and not larger than 64k.
+// If this is smaller than 64k aliasing will occur on Read/Write. Sizes under 256 are a single partial page.
+// The policy determines the contents after PowerOn. If nil the RAM is randomized.
+func NewPagedRAMBank(size int, parent Bank, policy *PowerOnPolicy) (*PagedRAM, error) {
+	if size <= 0 || size&(size-1) != 0 {
+		return nil, fmt.Errorf("invalid size: %d must be a power of 2", size)
+	}
+	if size > 1<<16 {
+		return nil, fmt.Errorf("invalid size: %d is bigger than 64k", size)
+	}
+	pol, err := ResolvePowerOnPolicy(policy)
+	if err != nil {
+		return nil, err
+	}
+	n := (size + kPAGE_SIZE - 1) / kPAGE_SIZE
+	p := &PagedRAM{
+		pages:  make([]*[kPAGE_SIZE]uint8, n),
+		owned:  make([]bool, n),
+		dirty:  make([]bool, n),
+		mask:   uint16(size - 1),
+		parent: parent,
+		policy: pol,
+	}
+	for i := range p.pages {
+		p.pages[i] = new([kPAGE_SIZE]uint8)
+		p.owned[i] = true
+	}
+	return p, nil
+}
+
+// Read implements the interface for Bank. Address is clipped based on the size of the RAM.
+func (p *PagedRAM) Read(addr uint16) uint8 {
+	addr &= p.mask
+	p.databusVal = p.pages[addr>>8][addr&0xFF]
+	return p.databusVal
+}
+
+// Write implements the interface for Bank. Address is clipped based on the size of the RAM.
+func (p *PagedRAM) Write(addr uint16, val uint8) {
+	p.databusVal = val
+	p.Poke(addr, val)
+}
+
+// Peek implements the interface for Debug. Address is clipped based on the size of the RAM.
+func (p *PagedRAM) Peek(addr uint16) uint8 {
+	addr &= p.mask
+	return p.pages[addr>>8][addr&0xFF]
+}
+
+// Poke implements the interface for Debug. Address is clipped based on the size of the RAM.
+// It marks the page dirty the same as Write.
+func (p *PagedRAM) Poke(addr uint16, val uint8) {
+	addr &= p.mask
+	pg := addr >> 8
+	if !p.owned[pg] {
+		cp := *p.pages[pg]
+		p.pages[pg] = &cp
+		p.owned[pg] = true
+	}
+	p.pages[pg][addr&0xFF] = val
+	p.dirty[pg] = true
+}
+
+// PowerOn implements the interface for memory.Bank and fills the RAM based on the PowerOnPolicy.
+// Every page is marked dirty.
+func (p *PagedRAM) PowerOn() {
+	buf := make([]uint8, int(p.mask)+1)
+	p.policy.Fill(buf)
+	for i := range p.pages {
+		pg := new([kPAGE_SIZE]uint8)
+		copy(pg[:], buf[i*kPAGE_SIZE:])
+		p.pages[i] = pg
+		p.owned[i] = true
+		p.dirty[i] = true
+	}
+}
+
+// Parent implements the interface for returning a possible parent memory.Bank.
+func (p *PagedRAM) Parent() Bank {
+	return p.parent
+}
+
+// DatabusVal returns the most recent seen databus item.
+func (p *PagedRAM) DatabusVal() uint8 {
+	return p.databusVal
+}
+
+// Dirty returns the base address of each page written since the last Snapshot or Restore
+// (or since creation if neither has been called) in ascending order.
+func (p *PagedRAM) Dirty() []uint16 {
+	var ret []uint16
+	for i, d := range p.dirty {
+		if d {
+			ret = append(ret, uint16(i*kPAGE_SIZE))
+		}
+	}
+	return ret
+}
+
+// clean shares every page and clears the dirty state.
+func (p *PagedRAM) clean() {
+	for i := range p.pages {
+		p.owned[i] = false
+		p.dirty[i] = false
+	}
+}
+
+// Snapshot returns a copy of the current contents and clears the dirty state. Only page pointers
+// are copied. Pages are copied later as they're written.
+func (p *PagedRAM) Snapshot() *Snapshot {
+	s := &Snapshot{
+		pages: append([]*[kPAGE_SIZE]uint8(nil), p.pages...),
+		mask:  p.mask,
+	}
+	p.clean()
+	return s
+}
+
+// Restore sets the contents back to the given snapshot (which must have come from a PagedRAM of the same size)
+// and clears the dirty state. The snapshot is unchanged and can be restored again. DatabusVal is unchanged.
+func (p *PagedRAM) Restore(s *Snapshot) error {
+	if s == nil || s.mask != p.mask {
+		return fmt.Errorf("snapshot is not for a RAM of size %d", int(p.mask)+1)
+	}
+	copy(p.pages, s.pages)
+	p.clean()
+	return nil
+}
+
+// Peek returns the value at addr in the snapshot. Address is clipped based on the size of the RAM.
+func (s *Snapshot) Peek(addr uint16) uint8 {
+	addr &= s.mask
+	return s.pages[addr>>8][addr&0xFF]
+}
+
+// Diff returns the base address of each page whose contents differ between a and b in ascending order.
+// Pages which are still shared aren't compared so this is fast for snapshots taken from the same PagedRAM.
+// Both must be from RAMs of the same size.
+func Diff(a, b *Snapshot) ([]uint16, error) {
+	if a == nil || b == nil || a.mask != b.mask {
+		return nil, fmt.Errorf("snapshots must be non-nil and the same size")
+	}
+	var ret []uint16
+	for i := range a.pages {
+		if a.pages[i] != b.pages[i] && *a.pages[i] != *b.pages[i] {
+			ret = append(ret, uint16(i*kPAGE_SIZE))
+		}
+	}
+	return ret, nil
+}
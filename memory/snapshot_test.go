@@ -0,0 +1,120 @@
+package memory
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPagedRAM(t *testing.T) {
+	p, err := NewPagedRAMBank(1<<16, nil, &PowerOnPolicy{Mode: POWER_ON_ZERO})
+	if err != nil {
+		t.Fatalf("Can't create RAM: %v", err)
+	}
+	p.PowerOn()
+	if got := len(p.Dirty()); got != 256 {
+		t.Errorf("PowerOn didn't dirty every page. Got %d", got)
+	}
+
+	base := p.Snapshot()
+	if got := p.Dirty(); len(got) != 0 {
+		t.Errorf("Snapshot didn't clear dirty pages: %v", got)
+	}
+	p.Write(0x0010, 0x11)
+	p.Write(0x0020, 0x22)
+	p.Poke(0xFFFF, 0xFF)
+	if got, want := fmt.Sprint(p.Dirty()), fmt.Sprint([]uint16{0x0000, 0xFF00}); got != want {
+		t.Errorf("Bad dirty pages. Got %s and want %s", got, want)
+	}
+	if got, want := p.Read(0x0010), uint8(0x11); got != want {
+		t.Errorf("Bad read. Got %.2X and want %.2X", got, want)
+	}
+	if got, want := p.DatabusVal(), uint8(0x11); got != want {
+		t.Errorf("Bad databus value. Got %.2X and want %.2X", got, want)
+	}
+	// Writes after the snapshot don't change it.
+	if got := base.Peek(0x0010); got != 0x00 {
+		t.Errorf("Snapshot changed. Got %.2X", got)
+	}
+
+	next := p.Snapshot()
+	p.Write(0x1234, 0x34)
+	d, err := Diff(base, next)
+	if err != nil {
+		t.Fatalf("Can't diff: %v", err)
+	}
+	if got, want := fmt.Sprint(d), fmt.Sprint([]uint16{0x0000, 0xFF00}); got != want {
+		t.Errorf("Bad diff. Got %s and want %s", got, want)
+	}
+	// Writing a page back to its old contents isn't a difference.
+	p.Write(0x0010, 0x00)
+	p.Write(0x0020, 0x00)
+	d, err = Diff(base, p.Snapshot())
+	if err != nil {
+		t.Fatalf("Can't diff: %v", err)
+	}
+	if got, want := fmt.Sprint(d), fmt.Sprint([]uint16{0x1200, 0xFF00}); got != want {
+		t.Errorf("Bad diff. Got %s and want %s", got, want)
+	}
+
+	if err := p.Restore(next); err != nil {
+		t.Fatalf("Can't restore: %v", err)
+	}
+	for _, test := range []struct {
+		addr uint16
+		want uint8
+	}{
+		{0x0010, 0x11},
+		{0x0020, 0x22},
+		{0x1234, 0x00},
+		{0xFFFF, 0xFF},
+	} {
+		if got := p.Peek(test.addr); got != test.want {
+			t.Errorf("Bad value at %.4X after restore. Got %.2X and want %.2X", test.addr, got, test.want)
+		}
+	}
+	// Changing restored state doesn't change the snapshot so it can be restored again.
+	p.Write(0x0010, 0x99)
+	if got, want := next.Peek(0x0010), uint8(0x11); got != want {
+		t.Errorf("Snapshot changed after restore. Got %.2X and want %.2X", got, want)
+	}
+	if err := p.Restore(next); err != nil {
+		t.Fatalf("Can't restore: %v", err)
+	}
+	if got, want := p.Read(0x0010), uint8(0x11); got != want {
+		t.Errorf("Bad value after second restore. Got %.2X and want %.2X", got, want)
+	}
+
+	small, err := NewPagedRAMBank(128, nil, &PowerOnPolicy{Mode: POWER_ON_ZERO})
+	if err != nil {
+		t.Fatalf("Can't create RAM: %v", err)
+	}
+	small.Write(0x0180, 0x55)
+	if got, want := small.Read(0x0000), uint8(0x55); got != want {
+		t.Errorf("Small RAM didn't alias. Got %.2X and want %.2X", got, want)
+	}
+	if err := small.Restore(base); err == nil {
+		t.Errorf("Didn't get error restoring snapshot of a different size")
+	}
+	if _, err := Diff(base, small.Snapshot()); err == nil {
+		t.Errorf("Didn't get error diffing snapshots of different sizes")
+	}
+	for _, size := range []int{0, 3, 1 << 17} {
+		if _, err := NewPagedRAMBank(size, nil, nil); err == nil {
+			t.Errorf("Didn't get error for size %d", size)
+		}
+	}
+}
+
+func BenchmarkSnapshot(b *testing.B) {
+	p, err := NewPagedRAMBank(1<<16, nil, &PowerOnPolicy{Mode: POWER_ON_ZERO})
+	if err != nil {
+		b.Fatalf("Can't create RAM: %v", err)
+	}
+	for i := 0; i < b.N; i++ {
+		s := p.Snapshot()
+		p.Write(uint16(i), uint8(i))
+		if err := p.Restore(s); err != nil {
+			b.Fatalf("Can't restore: %v", err)
+		}
+	}
+}
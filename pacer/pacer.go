@@ -0,0 +1,210 @@
+// Package pacer implements real time pacing for an emulated system. The system reports how many of
+// its clock ticks have run and the pacer sleeps (in slice sized chunks such as a video frame) so the
+// emulated time matches wall time. Nothing here depends on a specific chip so one pacer can throttle
+// a whole machine by counting ticks of its master clock.
+package pacer
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Clock is the source of wall time for a Pacer. Tests can substitute a fake one.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Sleep pauses for at least d.
+	Sleep(d time.Duration)
+}
+
+// realClock implements Clock with the time package.
+type realClock struct{}
+
+// Now implements Clock.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep implements Clock.
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+const (
+	// kDEFAULT_SLICE is used if PacerDef.Slice isn't set. It's roughly one 60Hz video frame.
+	kDEFAULT_SLICE = 16 * time.Millisecond
+	// kDEFAULT_MAX_LAG is the number of slices the pacer can fall behind before giving up on catching up.
+	kDEFAULT_MAX_LAG = 4
+)
+
+// PacerDef defines a Pacer.
+type PacerDef struct {
+	// Hz is the frequency of the ticks being counted (i.e. the master clock of the system). Must be positive.
+	Hz float64
+	// Slice is the amount of emulated time to run between sleeps. Smaller values give smoother pacing
+	// at the cost of more sleeps (which are only accurate to the OS scheduler). If zero this defaults
+	// to 16ms (about one frame at 60Hz).
+	Slice time.Duration
+	// Speed is a multiplier for the target rate. i.e. 2 is fast forward at double speed and 0.5 is slow motion
+	// at half speed. math.Inf(1) runs without any pacing at all. If zero this defaults to 1 (real time).
+	Speed float64
+	// MaxLag is how far behind (in slices) the emulation can get before the pacer stops trying to catch up
+	// and restarts timing from the current point. This keeps a system which is too slow (or was paused
+	// by the host) from running flat out afterwards. If zero this defaults to 4.
+	MaxLag int
+	// Clock if non-nil is used as the source of wall time. Otherwise the time package is used.
+	Clock Clock
+}
+
+// Stats contains counters about a Pacer since Init (or the last ResetStats).
+type Stats struct {
+	Slices  int           // Number of slices completed.
+	Sleeps  int           // Number of slices where the pacer had to sleep (emulation was ahead).
+	Slept   time.Duration // Total time requested to sleep.
+	Late    int           // Number of slices where emulation was behind wall time.
+	MaxLate time.Duration // Largest amount emulation was behind wall time at the end of a slice.
+	Resyncs int           // Number of times emulation fell more than MaxLag slices behind and timing restarted.
+}
+
+// Pacer tracks emulated ticks against wall time.
+type Pacer struct {
+	hz         float64
+	slice      time.Duration
+	sliceTicks int
+	speed      float64
+	maxLag     time.Duration
+	clock      Clock
+	base       time.Time     // Wall time timing (re)started.
+	ticks      int64         // Ticks since base.
+	pending    int           // Ticks since the end of the last slice.
+	drift      time.Duration // Drift computed at the end of the last slice.
+	stats      Stats
+}
+
+// Init returns a Pacer setup as defined by def. Timing starts immediately.
+func Init(def *PacerDef) (*Pacer, error) {
+	if def.Hz <= 0 || math.IsNaN(def.Hz) || math.IsInf(def.Hz, 0) {
+		return nil, fmt.Errorf("Hz must be positive and finite. Got %f", def.Hz)
+	}
+	if def.Slice < 0 {
+		return nil, fmt.Errorf("Slice can't be negative. Got %s", def.Slice)
+	}
+	if def.MaxLag < 0 {
+		return nil, fmt.Errorf("MaxLag can't be negative. Got %d", def.MaxLag)
+	}
+	p := &Pacer{
+		hz:    def.Hz,
+		slice: def.Slice,
+		clock: def.Clock,
+	}
+	if p.slice == 0 {
+		p.slice = kDEFAULT_SLICE
+	}
+	if p.clock == nil {
+		p.clock = realClock{}
+	}
+	// Always run at least one tick per slice.
+	p.sliceTicks = int(math.Ceil(p.hz * p.slice.Seconds()))
+	if p.sliceTicks < 1 {
+		p.sliceTicks = 1
+	}
+	lag := def.MaxLag
+	if lag == 0 {
+		lag = kDEFAULT_MAX_LAG
+	}
+	p.maxLag = time.Duration(lag) * p.slice
+	speed := def.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	if err := p.SetSpeed(speed); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Tick accounts for n ticks of the system clock having run. Once a slice worth of ticks have run
+// this sleeps until wall time catches up (if needed).
+func (p *Pacer) Tick(n int) {
+	p.pending += n
+	if p.pending >= p.sliceTicks {
+		p.Sync()
+	}
+}
+
+// Sync ends the current slice early and sleeps until wall time catches up with the ticks run so far.
+// This is useful for pacing on natural boundaries such as the end of a video frame.
+func (p *Pacer) Sync() {
+	p.ticks += int64(p.pending)
+	p.pending = 0
+	p.stats.Slices++
+	if math.IsInf(p.speed, 1) {
+		p.drift = 0
+		return
+	}
+	target := p.base.Add(p.emulated(p.ticks))
+	now := p.clock.Now()
+	p.drift = now.Sub(target)
+	switch {
+	case p.drift < 0:
+		p.stats.Sleeps++
+		p.stats.Slept += -p.drift
+		p.clock.Sleep(-p.drift)
+	case p.drift > 0:
+		p.stats.Late++
+		if p.drift > p.stats.MaxLate {
+			p.stats.MaxLate = p.drift
+		}
+		if p.drift > p.maxLag {
+			p.stats.Resyncs++
+			p.restart()
+		}
+	}
+}
+
+// emulated returns the wall time ticks should take at the current speed.
+func (p *Pacer) emulated(ticks int64) time.Duration {
+	return time.Duration(float64(ticks) / (p.hz * p.speed) * float64(time.Second))
+}
+
+// restart begins timing again from the current time.
+func (p *Pacer) restart() {
+	p.base = p.clock.Now()
+	p.ticks = 0
+}
+
+// SetSpeed changes the speed multiplier (see PacerDef.Speed). Timing restarts from the current time
+// so the change takes effect immediately.
+func (p *Pacer) SetSpeed(speed float64) error {
+	if speed <= 0 || math.IsNaN(speed) {
+		return fmt.Errorf("speed must be positive. Got %f", speed)
+	}
+	p.speed = speed
+	p.restart()
+	p.pending = 0
+	p.drift = 0
+	return nil
+}
+
+// Speed returns the current speed multiplier.
+func (p *Pacer) Speed() float64 {
+	return p.speed
+}
+
+// Drift returns how far wall time was from emulated time at the end of the last slice.
+// Positive values mean emulation was behind (running slow) and negative values mean it was
+// ahead (and the pacer slept to make that up).
+func (p *Pacer) Drift() time.Duration {
+	return p.drift
+}
+
+// Stats returns the counters since Init (or the last ResetStats).
+func (p *Pacer) Stats() Stats {
+	return p.stats
+}
+
+// ResetStats zeros the counters returned by Stats.
+func (p *Pacer) ResetStats() {
+	p.stats = Stats{}
+}
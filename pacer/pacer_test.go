@@ -0,0 +1,131 @@
+package pacer
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// fakeClock implements Clock where time only moves on Sleep or when advanced by the test.
+type fakeClock struct {
+	now   time.Time
+	slept []time.Duration
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Sleep(d time.Duration) {
+	f.slept = append(f.slept, d)
+	f.now = f.now.Add(d)
+}
+
+func TestPacer(t *testing.T) {
+	clk := &fakeClock{now: time.Unix(0, 0)}
+	p, err := Init(&PacerDef{Hz: 1000, Slice: 10 * time.Millisecond, Clock: clk})
+	if err != nil {
+		t.Fatalf("Can't init: %v", err)
+	}
+
+	// Not a full slice yet so nothing happens.
+	p.Tick(9)
+	if len(clk.slept) != 0 {
+		t.Fatalf("Slept before a slice completed: %v", clk.slept)
+	}
+	// Emulation took no wall time so all 10ms is slept.
+	p.Tick(1)
+	if got, want := clk.slept, []time.Duration{10 * time.Millisecond}; len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("Bad sleep. Got %v and want %v", got, want)
+	}
+	if got, want := p.Drift(), -10*time.Millisecond; got != want {
+		t.Errorf("Bad drift. Got %s and want %s", got, want)
+	}
+
+	// Emulation taking longer than real time is late and doesn't sleep.
+	clk.now = clk.now.Add(30 * time.Millisecond)
+	p.Tick(10)
+	if got, want := p.Drift(), 20*time.Millisecond; got != want {
+		t.Errorf("Bad drift. Got %s and want %s", got, want)
+	}
+	if len(clk.slept) != 1 {
+		t.Errorf("Slept while behind: %v", clk.slept)
+	}
+	// Catching up sleeps less.
+	p.Tick(30)
+	if got, want := clk.slept[len(clk.slept)-1], 10*time.Millisecond; got != want {
+		t.Errorf("Bad catch up sleep. Got %s and want %s", got, want)
+	}
+
+	// Falling too far behind restarts timing instead of trying to catch up.
+	clk.now = clk.now.Add(time.Second)
+	p.Tick(10)
+	st := p.Stats()
+	if st.Resyncs != 1 || st.Late != 2 || st.Slices != 4 || st.Sleeps != 2 || st.Slept != 20*time.Millisecond {
+		t.Errorf("Bad stats: %+v", st)
+	}
+	p.Tick(10)
+	if got, want := clk.slept[len(clk.slept)-1], 10*time.Millisecond; got != want {
+		t.Errorf("Bad sleep after resync. Got %s and want %s", got, want)
+	}
+
+	// Sync ends a slice early.
+	p.ResetStats()
+	p.Tick(5)
+	p.Sync()
+	if got, want := clk.slept[len(clk.slept)-1], 5*time.Millisecond; got != want {
+		t.Errorf("Bad sleep for Sync. Got %s and want %s", got, want)
+	}
+
+	// Fast forward and slow motion.
+	for _, test := range []struct {
+		speed float64
+		want  time.Duration
+	}{
+		{2, 5 * time.Millisecond},
+		{0.5, 20 * time.Millisecond},
+	} {
+		if err := p.SetSpeed(test.speed); err != nil {
+			t.Fatalf("Can't set speed: %v", err)
+		}
+		p.Tick(10)
+		if got := clk.slept[len(clk.slept)-1]; got != test.want {
+			t.Errorf("Bad sleep at speed %f. Got %s and want %s", test.speed, got, test.want)
+		}
+	}
+	if err := p.SetSpeed(math.Inf(1)); err != nil {
+		t.Fatalf("Can't set speed: %v", err)
+	}
+	n := len(clk.slept)
+	p.Tick(1000)
+	if len(clk.slept) != n || p.Drift() != 0 {
+		t.Errorf("Slept while unthrottled: %v", clk.slept[n:])
+	}
+	if got, want := p.Speed(), math.Inf(1); got != want {
+		t.Errorf("Bad speed. Got %f and want %f", got, want)
+	}
+}
+
+func TestPacerErrors(t *testing.T) {
+	for _, def := range []*PacerDef{
+		{Hz: 0},
+		{Hz: -1},
+		{Hz: math.Inf(1)},
+		{Hz: 1, Slice: -1},
+		{Hz: 1, MaxLag: -1},
+		{Hz: 1, Speed: -1},
+		{Hz: 1, Speed: math.NaN()},
+	} {
+		if _, err := Init(def); err == nil {
+			t.Errorf("Didn't get error for %+v", def)
+		}
+	}
+	// A slow clock still runs at least one tick per slice.
+	p, err := Init(&PacerDef{Hz: 1, Clock: &fakeClock{}})
+	if err != nil {
+		t.Fatalf("Can't init: %v", err)
+	}
+	if got, want := p.sliceTicks, 1; got != want {
+		t.Errorf("Bad slice ticks. Got %d and want %d", got, want)
+	}
+}
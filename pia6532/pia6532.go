@@ -6,7 +6,6 @@ package pia6532
 import (
 	"errors"
 	"fmt"
-	"math/rand"
 
 	"github.com/jmchacon/6502/io"
 	"github.com/jmchacon/6502/memory"
@@ -15,6 +14,8 @@ import (
 var (
 	_ = memory.Bank(&Chip{})
 	_ = memory.Bank(&ioRam{})
+	_ = memory.Debug(&Chip{})
+	_ = memory.Debug(&ioRam{})
 )
 
 type edgeType int
@@ -97,6 +98,7 @@ type Chip struct {
 	debug                bool // If true Debug() emits output.
 	tickDone             bool // True if TickDone() was called before the current Tick() call
 	io                   *ioRam
+	policy               *memory.PowerOnPolicy
 	portAOutput          *out        // The output of port A.
 	shadowPortAOutput    uint8       // Shadow value for portAOutput to load on TickDone().
 	portBOutput          *out        // The output of port B.
@@ -142,12 +144,21 @@ type ChipDef struct {
 
 	// Parent if non-nil defines a containing memory.Bank this chip is contained within.
 	Parent memory.Bank
+
+	// PowerOnPolicy if non-nil determines the RAM contents at power on and the source for the
+	// initial timer value. Otherwise these are random.
+	PowerOnPolicy *memory.PowerOnPolicy
 }
 
 // Init returns a full initialized 6532. If the irq receiver passed in is
 // non-nil it will be used to raise interrupts based on timer/PA7 state.
 func Init(d *ChipDef) (*Chip, error) {
+	pol, err := memory.ResolvePowerOnPolicy(d.PowerOnPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid power on policy: %v", err)
+	}
 	p := &Chip{
+		policy:      pol,
 		portAOutput: &out{},
 		portBOutput: &out{},
 		portAInput:  d.PortA,
@@ -156,8 +167,7 @@ func Init(d *ChipDef) (*Chip, error) {
 		debug:       d.Debug,
 		parent:      d.Parent,
 	}
-	var err error
-	if p.ram, err = memory.New8BitRAMBank(0x80, p); err != nil {
+	if p.ram, err = memory.New8BitRAMBank(0x80, p, pol); err != nil {
 		return nil, fmt.Errorf("can't initialize RAM: %v", err)
 	}
 	p.io = &ioRam{p, 0}
@@ -187,7 +197,7 @@ func (p *Chip) Reset() {
 	p.shadowPortBOutput = 0x00
 	p.portBDDR = 0x00
 	p.shadowPortBDDR = 0x00
-	p.timer = uint8(rand.Intn(256))
+	p.timer = p.policy.Values().Uint8()
 	p.wroteTimer = false
 	p.shadowTimer = p.timer
 	// Evidently the real hardware starts up in this mode
@@ -226,7 +236,7 @@ func (p *Chip) IO() memory.Bank {
 // Read implements the interface for memory.Bank and gives access to the RAM
 // portion of the PIA. Use IO() to get an inteface to the I/O section.
 func (p *Chip) Read(addr uint16) uint8 {
-	val := p.read(addr, true)
+	val := p.read(addr, true, false)
 	p.databusVal = val
 	return val
 }
@@ -238,6 +248,18 @@ func (p *Chip) Write(addr uint16, val uint8) {
 	p.write(addr, true, val)
 }
 
+// Peek implements the interface for memory.Debug and gives access to the RAM
+// portion of the PIA without side effects.
+func (p *Chip) Peek(addr uint16) uint8 {
+	return p.read(addr, true, true)
+}
+
+// Poke implements the interface for memory.Debug and gives access to the RAM
+// portion of the PIA without side effects.
+func (p *Chip) Poke(addr uint16, val uint8) {
+	memory.Poke(p.ram, addr, val)
+}
+
 // Parent implements the interface for returning a possible parent memory.Bank.
 func (p *Chip) Parent() memory.Bank {
 	return p.parent
@@ -251,7 +273,7 @@ func (p *Chip) DatabusVal() uint8 {
 // Read implements the interface for memory.Bank and gives access to the I/O
 // portion of the PIA.
 func (i *ioRam) Read(addr uint16) uint8 {
-	val := i.p.read(addr, false)
+	val := i.p.read(addr, false, false)
 	i.databusVal = val
 	return val
 }
@@ -263,6 +285,36 @@ func (i *ioRam) Write(addr uint16, val uint8) {
 	i.p.write(addr, false, val)
 }
 
+// Peek implements the interface for memory.Debug and returns the same value Read would
+// for the I/O portion of the PIA without clearing any interrupt flags.
+func (i *ioRam) Peek(addr uint16) uint8 {
+	return i.p.read(addr, false, true)
+}
+
+// Poke implements the interface for memory.Debug for the I/O portion of the PIA. This sets
+// the register a Read of addr would return for the port outputs, DDRs and the timer.
+// The interrupt flags are left unchanged.
+func (i *ioRam) Poke(addr uint16, val uint8) {
+	p := i.p
+	switch addr & kMASK_RW {
+	case kREAD_PORT_A, 0x08, 0x10, 0x18:
+		p.portAOutput.data = (val & p.portADDR) | ^p.portADDR
+		p.shadowPortAOutput = p.portAOutput.data
+	case kREAD_PORT_A_DDR, 0x09, 0x11, 0x19:
+		p.portADDR = val
+		p.shadowPortADDR = val
+	case kREAD_PORT_B, 0x0A, 0x12, 0x1A:
+		p.portBOutput.data = (val & p.portBDDR) | ^p.portBDDR
+		p.shadowPortBOutput = p.portBOutput.data
+	case kREAD_PORT_B_DDR, 0x0B, 0x13, 0x1B:
+		p.portBDDR = val
+		p.shadowPortBDDR = val
+	case kREAD_TIMER_NO_INT, 0x06, 0x14, 0x16, kREAD_TIMER_INT, 0x0E, 0x1C, 0x1E:
+		p.timer = val
+		p.shadowTimer = val
+	}
+}
+
 func (i *ioRam) PowerOn() {}
 
 // Parent implements the interface for returning a possible parent memory.Bank.
@@ -277,13 +329,16 @@ func (i *ioRam) DatabusVal() uint8 {
 
 // read returns memory at the given address which is either the RAM (if ram is true) or
 // internal registers. For RAM the address is masked to 7 bits and internal addresses
-// are masked to 5 bits.
+// are masked to 5 bits. If peek is true nothing is changed (i.e. interrupt flags aren't cleared).
 // NOTE: This isn't tied to the clock so it's possible to read/write more than one
 //       item per cycle. Integration is expected to coordinate clocks as needed to control this
 //       since it's assumed real reads are happening on clocked CPU Tick()'s.
-func (p *Chip) read(addr uint16, ram bool) uint8 {
+func (p *Chip) read(addr uint16, ram, peek bool) uint8 {
 	if ram {
 		// Assumption is memory interface impl correctly deals with any aliasing.
+		if peek {
+			return memory.Peek(p.ram, addr)
+		}
 		return p.ram.Read(addr)
 	}
 	// Strip to 5 bits for internal regs.
@@ -313,6 +368,9 @@ func (p *Chip) read(addr uint16, ram bool) uint8 {
 		ret = p.portBDDR
 	case kREAD_TIMER_NO_INT, 0x06, 0x14, 0x16:
 		ret = p.timer
+		if peek {
+			break
+		}
 		p.shadowInterrupt = false
 		p.shadowInterruptOn = (p.interruptOn &^ kMASK_INT)
 		p.wroteInterrupt = true
@@ -323,12 +381,18 @@ func (p *Chip) read(addr uint16, ram bool) uint8 {
 		if p.edgeInterrupt {
 			ret |= kMASK_EDGE
 		}
+		if peek {
+			break
+		}
 		p.shadowEdgeInterrupt = false
 		p.shadowInterrupt = p.interrupt
 		p.shadowInterruptOn = (p.interruptOn &^ kMASK_EDGE)
 		p.wroteInterrupt = true
 	case kREAD_TIMER_INT, 0x0E, 0x1C, 0x1E:
 		ret = p.timer
+		if peek {
+			break
+		}
 		p.shadowInterrupt = true
 		p.shadowInterruptOn = p.interruptOn
 		p.wroteInterrupt = true
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/davecgh/go-spew/spew"
+	"github.com/jmchacon/6502/memory"
 )
 
 func TestRam(t *testing.T) {
@@ -22,6 +23,46 @@ func TestRam(t *testing.T) {
 	}
 }
 
+func TestPowerOnPolicy(t *testing.T) {
+	p1, err := Init(&ChipDef{PowerOnPolicy: memory.NewSeededPowerOnPolicy(1)})
+	if err != nil {
+		t.Fatalf("Can't init: %v", err)
+	}
+	p2, err := Init(&ChipDef{PowerOnPolicy: memory.NewSeededPowerOnPolicy(1)})
+	if err != nil {
+		t.Fatalf("Can't init: %v", err)
+	}
+	if got, want := p2.timer, p1.timer; got != want {
+		t.Errorf("Timers differ with same seed. Got %.2X and want %.2X", got, want)
+	}
+	for i := uint16(0x0000); i < 0x80; i++ {
+		if got, want := p2.Read(i), p1.Read(i); got != want {
+			t.Errorf("RAM differs at %.2X with same seed. Got %.2X and want %.2X", i, got, want)
+		}
+	}
+
+	p, err := Init(&ChipDef{PowerOnPolicy: &memory.PowerOnPolicy{Mode: memory.POWER_ON_PATTERN, Pattern: []uint8{0x12, 0x34}}})
+	if err != nil {
+		t.Fatalf("Can't init: %v", err)
+	}
+	for i := uint16(0x0000); i < 0x80; i++ {
+		want := uint8(0x12)
+		if i%2 == 1 {
+			want = 0x34
+		}
+		if got := p.Read(i); got != want {
+			t.Errorf("Bad RAM at %.2X for pattern. Got %.2X and want %.2X", i, got, want)
+		}
+	}
+	if got, want := p.timer, uint8(0x12); got != want {
+		t.Errorf("Bad timer for pattern. Got %.2X and want %.2X", got, want)
+	}
+
+	if _, err := Init(&ChipDef{PowerOnPolicy: &memory.PowerOnPolicy{Mode: memory.POWER_ON_PATTERN}}); err == nil {
+		t.Error("Didn't get error for empty pattern")
+	}
+}
+
 func TestErrors(t *testing.T) {
 	p, err := Init(&ChipDef{})
 	if err != nil {
@@ -261,7 +302,7 @@ func TestInterruptState(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 			portA := &in{}
-			p, err := Init(&ChipDef{portA, nil, false, nil})
+			p, err := Init(&ChipDef{PortA: portA})
 			if err != nil {
 				t.Fatalf("Can't init: %v", err)
 			}
@@ -461,7 +502,7 @@ func TestInterruptState(t *testing.T) {
 func TestPorts(t *testing.T) {
 	portA := &in{0xA5}
 	portB := &in{0xAA}
-	p, err := Init(&ChipDef{portA, portB, false, nil})
+	p, err := Init(&ChipDef{PortA: portA, PortB: portB})
 	if err != nil {
 		t.Fatalf("Can't init: %v", err)
 	}
@@ -555,3 +596,32 @@ func TestPorts(t *testing.T) {
 	}
 	p.TickDone()
 }
+
+func TestPeekPoke(t *testing.T) {
+	p, err := Init(&ChipDef{})
+	if err != nil {
+		t.Fatalf("Can't init: %v", err)
+	}
+	p.Poke(0x0010, 0xAB)
+	if got, want := p.Peek(0x0090), uint8(0xAB); got != want {
+		t.Errorf("Bad RAM peek. Got %.2X and want %.2X", got, want)
+	}
+	d := p.DatabusVal()
+	for _, reg := range []uint16{kREAD_TIMER_NO_INT, kREAD_INT, kREAD_TIMER_INT} {
+		memory.Peek(p.IO(), reg)
+		if p.wroteInterrupt {
+			t.Errorf("Peek of %.2X changed interrupt state", reg)
+		}
+	}
+	if got := p.DatabusVal(); got != d {
+		t.Errorf("Peek changed databus from %.2X to %.2X", d, got)
+	}
+	memory.Poke(p.IO(), kREAD_TIMER_NO_INT, 0x42)
+	if got, want := memory.Peek(p.IO(), kREAD_TIMER_NO_INT), uint8(0x42); got != want {
+		t.Errorf("Bad timer after poke. Got %.2X and want %.2X", got, want)
+	}
+	p.IO().Read(kREAD_INT)
+	if !p.wroteInterrupt {
+		t.Errorf("Read of interrupt register didn't change interrupt state")
+	}
+}
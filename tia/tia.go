@@ -7,15 +7,17 @@ import (
 	"image/color"
 	"image/draw"
 	"log"
-	"math/rand"
-	"time"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/jmchacon/6502/io"
 	"github.com/jmchacon/6502/memory"
 )
 
-var _ = memory.Bank(&Chip{})
+var (
+	_ = memory.Bank(&Chip{})
+	_ = memory.Debug(&Chip{})
+	_ = memory.Driver(&Chip{})
+)
 
 const (
 	// Convention for constants:
@@ -55,6 +57,11 @@ const (
 	// All implementations do the same VSYNC lines
 	kVSYNCLines = 3
 
+	// The TIA color clock rate (in Hz) for each mode. The CPU runs at 1/3 of this.
+	NTSCClock  = 3579545
+	PALClock   = 3546894
+	SECAMClock = 3546894
+
 	// Always 68 hblank clocks
 	kHblank = 68
 
@@ -465,6 +472,9 @@ type ChipDef struct {
 
 	// Parent if non-nil defines a containing memory.Bank this chip is contained within.
 	Parent memory.Bank
+
+	// PowerOnPolicy if non-nil determines the initial register/object state. Otherwise it's random.
+	PowerOnPolicy *memory.PowerOnPolicy
 }
 
 // Init returns a full initialized Chip.
@@ -495,10 +505,14 @@ func Init(def *ChipDef) (*Chip, error) {
 	}
 
 	// The player/missile/ball drawing only happens during visible pixels. But..the start locations
-	// aren't defined so we randomize them somewhere on the line. Makes sure that users (and tests)
+	// aren't defined so we randomize them (by default) somewhere on the line. Makes sure that users (and tests)
 	// don't assume left edge or anything. We set the shadow registers since they copy in on the first
 	// clock anyways.
-	rand.Seed(time.Now().UnixNano())
+	pol, err := memory.ResolvePowerOnPolicy(def.PowerOnPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid power on policy: %v", err)
+	}
+	v := pol.Values()
 	t := &Chip{
 		mode:           def.Mode,
 		debug:          def.Debug,
@@ -507,25 +521,25 @@ func Init(def *ChipDef) (*Chip, error) {
 		tickDone:       true,
 		h:              h,
 		w:              w,
-		collision:      [8]uint8{uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256)), uint8(rand.Intn(256))},
-		vsync:          rand.Float32() > 0.5,
-		vblank:         rand.Float32() > 0.5,
+		collision:      [8]uint8{v.Uint8(), v.Uint8(), v.Uint8(), v.Uint8(), v.Uint8(), v.Uint8(), v.Uint8(), v.Uint8()},
+		vsync:          v.Bool(),
+		vblank:         v.Bool(),
 		inputPorts:     [6]io.PortIn1{def.Port0, def.Port1, def.Port2, def.Port3, def.Port4, def.Port5},
 		picture:        def.Image,
 		scaleFactor:    scale,
 		frameDone:      def.FrameDone,
-		playerClock:    [2]int{rand.Intn(kVisible), rand.Intn(kVisible)},
-		playerCntWidth: [2]playerCntWidth{playerCntWidth(rand.Intn(int(kPlayerCntMax))), playerCntWidth(rand.Intn(int(kPlayerCntMax)))},
-		reflectPlayers: [2]bool{rand.Float32() > 0.5, rand.Float32() > 0.5},
-		missileClock:   [2]int{rand.Intn(kVisible), rand.Intn(kVisible)},
-		missileWidth:   [2]int{1 << uint(rand.Intn(3)), 1 << uint(rand.Intn(3))},
-		ballClock:      rand.Intn(kVisible),
-		ballWidth:      1 << uint(rand.Intn(8)),
+		playerClock:    [2]int{v.Intn(kVisible), v.Intn(kVisible)},
+		playerCntWidth: [2]playerCntWidth{playerCntWidth(v.Intn(int(kPlayerCntMax))), playerCntWidth(v.Intn(int(kPlayerCntMax)))},
+		reflectPlayers: [2]bool{v.Bool(), v.Bool()},
+		missileClock:   [2]int{v.Intn(kVisible), v.Intn(kVisible)},
+		missileWidth:   [2]int{1 << uint(v.Intn(3)), 1 << uint(v.Intn(3))},
+		ballClock:      v.Intn(kVisible),
+		ballWidth:      1 << uint(v.Intn(8)),
 		colors: [4]color.RGBA{
-			decodeColor(def.Mode, uint8(rand.Intn(256))),
-			decodeColor(def.Mode, uint8(rand.Intn(256))),
-			decodeColor(def.Mode, uint8(rand.Intn(256))),
-			decodeColor(def.Mode, uint8(rand.Intn(256))),
+			decodeColor(def.Mode, v.Uint8()),
+			decodeColor(def.Mode, v.Uint8()),
+			decodeColor(def.Mode, v.Uint8()),
+			decodeColor(def.Mode, v.Uint8()),
 		},
 		parent: def.Parent,
 	}
@@ -665,6 +679,14 @@ const (
 //       item per cycle. Integration is expected to coordinate clocks as needed to control this
 //       since it's assumed real reads are happening on clocked CPU Tick()'s.
 func (t *Chip) Read(addr uint16) uint8 {
+	ret := t.Peek(addr)
+	t.databusVal = ret
+	return ret
+}
+
+// Peek implements the interface for memory.Debug and returns the same value Read would
+// without updating the databus.
+func (t *Chip) Peek(addr uint16) uint8 {
 	// Strip to 4 bits for internal regs.
 	addr &= kMASK_READ
 	var ret uint8
@@ -709,11 +731,19 @@ func (t *Chip) Read(addr uint16) uint8 {
 		ret = 0xFF
 	}
 	// Apply read mask before returning.
-	ret &= kMASK_READ_OUTPUT
-	t.databusVal = ret
-	return ret
+	return ret & kMASK_READ_OUTPUT
 }
 
+// Driven implements the interface for memory.Driver. Only D7/D6 are driven on reads
+// so the rest come from the open bus.
+func (t *Chip) Driven(addr uint16) uint8 {
+	return kMASK_READ_OUTPUT
+}
+
+// Poke implements the interface for memory.Debug. Every TIA register write has side effects
+// (and the read registers can't be written) so this does nothing.
+func (t *Chip) Poke(addr uint16, val uint8) {}
+
 // Write stores the value at the given address. The address is masked to 6 bits.
 // NOTE: This isn't tied to the clock so it's possible to read/write more than one
 //       item per cycle. Integration is expected to coordinate clocks as needed to control this
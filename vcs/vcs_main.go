@@ -8,14 +8,19 @@ import (
 	"image/draw"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	_ "net/http/pprof"
+	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/jmchacon/6502/atari2600"
+	"github.com/jmchacon/6502/cpu"
 	"github.com/jmchacon/6502/io"
+	"github.com/jmchacon/6502/memory"
+	"github.com/jmchacon/6502/pacer"
 	"github.com/jmchacon/6502/tia"
 	"github.com/veandco/go-sdl2/sdl"
 )
@@ -28,8 +33,30 @@ var (
 	advance     = flag.Bool("advance", true, "If true the game select will be toggled to advance the play screen")
 	advanceRate = flag.Int("advance_rate", 60, "After how many frames to toggle the game select")
 	mode        = flag.String("mode", "NTSC", "Either NTSC, PAL or SECAM (case insensitive) to determine video mode")
+	seed        = flag.Int64("seed", 0, "If non-zero seeds the random power on state so runs are reproducible")
+	profile     = flag.String("profile", "", "If set writes a pprof profile of the cart code (not the emulator) to this path once -frames have run")
+	frames      = flag.Int("frames", 0, "If non-zero exit after running this many frames")
+	stackCheck  = flag.Bool("stack_check", false, "If true log any stack misuse (mismatched RTS/RTI, overwritten return addresses, wraps) by the cart along with a backtrace")
+	unstable    = flag.String("unstable", "allow", "What to do when the cart runs an unstable undocumented opcode (XAA, OAL, AHX, SHX, SHY, TAS). One of allow, log or trap")
+	undoc       = flag.String("undocumented", "allow", "What to do when the cart runs a stable undocumented opcode (LAX, SAX, DCP, etc). One of allow, log or trap")
+	speed       = flag.Float64("speed", 1, "Speed multiplier relative to real hardware (i.e. 2 is fast forward and 0.5 slow motion). 0 runs as fast as possible")
+	openBus     = flag.String("open_bus", "float", "What the data bits the TIA doesn't drive read as. One of float (the last value on the bus as on real hardware), low or high")
 )
 
+// openBusModes maps the -open_bus flag values to policies.
+var openBusModes = map[string]memory.OpenBus{
+	"float": memory.OPEN_BUS_FLOAT,
+	"low":   memory.OPEN_BUS_PULL_LOW,
+	"high":  memory.OPEN_BUS_PULL_HIGH,
+}
+
+// opActions maps the -unstable and -undocumented flag values to actions.
+var opActions = map[string]cpu.OpAction{
+	"allow": cpu.OPACTION_ALLOW,
+	"log":   cpu.OPACTION_LOG,
+	"trap":  cpu.OPACTION_TRAP,
+}
+
 type swtch struct {
 	b bool
 }
@@ -100,23 +127,44 @@ func main() {
 	vidMode := strings.ToUpper(*mode)
 	var tiaMode tia.TIAMode
 	var h, w int
+	var hz float64
 	switch vidMode {
 	case "NTSC":
 		tiaMode = tia.TIA_MODE_NTSC
 		h = tia.NTSCHeight
 		w = tia.NTSCWidth
+		hz = tia.NTSCClock
 	case "PAL":
 		tiaMode = tia.TIA_MODE_PAL
 		h = tia.PALHeight
 		w = tia.PALWidth
+		hz = tia.PALClock
 	case "SECAM":
 		tiaMode = tia.TIA_MODE_SECAM
 		h = tia.SECAMHeight
 		w = tia.SECAMWidth
+		hz = tia.SECAMClock
 	default:
 		log.Fatalf("Invalid video mode %q - Must be NTSC, PAL or SECAM\n", vidMode)
 	}
 
+	if *speed < 0 {
+		log.Fatalf("Invalid speed %f - Must be >= 0", *speed)
+	}
+	s := *speed
+	if s == 0 {
+		s = math.Inf(1)
+	}
+	// Pace the whole console off the TIA clock. Each slice is one frame.
+	pace, err := pacer.Init(&pacer.PacerDef{
+		Hz:    hz,
+		Slice: time.Duration(float64(h*w) / hz * float64(time.Second)),
+		Speed: s,
+	})
+	if err != nil {
+		log.Fatalf("Can't init pacer: %v", err)
+	}
+
 	go func() {
 		log.Println(http.ListenAndServe(fmt.Sprintf("localhost:%d", *port), nil))
 	}()
@@ -158,6 +206,32 @@ func main() {
 			sdl.Quit()
 		}()
 
+		var pol *memory.PowerOnPolicy
+		if *seed != 0 {
+			pol = memory.NewSeededPowerOnPolicy(*seed)
+		}
+		var prof *cpu.Profiler
+		if *profile != "" {
+			prof = cpu.NewProfiler()
+		}
+		var calls *cpu.CallStack
+		if *stackCheck {
+			calls = cpu.NewCallStack(func(i cpu.StackIssue) {
+				log.Printf("Stack issue: %s\n%s", i, cpu.FormatBacktrace(i.Trace, nil))
+			})
+		}
+		actions := make(map[cpu.OpClass]cpu.OpAction)
+		for cl, f := range map[cpu.OpClass]string{cpu.OPCLASS_UNSTABLE: *unstable, cpu.OPCLASS_UNDOCUMENTED: *undoc} {
+			act, ok := opActions[strings.ToLower(f)]
+			if !ok {
+				log.Fatalf("Invalid action %q for %s opcodes. Must be allow, log or trap", f, cl)
+			}
+			actions[cl] = act
+		}
+		ob, ok := openBusModes[strings.ToLower(*openBus)]
+		if !ok {
+			log.Fatalf("Invalid open bus mode %q. Must be float, low or high", *openBus)
+		}
 		now := time.Now()
 		var tot, cnt time.Duration
 		a, err := atari2600.Init(&atari2600.VCSDef{
@@ -192,21 +266,39 @@ func main() {
 					if *advance && int(cnt)%*advanceRate == 0 {
 						game.b = !game.b
 					}
-					fmt.Printf("Frame took %s average %s\n", df, tot/cnt)
+					fmt.Printf("Frame took %s average %s drift %s\n", df, tot/cnt, pace.Drift())
 					window.UpdateSurface()
 					now = time.Now()
 				})
 			},
-			Rom:   []uint8(rom),
-			Debug: *debug,
+			Rom:           []uint8(rom),
+			PowerOnPolicy: pol,
+			Debug:         *debug,
+			Profiler:      prof,
+			CallStack:     calls,
+			OpcodeActions: actions,
+			OpenBus:       ob,
 		})
 		if err != nil {
 			log.Fatalf("Can't init VCS: %v", err)
 		}
-		for {
+		for *frames == 0 || int(cnt) < *frames {
 			if err := a.Tick(); err != nil {
 				log.Fatalf("Tick error: %v", err)
 			}
+			pace.Tick(1)
+		}
+		if prof != nil {
+			f, err := os.Create(*profile)
+			if err != nil {
+				log.Fatalf("Can't create profile: %v", err)
+			}
+			if err := prof.WritePprof(f, nil); err != nil {
+				log.Fatalf("Can't write profile: %v", err)
+			}
+			if err := f.Close(); err != nil {
+				log.Fatalf("Can't write profile: %v", err)
+			}
 		}
 	})
 }